@@ -0,0 +1,93 @@
+package httpcache
+
+import "net/http"
+
+// AuthorizationPolicy decides how the Authorization request header affects the
+// cache's bookkeeping. Whatever the policy, the header is forwarded upstream
+// unchanged.
+type AuthorizationPolicy int
+
+const (
+	// AuthorizationRFC follows RFC 9111 Section 3.5: in a shared cache
+	// (IsPublicCache), responses to authenticated requests are only stored when
+	// marked public, must-revalidate or s-maxage. Authorization is part of the
+	// cache key only if listed in CacheKeyHeaders or VaryByHeaders, and
+	// authenticated entries are tagged as sensitive (see IsSensitiveEntry).
+	AuthorizationRFC AuthorizationPolicy = iota
+
+	// AuthorizationPerCredential always includes the Authorization value,
+	// hashed, in the cache key, so every credential gets its own entries. Since
+	// entries are never shared between credentials, a shared cache stores
+	// responses to authenticated requests without requiring public,
+	// must-revalidate or s-maxage. Entries are still tagged as sensitive.
+	AuthorizationPerCredential
+
+	// AuthorizationIgnore makes the cache disregard Authorization, for origins
+	// whose responses don't depend on the credential, e.g. a gateway token
+	// checked before identical content is served: the header is never part of
+	// the cache key, even if listed in CacheKeyHeaders or VaryByHeaders, doesn't
+	// restrict storage in a shared cache and doesn't tag entries as sensitive.
+	// Responses to one client are then served to every client.
+	AuthorizationIgnore
+)
+
+// String returns the name of the policy
+func (p AuthorizationPolicy) String() string {
+	switch p {
+	case AuthorizationRFC:
+		return "rfc"
+	case AuthorizationPerCredential:
+		return "per-credential"
+	case AuthorizationIgnore:
+		return "ignore"
+	default:
+		return "unknown"
+	}
+}
+
+// authorizationKeyHeaders adds Authorization to the key headers for
+// AuthorizationPerCredential, and removes it for AuthorizationIgnore
+func (t *Transport) authorizationKeyHeaders(headers []string) []string {
+	filtered := make([]string, 0, len(headers)+1)
+	for _, header := range headers {
+		if http.CanonicalHeaderKey(header) != "Authorization" {
+			filtered = append(filtered, header)
+		}
+	}
+	if t.AuthorizationPolicy == AuthorizationPerCredential {
+		filtered = append(filtered, "Authorization")
+	}
+	return filtered
+}
+
+// storageRequest returns the request used to decide whether the response to req
+// may be stored: req itself, or a copy without Authorization when the policy
+// lifts the shared-cache restriction on authenticated requests
+func (t *Transport) storageRequest(req *http.Request) *http.Request {
+	if t.AuthorizationPolicy == AuthorizationRFC {
+		return req
+	}
+	return withoutAuthorization(req)
+}
+
+// sensitivityRequest returns the request used to decide whether the response
+// to req is tagged as sensitive: req itself, or a copy without Authorization
+// for AuthorizationIgnore
+func (t *Transport) sensitivityRequest(req *http.Request) *http.Request {
+	if t.AuthorizationPolicy != AuthorizationIgnore {
+		return req
+	}
+	return withoutAuthorization(req)
+}
+
+// withoutAuthorization returns a shallow copy of req without the Authorization
+// header, or req itself if it has none
+func withoutAuthorization(req *http.Request) *http.Request {
+	if req.Header.Get("Authorization") == "" {
+		return req
+	}
+	stripped := *req
+	stripped.Header = req.Header.Clone()
+	stripped.Header.Del("Authorization")
+	return &stripped
+}
@@ -133,6 +133,12 @@ func (c *cache) cacheKey(key string) string {
 	return c.keyPrefix + hex.EncodeToString(hash[:])
 }
 
+// Name returns "blob", the backend name reported with
+// httpcache.Transport.ServeDiagnostics.
+func (c *cache) Name() string {
+	return "blob"
+}
+
 // Get returns the response corresponding to key if present.
 func (c *cache) Get(key string) ([]byte, bool) {
 	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
@@ -0,0 +1,90 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// defaultMaxPooledBufferSize is the capacity above which NewBufferPool drops
+// buffers instead of keeping them, when no limit is given
+const defaultMaxPooledBufferSize = 64 * 1024
+
+// BufferPool provides reusable buffers to the Transport for capturing response
+// bodies and serializing the responses it stores. Get returns an empty buffer;
+// Put takes back a buffer the caller no longer references. Implementations must
+// be safe for concurrent use.
+type BufferPool interface {
+	Get() *bytes.Buffer
+	Put(buf *bytes.Buffer)
+}
+
+// syncBufferPool is a BufferPool backed by a sync.Pool
+type syncBufferPool struct {
+	pool    sync.Pool
+	maxSize int
+}
+
+// NewBufferPool returns a BufferPool backed by a sync.Pool. Buffers grown beyond
+// maxSize bytes, e.g. by a large response, are dropped rather than pooled, so
+// the pool doesn't pin memory; if maxSize is not positive, 64 KiB is used.
+func NewBufferPool(maxSize int) BufferPool {
+	if maxSize <= 0 {
+		maxSize = defaultMaxPooledBufferSize
+	}
+	return &syncBufferPool{
+		pool:    sync.Pool{New: func() any { return new(bytes.Buffer) }},
+		maxSize: maxSize,
+	}
+}
+
+// Get returns an empty buffer from the pool
+func (p *syncBufferPool) Get() *bytes.Buffer {
+	return p.pool.Get().(*bytes.Buffer)
+}
+
+// Put resets buf and returns it to the pool, unless it grew beyond the limit
+func (p *syncBufferPool) Put(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > p.maxSize {
+		return
+	}
+	buf.Reset()
+	p.pool.Put(buf)
+}
+
+// dumpPooledResponse serializes resp like dumpStoredResponse, using buffers from
+// pool for the body and the serialized form. The returned bytes are a copy the
+// Cache may keep, so no pooled memory ever reaches it, even when the backend
+// writes asynchronously. resp.Body is consumed and closed.
+func dumpPooledResponse(resp *http.Response, pool BufferPool) ([]byte, error) {
+	resp.Proto, resp.ProtoMajor, resp.ProtoMinor = "HTTP/1.1", 1, 1
+
+	body := pool.Get()
+	defer pool.Put(body)
+	if resp.Body != nil {
+		_, err := body.ReadFrom(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := pool.Get()
+	defer pool.Put(out)
+	stored := *resp
+	stored.Body = io.NopCloser(bytes.NewReader(body.Bytes()))
+	if err := stored.Write(out); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(out.Bytes()), nil
+}
+
+// dumpCapturedResponse serializes a stored response whose body was captured by
+// a cachingReadCloser, using BufferPool when configured
+func (t *Transport) dumpCapturedResponse(resp *http.Response) ([]byte, error) {
+	if t.BufferPool == nil {
+		return dumpStoredResponse(resp)
+	}
+	return dumpPooledResponse(resp, t.BufferPool)
+}
@@ -0,0 +1,41 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// BytesSavedFromOrigin returns the number of response body bytes served from
+// cache so far, i.e. the origin egress the cache saved. Bytes are counted as the
+// caller reads the bodies of responses served from cache (fresh hits, stale
+// serves and entries revalidated with a 304). It is safe for concurrent use.
+func (t *Transport) BytesSavedFromOrigin() int64 {
+	return t.bytesSaved.Load()
+}
+
+// countBytesSaved wraps the body of resp, if fromCache, to add the bytes read to
+// BytesSavedFromOrigin and WindowStats
+func (t *Transport) countBytesSaved(resp *http.Response, fromCache bool) {
+	if !fromCache || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	resp.Body = &countingReadCloser{ReadCloser: resp.Body, count: &t.bytesSaved, window: t.WindowStats}
+}
+
+// countingReadCloser adds the bytes read from the wrapped body to count, and to
+// window if set
+type countingReadCloser struct {
+	io.ReadCloser
+	count  *atomic.Int64
+	window *WindowStats
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.count.Add(int64(n))
+	if r.window != nil && n > 0 {
+		r.window.addBytesSaved(int64(n))
+	}
+	return n, err
+}
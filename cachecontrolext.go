@@ -0,0 +1,89 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FreshnessDecision is the freshness lifetime of a stored response as computed
+// from the standard directives, passed to Cache-Control extension handlers so
+// they can change it
+type FreshnessDecision struct {
+	// Lifetime is the freshness lifetime: max-age, or else Expires minus Date,
+	// or zero if neither is present
+	Lifetime time.Duration
+	// Header holds the stored response headers. Handlers must not modify it.
+	Header http.Header
+}
+
+// cacheControlExtension is a registered Cache-Control extension directive
+type cacheControlExtension struct {
+	name    string
+	handler func(value string, decision *FreshnessDecision)
+}
+
+var (
+	cacheControlExtensionsMu sync.RWMutex
+	cacheControlExtensions   []cacheControlExtension
+)
+
+// RegisterCacheControlExtension registers handler for the response Cache-Control
+// extension directive name (e.g. "x-edge-ttl"), which is otherwise ignored.
+// Whenever the freshness of a stored response carrying the directive is
+// computed, handler is called with the directive's value (empty if it has none)
+// and may change decision.Lifetime.
+//
+// Extensions take precedence over the standard lifetime directives: handlers
+// run after max-age and Expires have been applied, in registration order. They
+// don't override no-store, no-cache, must-revalidate or request directives such
+// as max-age and min-fresh, nor a lifetime set by the Transport itself (e.g.
+// WithRequestTTL, ValidatorOnlyFreshness or SoftTTL).
+//
+// Names are case-insensitive. Registering a name again replaces its handler; a
+// nil handler removes it. Extensions apply to every Transport and should be
+// registered during initialization.
+func RegisterCacheControlExtension(name string, handler func(value string, decision *FreshnessDecision)) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	cacheControlExtensionsMu.Lock()
+	defer cacheControlExtensionsMu.Unlock()
+
+	for i, ext := range cacheControlExtensions {
+		if ext.name != name {
+			continue
+		}
+		if handler == nil {
+			cacheControlExtensions = append(cacheControlExtensions[:i:i], cacheControlExtensions[i+1:]...)
+		} else {
+			cacheControlExtensions[i].handler = handler
+		}
+		return
+	}
+	if handler != nil {
+		cacheControlExtensions = append(cacheControlExtensions, cacheControlExtension{name: name, handler: handler})
+	}
+}
+
+// applyCacheControlExtensions runs the registered extension handlers for the
+// directives present in respCacheControl, returning the resulting lifetime
+func applyCacheControlExtensions(respCacheControl cacheControl, respHeaders http.Header, lifetime time.Duration) time.Duration {
+	cacheControlExtensionsMu.RLock()
+	defer cacheControlExtensionsMu.RUnlock()
+
+	if len(cacheControlExtensions) == 0 {
+		return lifetime
+	}
+
+	decision := FreshnessDecision{Lifetime: lifetime, Header: respHeaders}
+	for _, ext := range cacheControlExtensions {
+		for directive, value := range respCacheControl {
+			if strings.ToLower(directive) == ext.name {
+				ext.handler(value, &decision)
+				break
+			}
+		}
+	}
+	return decision.Lifetime
+}
@@ -0,0 +1,97 @@
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrInvalidCachedResponse is returned when a cache entry can't be decoded into a
+// response, e.g. because it was truncated or corrupted in the backend. The
+// Transport treats such entries as a cache miss.
+var ErrInvalidCachedResponse = errors.New("invalid cached response")
+
+// ErrCacheMiss is returned by CachedResponseE when no entry is stored for the
+// request.
+var ErrCacheMiss = errors.New("cache miss")
+
+// CachedResponseE returns the cached http.Response for req. Unlike
+// CachedResponse, a miss is reported as ErrCacheMiss, so callers can tell it
+// apart from a failure with errors.Is. If c implements FallibleCache, backend
+// errors are returned too; an entry that can't be decoded is reported with
+// ErrInvalidCachedResponse.
+func CachedResponseE(c Cache, req *http.Request) (*http.Response, error) {
+	key := cacheKey(req)
+	var cachedVal []byte
+	var ok bool
+	if fc, fallible := c.(FallibleCache); fallible {
+		var err error
+		cachedVal, ok, err = fc.GetWithError(key)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		cachedVal, ok = c.Get(key)
+	}
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return readCachedResponse(cachedVal, req)
+}
+
+// readCachedResponse decodes a stored entry into a response for req
+func readCachedResponse(raw []byte, req *http.Request) (*http.Response, error) {
+	resp, _, err := parseCachedResponse(raw, req)
+	return resp, err
+}
+
+// parseCachedResponse decodes a stored entry and returns the offset of its body in
+// raw. Unlike a plain http.ReadResponse, it rejects entries whose status line is
+// out of range or whose body is shorter than declared, so a corrupted entry is
+// reported here instead of failing midway through serving it.
+func parseCachedResponse(raw []byte, req *http.Request) (*http.Response, int, error) {
+	if len(raw) == 0 {
+		return nil, 0, fmt.Errorf("%w: empty entry", ErrInvalidCachedResponse)
+	}
+
+	r := bytes.NewReader(raw)
+	br := bufio.NewReader(r)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidCachedResponse, err)
+	}
+	if resp.StatusCode < 100 || resp.StatusCode > 599 {
+		return nil, 0, fmt.Errorf("%w: status code %d", ErrInvalidCachedResponse, resp.StatusCode)
+	}
+
+	bodyOffset := len(raw) - r.Len() - br.Buffered()
+	if !responseHasBody(req, resp) {
+		return resp, bodyOffset, nil
+	}
+
+	switch {
+	case len(resp.TransferEncoding) > 0:
+		// Decode chunked bodies now, so framing errors surface as an invalid entry
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidCachedResponse, err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	case resp.ContentLength > int64(len(raw)-bodyOffset):
+		return nil, 0, fmt.Errorf("%w: truncated body", ErrInvalidCachedResponse)
+	}
+	return resp, bodyOffset, nil
+}
+
+// responseHasBody reports whether a response to req with its status code carries
+// a body (RFC 9110 Section 6.4.1)
+func responseHasBody(req *http.Request, resp *http.Response) bool {
+	if req != nil && req.Method == methodHEAD {
+		return false
+	}
+	return resp.StatusCode >= 200 && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified
+}
@@ -0,0 +1,243 @@
+// Package cadiskcache provides a persistent implementation of httpcache.Cache
+// that deduplicates response bodies on disk.
+//
+// Each cached response is split at the end of its headers. The body is stored
+// once as a blob named after its SHA-256 hash, and each key only stores its
+// headers and a pointer to the blob. URLs returning identical bodies (e.g.
+// shared thumbnails or placeholder images) therefore share one blob, which is
+// removed when the last key referencing it is deleted or overwritten.
+//
+// Reference counts are kept next to the blobs and updated under an in-process
+// lock, so a cache directory must not be shared by several processes.
+//
+// Example usage:
+//
+//	cache, err := cadiskcache.New("/var/cache/myapp")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	transport := httpcache.NewTransport(cache)
+//	client := transport.Client()
+package cadiskcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/sandrolain/httpcache"
+)
+
+const (
+	keysDir  = "keys"
+	blobsDir = "blobs"
+	refExt   = ".refs"
+)
+
+// headerEnd separates the headers from the body in a serialized response
+var headerEnd = []byte("\r\n\r\n")
+
+// Cache is an implementation of httpcache.Cache that stores response bodies in
+// content-addressed blobs shared by all keys with the same body
+type Cache struct {
+	basePath string
+	mu       sync.RWMutex
+}
+
+// New returns a new Cache storing its files in basePath, creating the directory
+// if needed
+func New(basePath string) (*Cache, error) {
+	for _, dir := range []string{keysDir, blobsDir} {
+		if err := os.MkdirAll(filepath.Join(basePath, dir), 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+	return &Cache{basePath: basePath}, nil
+}
+
+// Name returns "cadisk", the backend name reported with
+// httpcache.Transport.ServeDiagnostics
+func (c *Cache) Name() string {
+	return "cadisk"
+}
+
+// Get returns the response corresponding to key if present
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	hash, header, ok := c.readPointer(key)
+	if !ok {
+		return nil, false
+	}
+	body, err := os.ReadFile(c.blobPath(hash))
+	if err != nil {
+		httpcache.GetLogger().Warn("missing blob for cache entry", "key", key, "error", err)
+		return nil, false
+	}
+	return append(header, body...), true
+}
+
+// Set saves a response to the cache as key, reusing the stored blob if another
+// key already has the same body
+func (c *Cache) Set(key string, value []byte) {
+	header, body := splitResponse(value)
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	oldHash, _, hadOld := c.readPointer(key)
+	if !hadOld || oldHash != hash {
+		if err := c.addRef(hash, body); err != nil {
+			httpcache.GetLogger().Warn("failed to write cache blob", "key", key, "error", err)
+			return
+		}
+	}
+
+	pointer := make([]byte, 0, len(hash)+1+len(header))
+	pointer = append(pointer, hash...)
+	pointer = append(pointer, '\n')
+	pointer = append(pointer, header...)
+	if err := writeFileAtomic(c.keyPath(key), pointer); err != nil {
+		httpcache.GetLogger().Warn("failed to write to disk cache", "key", key, "error", err)
+		if !hadOld || oldHash != hash {
+			c.release(hash)
+		}
+		return
+	}
+
+	if hadOld && oldHash != hash {
+		c.release(oldHash)
+	}
+}
+
+// Delete removes the response with key from the cache, and its blob if no other
+// key references it
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hash, _, ok := c.readPointer(key)
+	if !ok {
+		return
+	}
+	if err := os.Remove(c.keyPath(key)); err != nil {
+		httpcache.GetLogger().Warn("failed to delete from disk cache", "key", key, "error", err)
+		return
+	}
+	c.release(hash)
+}
+
+// readPointer returns the blob hash and headers stored for key
+func (c *Cache) readPointer(key string) (hash string, header []byte, ok bool) {
+	data, err := os.ReadFile(c.keyPath(key))
+	if err != nil {
+		return "", nil, false
+	}
+	hashBytes, header, found := bytes.Cut(data, []byte("\n"))
+	if !found || len(hashBytes) != sha256.Size*2 {
+		return "", nil, false
+	}
+	return string(hashBytes), header, true
+}
+
+// addRef increments the reference count of the blob, writing it first if this
+// is its first reference
+func (c *Cache) addRef(hash string, body []byte) error {
+	refs := c.refCount(hash)
+	if refs == 0 {
+		if err := os.MkdirAll(filepath.Dir(c.blobPath(hash)), 0o750); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(c.blobPath(hash), body); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(c.blobPath(hash)+refExt, []byte(strconv.Itoa(refs+1)))
+}
+
+// release decrements the reference count of the blob, removing it when no key
+// references it anymore
+func (c *Cache) release(hash string) {
+	refs := c.refCount(hash) - 1
+	if refs > 0 {
+		if err := writeFileAtomic(c.blobPath(hash)+refExt, []byte(strconv.Itoa(refs))); err != nil {
+			httpcache.GetLogger().Warn("failed to update blob reference count", "hash", hash, "error", err)
+		}
+		return
+	}
+	for _, path := range []string{c.blobPath(hash), c.blobPath(hash) + refExt} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			httpcache.GetLogger().Warn("failed to delete cache blob", "hash", hash, "error", err)
+		}
+	}
+}
+
+// refCount returns the number of keys referencing the blob
+func (c *Cache) refCount(hash string) int {
+	data, err := os.ReadFile(c.blobPath(hash) + refExt)
+	if err != nil {
+		return 0
+	}
+	refs, err := strconv.Atoi(string(data))
+	if err != nil || refs < 0 {
+		return 0
+	}
+	return refs
+}
+
+// keyPath returns the path of the pointer file for key
+func (c *Cache) keyPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.basePath, keysDir, hex.EncodeToString(sum[:]))
+}
+
+// blobPath returns the path of the blob with the given hash, sharded by its
+// first two characters to keep directories small
+func (c *Cache) blobPath(hash string) string {
+	return filepath.Join(c.basePath, blobsDir, hash[:2], hash)
+}
+
+// splitResponse splits a serialized response into its headers, including the
+// blank line ending them, and its body. Values that aren't HTTP responses are
+// stored entirely as the body.
+func splitResponse(value []byte) (header, body []byte) {
+	idx := bytes.Index(value, headerEnd)
+	if idx < 0 {
+		return nil, value
+	}
+	idx += len(headerEnd)
+	return value[:idx], value[idx:]
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to path, so
+// readers never see a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package cadiskcache
+
+import (
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sandrolain/httpcache/test"
+)
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+	cache, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return cache
+}
+
+// blobFiles returns the body blobs stored on disk
+func blobFiles(t *testing.T, c *Cache) []string {
+	t.Helper()
+	var blobs []string
+	err := filepath.WalkDir(filepath.Join(c.basePath, blobsDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !strings.HasSuffix(path, refExt) {
+			blobs = append(blobs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir: %v", err)
+	}
+	return blobs
+}
+
+func response(date string, body []byte) []byte {
+	return append([]byte("HTTP/1.1 200 OK\r\nDate: "+date+"\r\nContent-Length: "+
+		strconv.Itoa(len(body))+"\r\n\r\n"), body...)
+}
+
+func TestCADiskCache(t *testing.T) {
+	test.Cache(t, newTestCache(t))
+}
+
+func TestIdenticalBodiesShareOneBlob(t *testing.T) {
+	cache := newTestCache(t)
+	body := bytes.Repeat([]byte("thumbnail"), 64*1024)
+
+	first := response("Mon, 02 Jan 2006 15:04:05 GMT", body)
+	second := response("Tue, 03 Jan 2006 15:04:05 GMT", body)
+	cache.Set("https://example.com/a.png", first)
+	cache.Set("https://example.com/b.png", second)
+
+	if blobs := blobFiles(t, cache); len(blobs) != 1 {
+		t.Fatalf("expected one body blob on disk, got %d", len(blobs))
+	}
+
+	for key, want := range map[string][]byte{
+		"https://example.com/a.png": first,
+		"https://example.com/b.png": second,
+	} {
+		got, ok := cache.Get(key)
+		if !ok || !bytes.Equal(got, want) {
+			t.Fatalf("unexpected value for %s", key)
+		}
+	}
+
+	cache.Delete("https://example.com/a.png")
+	if blobs := blobFiles(t, cache); len(blobs) != 1 {
+		t.Fatal("blob removed while still referenced")
+	}
+	if got, ok := cache.Get("https://example.com/b.png"); !ok || !bytes.Equal(got, second) {
+		t.Fatal("remaining key lost its body")
+	}
+
+	cache.Delete("https://example.com/b.png")
+	if blobs := blobFiles(t, cache); len(blobs) != 0 {
+		t.Fatalf("expected unreferenced blob to be removed, got %d", len(blobs))
+	}
+}
+
+func TestOverwriteReleasesOldBlob(t *testing.T) {
+	cache := newTestCache(t)
+	date := "Mon, 02 Jan 2006 15:04:05 GMT"
+
+	cache.Set("key", response(date, []byte("v1")))
+	cache.Set("key", response(date, []byte("v1")))
+	if blobs := blobFiles(t, cache); len(blobs) != 1 {
+		t.Fatalf("expected one blob after rewriting the same body, got %d", len(blobs))
+	}
+
+	cache.Set("key", response(date, []byte("v2")))
+	if blobs := blobFiles(t, cache); len(blobs) != 1 {
+		t.Fatalf("expected the old blob to be released, got %d blobs", len(blobs))
+	}
+	if got, _ := cache.Get("key"); !bytes.HasSuffix(got, []byte("v2")) {
+		t.Fatalf("unexpected value %q", got)
+	}
+
+	cache.Delete("key")
+	if blobs := blobFiles(t, cache); len(blobs) != 0 {
+		t.Fatalf("expected no blobs after delete, got %d", len(blobs))
+	}
+}
+
+func TestPersistsAcrossInstances(t *testing.T) {
+	dir := t.TempDir()
+	first, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := response("Mon, 02 Jan 2006 15:04:05 GMT", []byte("body"))
+	first.Set("a", value)
+	first.Set("b", value)
+
+	second, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Delete("a")
+	if got, ok := second.Get("b"); !ok || !bytes.Equal(got, value) {
+		t.Fatal("expected reference counts to survive reopening the cache")
+	}
+}
@@ -0,0 +1,98 @@
+package httpcache
+
+import (
+	"sync"
+	"time"
+)
+
+// KeyCardinalityMonitor tracks how many distinct cache keys are stored per base URL
+// within a time window, and reports when that number exceeds a threshold.
+//
+// With CacheKeyHeaders or EnableVarySeparation, a high-cardinality header (e.g. a
+// per-request nonce or trace ID included by mistake) creates a new entry on every
+// request. The monitor surfaces this misconfiguration before it fills the backend.
+//
+// A monitor is safe for concurrent use and can be shared between Transports.
+type KeyCardinalityMonitor struct {
+	threshold  int
+	window     time.Duration
+	onExceeded func(baseURL string, uniqueKeys int)
+	now        func() time.Time
+
+	mu        sync.Mutex
+	windows   map[string]*cardinalityWindow
+	lastPrune time.Time
+}
+
+type cardinalityWindow struct {
+	start time.Time
+	keys  map[string]struct{}
+	fired bool
+}
+
+// NewKeyCardinalityMonitor returns a monitor that calls onExceeded once per window
+// when more than threshold distinct keys are stored for the same base URL.
+// onExceeded may be nil, in which case only a warning is logged. It is called
+// synchronously on the request path and should be fast (e.g. increment a metric).
+func NewKeyCardinalityMonitor(threshold int, window time.Duration, onExceeded func(baseURL string, uniqueKeys int)) *KeyCardinalityMonitor {
+	return &KeyCardinalityMonitor{
+		threshold:  threshold,
+		window:     window,
+		onExceeded: onExceeded,
+		now:        time.Now,
+		windows:    make(map[string]*cardinalityWindow),
+	}
+}
+
+// Observe records that key was stored for baseURL.
+func (m *KeyCardinalityMonitor) Observe(baseURL, key string) {
+	m.mu.Lock()
+	now := m.now()
+	m.pruneLocked(now)
+
+	w, ok := m.windows[baseURL]
+	if !ok || now.Sub(w.start) >= m.window {
+		w = &cardinalityWindow{start: now, keys: make(map[string]struct{})}
+		m.windows[baseURL] = w
+	}
+
+	// Once fired, stop tracking until the window resets to bound memory usage
+	if w.fired {
+		m.mu.Unlock()
+		return
+	}
+
+	w.keys[key] = struct{}{}
+	count := len(w.keys)
+	exceeded := count > m.threshold
+	if exceeded {
+		w.fired = true
+		w.keys = nil
+	}
+	m.mu.Unlock()
+
+	if exceeded {
+		GetLogger().Warn("cache key cardinality threshold exceeded, check CacheKeyHeaders and Vary configuration",
+			"base_url", baseURL,
+			"unique_keys", count,
+			"threshold", m.threshold,
+			"window", m.window)
+		if m.onExceeded != nil {
+			m.onExceeded(baseURL, count)
+		}
+	}
+}
+
+// pruneLocked drops expired windows at most once per window duration.
+// Must be called with m.mu held.
+func (m *KeyCardinalityMonitor) pruneLocked(now time.Time) {
+	if now.Sub(m.lastPrune) < m.window {
+		return
+	}
+	for baseURL, w := range m.windows {
+		if now.Sub(w.start) >= m.window {
+			delete(m.windows, baseURL)
+		}
+	}
+	m.lastPrune = now
+}
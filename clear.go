@@ -0,0 +1,41 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClearNotSupported is returned by Transport.Clear when the cache backend
+// does not implement Clearer.
+var ErrClearNotSupported = errors.New("cache does not implement Clearer")
+
+// Clearer is an optional interface implemented by caches that can remove all
+// their entries at once. ClearWithContext only removes the entries the cache
+// stored, e.g. the keys under its prefix in a Redis database shared with other
+// data, and may return early with ctx's error when ctx is done.
+//
+// The method is named ClearWithContext rather than Clear because some backends,
+// such as freecache.Cache, already have a Clear method without a context that
+// existing callers rely on.
+type Clearer interface {
+	ClearWithContext(ctx context.Context) error
+}
+
+// Clear removes every entry from the cache backend, e.g. to purge it after a
+// deployment, returning ErrClearNotSupported if it doesn't implement Clearer.
+// Writes in flight may land after Clear returns.
+//
+// The whole backend is cleared, including the entries stored under other
+// KeyVersion namespaces or by other Transports sharing it; data stored there
+// by something other than the cache is left alone.
+func (t *Transport) Clear(ctx context.Context) error {
+	clearer, ok := t.Cache.(Clearer)
+	if !ok {
+		return ErrClearNotSupported
+	}
+	if err := clearer.ClearWithContext(ctx); err != nil {
+		return err
+	}
+	GetLogger().Debug("cache cleared")
+	return nil
+}
@@ -0,0 +1,75 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+)
+
+// notModifiedOmittedHeaders are the representation headers of a cached 200
+// response left out of the 304 answering a client's conditional request
+// (RFC 9110 Section 15.4.5)
+var notModifiedOmittedHeaders = []string{"Content-Length", "Content-Type", "Content-Encoding", "Content-Range", "Transfer-Encoding"}
+
+// answerClientConditional returns a 304 Not Modified in place of resp, a 200
+// served from cache for a GET or HEAD request, when the validators the client
+// sent match it (RFC 9110 Section 13.2.2): If-None-Match, compared weakly to the
+// ETag, or else If-Modified-Since, compared to Last-Modified. Otherwise resp is
+// returned as is, so a client whose validators don't match gets the cached
+// body. The origin is never contacted.
+func answerClientConditional(req *http.Request, resp *http.Response) *http.Response {
+	if resp.StatusCode != http.StatusOK || (req.Method != methodGET && req.Method != methodHEAD) {
+		return resp
+	}
+	if !clientValidatorsMatch(req.Header, resp.Header) {
+		return resp
+	}
+
+	notModified := *resp
+	notModified.StatusCode = http.StatusNotModified
+	notModified.Status = "304 Not Modified"
+	notModified.Header = resp.Header.Clone()
+	for _, name := range notModifiedOmittedHeaders {
+		notModified.Header.Del(name)
+	}
+	notModified.ContentLength = 0
+	notModified.Body = http.NoBody
+	if resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+	return &notModified
+}
+
+// clientValidatorsMatch reports whether the conditional headers of a request
+// select the response with respHeader, i.e. the client's copy is current
+func clientValidatorsMatch(reqHeader, respHeader http.Header) bool {
+	if ifNoneMatch := reqHeader.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := respHeader.Get(headerETag)
+		if strings.TrimSpace(ifNoneMatch) == "*" {
+			return true
+		}
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince := reqHeader.Get("If-Modified-Since")
+	lastModified := respHeader.Get(headerLastModified)
+	if ifModifiedSince == "" || lastModified == "" {
+		return false
+	}
+	since, err := parseHTTPDate(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	modified, err := parseHTTPDate(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
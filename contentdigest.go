@@ -0,0 +1,113 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const headerContentDigest = "Content-Digest"
+
+// contentDigestAlgorithms are the Content-Digest algorithms that can be verified
+// (RFC 9530 Section 5)
+var contentDigestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// parseContentDigest returns the digests of the supported algorithms in the
+// Content-Digest header, a structured dictionary such as sha-256=:<base64>:
+func parseContentDigest(header http.Header) map[string][]byte {
+	var digests map[string][]byte
+	for _, member := range headerAllCommaSepValues(header, headerContentDigest) {
+		name, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, supported := contentDigestAlgorithms[name]; !supported {
+			continue
+		}
+		// Drop parameters, then the colons delimiting the byte sequence
+		value, _, _ = strings.Cut(strings.TrimSpace(value), ";")
+		if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			continue
+		}
+		digest, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil {
+			continue
+		}
+		if digests == nil {
+			digests = map[string][]byte{}
+		}
+		digests[name] = digest
+	}
+	return digests
+}
+
+// verifyCachedDigest checks the body of cachedResp against its Content-Digest,
+// if VerifyContentDigest is enabled. A corrupted entry is deleted from the
+// cache and nil is returned, so the request is treated as a miss.
+func (t *Transport) verifyCachedDigest(req *http.Request, cachedResp *http.Response, key string) *http.Response {
+	if !t.VerifyContentDigest || req.Method != methodGET {
+		return cachedResp
+	}
+	digests := parseContentDigest(cachedResp.Header)
+	if len(digests) == 0 {
+		return cachedResp
+	}
+
+	body, err := io.ReadAll(cachedResp.Body)
+	_ = cachedResp.Body.Close()
+	if err != nil || !bodyMatchesDigests(body, digests) {
+		GetLogger().Warn("cached body does not match its Content-Digest, refetching",
+			"url", req.URL.String(), "key", key, "error", err)
+		t.deleteEntry(key)
+		return nil
+	}
+
+	cachedResp.Body = io.NopCloser(bytes.NewReader(body))
+	return cachedResp
+}
+
+// bodyMatchesDigests reports whether body matches every digest in digests
+func bodyMatchesDigests(body []byte, digests map[string][]byte) bool {
+	for name, digest := range digests {
+		h := contentDigestAlgorithms[name]()
+		h.Write(body)
+		if !bytes.Equal(h.Sum(nil), digest) {
+			return false
+		}
+	}
+	return true
+}
+
+// contentDigestsMatch reports whether a 304 response and the stored response
+// agree on the Content-Digest of an algorithm both carry. If they share none,
+// the digest gives no signal and they match.
+func contentDigestsMatch(cachedResp, notModified *http.Response) bool {
+	newDigests := parseContentDigest(notModified.Header)
+	if len(newDigests) == 0 {
+		return true
+	}
+	for name, stored := range parseContentDigest(cachedResp.Header) {
+		if digest, ok := newDigests[name]; ok && !bytes.Equal(digest, stored) {
+			return false
+		}
+	}
+	return true
+}
+
+// dropDecodedContentDigest removes the Content-Digest of a response the
+// underlying transport transparently decompressed: it describes the encoded
+// content, so the stored body would never match it
+func (t *Transport) dropDecodedContentDigest(resp *http.Response) {
+	if t.VerifyContentDigest && resp.Uncompressed {
+		resp.Header.Del(headerContentDigest)
+	}
+}
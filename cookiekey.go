@@ -0,0 +1,29 @@
+package httpcache
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// cookieKey appends the values of the CacheKeyCookies sent with req to key,
+// sorted by cookie name. Values are hashed like the Cookie header, so they never
+// appear in keys.
+func (t *Transport) cookieKey(req *http.Request, key string) string {
+	if len(t.CacheKeyCookies) == 0 {
+		return key
+	}
+	var parts []string
+	for _, name := range t.CacheKeyCookies {
+		cookie, err := req.Cookie(strings.TrimSpace(name))
+		if err != nil {
+			continue
+		}
+		parts = append(parts, cookie.Name+"="+keyHeaderValue("Cookie", cookie.Value))
+	}
+	if len(parts) == 0 {
+		return key
+	}
+	sort.Strings(parts)
+	return key + "|cookies:" + strings.Join(parts, "|")
+}
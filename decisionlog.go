@@ -0,0 +1,161 @@
+package httpcache
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DecisionOutcome is how a request was answered, as recorded by DecisionLogSize
+// and OnCacheDecision
+type DecisionOutcome string
+
+const (
+	// DecisionHit means a fresh entry was served without contacting the origin
+	DecisionHit DecisionOutcome = "hit"
+	// DecisionRevalidated means an entry was served after the origin answered 304
+	DecisionRevalidated DecisionOutcome = "revalidated"
+	// DecisionStale means a stale entry was served (stale-while-revalidate,
+	// stale-if-error or serve-stale mode)
+	DecisionStale DecisionOutcome = "stale"
+	// DecisionMiss means the response came from the origin
+	DecisionMiss DecisionOutcome = "miss"
+	// DecisionBypass means the request wasn't eligible for caching (method not
+	// cacheable, or a Range request) and went to the origin
+	DecisionBypass DecisionOutcome = "bypass"
+	// DecisionError means the request failed
+	DecisionError DecisionOutcome = "error"
+)
+
+// Decision records how the Transport handled one request, see RecentDecisions
+// and OnCacheDecision. The decision log and OnCacheDecision share this one type,
+// so it is named Decision rather than CacheDecision.
+type Decision struct {
+	// Time is when the response (or error) was returned
+	Time time.Time
+	// Method and URL identify the request
+	Method string
+	URL    string
+	// Outcome is how the request was answered
+	Outcome DecisionOutcome
+	// FromCache reports whether the response was served from cache, fresh,
+	// stale or after revalidation
+	FromCache bool
+	// Revalidated reports whether the origin confirmed the cached entry with a
+	// 304 before it was served
+	Revalidated bool
+	// StatusCode is the status of the returned response, or 0 on error
+	StatusCode int
+	// CacheControl is the Cache-Control header of the response from the origin
+	// or the cache, before serve-time changes such as ClientCacheControl
+	CacheControl string
+	// Stored reports whether the response may be stored: false explains why a
+	// following request misses. GET responses are only stored once their body
+	// has been read.
+	Stored bool
+	// Err is the error message when Outcome is DecisionError
+	Err string
+	// CacheKey is the resolved cache key of the request, including the Vary
+	// variant when EnableVarySeparation found one
+	CacheKey string
+	// Freshness is the freshness of the entry found in cache when the request
+	// arrived ("fresh", "stale", "stale-while-revalidate" or "transparent"),
+	// or empty if there was none
+	Freshness string
+}
+
+// decisionLog is a fixed-size ring buffer of the latest decisions
+type decisionLog struct {
+	mu      sync.Mutex
+	entries []Decision
+	next    int
+	full    bool
+}
+
+// add records d in a buffer of size decisions, overwriting the oldest decision
+// once the buffer is full
+func (l *decisionLog) add(size int, d Decision) {
+	l.mu.Lock()
+	if l.entries == nil {
+		l.entries = make([]Decision, size)
+	}
+	l.entries[l.next] = d
+	l.next++
+	if l.next == len(l.entries) {
+		l.next, l.full = 0, true
+	}
+	l.mu.Unlock()
+}
+
+// snapshot returns the recorded decisions, oldest first
+func (l *decisionLog) snapshot() []Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]Decision(nil), l.entries[:l.next]...)
+	}
+	out := make([]Decision, 0, len(l.entries))
+	out = append(out, l.entries[l.next:]...)
+	return append(out, l.entries[:l.next]...)
+}
+
+// RecentDecisions returns the last DecisionLogSize decisions of the Transport,
+// oldest first, or nil if DecisionLogSize is not set. It is safe to call while
+// the Transport is serving requests, e.g. from a debug endpoint.
+func (t *Transport) RecentDecisions() []Decision {
+	if t.DecisionLogSize <= 0 {
+		return nil
+	}
+	return t.decisions.snapshot()
+}
+
+// recordsDecisions reports whether decisions are wanted, by the decision log or
+// by OnCacheDecision
+func (t *Transport) recordsDecisions() bool {
+	return t.DecisionLogSize > 0 || t.OnCacheDecision != nil
+}
+
+// entryFreshness returns the freshness of cachedResp for req as reported in
+// Decision, or empty if there is no entry or decisions aren't recorded
+func (t *Transport) entryFreshness(req *http.Request, cachedResp *http.Response) string {
+	if cachedResp == nil || !t.recordsDecisions() {
+		return ""
+	}
+	return freshnessString(getFreshness(cachedResp.Header, req.Header))
+}
+
+// recordDecision adds the outcome of req to the decision log and passes it to
+// OnCacheDecision, if enabled. outcome is how RoundTrip answered a cacheable
+// request, whatever marker headers the response carries.
+func (t *Transport) recordDecision(req *http.Request, resp *http.Response, outcome DecisionOutcome, err error, cacheable, stored bool, cacheKey, freshness string) {
+	if !t.recordsDecisions() {
+		return
+	}
+	d := Decision{
+		Time:      time.Now(),
+		Method:    req.Method,
+		URL:       req.URL.String(),
+		Outcome:   outcome,
+		Stored:    stored,
+		CacheKey:  cacheKey,
+		Freshness: freshness,
+	}
+	switch {
+	case err != nil:
+		d.Outcome, d.Err = DecisionError, err.Error()
+	case !cacheable:
+		d.Outcome = DecisionBypass
+	}
+	d.FromCache = d.Outcome == DecisionHit || d.Outcome == DecisionStale || d.Outcome == DecisionRevalidated
+	d.Revalidated = d.Outcome == DecisionRevalidated
+	if resp != nil {
+		d.StatusCode = resp.StatusCode
+		d.CacheControl = resp.Header.Get("Cache-Control")
+	}
+	if t.DecisionLogSize > 0 {
+		t.decisions.add(t.DecisionLogSize, d)
+	}
+	if t.OnCacheDecision != nil {
+		t.OnCacheDecision(req, d)
+	}
+}
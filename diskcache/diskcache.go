@@ -4,9 +4,12 @@ package diskcache
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"io"
+	"io/fs"
 
 	"github.com/peterbourgon/diskv"
 	"github.com/sandrolain/httpcache"
@@ -17,6 +20,12 @@ type Cache struct {
 	d *diskv.Diskv
 }
 
+// Name returns "disk", the backend name reported with
+// httpcache.Transport.ServeDiagnostics
+func (c *Cache) Name() string {
+	return "disk"
+}
+
 // Get returns the response corresponding to key if present
 func (c *Cache) Get(key string) (resp []byte, ok bool) {
 	key = keyToFilename(key)
@@ -43,6 +52,47 @@ func (c *Cache) Delete(key string) {
 	}
 }
 
+// ClearWithContext removes all entries from the cache. Only the files named
+// like cache entries are erased, so other files kept in the base directory
+// survive. It implements httpcache.Clearer.
+func (c *Cache) ClearWithContext(ctx context.Context) error {
+	cancel := make(chan struct{})
+	defer close(cancel)
+
+	// Collect the keys first rather than erasing files during the walk
+	var keys []string
+	for key := range c.d.Keys(cancel) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if isCacheFilename(key) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.d.Erase(key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// isCacheFilename reports whether name is a file name produced by keyToFilename
+func isCacheFilename(name string) bool {
+	if len(name) != sha256.Size*2 {
+		return false
+	}
+	for _, r := range name {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
 func keyToFilename(key string) string {
 	h := sha256.New()
 	// Hash.Write never returns an error according to the interface contract
@@ -1,9 +1,14 @@
 package diskcache
 
 import (
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 
+	"github.com/sandrolain/httpcache"
 	"github.com/sandrolain/httpcache/test"
 )
 
@@ -18,3 +23,90 @@ func TestDiskCache(t *testing.T) {
 
 	test.Cache(t, New(tempDir))
 }
+
+func TestDiskCacheClear(t *testing.T) {
+	test.Clear(t, New(t.TempDir()))
+}
+
+// writeLegacyEntry writes value the way gregjones/httpcache's diskcache stored it
+func writeLegacyEntry(t *testing.T, dir, key string, value []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, legacyKeyToFilename(key)), value, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func TestMigrateFromLegacy(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+
+	entry := func(body string) []byte {
+		return []byte("HTTP/1.1 200 OK\r\nCache-Control: max-age=3600\r\nContent-Length: " +
+			strconv.Itoa(len(body)) + "\r\n\r\n" + body)
+	}
+	writeLegacyEntry(t, oldDir, "https://example.com/a", entry("a"))
+	writeLegacyEntry(t, oldDir, "https://example.com/b", entry("b"))
+	writeLegacyEntry(t, oldDir, "https://example.com/broken", []byte("not a response"))
+	writeLegacyEntry(t, oldDir, "https://example.com/unlisted", entry("unlisted"))
+
+	cache := New(newDir)
+	migrated, err := MigrateFromLegacy(oldDir, cache, []string{
+		"https://example.com/a",
+		"https://example.com/b",
+		"https://example.com/broken",
+		"https://example.com/missing",
+	})
+	if err != nil {
+		t.Fatalf("MigrateFromLegacy: %v", err)
+	}
+	if migrated != 2 {
+		t.Fatalf("expected 2 migrated entries, got %d", migrated)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com/"+name, nil)
+		resp, err := httpcache.CachedResponse(cache, req)
+		if err != nil || resp == nil {
+			t.Fatalf("expected the migrated entry for %s, got %v", name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != name {
+			t.Errorf("expected body %q, got %q", name, body)
+		}
+	}
+	for _, key := range []string{"https://example.com/broken", "https://example.com/unlisted"} {
+		if _, ok := cache.Get(key); ok {
+			t.Errorf("expected %s not to be migrated", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(newDir, keyToFilename("https://example.com/a"))); err != nil {
+		t.Errorf("expected the entry under its SHA-256 file name: %v", err)
+	}
+}
+
+func TestMigrateFromLegacyMissingDir(t *testing.T) {
+	if _, err := MigrateFromLegacy(filepath.Join(t.TempDir(), "missing"), New(t.TempDir()), []string{"k"}); err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+}
+
+func TestDiskCacheClearKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	other := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(other, []byte("keep me"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cache := New(dir)
+	cache.Set("key", []byte("value"))
+	if err := cache.ClearWithContext(t.Context()); err != nil {
+		t.Fatalf("ClearWithContext: %v", err)
+	}
+
+	if _, ok := cache.Get("key"); ok {
+		t.Error("expected the entry to be removed")
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("expected other files in the directory to survive, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package diskcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5" //nolint:gosec // MD5 is only used to locate entries written by the legacy cache
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/sandrolain/httpcache"
+)
+
+// MigrateFromLegacy copies the entries of a disk cache directory written by the
+// original github.com/gregjones/httpcache diskcache into newCache, returning the
+// number of entries migrated.
+//
+// The legacy cache stores each entry directly under its directory, in a file
+// named after the hex MD5 hash of the cache key, and the entry doesn't record
+// its key. As the hash can't be reversed, the keys to migrate must be given:
+// the key of a GET request is its URL, e.g. "https://example.com/a", and other
+// methods use "METHOD URL". Legacy files matching none of the keys are left
+// alone, and entries that can't be parsed as a stored response are skipped.
+//
+// Entries are written with newCache.Set, so a Cache stores them under its
+// SHA-256 file names. Legacy entries have no X-Cached-Time, so they are ignored
+// when Transport.MaxServableAgeSinceStore is set.
+func MigrateFromLegacy(oldDir string, newCache httpcache.Cache, keys []string) (migrated int, err error) {
+	info, err := os.Stat(oldDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open legacy cache directory: %w", err)
+	}
+	if !info.IsDir() {
+		return 0, fmt.Errorf("legacy cache path %s is not a directory", oldDir)
+	}
+
+	for _, key := range keys {
+		entry, err := os.ReadFile(filepath.Join(oldDir, legacyKeyToFilename(key)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return migrated, fmt.Errorf("failed to read legacy entry for %s: %w", key, err)
+		}
+		if _, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(entry)), nil); err != nil {
+			httpcache.GetLogger().Warn("skipping invalid legacy cache entry", "key", key, "error", err)
+			continue
+		}
+		newCache.Set(key, entry)
+		migrated++
+	}
+	return migrated, nil
+}
+
+// legacyKeyToFilename returns the file name gregjones/httpcache used for key
+func legacyKeyToFilename(key string) string {
+	sum := md5.Sum([]byte(key)) //nolint:gosec // see import
+	return hex.EncodeToString(sum[:])
+}
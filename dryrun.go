@@ -0,0 +1,29 @@
+package httpcache
+
+import "net/http"
+
+// cacheSet writes an entry serialized from the response to req with header
+// under key, followed by its StoreURLMetadata sidecar, or only reports it with
+// OnDryRunStore and a log line when DryRunStore is set
+func (t *Transport) cacheSet(key string, req *http.Request, header http.Header, respBytes []byte) {
+	if !t.DryRunStore {
+		t.Cache.Set(key, respBytes)
+		if t.StoreURLMetadata {
+			t.storeURLMetadata(key, req)
+		}
+		return
+	}
+	ttl := remainingFreshness(header)
+	GetLogger().Info("dry run: response would be stored", "key", key, "size", len(respBytes), "ttl", ttl)
+	if t.OnDryRunStore != nil {
+		t.OnDryRunStore(key, len(respBytes), ttl)
+	}
+}
+
+// storeDelete removes the entry under key while storing a response, unless
+// DryRunStore is set
+func (t *Transport) storeDelete(key string) {
+	if !t.DryRunStore {
+		t.deleteEntry(key)
+	}
+}
@@ -0,0 +1,265 @@
+// Package dsn builds an httpcache.Transport from a URL-style data source name,
+// so configuration files can select and configure the cache backend with a
+// single string:
+//
+//	mem://
+//	disk:///var/cache/myapp?ttl=24h
+//	leveldb:///var/cache/myapp.db
+//	redis://:password@localhost:6379/2?encrypt=true
+//	mongodb://localhost:27017/myapp?collection=httpcache&ttl=1h
+//
+// The scheme selects the backend. The following query parameters are handled
+// for every backend and removed before the backend is configured:
+//
+//   - ttl: stored entries older than this duration are not served
+//     (Transport.MaxServableAgeSinceStore); MongoDB also expires them
+//   - encrypt: "true" wraps the backend with securecache, using the passphrase
+//     read from the environment variable named by passphrase_env
+//   - passphrase_env: the environment variable holding the encryption
+//     passphrase (default HTTPCACHE_PASSPHRASE)
+//
+// The package lives outside httpcache because it imports the backends, which
+// themselves import httpcache.
+package dsn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandrolain/httpcache"
+	"github.com/sandrolain/httpcache/diskcache"
+	"github.com/sandrolain/httpcache/leveldbcache"
+	"github.com/sandrolain/httpcache/mongodb"
+	"github.com/sandrolain/httpcache/redis"
+	"github.com/sandrolain/httpcache/wrapper/securecache"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// DefaultPassphraseEnv is the environment variable read for the encryption
+// passphrase when the DSN doesn't set passphrase_env
+const DefaultPassphraseEnv = "HTTPCACHE_PASSPHRASE"
+
+// ErrUnknownScheme is returned for a DSN whose scheme names no supported backend.
+var ErrUnknownScheme = errors.New("unknown cache DSN scheme")
+
+// options holds the backend-independent DSN parameters
+type options struct {
+	ttl           time.Duration
+	encrypt       bool
+	passphraseEnv string
+}
+
+// nopCloser is returned for backends that hold no resources
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// FromDSN parses dsn, creates and configures the backend it describes and
+// returns a Transport using it. The returned io.Closer releases the backend's
+// resources (connections, open files) and must be closed when the Transport is
+// no longer used.
+func FromDSN(ctx context.Context, dsn string) (*httpcache.Transport, io.Closer, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cache DSN: %w", err)
+	}
+	opts, err := parseOptions(u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cache, closer, err := newBackend(ctx, u, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.encrypt {
+		passphrase := os.Getenv(opts.passphraseEnv)
+		if passphrase == "" {
+			_ = closer.Close()
+			return nil, nil, fmt.Errorf("encryption requested but %s is empty", opts.passphraseEnv)
+		}
+		cache, err = securecache.New(securecache.Config{Cache: cache, Passphrase: passphrase})
+		if err != nil {
+			_ = closer.Close()
+			return nil, nil, err
+		}
+	}
+
+	transport := httpcache.NewTransport(cache)
+	transport.MaxServableAgeSinceStore = opts.ttl
+	return transport, closer, nil
+}
+
+// parseOptions extracts the backend-independent parameters, removing them from
+// the query of u
+func parseOptions(u *url.URL) (options, error) {
+	query := u.Query()
+	opts := options{passphraseEnv: DefaultPassphraseEnv}
+
+	if v := query.Get("ttl"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			return opts, fmt.Errorf("invalid cache DSN ttl %q: must be a positive duration", v)
+		}
+		opts.ttl = ttl
+	}
+	if v := query.Get("encrypt"); v != "" {
+		encrypt, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid cache DSN encrypt %q: %w", v, err)
+		}
+		opts.encrypt = encrypt
+	}
+	if v := query.Get("passphrase_env"); v != "" {
+		opts.passphraseEnv = v
+	}
+
+	for _, name := range []string{"ttl", "encrypt", "passphrase_env"} {
+		query.Del(name)
+	}
+	u.RawQuery = query.Encode()
+	return opts, nil
+}
+
+// newBackend creates the cache selected by the scheme of u
+func newBackend(ctx context.Context, u *url.URL, opts options) (httpcache.Cache, io.Closer, error) {
+	switch strings.ToLower(u.Scheme) {
+	case "mem", "memory":
+		if err := noParams(u); err != nil {
+			return nil, nil, err
+		}
+		return httpcache.NewMemoryCache(), nopCloser{}, nil
+
+	case "disk":
+		path, err := localPath(u)
+		if err != nil {
+			return nil, nil, err
+		}
+		return diskcache.New(path), nopCloser{}, nil
+
+	case "leveldb":
+		path, err := localPath(u)
+		if err != nil {
+			return nil, nil, err
+		}
+		db, err := leveldb.OpenFile(path, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open leveldb cache: %w", err)
+		}
+		return leveldbcache.NewWithDB(db), db, nil
+
+	case "redis":
+		config, err := redisConfig(u)
+		if err != nil {
+			return nil, nil, err
+		}
+		cache, err := redis.New(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cache, closerOf(cache), nil
+
+	case "mongodb", "mongodb+srv":
+		config, err := mongoConfig(u, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		cache, err := mongodb.New(ctx, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cache, closerOf(cache), nil
+
+	default:
+		return nil, nil, fmt.Errorf("%w %q: supported schemes are mem, disk, leveldb, redis and mongodb", ErrUnknownScheme, u.Scheme)
+	}
+}
+
+// localPath returns the filesystem path of a disk or leveldb DSN, such as
+// disk:///var/cache/app or disk://./cache for a relative path
+func localPath(u *url.URL) (string, error) {
+	if err := noParams(u); err != nil {
+		return "", err
+	}
+	path := u.Host + u.Path
+	if path == "" {
+		return "", fmt.Errorf("cache DSN %s:// requires a path", u.Scheme)
+	}
+	return path, nil
+}
+
+// redisConfig builds the redis configuration from a DSN such as
+// redis://:password@host:6379/2
+func redisConfig(u *url.URL) (redis.Config, error) {
+	if err := noParams(u); err != nil {
+		return redis.Config{}, err
+	}
+	config := redis.Config{Address: u.Host}
+	if config.Address == "" {
+		return config, fmt.Errorf("cache DSN redis:// requires a host")
+	}
+	if u.Port() == "" {
+		config.Address += ":6379"
+	}
+	if u.User != nil {
+		config.Password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil || n < 0 {
+			return config, fmt.Errorf("invalid redis database %q in cache DSN", db)
+		}
+		config.DB = n
+	}
+	return config, nil
+}
+
+// mongoConfig builds the MongoDB configuration from a DSN such as
+// mongodb://host:27017/database?collection=name. Parameters other than
+// collection and key_prefix are passed on in the connection URI.
+func mongoConfig(u *url.URL, opts options) (mongodb.Config, error) {
+	database := strings.Trim(u.Path, "/")
+	if database == "" {
+		return mongodb.Config{}, fmt.Errorf("cache DSN %s:// requires a database path", u.Scheme)
+	}
+
+	query := u.Query()
+	config := mongodb.Config{
+		Database:   database,
+		Collection: query.Get("collection"),
+		KeyPrefix:  query.Get("key_prefix"),
+		TTL:        opts.ttl,
+	}
+	query.Del("collection")
+	query.Del("key_prefix")
+
+	uri := *u
+	uri.Path = "/"
+	uri.RawQuery = query.Encode()
+	config.URI = uri.String()
+	return config, nil
+}
+
+// closerOf returns cache as an io.Closer, or a no-op closer if it holds no resources
+func closerOf(cache httpcache.Cache) io.Closer {
+	if closer, ok := cache.(io.Closer); ok {
+		return closer
+	}
+	return nopCloser{}
+}
+
+// noParams returns an error if u has query parameters the backend doesn't support
+func noParams(u *url.URL) error {
+	if u.RawQuery != "" {
+		return fmt.Errorf("unsupported parameters for cache DSN %s://: %s", u.Scheme, u.RawQuery)
+	}
+	return nil
+}
@@ -128,7 +128,7 @@ func main() {
 	fmt.Println("   sum by (cache_status) (httpcache_http_requests_total)")
 	fmt.Println()
 	fmt.Println("4. Total bandwidth saved:")
-	fmt.Println("   httpcache_http_response_size_bytes_total{cache_status=\"hit\"}")
+	fmt.Println("   httpcache_bytes_saved_total")
 
 	fmt.Println("\n\nPress Ctrl+C to exit (server will keep running)")
 
@@ -0,0 +1,35 @@
+package httpcache
+
+import "net/http"
+
+// fillTransport applies FillRequestHeaders to the requests sent to the origin
+// for a cacheable request, on a copy so the caller's request is never modified
+type fillTransport struct {
+	next http.RoundTripper
+	fill func(*http.Request)
+}
+
+func (f fillTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	upstream := req.Clone(req.Context())
+	f.fill(upstream)
+	resp, err := f.next.RoundTrip(upstream)
+	if resp != nil {
+		// Hide the filled request from the caller
+		resp.Request = req
+	}
+	return resp, err
+}
+
+// upstreamTransport returns the RoundTripper used to reach the origin, applying
+// MaxInFlightUpstream, and FillRequestHeaders when the request is cacheable
+func (t *Transport) upstreamTransport(cacheable bool) http.RoundTripper {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	transport = t.limitUpstream(transport)
+	if cacheable && t.FillRequestHeaders != nil {
+		return fillTransport{next: transport, fill: t.FillRequestHeaders}
+	}
+	return transport
+}
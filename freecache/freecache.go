@@ -12,6 +12,8 @@
 package freecache
 
 import (
+	"context"
+
 	"github.com/coocood/freecache"
 	"github.com/sandrolain/httpcache"
 )
@@ -39,6 +41,12 @@ func New(size int) *Cache {
 	}
 }
 
+// Name returns "freecache", the backend name reported with
+// httpcache.Transport.ServeDiagnostics
+func (c *Cache) Name() string {
+	return "freecache"
+}
+
 // Get returns the cached response bytes and true if present, false if not found
 func (c *Cache) Get(key string) ([]byte, bool) {
 	value, err := c.cache.Get([]byte(key))
@@ -69,6 +77,13 @@ func (c *Cache) Clear() {
 	c.cache.Clear()
 }
 
+// ClearWithContext removes all entries from the cache, like Clear. It
+// implements httpcache.Clearer and never fails.
+func (c *Cache) ClearWithContext(_ context.Context) error {
+	c.Clear()
+	return nil
+}
+
 // EntryCount returns the number of entries currently in the cache
 func (c *Cache) EntryCount() int64 {
 	return c.cache.EntryCount()
@@ -93,3 +108,30 @@ func (c *Cache) ExpiredCount() int64 {
 func (c *Cache) ResetStatistics() {
 	c.cache.ResetStatistics()
 }
+
+// Iterate calls fn for each entry in the cache until fn returns false or ctx is done.
+// Entries added or evicted during iteration may or may not be visited.
+func (c *Cache) Iterate(ctx context.Context, fn func(key string, value []byte) bool) error {
+	it := c.cache.NewIterator()
+	for entry := it.Next(); entry != nil; entry = it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fn(string(entry.Key), entry.Value) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// SaveToFile writes a snapshot of the cache to path, so it can be restored with
+// LoadFromFile after a restart
+func (c *Cache) SaveToFile(path string) error {
+	return httpcache.SaveSnapshotFile(context.Background(), c, path)
+}
+
+// LoadFromFile restores a snapshot written by SaveToFile into the cache.
+// Entries that don't fit in the cache size are evicted as usual.
+func (c *Cache) LoadFromFile(path string) error {
+	return httpcache.LoadSnapshotFile(path, c)
+}
@@ -1,10 +1,12 @@
 package freecache
 
 import (
+	"path/filepath"
 	"strconv"
 	"testing"
 
 	"github.com/sandrolain/httpcache"
+	"github.com/sandrolain/httpcache/test"
 )
 
 func TestFreecacheImplementsCache(t *testing.T) {
@@ -89,6 +91,10 @@ func TestClear(t *testing.T) {
 	}
 }
 
+func TestClearWithContext(t *testing.T) {
+	test.Clear(t, New(1024*1024))
+}
+
 func TestEntryCount(t *testing.T) {
 	cache := New(1024 * 1024)
 
@@ -202,3 +208,34 @@ func TestConcurrentAccess(t *testing.T) {
 		t.Error("Cache should work correctly after concurrent access")
 	}
 }
+
+func TestFreecacheImplementsIterable(t *testing.T) {
+	var _ httpcache.Iterable = &Cache{}
+}
+
+func TestSaveAndLoadFromFile(t *testing.T) {
+	cache := New(1024 * 1024)
+	for i := 0; i < 10; i++ {
+		cache.Set("key"+strconv.Itoa(i), []byte("value"+strconv.Itoa(i)))
+	}
+
+	path := filepath.Join(t.TempDir(), "freecache.snapshot")
+	if err := cache.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+
+	restored := New(1024 * 1024)
+	if err := restored.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+
+	if restored.EntryCount() != 10 {
+		t.Fatalf("EntryCount() = %d, want 10", restored.EntryCount())
+	}
+	for i := 0; i < 10; i++ {
+		value, ok := restored.Get("key" + strconv.Itoa(i))
+		if !ok || string(value) != "value"+strconv.Itoa(i) {
+			t.Errorf("key%d: got %q (present=%v)", i, value, ok)
+		}
+	}
+}
@@ -9,6 +9,7 @@ require (
 	github.com/coocood/freecache v1.2.4
 	github.com/golang/snappy v1.0.0
 	github.com/gomodule/redigo v1.9.3
+	github.com/hashicorp/golang-lru/v2 v2.0.7
 	github.com/hazelcast/hazelcast-go-client v1.4.3
 	github.com/jackc/pgx/v5 v5.9.0
 	github.com/nats-io/nats-server/v2 v2.14.2
@@ -26,6 +27,7 @@ require (
 	go.mongodb.org/mongo-driver v1.17.6
 	gocloud.dev v0.43.0
 	golang.org/x/crypto v0.52.0
+	golang.org/x/sync v0.20.0
 )
 
 require (
@@ -124,7 +126,6 @@ require (
 	go.opentelemetry.io/otel/trace v1.44.0 // indirect
 	go.yaml.in/yaml/v2 v2.4.3 // indirect
 	golang.org/x/net v0.55.0 // indirect
-	golang.org/x/sync v0.20.0 // indirect
 	golang.org/x/sys v0.45.0 // indirect
 	golang.org/x/text v0.37.0 // indirect
 	golang.org/x/time v0.15.0 // indirect
@@ -0,0 +1,28 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	headerGRPCStatus = "Grpc-Status"
+	grpcContentType  = "application/grpc"
+	grpcStatusOK     = "0"
+)
+
+// GRPCStatusOK is a ShouldCacheTrailers classifier for unary gRPC responses.
+// gRPC reports failures with an HTTP 200 and a non-zero grpc-status trailer, so
+// only responses whose grpc-status is 0 are cached. The status is read from the
+// trailers, or from the headers for trailers-only responses. Responses that are
+// not gRPC (by Content-Type) are always allowed.
+func GRPCStatusOK(resp *http.Response) bool {
+	if !strings.HasPrefix(strings.ToLower(resp.Header.Get("Content-Type")), grpcContentType) {
+		return true
+	}
+	status := resp.Trailer.Get(headerGRPCStatus)
+	if status == "" {
+		status = resp.Header.Get(headerGRPCStatus)
+	}
+	return strings.TrimSpace(status) == grpcStatusOK
+}
@@ -21,6 +21,12 @@ func cacheKey(key string) string {
 	return "httpcache:" + key
 }
 
+// Name returns "hazelcast", the backend name reported with
+// httpcache.Transport.ServeDiagnostics.
+func (c cache) Name() string {
+	return "hazelcast"
+}
+
 // Get returns the response corresponding to key if present.
 func (c cache) Get(key string) (resp []byte, ok bool) {
 	val, err := c.m.Get(c.ctx, cacheKey(key))
@@ -0,0 +1,59 @@
+package httpcache
+
+import (
+	"net/http"
+)
+
+// updateCacheFromHead uses a HEAD response from the origin to update the stored
+// GET response for the same resource (RFC 9111 Section 4.3.5), when
+// UpdateCacheFromHead is set.
+//
+// If the HEAD response carries the same validators (and Content-Length) as the
+// stored response, its headers are merged into the stored entry, keeping the
+// body. If they differ, the stored body belongs to an older representation: the
+// entry is removed rather than given validators that don't describe it.
+func (t *Transport) updateCacheFromHead(req *http.Request, headResp *http.Response) {
+	if !t.UpdateCacheFromHead || req.Method != methodHEAD || headResp.StatusCode != http.StatusOK {
+		return
+	}
+
+	getReq := cloneRequest(req)
+	getReq.Method = methodGET
+	cachedResp, key, err := t.lookupCachedResponse(getReq, t.requestCacheKey(getReq))
+	if err != nil || cachedResp == nil {
+		return
+	}
+	if !varyMatches(cachedResp, getReq) {
+		_ = cachedResp.Body.Close()
+		return
+	}
+
+	if !headMatchesStored(cachedResp, headResp) {
+		_ = cachedResp.Body.Close()
+		t.deleteEntry(key)
+		GetLogger().Debug("HEAD response changed validators, invalidated cached GET", "key", key)
+		return
+	}
+
+	for _, header := range getEndToEndHeaders(headResp.Header) {
+		if header == "Content-Length" {
+			continue
+		}
+		cachedResp.Header[header] = headResp.Header[header]
+	}
+	t.storeCachedResponse(cachedResp, getReq, key)
+	_ = cachedResp.Body.Close()
+	GetLogger().Debug("updated cached GET headers from HEAD response", "key", key)
+}
+
+// headMatchesStored reports whether a HEAD response describes the same
+// representation as the stored GET response: its validators must match, as for
+// a 304, and a Content-Length present on both must be equal
+func headMatchesStored(cachedResp, headResp *http.Response) bool {
+	if !notModifiedMatches(cachedResp, headResp) {
+		return false
+	}
+	headLength := headResp.Header.Get("Content-Length")
+	storedLength := cachedResp.Header.Get("Content-Length")
+	return headLength == "" || storedLength == "" || headLength == storedLength
+}
@@ -20,6 +20,8 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,12 +38,42 @@ const (
 	XStale = "X-Stale"
 	// XFreshness is the header added to responses indicating the freshness state
 	XFreshness = "X-Cache-Freshness"
+	// XCacheTTL is the header added to responses served from cache reporting the
+	// remaining freshness lifetime in seconds (negative once stale)
+	XCacheTTL = "X-Cache-TTL"
 	// XCachedTime is the internal header used to store when a response was cached
 	XCachedTime = "X-Cached-Time"
 	// XRequestTime stores when the HTTP request was initiated (for Age calculation per RFC 9111)
 	XRequestTime = "X-Request-Time"
 	// XResponseTime stores when the HTTP response was received (for Age calculation per RFC 9111)
 	XResponseTime = "X-Response-Time"
+	// XCacheInitialAge is the internal header used to store, in seconds, the age
+	// a response fetched through another cache already had when it was received:
+	// its Age header plus the response delay (RFC 9111 Section 4.2.3)
+	XCacheInitialAge = "X-Cache-Initial-Age"
+	// XCacheLifetime is the internal header used to store a freshness lifetime
+	// override in seconds, such as the one set with WithRequestTTL
+	XCacheLifetime = "X-Cache-Lifetime"
+	// XCacheHardTTL is the internal header used to store the hard TTL in seconds
+	// set with Transport.HardTTL: the age after which the entry is no longer served
+	XCacheHardTTL = "X-Cache-Hard-TTL"
+	// XCacheSensitive is the internal header used to tag stored entries holding
+	// user-specific data (Cache-Control: private, or requests with Authorization),
+	// so cache wrappers can refuse to persist them (see IsSensitiveEntry)
+	XCacheSensitive = "X-Cache-Sensitive"
+	// XCachePlaintext is the internal header used to tag stored entries that
+	// Transport.ShouldEncrypt exempted from encryption, so encrypting cache
+	// wrappers store them as is (see IsPlaintextEntry)
+	XCachePlaintext = "X-Cache-Plaintext"
+	// XCacheBackend is the header added with ServeDiagnostics to responses served
+	// from cache, naming the backend that served them
+	XCacheBackend = "X-Cache-Backend"
+	// XCacheTier is the header added with ServeDiagnostics to responses served
+	// from a tiered cache, with the index of the tier that served them
+	XCacheTier = "X-Cache-Tier"
+	// XCacheKeyHash is the header added with ServeDiagnostics to responses served
+	// from cache, with a prefix of the SHA-256 hash of their cache key
+	XCacheKeyHash = "X-Cache-Key-Hash"
 
 	methodGET    = "GET"
 	methodHEAD   = "HEAD"
@@ -54,6 +86,7 @@ const (
 	headerLastModified    = "last-modified"
 	headerETag            = "etag"
 	headerAge             = "Age"
+	headerDate            = "Date"
 	headerWarning         = "Warning"
 	headerLocation        = "Location"
 	headerContentLocation = "Content-Location"
@@ -117,6 +150,57 @@ type Cache interface {
 	Delete(key string)
 }
 
+// Iterable is an optional interface implemented by caches that can enumerate
+// their entries. Features that need to walk the whole cache (such as ListURLs)
+// require the backend to implement it.
+type Iterable interface {
+	// Iterate calls fn for each entry in the cache, stopping early if fn
+	// returns false or ctx is done. Keys are passed as stored by the backend.
+	Iterate(ctx context.Context, fn func(key string, value []byte) bool) error
+}
+
+// Snapshot is a read-only, point-in-time view of a cache: iterating it sees the
+// entries as they were when it was taken, whatever writes happen meanwhile.
+// Release must be called once it is no longer needed.
+type Snapshot interface {
+	Iterable
+	Release()
+}
+
+// SnapshotCache is an optional interface implemented by caches that can take a
+// consistent Snapshot, such as LevelDB. Features walking the whole cache (ListURLs,
+// Vacuum, WriteSnapshot) iterate a snapshot when available, and fall back to the
+// best-effort Iterate otherwise, which may miss or repeat entries written
+// concurrently.
+type SnapshotCache interface {
+	Snapshot() (Snapshot, error)
+}
+
+// FallibleCache is an optional interface implemented by caches whose operations
+// can fail, such as network backends. The Cache methods log and swallow those
+// errors; these variants return them so wrappers (e.g. metrics) can surface
+// backend failures. A missing key is reported as ok == false with a nil error.
+type FallibleCache interface {
+	GetWithError(key string) (responseBytes []byte, ok bool, err error)
+	SetWithError(key string, responseBytes []byte) error
+	DeleteWithError(key string) error
+}
+
+// ContextCache is an optional interface implemented by caches whose reads can
+// depend on the request, such as a multi-tier cache skipping an expensive tier
+// for unimportant requests. The Transport calls GetWithContext with the request
+// context instead of Get.
+type ContextCache interface {
+	GetWithContext(ctx context.Context, key string) (responseBytes []byte, ok bool)
+}
+
+// AddCache is an optional interface implemented by caches that can store an
+// entry only if its key is absent, atomically where the backend supports it
+// (e.g. Redis SET NX). Add reports whether the entry was stored.
+type AddCache interface {
+	Add(key string, responseBytes []byte) bool
+}
+
 // cacheKey returns the cache key for req.
 func cacheKey(req *http.Request) string {
 	if req.Method == http.MethodGet {
@@ -128,7 +212,7 @@ func cacheKey(req *http.Request) string {
 
 // cacheKeyWithHeaders returns the cache key for req, including specified header values.
 // This is used when CacheKeyHeaders is configured to differentiate cache entries
-// based on request header values.
+// based on request header values. Credential headers are included hashed.
 func cacheKeyWithHeaders(req *http.Request, headers []string) string {
 	key := cacheKey(req)
 
@@ -139,7 +223,7 @@ func cacheKeyWithHeaders(req *http.Request, headers []string) string {
 			canonicalHeader := http.CanonicalHeaderKey(header)
 			value := req.Header.Get(canonicalHeader)
 			if value != "" {
-				headerParts = append(headerParts, canonicalHeader+":"+value)
+				headerParts = append(headerParts, canonicalHeader+":"+keyHeaderValue(canonicalHeader, value))
 			}
 		}
 		if len(headerParts) > 0 {
@@ -152,17 +236,23 @@ func cacheKeyWithHeaders(req *http.Request, headers []string) string {
 	return key
 }
 
-// cacheKeyWithVary returns the cache key for req, including Vary header values from the cached response.
-// This implements RFC 9111 vary separation: separate cache entries for each variant.
-// The varyHeaders parameter contains the list of headers specified in the Vary response header.
-// RFC 9111 Section 4.1: Header values are normalized before inclusion in the cache key.
-func cacheKeyWithVary(req *http.Request, varyHeaders []string) string {
-	key := cacheKey(req)
+// varyKeyMarker separates the key of a request from the Vary header values in
+// the cache key of one of its variants
+const varyKeyMarker = "|vary:"
 
-	if len(varyHeaders) == 0 {
-		return key
-	}
+// variantCacheKey returns the cache key of the variant of req selected by the
+// Vary headers of the cached response: the full key of req, with its credential,
+// cookie, header, negotiation and partition components, followed by the Vary
+// header values. This implements RFC 9111 vary separation: separate cache
+// entries for each variant.
+func (t *Transport) variantCacheKey(req *http.Request, varyHeaders []string) string {
+	return t.requestCacheKey(req) + varyKeySuffix(req, varyHeaders)
+}
 
+// varyKeySuffix returns the part of a variant cache key holding the values of
+// the varyHeaders of req, or "" if there are none.
+// RFC 9111 Section 4.1: Header values are normalized before inclusion in the cache key.
+func varyKeySuffix(req *http.Request, varyHeaders []string) string {
 	// Collect vary header values from the request
 	var varyParts []string
 	for _, header := range varyHeaders {
@@ -171,32 +261,34 @@ func cacheKeyWithVary(req *http.Request, varyHeaders []string) string {
 			continue
 		}
 
-		value := req.Header.Get(canonicalHeader)
-		// RFC 9111 Section 4.1: Normalize value before including in cache key
-		normalizedValue := normalizeHeaderValue(value)
 		// Include even empty values to ensure proper cache separation
-		varyParts = append(varyParts, canonicalHeader+":"+normalizedValue)
+		varyParts = append(varyParts, canonicalHeader+":"+varyKeyValue(req, canonicalHeader))
 	}
-
-	if len(varyParts) > 0 {
-		// Sort to ensure consistent key generation
-		sort.Strings(varyParts)
-		key = key + "|vary:" + strings.Join(varyParts, "|")
+	if len(varyParts) == 0 {
+		return ""
 	}
 
-	return key
+	// Sort to ensure consistent key generation
+	sort.Strings(varyParts)
+	return varyKeyMarker + strings.Join(varyParts, "|")
+}
+
+// varyKeyValue returns the form of the value of the Vary header canonicalHeader
+// of req used in variant cache keys.
+// RFC 9111 Section 4.1: the value is normalized before inclusion in the cache key.
+func varyKeyValue(req *http.Request, canonicalHeader string) string {
+	return keyHeaderValue(canonicalHeader, normalizeHeaderValue(req.Header.Get(canonicalHeader)))
 }
 
 // CachedResponse returns the cached http.Response for req if present, and nil
-// otherwise.
+// otherwise. See CachedResponseE to tell a miss apart from an error.
 func CachedResponse(c Cache, req *http.Request) (resp *http.Response, err error) {
 	cachedVal, ok := c.Get(cacheKey(req))
 	if !ok {
 		return
 	}
 
-	b := bytes.NewBuffer(cachedVal)
-	return http.ReadResponse(bufio.NewReader(b), req)
+	return readCachedResponse(cachedVal, req)
 }
 
 // cachedResponseWithKey returns the cached http.Response for the given cache key if present, and nil otherwise.
@@ -207,8 +299,27 @@ func cachedResponseWithKey(c Cache, req *http.Request, key string) (resp *http.R
 		return
 	}
 
-	b := bytes.NewBuffer(cachedVal)
-	return http.ReadResponse(bufio.NewReader(b), req)
+	return readCachedResponse(cachedVal, req)
+}
+
+// cachedResponseWithKey returns the cached http.Response for the given cache key,
+// using the ParsedResponseCache when configured
+func (t *Transport) cachedResponseWithKey(req *http.Request, key string) (*http.Response, error) {
+	cachedVal, ok, maybeStale := t.getCached(req.Context(), key)
+	if !ok {
+		return nil, nil
+	}
+	var resp *http.Response
+	var err error
+	if t.ParsedResponseCache == nil {
+		resp, err = readCachedResponse(cachedVal, req)
+	} else {
+		resp, err = t.ParsedResponseCache.readResponse(key, cachedVal, req)
+	}
+	if err == nil && maybeStale {
+		resp.Header.Set(xMaybeStale, "1")
+	}
+	return resp, err
 }
 
 // Transport is an implementation of http.RoundTripper that will return values from a cache
@@ -242,6 +353,12 @@ type Transport struct {
 	// Enable this for full RFC 9111 compliance with content negotiation (Accept-Language, Accept, etc.).
 	// Note: Enabling this may increase cache storage usage as each variant is stored separately.
 	EnableVarySeparation bool
+	// MaxVaryVariants, if positive, bounds the number of variants stored per URL
+	// with EnableVarySeparation: storing a new variant beyond it evicts the least
+	// recently stored ones, so a high-cardinality header such as Accept-Language
+	// can't multiply entries without limit. Variants are tracked in a manifest
+	// entry stored alongside the base entry.
+	MaxVaryVariants int
 	// ShouldCache allows configuring non-standard caching behaviour based on the response.
 	// If set, this function is called to determine whether a non-200 response should be cached.
 	// This enables caching of responses like 404 Not Found, 301 Moved Permanently, etc.
@@ -249,6 +366,12 @@ type Transport struct {
 	// The function receives the http.Response and should return true to cache it.
 	// Note: This only bypasses the status code check; Cache-Control headers are still respected.
 	ShouldCache func(*http.Response) bool
+	// ShouldCacheWithContext, if set, decides whether a response is cached using the
+	// request and its context too, e.g. a feature flag or tenant tier carried in the
+	// context. It takes precedence over ShouldCache and the default status code check,
+	// so it decides for 200 responses as well: return false to skip storing one.
+	// Cache-Control headers are still respected.
+	ShouldCacheWithContext func(ctx context.Context, req *http.Request, resp *http.Response) bool
 	// CacheKeyHeaders specifies additional request headers to include in the cache key generation.
 	// This allows creating separate cache entries based on request header values.
 	// Common use cases include "Authorization" for user-specific caches or "Accept-Language"
@@ -256,15 +379,388 @@ type Transport struct {
 	// Header names are case-insensitive and will be canonicalized.
 	// Example: []string{"Authorization", "Accept-Language"}
 	// Note: This is different from the HTTP Vary response header mechanism, which is handled separately.
+	// See VaryByHeaders for resources that vary by a header but whose origin omits Vary.
 	CacheKeyHeaders []string
+	// VaryByHeaders declares request headers that resources vary by, for origins that
+	// forget to send the corresponding Vary header (e.g. content negotiated on Accept).
+	// It is the implicit-Vary counterpart of CacheKeyHeaders: the header values are
+	// included in the cache key in the same way, and the header names are also added
+	// to the Vary header of responses returned to the client, so downstream caches
+	// keep the variants apart too. The synthesized Vary is not stored, so it doesn't
+	// enable EnableVarySeparation lookups.
+	// Example: []string{"Accept"}
+	VaryByHeaders []string
+	// NegotiationHeaders declares request headers the origin negotiates content on
+	// without sending Vary, like VaryByHeaders, but only their canonical value is
+	// included in the cache key: for Accept, Accept-Language, Accept-Encoding and
+	// Accept-Charset, the most preferred element (e.g. "application/json" for
+	// "application/xml;q=0.9, application/json"), so requests with equivalent
+	// preferences share an entry. The header names are added to the Vary header
+	// of responses returned to the client.
+	// Example: []string{"Accept"}
+	NegotiationHeaders []string
+	// BufferPool, if set, provides the buffers used to capture the bodies of
+	// responses being stored and to serialize them, reducing allocations when
+	// many small responses are cached. NewBufferPool returns one backed by a
+	// sync.Pool. The bytes passed to the Cache are always a private copy.
+	BufferPool BufferPool
+	// AuthorizationPolicy decides how the Authorization request header affects
+	// the cache's own decisions: the cache key, whether a shared cache may store
+	// the response and whether the entry is tagged as sensitive. The header is
+	// always forwarded upstream. Default: AuthorizationRFC.
+	AuthorizationPolicy AuthorizationPolicy
+	// CacheKeyCookies lists cookies whose values are included in the cache key,
+	// for responses that legitimately vary by a cookie such as a feature flag.
+	// Unlike listing Cookie in CacheKeyHeaders, other cookies don't affect the key
+	// (they are still sent to the origin). Names are case-sensitive; values are
+	// hashed in the key. Requests without any listed cookie share the plain key.
+	// Example: []string{"beta_features"}
+	CacheKeyCookies []string
+	// ForceVaryHeaders declares request headers that are added to the stored Vary
+	// header of cacheable responses, as if the origin had listed them. Unlike
+	// VaryByHeaders, the augmented Vary is stored, so with EnableVarySeparation each
+	// value gets its own variant entry; without it, a request with a different value
+	// doesn't match the stored entry and replaces it. The headers are also part of
+	// the Vary header served to clients.
+	// Example: []string{"X-Client-Version"}
+	ForceVaryHeaders []string
 	// DisableWarningHeader disables the deprecated Warning header (RFC 7234) in responses.
 	// RFC 9111 has obsoleted the Warning header field, making it no longer part of the standard.
 	// When true, Warning headers (110, 111, etc.) will not be added to cached responses.
 	// Default is false (Warning headers are enabled for backward compatibility).
 	// Set to true to comply with RFC 9111 and avoid deprecated headers.
 	DisableWarningHeader bool
+	// StoreURLMetadata stores the original request URL as a sidecar entry next to
+	// each cached response, so that ListURLs can enumerate what is cached. The
+	// sidecar is written once its entry is stored and deleted along with it.
+	// Default is false.
+	//
+	// WARNING: this weakens enumeration resistance. The URL is stored as a regular
+	// cache value, so even when keys are hashed, anyone who can read the values in
+	// the backend can see which URLs were requested (wrappers such as securecache
+	// encrypt it like any other value when encryption is enabled). Only enable it
+	// for private, non-shared caches in trusted environments, and never in shared
+	// caches (IsPublicCache).
+	StoreURLMetadata bool
+	// KeyCardinalityMonitor, if set, is notified of every stored cache key and warns
+	// when a single URL accumulates too many distinct keys within a time window
+	// (typically a high-cardinality header in CacheKeyHeaders or Vary).
+	KeyCardinalityMonitor *KeyCardinalityMonitor
+	// WindowStats, if set, records every cacheable request and the body bytes served
+	// from cache, to report hit rate, bytes saved and request rate over recent time
+	// windows. See NewWindowStats.
+	WindowStats *WindowStats
+	// ServeRangeFromCache enables answering single-range "bytes=" Range requests from a
+	// complete, fresh 200 entry without contacting the origin (default: false).
+	// The cached body is sliced into a 206 Partial Content response with the correct
+	// Content-Range and Content-Length; unsatisfiable ranges get a 416 response.
+	// Requests with If-Range, multiple ranges or no usable entry are forwarded as usual.
+	ServeRangeFromCache bool
+	// DisableAgeHeader stops the cache from setting the Age header on responses served
+	// from cache (default: false). The age is still computed internally for freshness.
+	// Use this when downstream proxies misbehave on seeing an Age header from what
+	// they consider an origin server.
+	DisableAgeHeader bool
+	// RewriteDateOnServe sets the Date of responses served from cache to the
+	// current time minus their Age (default: false), so the two stay consistent for
+	// strict clients that reject an old Date. The stored Date is left unchanged.
+	RewriteDateOnServe bool
+	// PartitionKeyFunc, if set, returns a partition identifier for each request that is
+	// prepended to its cache key, so the same URL requested under different partitions
+	// yields isolated entries (similar to browser cache double-keying).
+	// An empty partition leaves the key unchanged. See PartitionByOrigin.
+	//
+	// A shared cache serving several top-level sites should partition by requesting site
+	// to prevent cross-site leaks, e.g. timing attacks revealing whether another site
+	// already fetched a resource. Unsafe-method invalidation only affects the partition
+	// of the invalidating request.
+	PartitionKeyFunc func(*http.Request) string
+	// ServeFilter, if set, is called on every response served from cache (fresh hits,
+	// stale serves and revalidated entries) just before it is returned to the client.
+	// It can modify the response, e.g. to inject a fresh Date or a custom header.
+	// Changes only affect the served response, never the stored entry.
+	ServeFilter func(*http.Response)
+	// ClientCacheControl, if set, replaces the Cache-Control header of the responses
+	// returned to the client (cache hits and misses alike), e.g. "max-age=60" so
+	// downstream clients revalidate with this cache more often than its own
+	// s-maxage allows. The stored entry and the cache's freshness decisions keep
+	// the origin's Cache-Control. Responses marked no-store or private are left
+	// unchanged, as they must not be made cacheable downstream.
+	ClientCacheControl string
+	// FallbackResponse, if set, is called when the request fails with an error and no
+	// cached response could be served in its place (e.g. via stale-if-error).
+	// The returned response, typically a branded 503 page, is returned instead of
+	// the error and is never stored. Returning nil keeps the original error.
+	FallbackResponse func(*http.Request, error) *http.Response
+	// FillRequestHeaders, if set, is called on a copy of every request the cache
+	// sends to the origin for a cacheable request (misses, revalidations and
+	// background refreshes), e.g. to add an internal X-Cache-Fill header or an
+	// origin auth token. The client's request, the cache key and Vary matching
+	// are unaffected, and the headers are never seen by the client.
+	FillRequestHeaders func(*http.Request)
+	// OnCacheHit, if set, is called with each request of a cacheable method that is
+	// answered from cache (fresh hits, stale serves and revalidated entries) just
+	// before the response is returned. It runs on the request path, so keep it fast.
+	OnCacheHit func(*http.Request)
+	// OnCacheMiss, if set, is called with each request of a cacheable method that
+	// could not be answered from cache, including requests that then failed.
+	OnCacheMiss func(*http.Request)
+	// DryRunStore, if true, makes the Transport decide what to store as usual but
+	// never write to the backend, to tune caching rules against real traffic:
+	// each entry that would be stored is logged at Info level, with its key,
+	// size and freshness lifetime, and reported to OnDryRunStore. Entries are
+	// not deleted when a response can't be stored either. The cache is still
+	// read, and entries are still invalidated, e.g. by unsafe methods.
+	DryRunStore bool
+	// OnDryRunStore, if set, is called with DryRunStore for each entry that would
+	// be stored, with its cache key, its serialized size in bytes and its
+	// remaining freshness lifetime (negative if already stale). GET responses
+	// are reported once their body has been read.
+	OnDryRunStore func(key string, size int, ttl time.Duration)
+	// StripStoredHeaders lists response headers removed from entries before they are
+	// stored (default: none). The response returned for the current request keeps
+	// them. Use TraceHeaders to avoid serving a stale trace context from cache.
+	StripStoredHeaders []string
+	// ShouldCacheTrailers, if set, is called once the response body has been fully read,
+	// when resp.Trailer is populated, just before the entry is stored. Returning false
+	// skips storing it. Use it for protocols that report their outcome in trailers,
+	// e.g. GRPCStatusOK to only cache gRPC responses with grpc-status 0.
+	// Cache-Control and ShouldCache are still evaluated first.
+	ShouldCacheTrailers func(*http.Response) bool
+	// ParsedResponseCache, if set, keeps parsed cache entries in process memory so
+	// cache hits on unchanged entries skip re-parsing the stored status line and
+	// headers. See NewParsedResponseCache.
+	ParsedResponseCache *ParsedResponseCache
+	// QueryParamAllowlist, if set, restricts the query parameters that are part of the
+	// cache key to those listed. QueryParamDenylist excludes the listed parameters
+	// from the key, e.g. tracking parameters such as "utm_*" or "fbclid" that would
+	// otherwise fragment the cache. A trailing "*" matches by prefix. When both are
+	// set, a parameter must be allowed and not denied. The full URL is still sent
+	// upstream; only the cache key is affected.
+	QueryParamAllowlist []string
+	QueryParamDenylist  []string
+	// MaxServableAgeSinceStore, if positive, makes entries stored longer ago than
+	// this (according to X-Cached-Time) be treated as a cache miss, regardless of
+	// their HTTP freshness. This keeps a restarted process from serving entries a
+	// previous version stored long ago in a persistent backend. Entries without
+	// a valid X-Cached-Time are treated as too old.
+	MaxServableAgeSinceStore time.Duration
+	// ValidatorOnlyFreshness, if positive, is the freshness lifetime of stored
+	// responses that have a validator (ETag or Last-Modified) but no explicit
+	// freshness (max-age, s-maxage or Expires), which are otherwise stale at once
+	// and revalidated on every request. After it, they are revalidated as usual.
+	// Responses with no-cache are not affected, and WithRequestTTL takes precedence.
+	ValidatorOnlyFreshness time.Duration
+	// SoftTTL and HardTTL, if positive, set the lifetime of entries when they are
+	// stored, regardless of the origin's Cache-Control: an entry is fresh until it
+	// is SoftTTL old, then served stale while it is revalidated in the background
+	// (like stale-while-revalidate) until it is HardTTL old, after which it is
+	// treated as a miss. Without SoftTTL, the origin's lifetime is the soft one.
+	// The TTLs are stored with the entry (X-Cache-Lifetime, X-Cache-Hard-TTL), so
+	// changing them only affects entries stored afterwards. A WithRequestTTL
+	// lifetime takes precedence over SoftTTL. Responses with no-cache,
+	// must-revalidate or proxy-revalidate get no stale window. HardTTL must not be
+	// shorter than SoftTTL: a shorter HardTTL is raised to SoftTTL, so the entry
+	// is then missed as soon as it is no longer fresh, as with HardTTL == SoftTTL.
+	SoftTTL time.Duration
+	HardTTL time.Duration
+	// StatusTTLOverrides sets the freshness lifetime of stored responses by status
+	// code, regardless of their Cache-Control or Expires, e.g. an hour for 301
+	// redirects and 30 seconds for 404s. Statuses not listed keep the origin's
+	// lifetime; responses are only stored if their status is cacheable (see
+	// ShouldCache). A WithRequestTTL lifetime takes precedence, and responses
+	// with no-cache are still revalidated.
+	StatusTTLOverrides map[int]time.Duration
+	// HonorRetryAfter, if true, makes a 503 or 429 response with a Retry-After header
+	// open a window during which the origin is not contacted for the same cache
+	// key: stale entries are served if stale-if-error allows it, and otherwise the
+	// 503 or 429 is returned with the remaining Retry-After. Fresh entries are
+	// served as usual.
+	HonorRetryAfter bool
+	// UpdateCacheFromHead, if true, lets HEAD responses from the origin update the
+	// stored GET response for the same resource (RFC 9111 Section 4.3.5). When the
+	// validators match, the HEAD headers (e.g. Cache-Control, Expires, Date) are
+	// merged into the stored entry, keeping its body; when they differ, the stored
+	// entry describes an outdated representation and is removed.
+	UpdateCacheFromHead bool
+
+	// CacheableMethods lists the request methods whose responses may be stored and
+	// served from cache (default: GET and HEAD). Responses for other methods are
+	// keyed by method and URL, so the request body is not part of the key: only
+	// list an unsafe method such as POST for endpoints whose response depends on
+	// the URL alone. Unsafe methods keep invalidating the cached GET and HEAD
+	// entries for the URL whenever the request reaches the origin.
+	CacheableMethods []string
+
+	// VerifyContentDigest, if true, checks the body of a GET response served from
+	// cache against its Content-Digest header (sha-256 or sha-512, RFC 9530). A
+	// corrupted entry is removed and the request is sent to the origin as a miss.
+	// A 304 response carrying a different Content-Digest than the stored one is
+	// treated as referring to another representation, and the full response is
+	// fetched instead.
+	VerifyContentDigest bool
+
+	// MaybeStaleWindow, if positive, lets entries a MaybeStaleCache backend flags
+	// as possibly stale (e.g. read from a lagging replica during an outage) be
+	// served from cache with X-Stale: 1, without contacting the origin, as long as
+	// they expired less than MaybeStaleWindow ago. Older flagged entries are
+	// revalidated as usual. If zero (default), the flag is ignored.
+	MaybeStaleWindow time.Duration
+
+	// CacheLookupBudget, if positive, bounds how long a cache read made to look
+	// up a request may take, so a slow backend can't consume the whole deadline
+	// of the request. A read exceeding the budget is treated as a miss and the
+	// request goes to the origin with the rest of its deadline. A ContextCache
+	// is passed a context with the budget as deadline; reads of other caches
+	// keep running in the background until the backend returns. If zero
+	// (default), reads are not bounded.
+	CacheLookupBudget time.Duration
+
+	// CachePreflight, if true, caches responses to CORS preflight requests
+	// (OPTIONS with Origin and Access-Control-Request-Method), whatever
+	// CacheableMethods lists, to offload the origin. Preflight entries are keyed
+	// by URL, Origin and the requested method and headers, separately from GET,
+	// and Access-Control-Max-Age sets their freshness lifetime.
+	CachePreflight bool
+
+	// BodyTransformer, if set, rewrites the body of text responses (text/*,
+	// JSON, XML and JavaScript content types without a Content-Encoding) before
+	// they are stored, e.g. to minify them. Only the stored copy is affected: the
+	// response that triggered the store is served unchanged, and later hits get
+	// the transformed body with its Content-Length recomputed. If it returns an
+	// error, the original body is stored.
+	BodyTransformer func(contentType string, body []byte) ([]byte, error)
+
+	// ShouldEncrypt, if set, is called before a response is stored; when it
+	// returns false the entry is tagged with XCachePlaintext, and encrypting
+	// wrappers such as securecache store it without encryption, e.g. to spare
+	// the CPU on large public assets. Entries tagged as sensitive (see
+	// IsSensitiveEntry) are always encrypted. If nil, every entry is encrypted.
+	ShouldEncrypt func(req *http.Request, resp *http.Response) bool
+
+	// MarkCacheTTL, if true, adds the X-Cache-TTL header to responses served from
+	// cache, reporting the remaining freshness lifetime in seconds as computed for
+	// the freshness decision (negative once the response is stale). Useful for
+	// debugging TTL decisions.
+	MarkCacheTTL bool
+
+	// AsyncStaleRefresh, if true, serves an expired cached response that has no
+	// validators (ETag or Last-Modified) immediately, marked with X-Stale, while
+	// a full refetch updates the cache in the background, like
+	// stale-while-revalidate without requiring the directive. Responses marked
+	// no-cache or must-revalidate, and requests with their own Cache-Control or
+	// Pragma, are still refetched synchronously. AsyncRevalidateTimeout applies
+	// to the background refetch.
+	AsyncStaleRefresh bool
+
+	// KeyVersion, if set, is mixed into every cache key as a namespace, so
+	// changing it makes all entries stored under a previous version unreachable
+	// at once. Use it to keep environments (dev, staging, prod) or application
+	// releases sharing a backend from reading each other's entries, e.g. after
+	// a change in how responses are stored. See VersionFromBuildInfo.
+	KeyVersion string
+
+	// StreamSnapshot, if set, caches only the leading snapshot of matching
+	// streaming responses (e.g. Server-Sent Events) up to a boundary, and serves
+	// it to later requests as a complete response. See StreamSnapshot.
+	StreamSnapshot *StreamSnapshot
+
+	// StaleOnErrorStatus classifies the origin responses treated as errors for
+	// stale-if-error: when it returns true and the cached response allows it,
+	// the stale entry is served instead. If nil, DefaultStaleOnErrorStatus is
+	// used (5xx and 429). Combine with HonorRetryAfter to also stop contacting
+	// a rate-limiting origin until its Retry-After elapses.
+	StaleOnErrorStatus func(*http.Response) bool
+
+	// StaleIfError, if positive, is the stale-if-error window applied to cached
+	// responses when neither they nor the request carry a stale-if-error
+	// directive: on an origin error, an entry stale by less than this is served
+	// instead. Entries marked no-cache, must-revalidate or proxy-revalidate are
+	// never served this way.
+	StaleIfError time.Duration
+	// StaleIfErrorHosts overrides StaleIfError for specific hosts, keyed by
+	// lowercase hostname without port, e.g. a longer window for a flaky
+	// third-party API, or 0 to disable it for an internal service. A
+	// stale-if-error directive from the origin or the client still applies.
+	StaleIfErrorHosts map[string]time.Duration
+
+	// OnlyIfCachedRetryAfterMin and OnlyIfCachedRetryAfterMax, if Max is positive,
+	// add a Retry-After header to the 504 Gateway Timeout returned for
+	// only-if-cached requests that miss, with a random number of seconds between
+	// Min and Max. After a cache flush, many cache-only clients then spread their
+	// follow-up requests instead of retrying at once.
+	OnlyIfCachedRetryAfterMin time.Duration
+	OnlyIfCachedRetryAfterMax time.Duration
+
+	// MaxInFlightUpstream, if positive, is the maximum number of requests this
+	// Transport has in flight to the origin at the same time, counting each one
+	// until its response body is read to the end or closed. Requests over the
+	// limit wait for a slot, or until their context is done, unless
+	// UpstreamFailFast is set. Requests served from cache are never limited.
+	// Changing it after the first request has no effect.
+	MaxInFlightUpstream int
+	// UpstreamFailFast makes requests over MaxInFlightUpstream fail at once with
+	// ErrUpstreamLimit instead of waiting (stale-if-error and FallbackResponse
+	// still apply).
+	UpstreamFailFast bool
+
+	// DecisionLogSize, if positive, keeps the last DecisionLogSize cache
+	// decisions (URL, outcome, status, whether the response may be stored) in
+	// memory, returned by RecentDecisions, to diagnose why a request wasn't
+	// served from cache. Changing it after the first request has no effect.
+	DecisionLogSize int
+	// OnCacheDecision, if set, is called exactly once per RoundTrip, just before
+	// the response or error is returned, with the Decision describing how the
+	// request was handled: its cache key, the freshness of the entry found, the
+	// outcome and whether the response may be stored. Requests that bypass the
+	// cache are reported too. It runs synchronously on the request path, so keep
+	// it fast (e.g. hand the Decision to a logger or a channel).
+	OnCacheDecision func(*http.Request, Decision)
+
+	// ServeDiagnostics, if true, adds to every response served from cache the
+	// X-Cache-Backend header with the name of the backend that served it (see
+	// Named), X-Cache-Tier with the index of the tier that served it when the
+	// Cache is tiered (see ReportServingTier), and X-Cache-Key-Hash with a
+	// prefix of the SHA-256 hash of its cache key, to debug multi-backend
+	// setups. Headers whose value is unknown are left out.
+	ServeDiagnostics bool
+
+	// serveStale is the runtime toggle behind SetServeStaleMode
+	serveStale atomic.Bool
+	// retryAfter holds the Retry-After windows used by HonorRetryAfter
+	retryAfter retryAfterTracker
+	// bytesSaved counts the body bytes served from cache, see BytesSavedFromOrigin
+	bytesSaved atomic.Int64
+	// upstreamLimit enforces MaxInFlightUpstream
+	upstreamLimit upstreamLimiter
+	// decisions holds the decision log, see DecisionLogSize
+	decisions decisionLog
+	// variantsMu serializes the vary manifest updates of MaxVaryVariants
+	variantsMu sync.Mutex
+}
+
+// SetServeStaleMode switches serve-stale mode on or off at runtime. It is safe to
+// call while the Transport is serving requests.
+//
+// While enabled, any cached entry matching the request is served without contacting
+// the origin, even when expired or requiring revalidation, and is marked with
+// XStale (and Warning 110 unless DisableWarningHeader is set). Requests with no
+// cached entry still go upstream. This is an operational break-glass for planned
+// origin maintenance, independent of stale-if-error.
+func (t *Transport) SetServeStaleMode(enabled bool) {
+	t.serveStale.Store(enabled)
 }
 
+// ServeStaleMode reports whether serve-stale mode is enabled
+func (t *Transport) ServeStaleMode() bool {
+	return t.serveStale.Load()
+}
+
+// TraceHeaders are the W3C Trace Context and Baggage headers, which are specific
+// to a single request and should not be served from cache.
+// See Transport.StripStoredHeaders.
+var TraceHeaders = []string{"Traceparent", "Tracestate", "Baggage"}
+
 // NewTransport returns a new Transport with the
 // provided Cache implementation and MarkCachedResponses set to true
 func NewTransport(c Cache) *Transport {
@@ -276,6 +772,22 @@ func (t *Transport) Client() *http.Client {
 	return &http.Client{Transport: t}
 }
 
+// WrapClient adds caching to an existing client: its Transport, with its proxy
+// and TLS settings, becomes the underlying transport of a new Transport using
+// cache, which replaces it. The client is modified in place and returned; its
+// other settings, such as Timeout and Jar, are kept. A nil client is replaced by
+// a new one. The caching Transport can be configured through
+// client.Transport.(*httpcache.Transport).
+func WrapClient(client *http.Client, cache Cache) *http.Client {
+	if client == nil {
+		client = &http.Client{}
+	}
+	t := NewTransport(cache)
+	t.Transport = client.Transport
+	client.Transport = t
+	return client
+}
+
 // varyMatches will return false unless all of the cached values for the headers listed in Vary
 // match the new request
 func varyMatches(cachedResp *http.Response, req *http.Request) bool {
@@ -439,27 +951,28 @@ func (t *Transport) asyncRevalidate(req *http.Request) {
 }
 
 // handleCachedResponse processes a cached response based on its freshness
-// Returns the request (possibly modified with validators) and whether to use cache directly
-func (t *Transport) handleCachedResponse(cachedResp *http.Response, req *http.Request) (*http.Request, bool) {
+// Returns the request (possibly modified with validators), whether to use cache
+// directly and whether the entry is then served stale
+func (t *Transport) handleCachedResponse(cachedResp *http.Response, req *http.Request) (*http.Request, bool, bool) {
+	maybeStale := takeMaybeStaleTag(cachedResp)
 	if !varyMatches(cachedResp, req) {
-		return req, false
+		return req, false, false
 	}
 
 	// Don't serve server errors (5xx) from cache if SkipServerErrorsFromCache is enabled
 	if t.SkipServerErrorsFromCache && cachedResp.StatusCode >= http.StatusInternalServerError {
-		return req, false
+		return req, false, false
 	}
 
 	freshness := getFreshness(cachedResp.Header, req.Header)
+	t.addServedHeaders(cachedResp, freshness)
 
-	// Add freshness header if marking cached responses
-	if t.MarkCachedResponses {
-		cachedResp.Header.Set(XFreshness, freshnessString(freshness))
-	}
-
-	// Calculate and set Age header (RFC 7234 Section 4.2.3)
-	if age, err := calculateAge(cachedResp.Header); err == nil {
-		cachedResp.Header.Set(headerAge, formatAge(age))
+	// The backend couldn't confirm the entry is current: serve it as stale
+	if maybeStale && t.canServeMaybeStale(cachedResp) {
+		if t.MarkCachedResponses {
+			cachedResp.Header.Set(XStale, "1")
+		}
+		return req, true, true
 	}
 
 	if freshness == fresh {
@@ -468,7 +981,17 @@ func (t *Transport) handleCachedResponse(cachedResp *http.Response, req *http.Re
 			// RFC 7234 Section 5.5: Add Warning 110 (Response is Stale)
 			addStaleWarning(cachedResp)
 		}
-		return req, true
+		return req, true, false
+	}
+
+	if t.ServeStaleMode() {
+		if t.MarkCachedResponses {
+			cachedResp.Header.Set(XStale, "1")
+		}
+		if !t.DisableWarningHeader {
+			addStaleWarning(cachedResp)
+		}
+		return req, true, true
 	}
 
 	if freshness == staleWhileRevalidate {
@@ -478,18 +1001,76 @@ func (t *Transport) handleCachedResponse(cachedResp *http.Response, req *http.Re
 		}
 		// Trigger async revalidation
 		t.asyncRevalidate(req)
-		return req, true
+		return req, true, true
+	}
+
+	if freshness == stale && t.canRefreshStaleAsync(cachedResp, req) {
+		if t.MarkCachedResponses {
+			cachedResp.Header.Set(XStale, "1")
+		}
+		if !t.DisableWarningHeader {
+			addStaleWarning(cachedResp)
+		}
+		t.asyncRevalidate(req)
+		return req, true, true
 	}
 
 	if freshness == stale {
-		return addValidatorsToRequest(req, cachedResp), false
+		return addValidatorsToRequest(req, cachedResp), false, false
+	}
+
+	return req, false, false
+}
+
+// addServedHeaders adds to an entry about to be served from cache the headers
+// computed at serve time: X-Freshness, X-Cache-TTL and Age
+func (t *Transport) addServedHeaders(cachedResp *http.Response, freshness int) {
+	// Add freshness header if marking cached responses
+	if t.MarkCachedResponses {
+		cachedResp.Header.Set(XFreshness, freshnessString(freshness))
 	}
+	t.markCacheTTL(cachedResp)
 
-	return req, false
+	// Calculate and set Age header (RFC 7234 Section 4.2.3)
+	if !t.DisableAgeHeader {
+		setAgeHeader(cachedResp)
+	}
+}
+
+// canRefreshStaleAsync reports whether AsyncStaleRefresh lets the expired
+// cachedResp be served while it is refetched in the background
+func (t *Transport) canRefreshStaleAsync(cachedResp *http.Response, req *http.Request) bool {
+	if !t.AsyncStaleRefresh {
+		return false
+	}
+	// Entries with validators are revalidated cheaply with a conditional request
+	if cachedResp.Header.Get(headerETag) != "" || cachedResp.Header.Get(headerLastModified) != "" {
+		return false
+	}
+	// The client asked for specific freshness, e.g. max-age=0 or no-cache
+	if req.Header.Get("Cache-Control") != "" || req.Header.Get(headerPragma) != "" {
+		return false
+	}
+	respCacheControl := parseCacheControl(cachedResp.Header)
+	for _, directive := range []string{cacheControlNoCache, cacheControlMustRevalidate, "proxy-revalidate"} {
+		if _, ok := respCacheControl[directive]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// setAgeHeader calculates and sets the Age header on a response served from cache
+func setAgeHeader(resp *http.Response) {
+	if age, err := calculateAge(resp.Header); err == nil {
+		resp.Header.Set(headerAge, formatAge(age))
+	}
 }
 
 // handleNotModifiedResponse updates the cached response with new headers from a 304 response
-func handleNotModifiedResponse(cachedResp *http.Response, newResp *http.Response, markRevalidated bool) *http.Response {
+func handleNotModifiedResponse(cachedResp *http.Response, newResp *http.Response, markRevalidated, setAge bool) *http.Response {
+	// The age a 304 arrives with replaces the one of the stored response
+	cachedResp.Header.Del(XCacheInitialAge)
 	endToEndHeaders := getEndToEndHeaders(newResp.Header)
 	for _, header := range endToEndHeaders {
 		cachedResp.Header[header] = newResp.Header[header]
@@ -499,27 +1080,77 @@ func handleNotModifiedResponse(cachedResp *http.Response, newResp *http.Response
 	}
 
 	// Recalculate and update Age header after revalidation (RFC 7234 Section 4.2.3)
-	if age, err := calculateAge(cachedResp.Header); err == nil {
-		cachedResp.Header.Set(headerAge, formatAge(age))
+	if setAge {
+		setAgeHeader(cachedResp)
 	}
 
 	return cachedResp
 }
 
+// notModifiedMatches reports whether a 304 response selects the stored response
+// (RFC 9111 Section 4.3.4). Servers may echo only one of the validators sent, or
+// none at all, so a validator is only compared when both responses carry it:
+// the ETag first (weak comparison), otherwise Last-Modified.
+func notModifiedMatches(cachedResp, notModified *http.Response) bool {
+	newETag := notModified.Header.Get(headerETag)
+	storedETag := cachedResp.Header.Get(headerETag)
+	if newETag != "" && storedETag != "" {
+		return strings.TrimPrefix(newETag, "W/") == strings.TrimPrefix(storedETag, "W/")
+	}
+
+	newLastModified := notModified.Header.Get(headerLastModified)
+	storedLastModified := cachedResp.Header.Get(headerLastModified)
+	if newLastModified != "" && storedLastModified != "" {
+		newTime, newErr := parseHTTPDate(newLastModified)
+		storedTime, storedErr := parseHTTPDate(storedLastModified)
+		if newErr != nil || storedErr != nil {
+			return newLastModified == storedLastModified
+		}
+		return newTime.Equal(storedTime)
+	}
+
+	return true
+}
+
 // shouldReturnStaleOnError checks if a stale cached response should be returned due to an error
-func shouldReturnStaleOnError(err error, resp *http.Response, cachedResp *http.Response, req *http.Request) bool {
+func (t *Transport) shouldReturnStaleOnError(err error, resp *http.Response, cachedResp *http.Response, req *http.Request) bool {
 	if req.Method != methodGET || cachedResp == nil {
 		return false
 	}
 
 	hasError := err != nil
-	hasServerError := resp != nil && resp.StatusCode >= 500
+	hasErrorStatus := resp != nil && t.isStaleOnErrorStatus(resp)
 
-	if !hasError && !hasServerError {
+	if !hasError && !hasErrorStatus {
 		return false
 	}
 
-	return canStaleOnError(cachedResp.Header, req.Header)
+	// RFC 9111 Section 5.2.2.2: a must-revalidate response is never served stale,
+	// even when stale-if-error allows it; the error is returned instead
+	if _, mustRevalidate := parseCacheControl(cachedResp.Header)[cacheControlMustRevalidate]; mustRevalidate {
+		return false
+	}
+
+	if hasStaleIfError(cachedResp.Header) || hasStaleIfError(req.Header) {
+		return canStaleOnError(cachedResp.Header, req.Header)
+	}
+	return t.canStaleOnErrorByDefault(cachedResp, req)
+}
+
+// isStaleOnErrorStatus reports whether resp counts as an origin error for
+// stale-if-error, per StaleOnErrorStatus or DefaultStaleOnErrorStatus
+func (t *Transport) isStaleOnErrorStatus(resp *http.Response) bool {
+	if t.StaleOnErrorStatus != nil {
+		return t.StaleOnErrorStatus(resp)
+	}
+	return DefaultStaleOnErrorStatus(resp)
+}
+
+// DefaultStaleOnErrorStatus is the default StaleOnErrorStatus classifier: server
+// errors (5xx) and 429 Too Many Requests, where a stale response is preferable
+// to propagating the rate limit.
+func DefaultStaleOnErrorStatus(resp *http.Response) bool {
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
 }
 
 // performRequest executes the HTTP request using the provided transport
@@ -544,11 +1175,30 @@ func performRequest(transport http.RoundTripper, req *http.Request, onlyIfCached
 	if resp != nil && resp.Header != nil {
 		resp.Header.Set(XRequestTime, requestTime.Format(time.RFC3339))
 		resp.Header.Set(XResponseTime, responseTime.Format(time.RFC3339))
+		storeInitialAge(resp.Header, responseTime.Sub(requestTime))
 	}
 
 	return resp, nil
 }
 
+// storeInitialAge records in XCacheInitialAge the corrected_age_value of RFC 9111
+// Section 4.2.3 of a response received with an Age header from an upstream
+// cache: age_value + response_delay. The header is removed otherwise, so it
+// always describes the latest response from the network.
+func storeInitialAge(respHeaders http.Header, responseDelay time.Duration) {
+	respHeaders.Del(XCacheInitialAge)
+	if respHeaders.Get(headerAge) == "" {
+		return
+	}
+	ageValue, valid := parseAgeHeader(respHeaders)
+	if !valid {
+		return
+	}
+	if initialAge := ageValue + responseDelay; initialAge >= time.Second {
+		respHeaders.Set(XCacheInitialAge, strconv.FormatInt(int64(initialAge/time.Second), 10))
+	}
+}
+
 // storeVaryHeaders stores the Vary header values in the response for future cache validation
 // storeVaryHeaders stores the Vary header values in the response for future cache validation.
 // RFC 9111 Section 4.1: Values are normalized before storage to enable proper matching.
@@ -570,19 +1220,29 @@ func storeVaryHeaders(resp *http.Response, req *http.Request) {
 	}
 }
 
-// setupCachingBody wraps the response body to cache it when fully read
-func (t *Transport) setupCachingBody(resp *http.Response, cacheKey string) {
+// setupCachingBody wraps the response body to cache it when fully read.
+// The headers are snapshotted now, so changes made to the served response
+// afterwards (e.g. by ServeFilter) don't leak into the stored entry.
+func (t *Transport) setupCachingBody(resp *http.Response, req *http.Request, cacheKey string) {
+	header := t.storedHeader(resp.Header)
 	resp.Body = &cachingReadCloser{
-		R: resp.Body,
+		R:    resp.Body,
+		Size: resp.ContentLength,
+		Pool: t.BufferPool,
 		OnEOF: func(r io.Reader) {
 			resp := *resp
+			resp.Header = header
 			resp.Body = io.NopCloser(r)
+			if !t.trailersAllowCaching(&resp) {
+				return
+			}
 			// Add cached timestamp (backward compatibility with X-Cached-Time)
 			// X-Request-Time and X-Response-Time are already set by performRequest
 			resp.Header.Set(XCachedTime, resp.Header.Get(XResponseTime))
-			respBytes, err := httputil.DumpResponse(&resp, true)
+			t.transformStoredBody(&resp)
+			respBytes, err := t.dumpCapturedResponse(&resp)
 			if err == nil {
-				t.Cache.Set(cacheKey, respBytes)
+				t.cacheSet(cacheKey, req, resp.Header, respBytes)
 			}
 		},
 	}
@@ -591,86 +1251,172 @@ func (t *Transport) setupCachingBody(resp *http.Response, cacheKey string) {
 // setupCachingBodyMultiple stores the cached response under multiple cache keys when the
 // response body is fully read. This is used for Vary separation where we also keep
 // a manifest or pointer under the base key to allow discovery of variant keys.
-func (t *Transport) setupCachingBodyMultiple(resp *http.Response, cacheKeys []string) {
+func (t *Transport) setupCachingBodyMultiple(resp *http.Response, req *http.Request, cacheKeys []string) {
+	header := t.storedHeader(resp.Header)
 	resp.Body = &cachingReadCloser{
-		R: resp.Body,
+		R:    resp.Body,
+		Size: resp.ContentLength,
+		Pool: t.BufferPool,
 		OnEOF: func(r io.Reader) {
 			respCopy := *resp
+			respCopy.Header = header
 			respCopy.Body = io.NopCloser(r)
+			if !t.trailersAllowCaching(&respCopy) {
+				return
+			}
 			// Add cached timestamp (backward compatibility with X-Cached-Time)
 			// X-Request-Time and X-Response-Time are already set by performRequest
 			respCopy.Header.Set(XCachedTime, respCopy.Header.Get(XResponseTime))
-			respBytes, err := httputil.DumpResponse(&respCopy, true)
+			t.transformStoredBody(&respCopy)
+			respBytes, err := t.dumpCapturedResponse(&respCopy)
 			if err == nil {
 				for _, k := range cacheKeys {
-					t.Cache.Set(k, respBytes)
+					t.cacheSet(k, req, respCopy.Header, respBytes)
 				}
 			}
 		},
 	}
 }
 
-// storeCachedResponse caches the response immediately
-func (t *Transport) storeCachedResponse(resp *http.Response, cacheKey string) {
+// storeCachedResponse caches the response to req immediately
+func (t *Transport) storeCachedResponse(resp *http.Response, req *http.Request, cacheKey string) {
 	// Add cached timestamp (backward compatibility with X-Cached-Time)
 	// X-Request-Time and X-Response-Time are already set by performRequest
 	resp.Header.Set(XCachedTime, resp.Header.Get(XResponseTime))
-	respBytes, err := httputil.DumpResponse(resp, true)
+	stored := *resp
+	stored.Header = t.storedHeader(resp.Header)
+	if !t.trailersAllowCaching(&stored) {
+		return
+	}
+	if t.transformsBody(&stored) {
+		// Buffer the body so the client still gets the original
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return
+		}
+		stored.Body = io.NopCloser(bytes.NewReader(body))
+		t.transformStoredBody(&stored)
+		if respBytes, err := dumpStoredResponse(&stored); err == nil {
+			t.cacheSet(cacheKey, req, stored.Header, respBytes)
+		}
+		return
+	}
+	respBytes, err := dumpStoredResponse(&stored)
 	if err == nil {
-		t.Cache.Set(cacheKey, respBytes)
+		t.cacheSet(cacheKey, req, stored.Header, respBytes)
 	}
+	resp.Body = stored.Body
 }
 
-// processCachedResponse handles the logic when a valid cached response exists
-func (t *Transport) processCachedResponse(cachedResp *http.Response, req *http.Request, transport http.RoundTripper, cacheKey string) (*http.Response, error) {
+// trailersAllowCaching reports whether ShouldCacheTrailers, if configured, allows
+// storing the fully read response
+func (t *Transport) trailersAllowCaching(resp *http.Response) bool {
+	return t.ShouldCacheTrailers == nil || t.ShouldCacheTrailers(resp)
+}
+
+// storedHeader returns a copy of header to be persisted, without StripStoredHeaders,
+// the serve-time X-Cache-TTL, the lookup-time possibly-stale tag and HTTP/2
+// pseudo-headers
+func (t *Transport) storedHeader(header http.Header) http.Header {
+	stored := header.Clone()
+	stored.Del(XCacheTTL)
+	stored.Del(xMaybeStale)
+	// HTTP/2 pseudo-headers leaked by a RoundTripper have no HTTP/1.1 form
+	for name := range stored {
+		if strings.HasPrefix(name, ":") {
+			delete(stored, name)
+		}
+	}
+	for _, name := range t.StripStoredHeaders {
+		stored.Del(name)
+	}
+	return stored
+}
+
+// dumpStoredResponse serializes resp, a copy of the response being stored, as an
+// HTTP/1.1 message, however it was fetched, so every entry has the same framing
+func dumpStoredResponse(resp *http.Response) ([]byte, error) {
+	resp.Proto, resp.ProtoMajor, resp.ProtoMinor = "HTTP/1.1", 1, 1
+	return httputil.DumpResponse(resp, true)
+}
+
+// processCachedResponse handles the logic when a valid cached response exists,
+// reporting how the request was answered
+func (t *Transport) processCachedResponse(cachedResp *http.Response, req *http.Request, transport http.RoundTripper, cacheKey string) (*http.Response, DecisionOutcome, error) {
 	if t.MarkCachedResponses {
 		cachedResp.Header.Set(XFromCache, "1")
 	}
 
-	modifiedReq, useCache := t.handleCachedResponse(cachedResp, req)
+	modifiedReq, useCache, servedStale := t.handleCachedResponse(cachedResp, req)
 	if useCache {
-		return cachedResp, nil
+		if servedStale {
+			return cachedResp, DecisionStale, nil
+		}
+		return cachedResp, DecisionHit, nil
+	}
+
+	if backoff := t.retryAfterResponse(req, cacheKey); backoff != nil {
+		if t.shouldReturnStaleOnError(nil, backoff, cachedResp, req) {
+			return t.staleOnErrorResponse(cachedResp), DecisionStale, nil
+		}
+		return backoff, DecisionMiss, nil
 	}
 
 	resp, err := performRequest(transport, modifiedReq, false)
+	if err == nil {
+		t.recordRetryAfter(cacheKey, resp)
+	}
 
 	// Handle 304 Not Modified
 	if err == nil && req.Method == methodGET && resp.StatusCode == http.StatusNotModified {
 		// Drain and close the 304 response body since we're using the cached response
-		if resp != nil {
-			if drainErr := drainDiscardedBody(resp.Body); drainErr != nil {
-				GetLogger().Warn("error draining 304 response body", "error", drainErr)
-			}
+		if drainErr := drainDiscardedBody(resp.Body); drainErr != nil {
+			GetLogger().Warn("error draining 304 response body", "error", drainErr)
+		}
+		if notModifiedMatches(cachedResp, resp) && (!t.VerifyContentDigest || contentDigestsMatch(cachedResp, resp)) {
+			revalidated := handleNotModifiedResponse(cachedResp, resp, t.MarkCachedResponses, !t.DisableAgeHeader)
+			t.markCacheTTL(revalidated)
+			return revalidated, DecisionRevalidated, nil
 		}
-		return handleNotModifiedResponse(cachedResp, resp, t.MarkCachedResponses), nil
+		// The 304 refers to a different representation than the stored one, so it
+		// can't be used to update it: fetch the full response instead
+		GetLogger().Debug("304 validator does not match cached response, refetching", "url", req.URL.String())
+		resp, err = performRequest(transport, req, false)
 	}
 
-	if shouldReturnStaleOnError(err, resp, cachedResp, req) {
+	if t.shouldReturnStaleOnError(err, resp, cachedResp, req) {
 		// Drain and close the error response body since we're using the cached response
 		if resp != nil {
 			if drainErr := drainDiscardedBody(resp.Body); drainErr != nil {
 				GetLogger().Warn("error draining stale response body", "error", drainErr)
 			}
 		}
-		if t.MarkCachedResponses {
-			cachedResp.Header.Set(XStale, "1")
-		}
-		// RFC 7234 Section 5.5: Add Warning 111 (Revalidation Failed)
-		if !t.DisableWarningHeader {
-			addRevalidationFailedWarning(cachedResp)
-		}
-		return cachedResp, nil
+		return t.staleOnErrorResponse(cachedResp), DecisionStale, nil
 	}
 
 	if err != nil || resp.StatusCode != http.StatusOK {
-		t.Cache.Delete(cacheKey)
+		t.deleteEntry(cacheKey)
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, DecisionError, err
 	}
 
-	return resp, nil
+	return resp, DecisionMiss, nil
+}
+
+// staleOnErrorResponse marks cachedResp as served stale because the origin failed
+func (t *Transport) staleOnErrorResponse(cachedResp *http.Response) *http.Response {
+	if t.MarkCachedResponses {
+		cachedResp.Header.Set(XStale, "1")
+	}
+	// RFC 7234 Section 5.5: Add Warning 111 (Revalidation Failed)
+	if !t.DisableWarningHeader {
+		addRevalidationFailedWarning(cachedResp)
+	}
+	return cachedResp
 }
 
 // processUncachedRequest handles the logic when no valid cached response exists
@@ -680,14 +1426,15 @@ func processUncachedRequest(transport http.RoundTripper, req *http.Request) (*ht
 	return performRequest(transport, req, onlyIfCached)
 }
 
-// storeResponseInCache stores the response in cache if applicable
-func (t *Transport) storeResponseInCache(resp *http.Response, req *http.Request, cacheKey string, cacheable bool) {
+// storeResponseInCache stores the response in cache if applicable, reporting
+// whether it is stored (GET responses once their body is read)
+func (t *Transport) storeResponseInCache(resp *http.Response, req *http.Request, cacheKey string, cacheable bool, storedVary []string) bool {
 	respCacheControl := parseCacheControl(resp.Header)
 	reqCacheControl := parseCacheControl(req.Header)
 
-	if !cacheable || !canStore(req, reqCacheControl, respCacheControl, t.IsPublicCache, resp.StatusCode) {
-		t.Cache.Delete(cacheKey)
-		return
+	if !cacheable || !canStore(t.storageRequest(req), reqCacheControl, respCacheControl, t.IsPublicCache, resp.StatusCode) {
+		t.storeDelete(cacheKey)
+		return false
 	}
 
 	// RFC 9111 Section 5.2.2.3: must-understand directive
@@ -710,17 +1457,28 @@ func (t *Transport) storeResponseInCache(resp *http.Response, req *http.Request,
 		resp.StatusCode == http.StatusNotImplemented || // 501
 		mustUnderstandAllowsCaching // must-understand overrides status code check
 
-	// Allow custom override via ShouldCache hook
-	if !shouldCache && t.ShouldCache != nil {
+	// Allow custom override via ShouldCacheWithContext or ShouldCache hook
+	if t.ShouldCacheWithContext != nil {
+		shouldCache = t.ShouldCacheWithContext(req.Context(), req, resp)
+	} else if !shouldCache && t.ShouldCache != nil {
 		shouldCache = t.ShouldCache(resp)
 	}
 
 	if !shouldCache {
-		t.Cache.Delete(cacheKey)
-		return
+		t.storeDelete(cacheKey)
+		return false
 	}
 
+	addVary(resp.Header, t.ForceVaryHeaders)
 	storeVaryHeaders(resp, req)
+	storeLifetimeOverride(resp, req)
+	t.storeStatusTTL(resp)
+	t.storePreflightLifetime(resp, req)
+	t.storeSoftHardTTL(resp, respCacheControl)
+	t.storeValidatorOnlyFreshness(resp, respCacheControl)
+	storeSensitiveTag(resp, t.sensitivityRequest(req))
+	t.storePlaintextTag(resp, req)
+	t.dropDecodedContentDigest(resp)
 
 	// RFC 9111 Vary Separation: If EnableVarySeparation is true and response has Vary headers,
 	// create separate cache entries for each variant (new behavior).
@@ -730,29 +1488,100 @@ func (t *Transport) storeResponseInCache(resp *http.Response, req *http.Request,
 		// Keep original base key so we can also persist a manifest/last-variant there
 		baseKey := cacheKey
 		// Use vary-specific cache key for this variant
-		varyKey := cacheKeyWithVary(req, varyHeaders)
+		varyKey := t.variantCacheKey(req, varyHeaders)
+		t.observeKeyCardinality(req, varyKey)
+		t.trackVariant(baseKey, varyKey)
 
 		if req.Method == methodGET {
 			// Store the full response under both the variant key and the base key so
 			// that RoundTrip can read the base entry (to discover Vary) and then
 			// re-lookup the variant-specific entry. This preserves backward compatibility
 			// with existing lookup behaviour while providing separate entries per variant.
-			t.setupCachingBodyMultiple(resp, []string{varyKey, baseKey})
-			return
+			if t.usesStreamSnapshot(resp) {
+				t.setupSnapshotBody(resp, req, []string{varyKey, baseKey})
+				return true
+			}
+			t.setupCachingBodyMultiple(resp, req, []string{varyKey, baseKey})
+			return true
 		}
 
 		// Non-GET responses: store under both keys immediately
-		t.storeCachedResponse(resp, varyKey)
+		t.storeCachedResponse(resp, req, varyKey)
 		// Also store a copy under base key
 		respCopy := *resp
-		t.storeCachedResponse(&respCopy, baseKey)
-		return
+		t.storeCachedResponse(&respCopy, req, baseKey)
+		return true
 	}
 
-	if req.Method == methodGET {
-		t.setupCachingBody(resp, cacheKey)
+	if t.EnableVarySeparation {
+		// A response replacing a varying entry no longer varies: store it under
+		// the base key, replacing the variants
+		cacheKey = t.requestCacheKey(req)
+		if len(storedVary) > 0 && !t.DryRunStore {
+			t.purgeVariants(req, cacheKey, storedVary)
+		}
+	}
+	t.observeKeyCardinality(req, cacheKey)
+
+	if req.Method == methodGET && t.usesStreamSnapshot(resp) {
+		t.setupSnapshotBody(resp, req, []string{cacheKey})
+	} else if req.Method == methodGET {
+		t.setupCachingBody(resp, req, cacheKey)
 	} else {
-		t.storeCachedResponse(resp, cacheKey)
+		t.storeCachedResponse(resp, req, cacheKey)
+	}
+	return true
+}
+
+// lookupCachedResponse reads the cached response for req under cacheKey.
+// RFC 9111 Vary Separation: If EnableVarySeparation is true and cached response has Vary headers,
+// the cache key is recalculated with vary values and the correct variant is looked up.
+// It returns the cached response (nil on miss) and the key it was found under.
+func (t *Transport) lookupCachedResponse(req *http.Request, cacheKey string) (*http.Response, string, error) {
+	cachedResp, err := t.cachedResponseWithKey(req, cacheKey)
+
+	// This only applies when the new vary separation behavior is enabled.
+	if t.EnableVarySeparation && cachedResp != nil && err == nil {
+		varyHeaders := headerAllCommaSepValues(cachedResp.Header, "vary")
+		if len(varyHeaders) > 0 {
+			// Recalculate key with vary headers for proper variant lookup
+			varyCacheKey := t.variantCacheKey(req, varyHeaders)
+			if varyCacheKey != cacheKey {
+				// Try with vary-specific key
+				varyCachedResp, varyErr := t.cachedResponseWithKey(req, varyCacheKey)
+				if varyErr == nil && varyCachedResp != nil {
+					return t.servableSinceStore(varyCachedResp), varyCacheKey, nil
+				}
+			}
+		}
+	}
+
+	if cachedResp != nil && err == nil {
+		cachedResp = t.servableSinceStore(cachedResp)
+	}
+	return cachedResp, cacheKey, err
+}
+
+// servableSinceStore returns cachedResp, or nil if MaxServableAgeSinceStore is set
+// and the entry was stored longer ago than that (or its store time is unknown)
+func (t *Transport) servableSinceStore(cachedResp *http.Response) *http.Response {
+	if t.MaxServableAgeSinceStore <= 0 {
+		return cachedResp
+	}
+	cachedTime, err := time.Parse(time.RFC3339, cachedResp.Header.Get(XCachedTime))
+	if err == nil && clock.since(cachedTime) <= t.MaxServableAgeSinceStore {
+		return cachedResp
+	}
+	GetLogger().Debug("ignoring cache entry stored too long ago",
+		"url", cachedResp.Request.URL.String(), "cached_time", cachedResp.Header.Get(XCachedTime))
+	_ = cachedResp.Body.Close()
+	return nil
+}
+
+// observeKeyCardinality reports a stored key to the KeyCardinalityMonitor, if configured
+func (t *Transport) observeKeyCardinality(req *http.Request, key string) {
+	if t.KeyCardinalityMonitor != nil {
+		t.KeyCardinalityMonitor.Observe(cacheKey(t.keyRequest(req)), key)
 	}
 }
 
@@ -765,72 +1594,242 @@ func (t *Transport) storeResponseInCache(resp *http.Response, req *http.Request,
 // to give the server a chance to respond with NotModified. If this happens, then the cached Response
 // will be returned.
 func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
-	cacheKey := cacheKeyWithHeaders(req, t.CacheKeyHeaders)
-	cacheable := (req.Method == methodGET || req.Method == methodHEAD) && req.Header.Get("range") == ""
+	if t.ServeRangeFromCache && req.Header.Get(headerRange) != "" {
+		if rangeResp, ok := t.serveRangeFromCache(req); ok {
+			t.addImplicitVary(rangeResp)
+			t.normalizeServedVary(rangeResp)
+			t.applyClientCacheControl(rangeResp)
+			t.rewriteServedDate(rangeResp)
+			t.applyServeFilter(rangeResp)
+			t.reportCacheOutcome(req, true)
+			t.countBytesSaved(rangeResp, true)
+			if t.recordsDecisions() {
+				t.recordDecision(req, rangeResp, DecisionHit, nil, true, false, t.requestCacheKey(req), freshnessStringFresh)
+			}
+			return rangeResp, nil
+		}
+	}
+
+	cacheKey := t.requestCacheKey(req)
+	cacheable := (t.isCacheableMethod(req.Method) || t.isCacheablePreflight(req)) && req.Header.Get("range") == ""
 
 	var cachedResp *http.Response
+	var source *serveSource
 	if cacheable {
-		// Try to get cached response
-		cachedResp, err = cachedResponseWithKey(t.Cache, req, cacheKey)
-
-		// RFC 9111 Vary Separation: If EnableVarySeparation is true and cached response has Vary headers,
-		// recalculate cache key with vary values and try again for the correct variant.
-		// This only applies when the new vary separation behavior is enabled.
-		if t.EnableVarySeparation && cachedResp != nil && err == nil {
-			varyHeaders := headerAllCommaSepValues(cachedResp.Header, "vary")
-			if len(varyHeaders) > 0 {
-				// Recalculate key with vary headers for proper variant lookup
-				varyCacheKey := cacheKeyWithVary(req, varyHeaders)
-				if varyCacheKey != cacheKey {
-					// Try with vary-specific key
-					varyCachedResp, varyErr := cachedResponseWithKey(t.Cache, req, varyCacheKey)
-					if varyErr == nil && varyCachedResp != nil {
-						cachedResp = varyCachedResp
-						cacheKey = varyCacheKey
-					}
-				}
-			}
+		lookupReq := req
+		if t.ServeDiagnostics {
+			lookupReq, source = withServeSource(req)
+		}
+		cachedResp, cacheKey, err = t.lookupCachedResponse(lookupReq, cacheKey)
+		if err == nil && cachedResp != nil {
+			// The entry belongs to req, not to its copy recording the serving tier
+			cachedResp.Request = req
+			cachedResp = t.verifyCachedDigest(req, cachedResp, cacheKey)
 		}
 	} else {
 		// RFC 7234 Section 4.4: Invalidate cache on unsafe methods
 		// Delete the request URI immediately for unsafe methods
-		t.Cache.Delete(cacheKey)
+		t.deleteEntry(cacheKey)
 	}
 
-	transport := t.Transport
-	if transport == nil {
-		transport = http.DefaultTransport
+	freshness := t.entryFreshness(req, cachedResp)
+	var storedVary []string
+	if cachedResp != nil {
+		storedVary = headerAllCommaSepValues(cachedResp.Header, "vary")
 	}
+	transport := t.upstreamTransport(cacheable)
 
 	// Handle cached vs uncached response
+	outcome := DecisionMiss
 	if cacheable && cachedResp != nil && err == nil {
-		resp, err = t.processCachedResponse(cachedResp, req, transport, cacheKey)
+		resp, outcome, err = t.processCachedResponse(cachedResp, req, transport, cacheKey)
+	} else if backoff := t.retryAfterResponse(req, cacheKey); backoff != nil {
+		resp, err = backoff, nil
 	} else {
 		resp, err = processUncachedRequest(transport, req)
+		if err == nil && cacheable {
+			t.recordRetryAfter(cacheKey, resp)
+		}
 	}
 
 	if err != nil {
-		return nil, err
+		if cacheable {
+			t.reportCacheOutcome(req, false)
+		}
+		t.recordDecision(req, nil, DecisionError, err, cacheable, false, cacheKey, freshness)
+		return t.fallbackResponse(req, err)
 	}
 
 	// RFC 7234 Section 4.4: Invalidate cache for unsafe methods
-	// After successful response, invalidate related URIs
-	if isUnsafeMethod(req.Method) {
+	// After successful response, invalidate related URIs. A cached response for an
+	// unsafe method never reached the origin, so nothing has changed there.
+	// Whether resp comes from cache is decided here rather than from X-From-Cache,
+	// which depends on MarkCachedResponses and may be removed by ServeFilter
+	fromCache := cachedResp != nil && resp == cachedResp
+	if isUnsafeMethod(req.Method) && !fromCache {
 		t.invalidateCache(req, resp)
 	}
 
 	// Store response in cache if applicable
-	t.storeResponseInCache(resp, req, cacheKey, cacheable)
+	stored := t.storeResponseInCache(resp, req, cacheKey, cacheable, storedVary)
+	if cacheable && !fromCache {
+		t.updateCacheFromHead(req, resp)
+	}
+	t.recordDecision(req, resp, outcome, nil, cacheable, stored, cacheKey, freshness)
 
+	// Serve-time changes are applied after storing so they never reach the backend
+	if cacheable {
+		t.addImplicitVary(resp)
+		t.applyClientCacheControl(resp)
+	}
+	if fromCache {
+		t.normalizeServedVary(resp)
+		t.rewriteServedDate(resp)
+		t.applyServeFilter(resp)
+		t.addServeDiagnostics(resp, source, cacheKey)
+		resp = answerClientConditional(req, resp)
+	}
+	if cacheable {
+		t.reportCacheOutcome(req, fromCache)
+		t.countBytesSaved(resp, fromCache)
+	}
+	t.addOnlyIfCachedRetryAfter(req, resp)
+
+	return resp, nil
+}
+
+// fallbackResponse returns the FallbackResponse for a failed request, if configured,
+// or the original error otherwise
+func (t *Transport) fallbackResponse(req *http.Request, err error) (*http.Response, error) {
+	if t.FallbackResponse == nil {
+		return nil, err
+	}
+	resp := t.FallbackResponse(req, err)
+	if resp == nil {
+		return nil, err
+	}
+	if resp.Request == nil {
+		resp.Request = req
+	}
+	GetLogger().Debug("serving fallback response", "url", req.URL.String(), "error", err)
 	return resp, nil
 }
 
+// addImplicitVary adds the VaryByHeaders and NegotiationHeaders missing from the
+// Vary header of resp
+func (t *Transport) addImplicitVary(resp *http.Response) {
+	addVary(resp.Header, t.VaryByHeaders)
+	addVary(resp.Header, t.NegotiationHeaders)
+}
+
+// addVary adds the names missing from the Vary header. A Vary of "*" is left as is.
+func addVary(header http.Header, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	present := map[string]bool{}
+	for _, name := range headerAllCommaSepValues(header, "vary") {
+		present[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+	}
+	if present["*"] {
+		return
+	}
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name != "" && !present[name] {
+			header.Add("Vary", name)
+			present[name] = true
+		}
+	}
+}
+
+// normalizeServedVary merges the Vary header lines of a response served from
+// cache into a single line when EnableVarySeparation is set, so downstream
+// caches see every header the variant was selected by. Names are canonicalized
+// and de-duplicated, keeping their order; a Vary of "*" is served as is.
+func (t *Transport) normalizeServedVary(resp *http.Response) {
+	if !t.EnableVarySeparation {
+		return
+	}
+	values := headerAllCommaSepValues(resp.Header, "vary")
+	if len(values) == 0 {
+		return
+	}
+	var names []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		name := http.CanonicalHeaderKey(value)
+		if name == "" || seen[name] {
+			continue
+		}
+		if name == "*" {
+			resp.Header.Set("Vary", "*")
+			return
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	resp.Header.Set("Vary", strings.Join(names, ", "))
+}
+
+// applyClientCacheControl replaces the Cache-Control of a served response with
+// ClientCacheControl, if configured
+func (t *Transport) applyClientCacheControl(resp *http.Response) {
+	if t.ClientCacheControl == "" {
+		return
+	}
+	cc := parseCacheControl(resp.Header)
+	if _, noStore := cc[cacheControlNoStore]; noStore {
+		return
+	}
+	if _, private := cc[cacheControlPrivate]; private {
+		return
+	}
+	resp.Header.Set("Cache-Control", t.ClientCacheControl)
+}
+
+// reportCacheOutcome calls OnCacheHit or OnCacheMiss, if configured, depending on
+// whether the response to req was served from cache
+func (t *Transport) reportCacheOutcome(req *http.Request, hit bool) {
+	if t.WindowStats != nil {
+		t.WindowStats.recordRequest(hit)
+	}
+	if hit {
+		if t.OnCacheHit != nil {
+			t.OnCacheHit(req)
+		}
+	} else if t.OnCacheMiss != nil {
+		t.OnCacheMiss(req)
+	}
+}
+
+// applyServeFilter runs ServeFilter on a response served from cache, if configured
+func (t *Transport) applyServeFilter(resp *http.Response) {
+	if t.ServeFilter != nil {
+		t.ServeFilter(resp)
+	}
+}
+
 // isUnsafeMethod returns true if the HTTP method is considered unsafe
 // RFC 7234 Section 4.4: POST, PUT, DELETE, PATCH are unsafe methods
 func isUnsafeMethod(method string) bool {
 	return method == methodPOST || method == methodPUT || method == methodDELETE || method == methodPATCH
 }
 
+// isCacheableMethod reports whether responses to method may be cached, per
+// CacheableMethods or GET and HEAD by default
+func (t *Transport) isCacheableMethod(method string) bool {
+	if t.CacheableMethods == nil {
+		return method == methodGET || method == methodHEAD
+	}
+	for _, m := range t.CacheableMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
 // invalidateCache invalidates cache entries per RFC 9111 Section 4.4
 // When receiving a non-error response to an unsafe method, invalidate:
 // 1. The effective Request-URI
@@ -849,11 +1848,11 @@ func (t *Transport) invalidateCache(req *http.Request, resp *http.Response) {
 	}
 
 	// Always invalidate the Request-URI
-	t.invalidateURI(req.URL, "request-uri")
+	t.invalidateURI(req, req.URL, "request-uri")
 
 	// Invalidate Location header URI (RFC 9111 Section 4.4)
 	if location := resp.Header.Get(headerLocation); location != "" {
-		if err := t.invalidateHeaderURI(req.URL, location, "Location"); err != nil {
+		if err := t.invalidateHeaderURI(req, location, "Location"); err != nil {
 			if logger := GetLogger(); logger != nil {
 				logger.Debug("failed to invalidate Location URI",
 					"location", location,
@@ -864,7 +1863,7 @@ func (t *Transport) invalidateCache(req *http.Request, resp *http.Response) {
 
 	// Invalidate Content-Location header URI (RFC 9111 Section 4.4)
 	if contentLocation := resp.Header.Get(headerContentLocation); contentLocation != "" {
-		if err := t.invalidateHeaderURI(req.URL, contentLocation, "Content-Location"); err != nil {
+		if err := t.invalidateHeaderURI(req, contentLocation, "Content-Location"); err != nil {
 			if logger := GetLogger(); logger != nil {
 				logger.Debug("failed to invalidate Content-Location URI",
 					"content-location", contentLocation,
@@ -877,7 +1876,9 @@ func (t *Transport) invalidateCache(req *http.Request, resp *http.Response) {
 // invalidateHeaderURI parses and invalidates a URI from a response header.
 // It ensures same-origin policy compliance per RFC 9111.
 // Returns an error if the URI cannot be parsed.
-func (t *Transport) invalidateHeaderURI(requestURL *url.URL, headerValue string, headerName string) error {
+func (t *Transport) invalidateHeaderURI(req *http.Request, headerValue string, headerName string) error {
+	requestURL := req.URL
+
 	// Parse the header value as a URI (may be relative or absolute)
 	targetURL, err := requestURL.Parse(headerValue)
 	if err != nil {
@@ -896,42 +1897,19 @@ func (t *Transport) invalidateHeaderURI(requestURL *url.URL, headerValue string,
 		return nil
 	}
 
-	t.invalidateURI(targetURL, headerName)
+	t.invalidateURI(req, targetURL, headerName)
 	return nil
 }
 
 // invalidateURI removes cache entries for the given URI.
-// It invalidates both GET and HEAD requests for the URI.
-func (t *Transport) invalidateURI(targetURL *url.URL, source string) {
-	// Invalidate GET request for this URL
-	getReq := &http.Request{
-		Method: methodGET,
-		URL:    targetURL,
-	}
-	getKey := cacheKey(getReq)
-	t.Cache.Delete(getKey)
-
-	if logger := GetLogger(); logger != nil {
-		logger.Debug("invalidated cache entry",
-			"key", getKey,
-			"source", source,
-			"url", targetURL.String())
-	}
-
-	// Also invalidate HEAD request if different key
-	headReq := &http.Request{
-		Method: methodHEAD,
-		URL:    targetURL,
-	}
-	headKey := cacheKey(headReq)
-	if headKey != getKey {
-		t.Cache.Delete(headKey)
-		if logger := GetLogger(); logger != nil {
-			logger.Debug("invalidated HEAD cache entry",
-				"key", headKey,
-				"source", source)
-		}
-	}
+// It invalidates both GET and HEAD requests for the URI, with the keys and Vary
+// variants Invalidate would remove for them given the headers of the originating
+// request, within its cache partition (see PartitionKeyFunc).
+func (t *Transport) invalidateURI(req *http.Request, targetURL *url.URL, source string) {
+	targetReq := cloneRequest(req)
+	targetReq.Method = methodGET
+	targetReq.URL = targetURL
+	t.invalidateEntries(targetReq, source)
 }
 
 // isSameOrigin checks if two URLs have the same origin.
@@ -957,7 +1935,22 @@ func Date(respHeaders http.Header) (date time.Time, err error) {
 		return
 	}
 
-	return time.Parse(time.RFC1123, dateHeader)
+	return parseHTTPDate(dateHeader)
+}
+
+// parseHTTPDate parses an HTTP-date in any of the formats recipients must accept
+// (RFC 9110 Section 5.6.7): IMF-fixdate, the obsolete RFC 850 format and ANSI C
+// asctime. Non-GMT zones and numeric offsets, which some origins still send, are
+// accepted as a fallback.
+func parseHTTPDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := http.ParseTime(value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC1123Z, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC1123, value)
 }
 
 // calculateAge calculates the Age header value according to RFC 7234 Section 4.2.3.
@@ -1145,7 +2138,7 @@ func isActuallyStale(respHeaders http.Header) bool {
 		return true // No date means we can't determine freshness, treat as stale
 	}
 
-	currentAge := clampedAge(date)
+	currentAge := currentAge(respHeaders, date)
 	lifetime := calculateLifetime(respCacheControl, respHeaders, date)
 
 	// Check if stale-while-revalidate extends freshness
@@ -1161,6 +2154,24 @@ func isActuallyStale(respHeaders http.Header) bool {
 	return lifetime <= currentAge
 }
 
+// markCacheTTL sets the X-Cache-TTL header on a response served from cache when
+// MarkCacheTTL is enabled
+func (t *Transport) markCacheTTL(resp *http.Response) {
+	if t.MarkCacheTTL {
+		resp.Header.Set(XCacheTTL, strconv.FormatInt(int64(remainingFreshness(resp.Header)/time.Second), 10))
+	}
+}
+
+// remainingFreshness returns the freshness lifetime of a stored response minus
+// its current age, negative once the response is stale
+func remainingFreshness(respHeaders http.Header) time.Duration {
+	date, err := Date(respHeaders)
+	if err != nil {
+		return 0
+	}
+	return calculateLifetime(parseCacheControl(respHeaders), respHeaders, date) - currentAge(respHeaders, date)
+}
+
 // checkCacheControl checks for no-cache directives, Pragma: no-cache, and only-if-cached
 // RFC 7234 Section 5.4: Pragma: no-cache is treated as Cache-Control: no-cache for HTTP/1.0 compatibility
 func checkCacheControl(respCacheControl, reqCacheControl cacheControl, reqHeaders http.Header) (int, bool) {
@@ -1184,8 +2195,14 @@ func checkCacheControl(respCacheControl, reqCacheControl cacheControl, reqHeader
 	return 0, false
 }
 
-// calculateLifetime calculates the response lifetime based on max-age or Expires header
+// calculateLifetime calculates the response lifetime based on a stored override,
+// max-age or Expires header
 func calculateLifetime(respCacheControl cacheControl, respHeaders http.Header, date time.Time) time.Duration {
+	// A stored override (e.g. from WithRequestTTL) takes precedence over the origin
+	if lifetime, ok := lifetimeOverride(respHeaders); ok {
+		return lifetime
+	}
+
 	var lifetime time.Duration
 	var zeroDuration time.Duration
 
@@ -1201,7 +2218,8 @@ func calculateLifetime(respCacheControl cacheControl, respHeaders http.Header, d
 	} else {
 		expiresHeader := respHeaders.Get("Expires")
 		if expiresHeader != "" {
-			expires, err := time.Parse(time.RFC1123, expiresHeader)
+			// RFC 9111 Section 5.3: an invalid Expires (e.g. "0") means already expired
+			expires, err := parseHTTPDate(expiresHeader)
 			if err != nil {
 				lifetime = zeroDuration
 			} else {
@@ -1210,7 +2228,7 @@ func calculateLifetime(respCacheControl cacheControl, respHeaders http.Header, d
 		}
 	}
 
-	return lifetime
+	return applyCacheControlExtensions(respCacheControl, respHeaders, lifetime)
 }
 
 // adjustAgeForRequestControls adjusts the current age based on request cache control directives
@@ -1279,6 +2297,27 @@ func clampedAge(date time.Time) time.Duration {
 	return 0
 }
 
+// currentAge returns the current age of a stored response dated date: now - date
+// clamped to >= 0 or, when an upstream cache sent an Age header, the age it had
+// when received (XCacheInitialAge) plus the time since, if larger, so a response
+// that was already aged when it was fetched isn't kept fresh for its whole
+// lifetime (RFC 9111 Section 4.2.3)
+func currentAge(respHeaders http.Header, date time.Time) time.Duration {
+	age := clampedAge(date)
+	initialAge, err := strconv.ParseInt(respHeaders.Get(XCacheInitialAge), 10, 64)
+	if err != nil || initialAge <= 0 {
+		return age
+	}
+	responseTime, err := time.Parse(time.RFC3339, respHeaders.Get(XResponseTime))
+	if err != nil {
+		return age
+	}
+	if corrected := time.Duration(initialAge)*time.Second + clock.since(responseTime); corrected > age {
+		return corrected
+	}
+	return age
+}
+
 // getFreshness will return one of fresh/stale/transparent based on the cache-control
 // values of the request and the response
 //
@@ -1303,7 +2342,13 @@ func getFreshness(respHeaders, reqHeaders http.Header) (freshness int) {
 	if err != nil {
 		return stale
 	}
-	currentAge := clampedAge(date)
+	currentAge := currentAge(respHeaders, date)
+
+	// Past the stored hard TTL the entry must not be served at all
+	hardTTL, hasHardTTL := storedHardTTL(respHeaders)
+	if hasHardTTL && currentAge >= hardTTL {
+		return transparent
+	}
 
 	// Calculate response lifetime
 	lifetime := calculateLifetime(respCacheControl, respHeaders, date)
@@ -1319,6 +2364,17 @@ func getFreshness(respHeaders, reqHeaders http.Header) (freshness int) {
 		return fresh
 	}
 
+	// Between the soft and hard TTLs the entry is served while it is revalidated
+	if hasHardTTL {
+		return staleWhileRevalidate
+	}
+
+	// RFC 9111 Section 5.2.2.2: must-revalidate forbids serving stale responses
+	// without successful validation, so stale-while-revalidate doesn't apply
+	if _, mustRevalidate := respCacheControl[cacheControlMustRevalidate]; mustRevalidate {
+		return stale
+	}
+
 	// Check for stale-while-revalidate directive
 	if stalewhilerevalidate, ok := respCacheControl[cacheControlStaleWhileRevalidate]; ok {
 		// If the cached response isn't too stale, we can return it and refresh asynchronously
@@ -1359,8 +2415,7 @@ func checkStaleIfErrorLifetime(respHeaders http.Header, lifetime time.Duration)
 	if err != nil {
 		return false
 	}
-	currentAge := clampedAge(date)
-	return lifetime > currentAge
+	return lifetime > currentAge(respHeaders, date)
 }
 
 // cache control extension: https://tools.ietf.org/html/rfc5861
@@ -1667,27 +2722,80 @@ type cachingReadCloser struct {
 	R io.ReadCloser
 	// OnEOF is called with a copy of the content of R when EOF is reached.
 	OnEOF func(io.Reader)
+	// Size is the expected length of the content of R, or -1 if unknown. A body
+	// closed before EOF is still complete, and passed to OnEOF, once Size bytes
+	// have been read.
+	Size int64
+	// Pool, if set, provides the buffer holding the copy of the content, which
+	// is returned to it once OnEOF returns or the content is found incomplete.
+	Pool BufferPool
 
-	buf bytes.Buffer // buf stores a copy of the content of R.
+	buf  *bytes.Buffer // buf stores a copy of the content of R.
+	done bool          // done is set once OnEOF was called or the content is incomplete.
 }
 
 // Read reads the next len(p) bytes from R or until R is drained. The
 // return value n is the number of bytes read. If R has no data to
 // return, err is io.EOF and OnEOF is called with a full copy of what
-// has been read so far.
+// has been read so far. Any other error means the content is incomplete,
+// and OnEOF is never called.
 func (r *cachingReadCloser) Read(p []byte) (n int, err error) {
 	n, err = r.R.Read(p)
+	if r.done {
+		return n, err
+	}
+	if r.buf == nil {
+		r.buf = r.newBuffer()
+	}
 	r.buf.Write(p[:n])
-	if err == io.EOF || n < len(p) {
-		r.OnEOF(bytes.NewReader(r.buf.Bytes()))
+	switch {
+	case err == io.EOF:
+		r.finish()
+	case err != nil:
+		r.done = true
+		r.release()
 	}
 	return n, err
 }
 
+// Close closes R. A body closed before EOF is only passed to OnEOF if all of
+// its Size bytes have been read; otherwise, e.g. when a download is interrupted,
+// nothing is stored.
 func (r *cachingReadCloser) Close() error {
+	if !r.done && r.Size > 0 && r.buf != nil && int64(r.buf.Len()) == r.Size {
+		r.finish()
+	}
+	r.done = true
+	r.release()
 	return r.R.Close()
 }
 
+// finish calls OnEOF with the content read, once
+func (r *cachingReadCloser) finish() {
+	r.done = true
+	if r.buf == nil {
+		r.buf = r.newBuffer()
+	}
+	r.OnEOF(bytes.NewReader(r.buf.Bytes()))
+	r.release()
+}
+
+// newBuffer returns an empty buffer, from Pool if set
+func (r *cachingReadCloser) newBuffer() *bytes.Buffer {
+	if r.Pool != nil {
+		return r.Pool.Get()
+	}
+	return new(bytes.Buffer)
+}
+
+// release drops the copy of the content, returning its buffer to Pool if set
+func (r *cachingReadCloser) release() {
+	if r.buf != nil && r.Pool != nil {
+		r.Pool.Put(r.buf)
+	}
+	r.buf = nil
+}
+
 // NewMemoryCacheTransport returns a new Transport using the in-memory cache implementation
 func NewMemoryCacheTransport() *Transport {
 	c := NewMemoryCache()
@@ -550,3 +550,102 @@ func TestCalculateAgeResponseDelayCalculation(t *testing.T) {
 		t.Errorf("calculateAge() = %v, want ~%v (response_delay should be included)", age, expectedAge)
 	}
 }
+
+// TestDisableAgeHeader verifies cached responses have no Age header when disabled,
+// while the computed age is still used for freshness
+func TestDisableAgeHeader(t *testing.T) {
+	resetTest()
+
+	counter := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Date", time.Now().UTC().Format(time.RFC1123))
+		w.Write([]byte("test"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.DisableAgeHeader = true
+	client := &http.Client{Transport: tp}
+
+	get := func() *http.Response {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	get()
+	resp := get()
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("Second request should be from cache")
+	}
+	if resp.Header.Get(headerAge) != "" {
+		t.Fatalf("Age header should not be set when disabled, got %q", resp.Header.Get(headerAge))
+	}
+
+	// Once the entry is older than max-age it must be refetched
+	clock = &fakeClock{elapsed: 120 * time.Second}
+	resp = get()
+	if resp.Header.Get(XFromCache) != "" {
+		t.Fatal("Expired entry should not be served from cache")
+	}
+	if counter != 2 {
+		t.Fatalf("Expected 2 server hits, got %d", counter)
+	}
+}
+
+// TestUpstreamAgeFreshness verifies the Age header of a response fetched through
+// another cache counts towards its current age, so a response with Age: 100 and
+// max-age=120 is only fresh for about 20 more seconds
+func TestUpstreamAgeFreshness(t *testing.T) {
+	tests := []struct {
+		name      string
+		elapsed   time.Duration
+		fromCache bool
+	}{
+		{"within remaining lifetime", 10 * time.Second, true},
+		{"past remaining lifetime", 30 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			counter := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				counter++
+				w.Header().Set("Cache-Control", "max-age=120")
+				w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+				w.Header().Set(headerAge, "100")
+				w.Write([]byte("test"))
+			}))
+			defer ts.Close()
+
+			tp := NewMemoryCacheTransport()
+			client := tp.Client()
+			fetchAndDrain(t, client, ts.URL)
+
+			clock = &fakeClock{elapsed: tt.elapsed}
+			defer func() { clock = &realClock{} }()
+
+			resp, err := client.Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			drainAndClose(resp)
+			if got := resp.Header.Get(XFromCache) == "1"; got != tt.fromCache {
+				t.Fatalf("expected from cache %v, got %v (%d origin requests)", tt.fromCache, got, counter)
+			}
+			if !tt.fromCache {
+				return
+			}
+			age, err := strconv.Atoi(resp.Header.Get(headerAge))
+			if err != nil || age < 109 || age > 112 {
+				t.Errorf("expected the served Age to include the upstream Age, got %q", resp.Header.Get(headerAge))
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func getBody(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestAsyncStaleRefresh(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	ts := newTestOrigin(t, respondNumbered("Cache-Control", "max-age=60"))
+
+	tp := NewMemoryCacheTransport()
+	tp.AsyncStaleRefresh = true
+	client := tp.Client()
+
+	getBody(t, client, ts.URL)
+
+	clock = &fakeClock{elapsed: 120 * time.Second}
+	resp, body := getBody(t, client, ts.URL)
+	if body != "response 1" || resp.Header.Get(XStale) != "1" || resp.Header.Get(XFromCache) != "1" {
+		t.Fatalf("expected the stale response to be served immediately, got %q (X-Stale %q)", body, resp.Header.Get(XStale))
+	}
+
+	// Wait for the background refetch to update the cache
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		if cached, _ := CachedResponse(tp.Cache, req); cached != nil {
+			b, _ := io.ReadAll(cached.Body)
+			if string(b) == "response 2" {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cache was not updated by the background refetch")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	clock = &fakeClock{elapsed: time.Second}
+	resp, body = getBody(t, client, ts.URL)
+	if body != "response 2" || resp.Header.Get(XStale) != "" || resp.Header.Get(XFreshness) != "fresh" {
+		t.Fatalf("expected the refreshed response to be served fresh, got %q", body)
+	}
+	if n := ts.requests(nil); n != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", n)
+	}
+}
+
+func TestAsyncStaleRefreshRespectsMustRevalidate(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	ts := newTestOrigin(t, respondNumbered("Cache-Control", "max-age=60, must-revalidate"))
+
+	tp := NewMemoryCacheTransport()
+	tp.AsyncStaleRefresh = true
+	client := tp.Client()
+
+	getBody(t, client, ts.URL)
+	clock = &fakeClock{elapsed: 120 * time.Second}
+	resp, body := getBody(t, client, ts.URL)
+	if body != "response 2" || resp.Header.Get(XStale) != "" {
+		t.Fatalf("expected a synchronous refetch, got %q", body)
+	}
+	if n := ts.requests(nil); n != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", n)
+	}
+}
+
+func TestAsyncStaleRefreshDisabled(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	ts := newTestOrigin(t, respondNumbered("Cache-Control", "max-age=60"))
+
+	client := NewMemoryCacheTransport().Client()
+	getBody(t, client, ts.URL)
+	clock = &fakeClock{elapsed: 120 * time.Second}
+	if _, body := getBody(t, client, ts.URL); body != "response 2" {
+		t.Fatalf("expected a synchronous refetch by default, got %q", body)
+	}
+}
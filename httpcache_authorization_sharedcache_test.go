@@ -609,3 +609,146 @@ func TestAuthorizationSharedCacheWithCacheKeyHeaders(t *testing.T) {
 		t.Fatal("Expected response to be cached with CacheKeyHeaders in shared cache")
 	}
 }
+
+// TestAuthorizationPolicySharedCache tests how each AuthorizationPolicy keys and
+// stores responses to authenticated requests in a shared cache, while the
+// Authorization header always reaches the origin
+func TestAuthorizationPolicySharedCache(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        AuthorizationPolicy
+		cacheControl  string
+		sameTokenHit  bool
+		otherTokenHit bool
+		sensitive     bool
+	}{
+		{"rfc public", AuthorizationRFC, "public, max-age=3600", true, true, true},
+		{"rfc without public", AuthorizationRFC, "max-age=3600", false, false, false},
+		{"per-credential public", AuthorizationPerCredential, "public, max-age=3600", true, false, true},
+		{"per-credential without public", AuthorizationPerCredential, "max-age=3600", true, false, true},
+		{"ignore public", AuthorizationIgnore, "public, max-age=3600", true, true, false},
+		{"ignore without public", AuthorizationIgnore, "max-age=3600", true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			var received []string
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = append(received, r.Header.Get("Authorization"))
+				w.Header().Set("Cache-Control", tt.cacheControl)
+				w.Write([]byte("Response"))
+			}))
+			defer testServer.Close()
+
+			cache := NewMemoryCache()
+			tp := NewTransport(cache)
+			tp.IsPublicCache = true
+			tp.AuthorizationPolicy = tt.policy
+			client := tp.Client()
+
+			get := func(token string) bool {
+				req, _ := http.NewRequest(http.MethodGet, testServer.URL, nil)
+				req.Header.Set("Authorization", token)
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				io.ReadAll(resp.Body)
+				resp.Body.Close()
+				return resp.Header.Get(XFromCache) == "1"
+			}
+
+			get("Bearer token1")
+			if got := get("Bearer token1"); got != tt.sameTokenHit {
+				t.Errorf("same token: expected from cache %v, got %v", tt.sameTokenHit, got)
+			}
+			if got := get("Bearer token2"); got != tt.otherTokenHit {
+				t.Errorf("other token: expected from cache %v, got %v", tt.otherTokenHit, got)
+			}
+			for _, auth := range received {
+				if auth == "" {
+					t.Error("expected Authorization to be forwarded to the origin")
+				}
+			}
+
+			if !tt.sameTokenHit {
+				return
+			}
+			req, _ := http.NewRequest(http.MethodGet, testServer.URL, nil)
+			req.Header.Set("Authorization", "Bearer token1")
+			value, ok := cache.Get(tp.requestCacheKey(req))
+			if !ok {
+				t.Fatal("expected the response to be stored")
+			}
+			if got := IsSensitiveEntry(value); got != tt.sensitive {
+				t.Errorf("expected sensitive %v, got %v", tt.sensitive, got)
+			}
+		})
+	}
+}
+
+// TestAuthorizationIgnoreOverridesCacheKeyHeaders tests that AuthorizationIgnore
+// keeps Authorization out of the cache key even when listed in CacheKeyHeaders
+func TestAuthorizationIgnoreOverridesCacheKeyHeaders(t *testing.T) {
+	tp := NewMemoryCacheTransport()
+	tp.CacheKeyHeaders = []string{"authorization", "X-Tenant"}
+	tp.AuthorizationPolicy = AuthorizationIgnore
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("Authorization", "Bearer token1")
+	req.Header.Set("X-Tenant", "acme")
+	_, headers, _ := tp.KeyComponents(req)
+	if _, ok := headers["Authorization"]; ok || headers["X-Tenant"] != "acme" {
+		t.Errorf("expected only X-Tenant in the key, got %v", headers)
+	}
+
+	tp.AuthorizationPolicy = AuthorizationPerCredential
+	tp.CacheKeyHeaders = nil
+	if _, headers, _ := tp.KeyComponents(req); headers["Authorization"] == "" {
+		t.Errorf("expected Authorization in the key, got %v", headers)
+	}
+}
+
+// TestAuthorizationPerCredentialVarySeparation tests that variant entries keep
+// the credential of the request in their key, so a varying response is never
+// served to another credential
+func TestAuthorizationPerCredentialVarySeparation(t *testing.T) {
+	resetTest()
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Vary", "Accept")
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer testServer.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.IsPublicCache = true
+	tp.AuthorizationPolicy = AuthorizationPerCredential
+	tp.EnableVarySeparation = true
+	client := tp.Client()
+
+	get := func(token string) (string, bool) {
+		req, _ := http.NewRequest(http.MethodGet, testServer.URL, nil)
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Accept", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return string(body), resp.Header.Get(XFromCache) == "1"
+	}
+
+	get("Bearer alice")
+	get("Bearer bob")
+	for _, token := range []string{"Bearer alice", "Bearer bob"} {
+		body, cached := get(token)
+		if body != token {
+			t.Errorf("%s: expected its own response, got %q", token, body)
+		}
+		if !cached {
+			t.Errorf("%s: expected its variant to be served from cache", token)
+		}
+	}
+}
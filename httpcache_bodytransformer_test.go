@@ -0,0 +1,172 @@
+package httpcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+const prettyJSON = "{\n  \"name\": \"httpcache\",\n  \"tags\": [\n    \"cache\",\n    \"http\"\n  ]\n}\n"
+
+// minifyJSON is a BodyTransformer compacting JSON bodies
+func minifyJSON(contentType string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// TestBodyTransformerMinifiesStoredJSON verifies the first response is served
+// as received while the stored and later served copy is minified
+func TestBodyTransformerMinifiesStoredJSON(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith(prettyJSON, "Cache-Control", "max-age=3600", "Content-Type", "application/json; charset=utf-8"))
+
+	tp := NewMemoryCacheTransport()
+	tp.BodyTransformer = minifyJSON
+	client := tp.Client()
+
+	resp, body := getBody(t, client, ts.URL)
+	if resp.Header.Get(XFromCache) != "" {
+		t.Fatal("expected first response from origin")
+	}
+	if body != prettyJSON {
+		t.Errorf("expected original body %q on first response, got %q", prettyJSON, body)
+	}
+
+	const minified = `{"name":"httpcache","tags":["cache","http"]}`
+	stored, storedBody := storedGET(t, tp, ts.URL)
+	if storedBody != minified {
+		t.Errorf("expected stored body %q, got %q", minified, storedBody)
+	}
+	if stored.ContentLength != int64(len(minified)) {
+		t.Errorf("expected stored Content-Length %d, got %d", len(minified), stored.ContentLength)
+	}
+
+	resp, body = getBody(t, client, ts.URL)
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected second response from cache")
+	}
+	if body != minified {
+		t.Errorf("expected cached body %q, got %q", minified, body)
+	}
+	if got := resp.Header.Get("Content-Length"); got != strconv.Itoa(len(minified)) {
+		t.Errorf("expected Content-Length %d, got %q", len(minified), got)
+	}
+}
+
+// TestBodyTransformerSkipsNonText verifies binary and encoded bodies are
+// stored untouched
+func TestBodyTransformerSkipsNonText(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+	}{
+		{"binary", "image/png", ""},
+		{"encoded JSON", "application/json", "br"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", "max-age=3600")
+				w.Header().Set("Content-Type", tt.contentType)
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				_, _ = w.Write([]byte(prettyJSON))
+			}))
+			defer ts.Close()
+
+			called := false
+			tp := NewMemoryCacheTransport()
+			tp.BodyTransformer = func(contentType string, body []byte) ([]byte, error) {
+				called = true
+				return []byte("transformed"), nil
+			}
+			fetchAndDrain(t, tp.Client(), ts.URL)
+
+			if called {
+				t.Error("expected BodyTransformer not to be called")
+			}
+			if _, body := storedGET(t, tp, ts.URL); body != prettyJSON {
+				t.Errorf("expected stored body unchanged, got %q", body)
+			}
+		})
+	}
+}
+
+// TestBodyTransformerErrorStoresOriginal verifies a failing transformer
+// leaves the stored body as received
+func TestBodyTransformerErrorStoresOriginal(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith("not json", "Cache-Control", "max-age=3600", "Content-Type", "text/plain"))
+
+	tp := NewMemoryCacheTransport()
+	tp.BodyTransformer = minifyJSON
+	fetchAndDrain(t, tp.Client(), ts.URL)
+
+	if _, body := storedGET(t, tp, ts.URL); body != "not json" {
+		t.Errorf("expected original body stored, got %q", body)
+	}
+}
+
+// TestBodyTransformerNonGET verifies responses stored immediately, such as a
+// cacheable POST, also keep the original body for the first client
+func TestBodyTransformerNonGET(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith(prettyJSON, "Cache-Control", "max-age=3600", "Content-Type", "application/json"))
+
+	tp := NewMemoryCacheTransport()
+	tp.CacheableMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	tp.BodyTransformer = minifyJSON
+	client := tp.Client()
+
+	post := func() (*http.Response, string) {
+		resp, err := client.Post(ts.URL, "text/plain", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(body)
+	}
+
+	if _, body := post(); body != prettyJSON {
+		t.Errorf("expected original body on first response, got %q", body)
+	}
+	resp, body := post()
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected second response from cache")
+	}
+	if body != `{"name":"httpcache","tags":["cache","http"]}` {
+		t.Errorf("expected minified cached body, got %q", body)
+	}
+}
+
+func TestIsTextContentType(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"text/html; charset=utf-8": true,
+		"application/json":         true,
+		"application/problem+json": true,
+		"application/xml":          true,
+		"image/svg+xml":            true,
+		"application/javascript":   true,
+		"application/octet-stream": false,
+		"image/png":                false,
+		"":                         false,
+	} {
+		if got := isTextContentType(contentType); got != want {
+			t.Errorf("isTextContentType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingPool is a BufferPool counting the buffers taken and returned
+type countingPool struct {
+	BufferPool
+	gets, puts atomic.Int64
+}
+
+func (p *countingPool) Get() *bytes.Buffer {
+	p.gets.Add(1)
+	return p.BufferPool.Get()
+}
+
+func (p *countingPool) Put(buf *bytes.Buffer) {
+	p.puts.Add(1)
+	p.BufferPool.Put(buf)
+}
+
+func TestBufferPool(t *testing.T) {
+	pool := NewBufferPool(16)
+	buf := pool.Get()
+	buf.WriteString("data")
+	pool.Put(buf)
+	if got := pool.Get(); got.Len() != 0 {
+		t.Errorf("expected an empty buffer, got %q", got.String())
+	}
+
+	large := bytes.NewBuffer(make([]byte, 0, 1024))
+	pool.Put(large)
+	for i := 0; i < 10; i++ {
+		if pool.Get() == large {
+			t.Fatal("expected a buffer beyond maxSize not to be pooled")
+		}
+	}
+}
+
+// TestDumpPooledResponse verifies the pooled serialization matches the default one
+func TestDumpPooledResponse(t *testing.T) {
+	for _, body := range []string{"", "data", strings.Repeat("x", 100000)} {
+		want, err := dumpStoredResponse(newStoredResponse(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := dumpPooledResponse(newStoredResponse(body), NewBufferPool(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("body of %d bytes: expected %q, got %q", len(body), want, got)
+		}
+	}
+}
+
+// TestBufferPoolTransport verifies responses stored with a BufferPool are served
+// back intact under concurrent load, and every buffer taken is returned
+func TestBufferPoolTransport(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte(strings.Repeat(r.URL.Path, 100)))
+	}))
+	defer ts.Close()
+
+	pool := &countingPool{BufferPool: NewBufferPool(0)}
+	tp := NewMemoryCacheTransport()
+	tp.BufferPool = pool
+	client := tp.Client()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := fmt.Sprintf("/item-%d", i%5)
+			for j := 0; j < 5; j++ {
+				resp, err := client.Get(ts.URL + path)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != strings.Repeat(path, 100) {
+					t.Errorf("%s: unexpected body of %d bytes", path, len(body))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if pool.gets.Load() == 0 || pool.gets.Load() != pool.puts.Load() {
+		t.Errorf("expected every buffer taken to be returned, got %d gets and %d puts", pool.gets.Load(), pool.puts.Load())
+	}
+}
+
+// TestCachingReadCloserPoolIncomplete verifies the buffer of an interrupted body
+// is returned to the pool without storing anything
+func TestCachingReadCloserPoolIncomplete(t *testing.T) {
+	pool := &countingPool{BufferPool: NewBufferPool(0)}
+	r := &cachingReadCloser{
+		R:     io.NopCloser(strings.NewReader(strings.Repeat("x", 100))),
+		Size:  100,
+		Pool:  pool,
+		OnEOF: func(io.Reader) { t.Error("expected no store for an incomplete body") },
+	}
+	if _, err := io.ReadFull(r, make([]byte, 50)); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	r.Close()
+	if pool.gets.Load() != 1 || pool.puts.Load() != 1 {
+		t.Errorf("expected the buffer to be returned once, got %d gets and %d puts", pool.gets.Load(), pool.puts.Load())
+	}
+}
+
+func benchmarkStore(b *testing.B, pool BufferPool) {
+	body := strings.Repeat(`{"id":1,"name":"item"},`, 40)
+	tp := NewTransport(&staticCache{})
+	tp.BufferPool = pool
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp := newStoredResponse(body)
+		tp.setupCachingBody(resp, resp.Request, "key")
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+}
+
+func BenchmarkStoreResponse(b *testing.B) {
+	benchmarkStore(b, nil)
+}
+
+func BenchmarkStoreResponseBufferPool(b *testing.B) {
+	benchmarkStore(b, NewBufferPool(0))
+}
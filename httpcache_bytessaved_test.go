@@ -0,0 +1,70 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestBytesSavedFromOrigin verifies the body bytes of hits accumulate across
+// fresh and revalidated hits, while misses don't count
+func TestBytesSavedFromOrigin(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("0123456789"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL) // miss
+	if got := tp.BytesSavedFromOrigin(); got != 0 {
+		t.Fatalf("expected nothing saved on a miss, got %d", got)
+	}
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL) // hit
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL) // hit
+	if got := tp.BytesSavedFromOrigin(); got != 20 {
+		t.Fatalf("expected 20 bytes saved after two hits, got %d", got)
+	}
+
+	// An entry revalidated with a 304 is served from cache too
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL)
+	if got := tp.BytesSavedFromOrigin(); got != 30 {
+		t.Fatalf("expected 30 bytes saved after a revalidation, got %d", got)
+	}
+
+	// HEAD hits have no body
+	clock = &fakeClock{}
+	doHeadUpdateRequest(t, tp, http.MethodHead, ts.URL)
+	doHeadUpdateRequest(t, tp, http.MethodHead, ts.URL)
+	if got := tp.BytesSavedFromOrigin(); got != 30 {
+		t.Fatalf("expected HEAD hits to save no body bytes, got %d", got)
+	}
+}
+
+// TestBytesSavedWithoutMarkers verifies bytes are counted with
+// MarkCachedResponses off
+func TestBytesSavedWithoutMarkers(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("0123456789"))
+	}))
+	defer ts.Close()
+
+	tp := &Transport{Cache: NewMemoryCache()}
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL) // miss
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL) // hit
+	if got := tp.BytesSavedFromOrigin(); got != 10 {
+		t.Fatalf("expected 10 bytes saved, got %d", got)
+	}
+}
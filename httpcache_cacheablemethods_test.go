@@ -0,0 +1,104 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func doMethodRequest(t *testing.T, tp *Transport, method, url string) *http.Response {
+	t.Helper()
+	var body io.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader("payload")
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+func TestCacheableMethodsEnablesPOST(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith("response", "Cache-Control", "max-age=3600"))
+
+	tp := NewMemoryCacheTransport()
+	tp.CacheableMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+	doMethodRequest(t, tp, http.MethodPost, ts.URL)
+	if resp := doMethodRequest(t, tp, http.MethodPost, ts.URL); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected the second POST to be served from cache")
+	}
+	if ts.requests(withMethod(http.MethodPost)) != 1 {
+		t.Fatalf("expected 1 POST to the origin, got %d", ts.requests(withMethod(http.MethodPost)))
+	}
+}
+
+func TestCacheableMethodsKeepsInvalidation(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith("response", "Cache-Control", "max-age=3600"))
+
+	tp := NewMemoryCacheTransport()
+	tp.CacheableMethods = []string{http.MethodGet, http.MethodPost}
+
+	doMethodRequest(t, tp, http.MethodGet, ts.URL)
+	doMethodRequest(t, tp, http.MethodPost, ts.URL)
+
+	// The POST reached the origin, so the GET entry is invalidated
+	if resp := doMethodRequest(t, tp, http.MethodGet, ts.URL); resp.Header.Get(XFromCache) != "" {
+		t.Fatal("expected the GET entry to be invalidated by the POST")
+	}
+
+	// A POST served from cache doesn't reach the origin and invalidates nothing
+	if resp := doMethodRequest(t, tp, http.MethodPost, ts.URL); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected the POST to be served from cache")
+	}
+	if resp := doMethodRequest(t, tp, http.MethodGet, ts.URL); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected the GET entry to survive a cached POST")
+	}
+	if ts.requests(withMethod(http.MethodGet)) != 2 || ts.requests(withMethod(http.MethodPost)) != 1 {
+		t.Fatalf("unexpected origin requests: %d GET, %d POST", ts.requests(withMethod(http.MethodGet)), ts.requests(withMethod(http.MethodPost)))
+	}
+}
+
+func TestCacheableMethodsDisabledMethodBypassesCache(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith("response", "Cache-Control", "max-age=3600"))
+
+	tp := NewMemoryCacheTransport()
+	tp.CacheableMethods = []string{http.MethodGet}
+
+	for i := 0; i < 2; i++ {
+		if resp := doMethodRequest(t, tp, http.MethodHead, ts.URL); resp.Header.Get(XFromCache) != "" {
+			t.Fatal("expected HEAD to bypass the cache")
+		}
+	}
+	if ts.requests(withMethod(http.MethodHead)) != 2 {
+		t.Fatalf("expected 2 HEAD requests to the origin, got %d", ts.requests(withMethod(http.MethodHead)))
+	}
+
+	doMethodRequest(t, tp, http.MethodGet, ts.URL)
+	if resp := doMethodRequest(t, tp, http.MethodGet, ts.URL); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected GET to be cached")
+	}
+}
+
+func TestCacheableMethodsDefault(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith("response", "Cache-Control", "max-age=3600"))
+
+	tp := NewMemoryCacheTransport()
+	doMethodRequest(t, tp, http.MethodPost, ts.URL)
+	doMethodRequest(t, tp, http.MethodPost, ts.URL)
+	if ts.requests(withMethod(http.MethodPost)) != 2 {
+		t.Fatalf("expected POST not to be cached by default, got %d origin requests", ts.requests(withMethod(http.MethodPost)))
+	}
+}
@@ -0,0 +1,84 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// registerEdgeTTL registers an x-edge-ttl extension setting the lifetime in
+// seconds, removing it when the test ends
+func registerEdgeTTL(t *testing.T) {
+	t.Helper()
+	RegisterCacheControlExtension("X-Edge-TTL", func(value string, decision *FreshnessDecision) {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			decision.Lifetime = time.Duration(seconds) * time.Second
+		}
+	})
+	t.Cleanup(func() { RegisterCacheControlExtension("x-edge-ttl", nil) })
+}
+
+// TestCacheControlExtension verifies a registered extension directive sets the
+// lifetime of a response with no standard freshness, and overrides max-age
+func TestCacheControlExtension(t *testing.T) {
+	registerEdgeTTL(t)
+
+	tests := []struct {
+		name         string
+		cacheControl string
+		elapsed      time.Duration
+		want         int
+	}{
+		{"within extension lifetime", "x-edge-ttl=60", 30 * time.Second, fresh},
+		{"past extension lifetime", "x-edge-ttl=60", 90 * time.Second, stale},
+		{"extension over max-age", "max-age=10, x-edge-ttl=60", 30 * time.Second, fresh},
+		{"invalid value keeps max-age", "max-age=10, x-edge-ttl=soon", 30 * time.Second, stale},
+		{"no-cache still applies", "no-cache, x-edge-ttl=60", 30 * time.Second, stale},
+		{"unregistered directive ignored", "x-other=60", 30 * time.Second, stale},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			clock = &fakeClock{elapsed: tt.elapsed}
+			respHeaders := http.Header{}
+			respHeaders.Set("Date", time.Now().UTC().Format(time.RFC1123))
+			respHeaders.Set("Cache-Control", tt.cacheControl)
+			if got := getFreshness(respHeaders, http.Header{}); got != tt.want {
+				t.Errorf("expected freshness %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestCacheControlExtensionRequestDirectives verifies request directives still
+// apply on top of an extension lifetime
+func TestCacheControlExtensionRequestDirectives(t *testing.T) {
+	registerEdgeTTL(t)
+	resetTest()
+	clock = &fakeClock{elapsed: 30 * time.Second}
+
+	respHeaders := http.Header{}
+	respHeaders.Set("Date", time.Now().UTC().Format(time.RFC1123))
+	respHeaders.Set("Cache-Control", "x-edge-ttl=60")
+	reqHeaders := http.Header{}
+	reqHeaders.Set("Cache-Control", "max-age=10")
+	if got := getFreshness(respHeaders, reqHeaders); got != stale {
+		t.Errorf("expected the request max-age to make the entry stale, got %d", got)
+	}
+}
+
+// TestRegisterCacheControlExtensionRemove verifies a nil handler removes the extension
+func TestRegisterCacheControlExtensionRemove(t *testing.T) {
+	registerEdgeTTL(t)
+	RegisterCacheControlExtension("x-edge-ttl", nil)
+
+	resetTest()
+	clock = &fakeClock{elapsed: 30 * time.Second}
+	respHeaders := http.Header{}
+	respHeaders.Set("Date", time.Now().UTC().Format(time.RFC1123))
+	respHeaders.Set("Cache-Control", "x-edge-ttl=60")
+	if got := getFreshness(respHeaders, http.Header{}); got != stale {
+		t.Errorf("expected a removed extension to be ignored, got %d", got)
+	}
+}
@@ -0,0 +1,86 @@
+package httpcache
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// failingGetCache is a FallibleCache whose reads fail
+type failingGetCache struct {
+	Cache
+	err error
+}
+
+func (c failingGetCache) GetWithError(string) ([]byte, bool, error) {
+	return nil, false, c.err
+}
+
+func (c failingGetCache) SetWithError(key string, value []byte) error {
+	c.Set(key, value)
+	return nil
+}
+
+func (c failingGetCache) DeleteWithError(key string) error {
+	c.Delete(key)
+	return nil
+}
+
+func TestCachedResponseE(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+
+	t.Run("miss", func(t *testing.T) {
+		resp, err := CachedResponseE(tp.Cache, req)
+		if !errors.Is(err, ErrCacheMiss) {
+			t.Fatalf("expected ErrCacheMiss, got %v", err)
+		}
+		if resp != nil {
+			t.Error("expected nil response on miss")
+		}
+	})
+
+	t.Run("hit", func(t *testing.T) {
+		fetchAndDrain(t, tp.Client(), ts.URL)
+		resp, err := CachedResponseE(tp.Cache, req)
+		if err != nil {
+			t.Fatalf("expected hit, got %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "data" {
+			t.Errorf("expected body %q, got %q", "data", body)
+		}
+	})
+
+	t.Run("backend error", func(t *testing.T) {
+		backendErr := errors.New("connection refused")
+		resp, err := CachedResponseE(failingGetCache{Cache: tp.Cache, err: backendErr}, req)
+		if !errors.Is(err, backendErr) {
+			t.Fatalf("expected backend error, got %v", err)
+		}
+		if errors.Is(err, ErrCacheMiss) {
+			t.Error("a backend error must not be reported as a miss")
+		}
+		if resp != nil {
+			t.Error("expected nil response on error")
+		}
+	})
+
+	t.Run("corrupted entry", func(t *testing.T) {
+		tp.Cache.Set(cacheKey(req), []byte("garbage"))
+		_, err := CachedResponseE(tp.Cache, req)
+		if !errors.Is(err, ErrInvalidCachedResponse) {
+			t.Fatalf("expected ErrInvalidCachedResponse, got %v", err)
+		}
+	})
+}
@@ -338,15 +338,14 @@ func TestCacheKeyHeadersInvalidation(t *testing.T) {
 	io.ReadAll(resp3.Body)
 	resp3.Body.Close()
 
-	// Note: Cache invalidation with CacheKeyHeaders invalidates only the base URL,
-	// not the header-specific entries. This is intentional behavior.
-	// The cache for this specific Authorization header combination is still valid.
-	if requestCount != 2 {
-		t.Fatalf("Expected 2 requests (cache with headers still valid), got %d", requestCount)
+	// The POST invalidates the entry keyed by its own CacheKeyHeaders values
+	// (RFC 9111 Section 4.4)
+	if requestCount != 3 {
+		t.Fatalf("Expected 3 requests (header-specific cache invalidated), got %d", requestCount)
 	}
 
-	if resp3.Header.Get(XFromCache) != "1" {
-		t.Fatal("Expected response to be served from cache (header-specific cache not invalidated)")
+	if resp3.Header.Get(XFromCache) != "" {
+		t.Fatal("Expected response not to be served from cache after invalidation")
 	}
 }
 
@@ -375,7 +374,7 @@ func TestCacheKeyFormat(t *testing.T) {
 				"Authorization": "Bearer token1",
 			},
 			cacheKeyHeaders: []string{"Authorization"},
-			expectedKey:     "http://example.com/test|Authorization:Bearer token1",
+			expectedKey:     "http://example.com/test|Authorization:sha256:1387b259d33fb41393f3999bd79b51389475e329b65be9b0429e28d683b58026",
 		},
 		{
 			name:   "GET with multiple cache key headers",
@@ -386,7 +385,7 @@ func TestCacheKeyFormat(t *testing.T) {
 				"Accept-Language": "en",
 			},
 			cacheKeyHeaders: []string{"Authorization", "Accept-Language"},
-			expectedKey:     "http://example.com/test|Accept-Language:en|Authorization:Bearer token1",
+			expectedKey:     "http://example.com/test|Accept-Language:en|Authorization:sha256:1387b259d33fb41393f3999bd79b51389475e329b65be9b0429e28d683b58026",
 		},
 		{
 			name:            "POST without cache key headers",
@@ -403,7 +402,7 @@ func TestCacheKeyFormat(t *testing.T) {
 				"Authorization": "Bearer token1",
 			},
 			cacheKeyHeaders: []string{"Authorization"},
-			expectedKey:     "POST http://example.com/test|Authorization:Bearer token1",
+			expectedKey:     "POST http://example.com/test|Authorization:sha256:1387b259d33fb41393f3999bd79b51389475e329b65be9b0429e28d683b58026",
 		},
 		{
 			name:   "GET with cache key header but header not present in request",
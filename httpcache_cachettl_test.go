@@ -0,0 +1,83 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestMarkCacheTTL verifies X-Cache-TTL reports the remaining freshness and
+// decreases as the stored response ages
+func TestMarkCacheTTL(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=100")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.MarkCacheTTL = true
+	get := func(cacheControl string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		if cacheControl != "" {
+			req.Header.Set("Cache-Control", cacheControl)
+		}
+		resp, err := tp.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := get(""); resp.Header.Get(XCacheTTL) != "" {
+		t.Fatalf("expected no X-Cache-TTL on a response from the origin, got %q", resp.Header.Get(XCacheTTL))
+	}
+
+	clock = &fakeClock{elapsed: 10 * time.Second}
+	if got := get("").Header.Get(XCacheTTL); got != "90" {
+		t.Fatalf("expected X-Cache-TTL 90, got %q", got)
+	}
+
+	clock = &fakeClock{elapsed: 40 * time.Second}
+	if got := get("").Header.Get(XCacheTTL); got != "60" {
+		t.Fatalf("expected X-Cache-TTL 60, got %q", got)
+	}
+
+	clock = &fakeClock{elapsed: 130 * time.Second}
+	resp := get("max-stale")
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected the stale response to be served from cache")
+	}
+	if got := resp.Header.Get(XCacheTTL); got != "-30" {
+		t.Fatalf("expected X-Cache-TTL -30 for a stale response, got %q", got)
+	}
+}
+
+// TestMarkCacheTTLDisabled verifies X-Cache-TTL is opt-in
+func TestMarkCacheTTLDisabled(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=100")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	client := NewMemoryCacheTransport().Client()
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.Header.Get(XCacheTTL) != "" {
+			t.Fatalf("expected no X-Cache-TTL header, got %q", resp.Header.Get(XCacheTTL))
+		}
+	}
+}
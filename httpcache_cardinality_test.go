@@ -0,0 +1,86 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestKeyCardinalityMonitorFires verifies the callback fires when a high-cardinality
+// header in CacheKeyHeaders creates too many distinct entries for one URL
+func TestKeyCardinalityMonitorFires(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	var firedURL string
+	firedCount := 0
+	tp := NewMemoryCacheTransport()
+	tp.CacheKeyHeaders = []string{"X-Nonce"}
+	tp.KeyCardinalityMonitor = NewKeyCardinalityMonitor(3, time.Minute, func(baseURL string, uniqueKeys int) {
+		firedURL = baseURL
+		firedCount++
+	})
+	client := tp.Client()
+
+	for i := 0; i < 10; i++ {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		req.Header.Set("X-Nonce", strconv.Itoa(i))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	if firedCount != 1 {
+		t.Fatalf("expected callback to fire once per window, fired %d times", firedCount)
+	}
+	if firedURL != ts.URL {
+		t.Fatalf("expected base URL %q, got %q", ts.URL, firedURL)
+	}
+}
+
+// TestKeyCardinalityMonitorBelowThreshold verifies repeated keys don't trigger the callback
+func TestKeyCardinalityMonitorBelowThreshold(t *testing.T) {
+	fired := false
+	m := NewKeyCardinalityMonitor(2, time.Minute, func(string, int) { fired = true })
+
+	for i := 0; i < 10; i++ {
+		m.Observe("http://example.com/", "http://example.com/|X-Lang:en")
+		m.Observe("http://example.com/", "http://example.com/|X-Lang:it")
+	}
+
+	if fired {
+		t.Fatal("callback fired although only 2 distinct keys were stored")
+	}
+}
+
+// TestKeyCardinalityMonitorWindowReset verifies counting restarts with a new window
+func TestKeyCardinalityMonitorWindowReset(t *testing.T) {
+	now := time.Now()
+	fired := 0
+	m := NewKeyCardinalityMonitor(2, time.Minute, func(string, int) { fired++ })
+	m.now = func() time.Time { return now }
+
+	m.Observe("u", "k1")
+	m.Observe("u", "k2")
+	now = now.Add(2 * time.Minute)
+	m.Observe("u", "k3")
+	m.Observe("u", "k4")
+	if fired != 0 {
+		t.Fatalf("expected no callback across windows, got %d", fired)
+	}
+
+	m.Observe("u", "k5")
+	if fired != 1 {
+		t.Fatalf("expected callback after threshold exceeded in window, got %d", fired)
+	}
+}
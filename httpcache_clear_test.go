@@ -0,0 +1,46 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestTransportClear verifies Clear empties the cache, so the next request
+// reaches the origin
+func TestTransportClear(t *testing.T) {
+	resetTest()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	client := tp.Client()
+	fetchAndDrain(t, client, ts.URL)
+	fetchAndDrain(t, client, ts.URL)
+	if requests != 1 {
+		t.Fatalf("expected the second request to be served from cache, got %d origin requests", requests)
+	}
+
+	if err := tp.Clear(context.Background()); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	fetchAndDrain(t, client, ts.URL)
+	if requests != 2 {
+		t.Errorf("expected a request after Clear to reach the origin, got %d origin requests", requests)
+	}
+}
+
+// TestTransportClearNotSupported verifies Clear reports backends that can't be cleared
+func TestTransportClearNotSupported(t *testing.T) {
+	tp := NewTransport(&staticCache{})
+	if err := tp.Clear(context.Background()); !errors.Is(err, ErrClearNotSupported) {
+		t.Errorf("expected ErrClearNotSupported, got %v", err)
+	}
+}
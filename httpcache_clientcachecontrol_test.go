@@ -0,0 +1,64 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestClientCacheControlRewritesServedResponses verifies misses and hits carry
+// ClientCacheControl while the stored entry keeps the origin's Cache-Control
+// and freshness
+func TestClientCacheControlRewritesServedResponses(t *testing.T) {
+	resetTest()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "public, s-maxage=3600, max-age=3600")
+		w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.ClientCacheControl = "max-age=60"
+
+	miss := doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL)
+	if got := miss.Header.Get("Cache-Control"); got != "max-age=60" {
+		t.Fatalf("expected the miss to carry the client Cache-Control, got %q", got)
+	}
+
+	stored, _ := storedGET(t, tp, ts.URL)
+	if got := stored.Header.Get("Cache-Control"); got != "public, s-maxage=3600, max-age=3600" {
+		t.Fatalf("expected the stored entry to keep the origin Cache-Control, got %q", got)
+	}
+
+	// Past the client max-age but within the origin's: still a fresh hit
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	hit := doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL)
+	if hit.Header.Get(XFromCache) != "1" || requests != 1 {
+		t.Fatalf("expected a fresh hit using the origin freshness, got %d requests", requests)
+	}
+	if got := hit.Header.Get("Cache-Control"); got != "max-age=60" {
+		t.Fatalf("expected the hit to carry the client Cache-Control, got %q", got)
+	}
+}
+
+// TestClientCacheControlKeepsPrivateResponses verifies no-store and private
+// responses are not made cacheable downstream
+func TestClientCacheControlKeepsPrivateResponses(t *testing.T) {
+	resetTest()
+	for _, cc := range []string{"no-store", "private, max-age=60"} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", cc)
+			w.Write([]byte("data"))
+		}))
+
+		tp := NewMemoryCacheTransport()
+		tp.ClientCacheControl = "public, max-age=60"
+		if got := doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL).Header.Get("Cache-Control"); got != cc {
+			t.Errorf("expected %q unchanged, got %q", cc, got)
+		}
+		ts.Close()
+	}
+}
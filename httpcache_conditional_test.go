@@ -0,0 +1,240 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const conditionalLastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+func doConditionalGet(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+// TestNotModifiedKeyedOnETagOnly verifies a 304 from a server that validates only
+// If-None-Match and echoes only the ETag serves the cached body with merged headers
+func TestNotModifiedKeyedOnETagOnly(t *testing.T) {
+	resetTest()
+	var gotIfNoneMatch, gotIfModifiedSince string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIfNoneMatch = r.Header.Get("If-None-Match")
+		gotIfModifiedSince = r.Header.Get("If-Modified-Since")
+		w.Header().Set("Cache-Control", "no-cache")
+		if gotIfNoneMatch == `"v1"` {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("X-Version", "revalidated")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", conditionalLastModified)
+		w.Header().Set("X-Version", "original")
+		w.Write([]byte("etag body"))
+	}))
+	defer ts.Close()
+
+	client := NewMemoryCacheTransport().Client()
+	doConditionalGet(t, client, ts.URL)
+	resp, body := doConditionalGet(t, client, ts.URL)
+
+	if gotIfNoneMatch != `"v1"` || gotIfModifiedSince != conditionalLastModified {
+		t.Fatalf("expected both validators, got If-None-Match=%q If-Modified-Since=%q", gotIfNoneMatch, gotIfModifiedSince)
+	}
+	if resp.StatusCode != http.StatusOK || body != "etag body" {
+		t.Fatalf("expected cached 200 body, got %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get(XRevalidated) != "1" {
+		t.Fatal("expected response to be marked as revalidated")
+	}
+	if got := resp.Header.Get("X-Version"); got != "revalidated" {
+		t.Fatalf("expected headers from 304 to be merged, got X-Version=%q", got)
+	}
+	if got := resp.Header.Get("Last-Modified"); got != conditionalLastModified {
+		t.Fatalf("expected stored Last-Modified to be kept, got %q", got)
+	}
+}
+
+// TestNotModifiedKeyedOnLastModifiedOnly verifies a 304 from a server that validates
+// only If-Modified-Since and sends no ETag serves the cached body
+func TestNotModifiedKeyedOnLastModifiedOnly(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-Modified-Since") == conditionalLastModified {
+			w.Header().Set("Last-Modified", conditionalLastModified)
+			w.Header().Set("X-Version", "revalidated")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", conditionalLastModified)
+		w.Header().Set("X-Version", "original")
+		w.Write([]byte("last-modified body"))
+	}))
+	defer ts.Close()
+
+	client := NewMemoryCacheTransport().Client()
+	doConditionalGet(t, client, ts.URL)
+	resp, body := doConditionalGet(t, client, ts.URL)
+
+	if resp.StatusCode != http.StatusOK || body != "last-modified body" {
+		t.Fatalf("expected cached 200 body, got %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get(XRevalidated) != "1" {
+		t.Fatal("expected response to be marked as revalidated")
+	}
+	if got := resp.Header.Get("X-Version"); got != "revalidated" {
+		t.Fatalf("expected headers from 304 to be merged, got X-Version=%q", got)
+	}
+	if got := resp.Header.Get("ETag"); got != `"v1"` {
+		t.Fatalf("expected stored ETag to be kept, got %q", got)
+	}
+}
+
+// TestNotModifiedMismatchedValidatorRefetches verifies a 304 carrying a different
+// ETag than the stored one is not used to update it (RFC 9111 §4.3.4)
+func TestNotModifiedMismatchedValidatorRefetches(t *testing.T) {
+	resetTest()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") != "" {
+			w.Header().Set("ETag", `"v2"`)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if requests == 1 {
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte("version 1"))
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		w.Write([]byte("version 2"))
+	}))
+	defer ts.Close()
+
+	client := NewMemoryCacheTransport().Client()
+	doConditionalGet(t, client, ts.URL)
+	resp, body := doConditionalGet(t, client, ts.URL)
+
+	if body != "version 2" {
+		t.Fatalf("expected refetched body, got %q", body)
+	}
+	if resp.Header.Get(XRevalidated) != "" {
+		t.Fatal("stored response must not be marked as revalidated")
+	}
+	if requests != 3 {
+		t.Fatalf("expected conditional request followed by a full fetch, got %d requests", requests)
+	}
+}
+
+func TestNotModifiedMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		stored   map[string]string
+		received map[string]string
+		want     bool
+	}{
+		{"no validators echoed", map[string]string{"ETag": `"a"`}, nil, true},
+		{"same etag", map[string]string{"ETag": `"a"`}, map[string]string{"ETag": `"a"`}, true},
+		{"weak etag", map[string]string{"ETag": `W/"a"`}, map[string]string{"ETag": `"a"`}, true},
+		{"different etag", map[string]string{"ETag": `"a"`}, map[string]string{"ETag": `"b"`}, false},
+		{"etag not stored", map[string]string{"Last-Modified": conditionalLastModified}, map[string]string{"ETag": `"a"`}, true},
+		{"same last-modified", map[string]string{"Last-Modified": conditionalLastModified}, map[string]string{"Last-Modified": "Monday, 02-Jan-06 15:04:05 GMT"}, true},
+		{"different last-modified", map[string]string{"Last-Modified": conditionalLastModified}, map[string]string{"Last-Modified": "Tue, 03 Jan 2006 15:04:05 GMT"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := &http.Response{Header: http.Header{}}
+			received := &http.Response{Header: http.Header{}}
+			for k, v := range tt.stored {
+				stored.Header.Set(k, v)
+			}
+			for k, v := range tt.received {
+				received.Header.Set(k, v)
+			}
+			if got := notModifiedMatches(stored, received); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestClientConditionalOnFreshEntry verifies a client's validators are answered
+// from a fresh cached entry: a 304 when they match, the cached 200 otherwise,
+// without contacting the origin
+func TestClientConditionalOnFreshEntry(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{"matching etag", "If-None-Match", `"v1"`, http.StatusNotModified},
+		{"matching weak etag in list", "If-None-Match", `"v0", W/"v1"`, http.StatusNotModified},
+		{"wildcard", "If-None-Match", "*", http.StatusNotModified},
+		{"other etag", "If-None-Match", `"v2"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", conditionalLastModified, http.StatusNotModified},
+		{"modified since", "If-Modified-Since", "Sun, 01 Jan 2006 15:04:05 GMT", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Header().Set("Cache-Control", "max-age=3600")
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("ETag", `"v1"`)
+				w.Header().Set("Last-Modified", conditionalLastModified)
+				w.Write([]byte("cached body"))
+			}))
+			defer ts.Close()
+
+			client := NewMemoryCacheTransport().Client()
+			doConditionalGet(t, client, ts.URL)
+
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+			req.Header.Set(tt.header, tt.value)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if requests != 1 {
+				t.Fatalf("expected the origin to be contacted once, got %d", requests)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if resp.Header.Get(XFromCache) != "1" {
+				t.Error("expected the response to be marked as served from cache")
+			}
+			if tt.status == http.StatusNotModified {
+				if len(body) != 0 || resp.Header.Get("Content-Type") != "" {
+					t.Errorf("expected a 304 without body and representation headers, got %q", body)
+				}
+				if resp.Header.Get("ETag") != `"v1"` || resp.Header.Get("Cache-Control") == "" {
+					t.Error("expected the 304 to carry the ETag and Cache-Control of the entry")
+				}
+			} else if string(body) != "cached body" {
+				t.Errorf("expected the cached body, got %q", body)
+			}
+		})
+	}
+}
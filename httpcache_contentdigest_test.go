@@ -0,0 +1,154 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func sha256Digest(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// corruptStoredBody flips a byte of the body stored for url
+func corruptStoredBody(t *testing.T, tp *Transport, url, body string) {
+	t.Helper()
+	raw, ok := tp.Cache.Get(url)
+	if !ok {
+		t.Fatal("expected a stored entry")
+	}
+	corrupted := bytes.Replace(raw, []byte(body), []byte("X"+body[1:]), 1)
+	if bytes.Equal(corrupted, raw) {
+		t.Fatal("body not found in the stored entry")
+	}
+	tp.Cache.Set(url, corrupted)
+}
+
+// TestVerifyContentDigest verifies a stored body matching its Content-Digest is
+// served, and a corrupted one is refetched
+func TestVerifyContentDigest(t *testing.T) {
+	resetTest()
+	const body = "hello digest"
+	ts := newTestOrigin(t, respondWith(body, "Cache-Control", "max-age=3600", "Content-Digest", sha256Digest(body)))
+
+	tp := NewMemoryCacheTransport()
+	tp.VerifyContentDigest = true
+	client := tp.Client()
+
+	fetchAndDrain(t, client, ts.URL)
+	resp, got := getBody(t, client, ts.URL)
+	if resp.Header.Get(XFromCache) != "1" || got != body {
+		t.Fatalf("expected the intact entry from cache, got %q from cache %q", got, resp.Header.Get(XFromCache))
+	}
+
+	corruptStoredBody(t, tp, ts.URL, body)
+	resp, got = getBody(t, client, ts.URL)
+	if resp.Header.Get(XFromCache) != "" {
+		t.Error("expected the corrupted entry not to be served")
+	}
+	if got != body {
+		t.Errorf("expected body %q, got %q", body, got)
+	}
+	if n := ts.requests(nil); n != 2 {
+		t.Errorf("expected 2 origin requests, got %d", n)
+	}
+
+	// The refetched response replaces the corrupted entry
+	resp, got = getBody(t, client, ts.URL)
+	if resp.Header.Get(XFromCache) != "1" || got != body {
+		t.Errorf("expected the refetched entry from cache, got %q from cache %q", got, resp.Header.Get(XFromCache))
+	}
+}
+
+// TestVerifyContentDigestDisabled verifies stored bodies aren't checked by default
+func TestVerifyContentDigestDisabled(t *testing.T) {
+	resetTest()
+	const body = "hello digest"
+	ts := newTestOrigin(t, respondWith(body, "Cache-Control", "max-age=3600", "Content-Digest", sha256Digest(body)))
+
+	tp := NewMemoryCacheTransport()
+	client := tp.Client()
+
+	fetchAndDrain(t, client, ts.URL)
+	corruptStoredBody(t, tp, ts.URL, body)
+	if resp, _ := getBody(t, client, ts.URL); resp.Header.Get(XFromCache) != "1" {
+		t.Error("expected the stored entry to be served unchecked")
+	}
+	if n := ts.requests(nil); n != 1 {
+		t.Errorf("expected 1 origin request, got %d", n)
+	}
+}
+
+// TestVerifyContentDigestNotModified verifies a 304 carrying another
+// Content-Digest than the stored response triggers a full refetch
+func TestVerifyContentDigestNotModified(t *testing.T) {
+	resetTest()
+	var version atomic.Int64
+	version.Store(1)
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body := "version 1"
+		if version.Load() == 2 {
+			body = "version 2"
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		// A misbehaving origin whose ETag doesn't change with the content
+		w.Header().Set("ETag", `"static"`)
+		w.Header().Set("Content-Digest", sha256Digest(body))
+		if r.Header.Get("If-None-Match") == `"static"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		verify   bool
+		wantBody string
+		wantReqs int64
+	}{
+		{"disabled", false, "version 1", 2},
+		{"enabled", true, "version 2", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			version.Store(1)
+			requests.Store(0)
+			tp := NewMemoryCacheTransport()
+			tp.VerifyContentDigest = tt.verify
+			client := tp.Client()
+
+			fetchAndDrain(t, client, ts.URL)
+			version.Store(2)
+			if _, got := getBody(t, client, ts.URL); got != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, got)
+			}
+			if n := requests.Load(); n != tt.wantReqs {
+				t.Errorf("expected %d origin requests, got %d", tt.wantReqs, n)
+			}
+		})
+	}
+}
+
+// TestParseContentDigest verifies supported digests are parsed and others ignored
+func TestParseContentDigest(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Digest", `md5=:AAAA:, SHA-256=:`+base64.StdEncoding.EncodeToString([]byte("abc"))+`:;p=1, sha-512=notbytes`)
+
+	digests := parseContentDigest(header)
+	if len(digests) != 1 {
+		t.Fatalf("expected 1 digest, got %v", digests)
+	}
+	if got := string(digests["sha-256"]); got != "abc" {
+		t.Errorf("unexpected sha-256 digest %q", got)
+	}
+}
@@ -0,0 +1,124 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCacheKeyCookies verifies only the listed cookies separate cache entries
+func TestCacheKeyCookies(t *testing.T) {
+	resetTest()
+	var requests atomic.Int64
+	var lastCookie atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		lastCookie.Store(r.Header.Get("Cookie"))
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.CacheKeyCookies = []string{"flags", "theme"}
+	client := tp.Client()
+
+	get := func(cookie string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		req.Header.Set("Cookie", cookie)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainAndClose(resp)
+		return resp
+	}
+
+	get("flags=beta; session=abc")
+	if got, _ := lastCookie.Load().(string); !strings.Contains(got, "session=abc") {
+		t.Errorf("expected all cookies to be sent upstream, got %q", got)
+	}
+
+	tests := []struct {
+		cookie    string
+		fromCache bool
+	}{
+		{"flags=beta; session=xyz", true},
+		{"session=other; flags=beta; tracking=1", true},
+		{"flags=stable; session=abc", false},
+		{"session=abc", false},
+		{"theme=dark; flags=beta", false},
+	}
+	for _, tt := range tests {
+		if got := get(tt.cookie).Header.Get(XFromCache) == "1"; got != tt.fromCache {
+			t.Errorf("%q: expected from cache %v, got %v", tt.cookie, tt.fromCache, got)
+		}
+	}
+	if got := requests.Load(); got != 4 {
+		t.Errorf("expected 4 origin requests, got %d", got)
+	}
+}
+
+// TestCacheKeyCookiesHashed verifies cookie values don't appear in cache keys
+func TestCacheKeyCookiesHashed(t *testing.T) {
+	tp := NewMemoryCacheTransport()
+	tp.CacheKeyCookies = []string{"flags"}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.AddCookie(&http.Cookie{Name: "flags", Value: "secret-value"})
+
+	key := tp.requestCacheKey(req)
+	if strings.Contains(key, "secret-value") {
+		t.Errorf("expected the cookie value to be hashed, got key %q", key)
+	}
+	if !strings.Contains(key, "|cookies:flags=sha256:") {
+		t.Errorf("expected the cookie in the key, got %q", key)
+	}
+}
+
+// TestCacheKeyCookiesVarySeparation verifies variants of a varying response
+// stay separated by the listed cookies
+func TestCacheKeyCookiesVarySeparation(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flags, _ := r.Cookie("flags")
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte(flags.Value))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.CacheKeyCookies = []string{"flags"}
+	tp.EnableVarySeparation = true
+	client := tp.Client()
+
+	get := func(flags string) (string, bool) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		req.AddCookie(&http.Cookie{Name: "flags", Value: flags})
+		req.Header.Set("Accept-Language", "en")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return string(body), resp.Header.Get(XFromCache) == "1"
+	}
+
+	get("beta")
+	get("stable")
+	for _, flags := range []string{"beta", "stable"} {
+		body, cached := get(flags)
+		if body != flags {
+			t.Errorf("flags=%s: expected its own response, got %q", flags, body)
+		}
+		if !cached {
+			t.Errorf("flags=%s: expected its variant to be served from cache", flags)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestDecisionLogKeepsLastN verifies the buffer holds the most recent decisions,
+// oldest first
+func TestDecisionLogKeepsLastN(t *testing.T) {
+	resetTest()
+
+	tp := NewMemoryCacheTransport()
+	tp.DecisionLogSize = 3
+	if got := tp.RecentDecisions(); len(got) != 0 {
+		t.Fatalf("expected no decisions yet, got %v", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL+"/"+strconv.Itoa(i))
+	}
+
+	got := tp.RecentDecisions()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 decisions, got %d", len(got))
+	}
+	for i, d := range got {
+		if want := s.server.URL + "/" + strconv.Itoa(i+2); d.URL != want {
+			t.Errorf("decision %d: expected %s, got %s", i, want, d.URL)
+		}
+	}
+	if got[0].Time.After(got[2].Time) {
+		t.Error("expected decisions in chronological order")
+	}
+}
+
+// TestDecisionLogOutcomes verifies the recorded outcome, status and storability
+func TestDecisionLogOutcomes(t *testing.T) {
+	resetTest()
+
+	tp := NewMemoryCacheTransport()
+	tp.DecisionLogSize = 10
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL)
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL)
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL+"/nostore")
+	doHeadUpdateRequest(t, tp, http.MethodPost, s.server.URL)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	req, _ := http.NewRequest(http.MethodGet, closed.URL, nil)
+	if _, err := tp.RoundTrip(req); err == nil {
+		t.Fatal("expected the request to a closed server to fail")
+	}
+
+	want := []struct {
+		outcome      DecisionOutcome
+		status       int
+		stored       bool
+		cacheControl string
+	}{
+		{DecisionMiss, http.StatusOK, true, "max-age=3600"},
+		{DecisionHit, http.StatusOK, true, "max-age=3600"},
+		{DecisionMiss, http.StatusOK, false, "no-store"},
+		{DecisionBypass, http.StatusOK, false, "max-age=3600"},
+		{DecisionError, 0, false, ""},
+	}
+	got := tp.RecentDecisions()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d decisions, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		d := got[i]
+		if d.Outcome != w.outcome || d.StatusCode != w.status || d.Stored != w.stored || d.CacheControl != w.cacheControl {
+			t.Errorf("decision %d: expected %+v, got %+v", i, w, d)
+		}
+	}
+	if got[4].Err == "" {
+		t.Error("expected the error message to be recorded")
+	}
+}
+
+// TestDecisionLogWithoutMarkers verifies hits and revalidations are recorded
+// with MarkCachedResponses off
+func TestDecisionLogWithoutMarkers(t *testing.T) {
+	resetTest()
+	tp := &Transport{Cache: NewMemoryCache(), DecisionLogSize: 4}
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL)
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL)
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL+"/etag")
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL+"/etag")
+
+	want := []DecisionOutcome{DecisionMiss, DecisionHit, DecisionMiss, DecisionRevalidated}
+	got := tp.RecentDecisions()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d decisions, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		if got[i].Outcome != w {
+			t.Errorf("decision %d: expected %s, got %s", i, w, got[i].Outcome)
+		}
+	}
+}
+
+// TestDecisionLogConcurrent verifies the log stays bounded under concurrent use
+func TestDecisionLogConcurrent(t *testing.T) {
+	resetTest()
+
+	tp := NewMemoryCacheTransport()
+	tp.DecisionLogSize = 8
+	client := tp.Client()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Get(s.server.URL + "/" + strconv.Itoa(i%5))
+			if err == nil {
+				drainAndClose(resp)
+			}
+			tp.RecentDecisions()
+		}(i)
+	}
+	wg.Wait()
+
+	if got := tp.RecentDecisions(); len(got) != 8 {
+		t.Errorf("expected the log to hold 8 decisions, got %d", len(got))
+	}
+}
+
+// TestDecisionLogDisabled verifies nothing is recorded by default
+func TestDecisionLogDisabled(t *testing.T) {
+	resetTest()
+
+	tp := NewMemoryCacheTransport()
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL)
+	if got := tp.RecentDecisions(); got != nil {
+		t.Errorf("expected no decisions, got %v", got)
+	}
+}
+
+// TestOnCacheDecision verifies the callback runs once per request, with the
+// cache key and the freshness of the entry found, without the decision log
+func TestOnCacheDecision(t *testing.T) {
+	resetTest()
+
+	tp := NewMemoryCacheTransport()
+	var got []Decision
+	tp.OnCacheDecision = func(req *http.Request, d Decision) {
+		if req.URL.String() != d.URL {
+			t.Errorf("expected the decision of %s, got %s", req.URL, d.URL)
+		}
+		got = append(got, d)
+	}
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL)
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL)
+	doHeadUpdateRequest(t, tp, http.MethodPost, s.server.URL)
+
+	want := []struct {
+		outcome   DecisionOutcome
+		key       string
+		freshness string
+		stored    bool
+	}{
+		{DecisionMiss, s.server.URL, "", true},
+		{DecisionHit, s.server.URL, "fresh", true},
+		{DecisionBypass, "POST " + s.server.URL, "", false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d decisions, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		d := got[i]
+		if d.Outcome != w.outcome || d.CacheKey != w.key || d.Freshness != w.freshness || d.Stored != w.stored {
+			t.Errorf("decision %d: expected %+v, got %+v", i, w, d)
+		}
+	}
+	if decisions := tp.RecentDecisions(); decisions != nil {
+		t.Errorf("expected no decision log without DecisionLogSize, got %v", decisions)
+	}
+}
+
+// TestOnCacheDecisionWithoutMarkers verifies outcomes don't depend on the marker
+// headers of MarkCachedResponses
+func TestOnCacheDecisionWithoutMarkers(t *testing.T) {
+	resetTest()
+	tp := &Transport{Cache: NewMemoryCache()}
+	var got []Decision
+	tp.OnCacheDecision = func(_ *http.Request, d Decision) {
+		got = append(got, d)
+	}
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL)
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL)
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL+"/etag")
+	doHeadUpdateRequest(t, tp, http.MethodGet, s.server.URL+"/etag")
+
+	want := []struct {
+		outcome     DecisionOutcome
+		fromCache   bool
+		revalidated bool
+	}{
+		{DecisionMiss, false, false},
+		{DecisionHit, true, false},
+		{DecisionMiss, false, false},
+		{DecisionRevalidated, true, true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d decisions, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		d := got[i]
+		if d.Outcome != w.outcome || d.FromCache != w.fromCache || d.Revalidated != w.revalidated {
+			t.Errorf("decision %d: expected %+v, got %+v", i, w, d)
+		}
+	}
+}
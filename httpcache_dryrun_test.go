@@ -0,0 +1,91 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeCountingCache is a MemoryCache counting the writes it receives
+type writeCountingCache struct {
+	*MemoryCache
+	mu     sync.Mutex
+	writes int
+}
+
+func (c *writeCountingCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	c.writes++
+	c.mu.Unlock()
+	c.MemoryCache.Set(key, value)
+}
+
+func (c *writeCountingCache) Delete(key string) {
+	c.mu.Lock()
+	c.writes++
+	c.mu.Unlock()
+	c.MemoryCache.Delete(key)
+}
+
+// TestDryRunStore verifies DryRunStore reports the entries that would be stored
+// without writing to the backend
+func TestDryRunStore(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/nostore" {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=3600")
+		}
+		w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	type intent struct {
+		key  string
+		size int
+		ttl  time.Duration
+	}
+	var intents []intent
+	cache := &writeCountingCache{MemoryCache: NewMemoryCache()}
+	tp := NewTransport(cache)
+	tp.DryRunStore = true
+	tp.OnDryRunStore = func(key string, size int, ttl time.Duration) {
+		intents = append(intents, intent{key, size, ttl})
+	}
+	client := tp.Client()
+
+	fetchAndDrain(t, client, ts.URL)
+	fetchAndDrain(t, client, ts.URL+"/nostore")
+
+	if cache.writes != 0 {
+		t.Errorf("expected no writes to the backend, got %d", cache.writes)
+	}
+	if len(intents) != 1 {
+		t.Fatalf("expected one would-be store, got %d", len(intents))
+	}
+	if intents[0].key != ts.URL {
+		t.Errorf("expected key %q, got %q", ts.URL, intents[0].key)
+	}
+	if intents[0].size <= len("data") {
+		t.Errorf("expected the size of the serialized entry, got %d", intents[0].size)
+	}
+	if intents[0].ttl <= 59*time.Minute || intents[0].ttl > time.Hour {
+		t.Errorf("expected a TTL of about an hour, got %v", intents[0].ttl)
+	}
+
+	// Nothing was stored, so the next request misses again
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+	if resp.Header.Get(XFromCache) != "" {
+		t.Error("expected a miss in dry run")
+	}
+	if len(intents) != 2 {
+		t.Errorf("expected the repeated request to be reported again, got %d reports", len(intents))
+	}
+}
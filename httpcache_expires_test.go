@@ -0,0 +1,78 @@
+package httpcache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestExpiresObsoleteDateFormats verifies Expires in the obsolete HTTP-date formats
+// (RFC 9110 §5.6.7) yields the intended lifetime instead of being treated as expired
+func TestExpiresObsoleteDateFormats(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	expires := now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		format string
+	}{
+		{"IMF-fixdate", http.TimeFormat},
+		{"RFC 850", time.RFC850},
+		{"asctime", time.ANSIC},
+		{"numeric offset", time.RFC1123Z},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			respHeaders := http.Header{}
+			respHeaders.Set("Date", now.Format(tt.format))
+			respHeaders.Set("Expires", expires.Format(tt.format))
+
+			if got := getFreshness(respHeaders, http.Header{}); got != fresh {
+				t.Fatalf("expected fresh, got %s", freshnessString(got))
+			}
+
+			clock = &fakeClock{elapsed: 2 * time.Hour}
+			if got := getFreshness(respHeaders, http.Header{}); got != stale {
+				t.Fatalf("expected stale after Expires, got %s", freshnessString(got))
+			}
+		})
+	}
+}
+
+// TestExpiresInvalidIsExpired verifies an unparseable Expires, e.g. "0", is
+// treated as a time in the past (RFC 9111 §5.3)
+func TestExpiresInvalidIsExpired(t *testing.T) {
+	resetTest()
+	respHeaders := http.Header{}
+	respHeaders.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	respHeaders.Set("Expires", "0")
+
+	if got := getFreshness(respHeaders, http.Header{}); got != stale {
+		t.Fatalf("expected stale, got %s", freshnessString(got))
+	}
+}
+
+func TestParseHTTPDate(t *testing.T) {
+	want := time.Date(1994, time.November, 6, 8, 49, 37, 0, time.UTC)
+	for _, value := range []string{
+		"Sun, 06 Nov 1994 08:49:37 GMT",
+		"Sunday, 06-Nov-94 08:49:37 GMT",
+		"Sun Nov  6 08:49:37 1994",
+		"Sun, 06 Nov 1994 09:49:37 +0100",
+		" Sun, 06 Nov 1994 08:49:37 GMT ",
+	} {
+		got, err := parseHTTPDate(value)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", value, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("%q: expected %v, got %v", value, want, got)
+		}
+	}
+
+	if _, err := parseHTTPDate("not a date"); err == nil {
+		t.Error("expected error for invalid date")
+	}
+}
@@ -0,0 +1,125 @@
+package httpcache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func brandedFallback(req *http.Request, err error) *http.Response {
+	body := "<h1>Service temporarily unavailable</h1>"
+	return &http.Response{
+		Status:        "503 Service Unavailable",
+		StatusCode:    http.StatusServiceUnavailable,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+		ContentLength: int64(len(body)),
+		Body:          io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// TestFallbackResponseOnUpstreamFailure verifies the custom response replaces the
+// transport error when there is no cache entry
+func TestFallbackResponseOnUpstreamFailure(t *testing.T) {
+	resetTest()
+	upstreamErr := errors.New("connection refused")
+	var gotErr error
+
+	tp := NewMemoryCacheTransport()
+	tp.Transport = transportMock{err: upstreamErr}
+	tp.FallbackResponse = func(req *http.Request, err error) *http.Response {
+		gotErr = err
+		return brandedFallback(req, err)
+	}
+
+	req, _ := http.NewRequest(methodGET, "http://somewhere.com/", nil)
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected fallback response, got error %v", err)
+	}
+	if !errors.Is(gotErr, upstreamErr) {
+		t.Fatalf("expected FallbackResponse to receive the upstream error, got %v", gotErr)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected Content-Type %q", got)
+	}
+	if resp.Request != req {
+		t.Fatal("expected fallback response to reference the request")
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "temporarily unavailable") {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	if _, ok := tp.Cache.Get(cacheKey(req)); ok {
+		t.Fatal("fallback response must not be stored")
+	}
+}
+
+// TestFallbackResponseNil verifies a nil fallback keeps the original error
+func TestFallbackResponseNil(t *testing.T) {
+	resetTest()
+	upstreamErr := errors.New("connection refused")
+
+	tp := NewMemoryCacheTransport()
+	tp.Transport = transportMock{err: upstreamErr}
+	tp.FallbackResponse = func(*http.Request, error) *http.Response { return nil }
+
+	req, _ := http.NewRequest(methodGET, "http://somewhere.com/", nil)
+	if _, err := tp.RoundTrip(req); !errors.Is(err, upstreamErr) {
+		t.Fatalf("expected original error, got %v", err)
+	}
+}
+
+// TestFallbackResponseStaleIfErrorWins verifies a usable stale entry is served in
+// preference to the fallback response
+func TestFallbackResponseStaleIfErrorWins(t *testing.T) {
+	resetTest()
+	tmock := &transportMock{
+		response: &http.Response{
+			Status:     http.StatusText(http.StatusOK),
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Date":          []string{time.Now().Format(time.RFC1123)},
+				"Cache-Control": []string{"no-cache, stale-if-error=100"},
+			},
+			Body: io.NopCloser(bytes.NewBufferString("cached data")),
+		},
+	}
+	fallbackCalled := false
+	tp := NewMemoryCacheTransport()
+	tp.Transport = tmock
+	tp.FallbackResponse = func(req *http.Request, err error) *http.Response {
+		fallbackCalled = true
+		return brandedFallback(req, err)
+	}
+
+	req, _ := http.NewRequest(methodGET, "http://somewhere.com/", nil)
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+
+	tmock.response = nil
+	tmock.err = errors.New("some error")
+	resp, err = tp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fallbackCalled {
+		t.Fatal("FallbackResponse must not be used when a stale entry can be served")
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get(XStale) != "1" {
+		t.Fatalf("expected stale cached response, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,79 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestFillRequestHeaders verifies the origin sees the injected headers on misses
+// and revalidations, while the client request and the cache key are unaffected
+func TestFillRequestHeaders(t *testing.T) {
+	resetTest()
+	var seen []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("X-Cache-Fill"))
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.FillRequestHeaders = func(req *http.Request) {
+		req.Header.Set("X-Cache-Fill", "1")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	key := tp.requestCacheKey(req)
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+	if req.Header.Get("X-Cache-Fill") != "" || resp.Request.Header.Get("X-Cache-Fill") != "" {
+		t.Error("expected the client request to be unaffected")
+	}
+	if _, ok := tp.Cache.Get(key); !ok {
+		t.Error("expected the entry under the key of the client request")
+	}
+
+	if hit := doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL); hit.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected a fresh hit")
+	}
+
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	if revalidated := doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL); revalidated.Header.Get(XRevalidated) != "1" {
+		t.Fatal("expected a revalidation")
+	}
+
+	if len(seen) != 2 || seen[0] != "1" || seen[1] != "1" {
+		t.Errorf("expected the miss and the revalidation to carry the header, got %q", seen)
+	}
+}
+
+// TestFillRequestHeadersUncacheable verifies requests that bypass the cache are
+// sent unchanged
+func TestFillRequestHeadersUncacheable(t *testing.T) {
+	resetTest()
+	var seen string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get("X-Cache-Fill")
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.FillRequestHeaders = func(req *http.Request) {
+		req.Header.Set("X-Cache-Fill", "1")
+	}
+
+	doHeadUpdateRequest(t, tp, http.MethodPost, ts.URL)
+	if seen != "" {
+		t.Errorf("expected a POST to be sent without the fill header, got %q", seen)
+	}
+}
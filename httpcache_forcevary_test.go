@@ -0,0 +1,90 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+// respondClientVersion answers with a body varying by X-Client-Version while
+// only listing Accept-Encoding in Vary
+func respondClientVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "max-age=3600")
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.Write([]byte("version " + r.Header.Get("X-Client-Version")))
+}
+
+func doClientVersionRequest(t *testing.T, client *http.Client, url, version string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Client-Version", version)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+// TestForceVaryHeadersSeparation verifies each forced header value gets its own
+// variant with EnableVarySeparation
+func TestForceVaryHeadersSeparation(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondClientVersion)
+
+	tp := NewMemoryCacheTransport()
+	tp.EnableVarySeparation = true
+	tp.ForceVaryHeaders = []string{"x-client-version"}
+	client := tp.Client()
+
+	for _, version := range []string{"1", "2"} {
+		resp, body := doClientVersionRequest(t, client, ts.URL, version)
+		if body != "version "+version {
+			t.Fatalf("unexpected body %q", body)
+		}
+		if got := resp.Header.Values("Vary"); len(got) != 2 || got[1] != "X-Client-Version" {
+			t.Fatalf("expected forced header in served Vary, got %v", got)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		for _, version := range []string{"1", "2"} {
+			resp, body := doClientVersionRequest(t, client, ts.URL, version)
+			if resp.Header.Get(XFromCache) != "1" {
+				t.Fatalf("expected version %s to be served from its variant", version)
+			}
+			if body != "version "+version {
+				t.Fatalf("variant for version %s served %q", version, body)
+			}
+		}
+	}
+	if n := ts.requests(nil); n != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", n)
+	}
+}
+
+// TestForceVaryHeadersWithoutSeparation verifies a different forced header value
+// doesn't match the stored entry when variants share a key
+func TestForceVaryHeadersWithoutSeparation(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondClientVersion)
+
+	tp := NewMemoryCacheTransport()
+	tp.ForceVaryHeaders = []string{"X-Client-Version"}
+	client := tp.Client()
+
+	doClientVersionRequest(t, client, ts.URL, "1")
+	if _, body := doClientVersionRequest(t, client, ts.URL, "2"); body != "version 2" {
+		t.Fatalf("expected version 2 not to be served the version 1 entry, got %q", body)
+	}
+	if resp, body := doClientVersionRequest(t, client, ts.URL, "2"); resp.Header.Get(XFromCache) != "1" || body != "version 2" {
+		t.Fatalf("expected the version 2 entry to be cached, got %q", body)
+	}
+	if n := ts.requests(nil); n != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", n)
+	}
+}
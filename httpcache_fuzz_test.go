@@ -0,0 +1,118 @@
+package httpcache
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// originStub answers every request with a fixed cacheable response
+type originStub struct{}
+
+func (originStub) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Cache-Control": {"max-age=60"}},
+		Body:       io.NopCloser(strings.NewReader("origin")),
+		Request:    req,
+	}, nil
+}
+
+// FuzzReadCachedResponse feeds arbitrary bytes as a cache entry, both to the
+// decoder and through the Transport, and checks neither panics and the decoder
+// returns either a readable response or ErrInvalidCachedResponse
+func FuzzReadCachedResponse(f *testing.F) {
+	f.Add([]byte("HTTP/1.1 200 OK\r\nCache-Control: max-age=60\r\nDate: Mon, 02 Jan 2006 15:04:05 GMT\r\nContent-Length: 5\r\n\r\nhello"))
+	f.Add([]byte("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nETag: \"v1\"\r\n\r\n5\r\nhello\r\n0\r\nGrpc-Status: 0\r\n\r\n"))
+	f.Add([]byte("HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\nshort"))
+	f.Add([]byte("HTTP/1.1 304 Not Modified\r\nVary: Accept, *\r\nX-Varied-Accept: text/html\r\n\r\n"))
+	f.Add([]byte("HTTP/1.1 206 Partial Content\r\nContent-Range: bytes 0-4/10\r\nContent-Length: 5\r\n\r\nhello"))
+	f.Add([]byte("HTTP/1.1 200 OK\r\nCache-Control: stale-if-error, max-stale=abc, max-age=-1\r\nAge: 99999999999999999999\r\nX-Cache-Lifetime: 9223372036854775807\r\nExpires: 0\r\n\r\n"))
+	f.Add([]byte("HTTP/1.1 999 Weird\r\n\r\n"))
+	f.Add([]byte("\x00\xff garbage"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req, err := http.NewRequest(http.MethodGet, "http://example.com/resource", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := readCachedResponse(data, req)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidCachedResponse) {
+				t.Fatalf("unexpected error type: %v", err)
+			}
+		} else {
+			if resp == nil {
+				t.Fatal("nil response without error")
+			}
+			if _, err := io.ReadAll(resp.Body); err != nil {
+				t.Fatalf("decoded response has an unreadable body: %v", err)
+			}
+			resp.Body.Close()
+		}
+
+		parsed, err := NewParsedResponseCache(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pc := range []*ParsedResponseCache{nil, parsed} {
+			cache := NewMemoryCache()
+			cache.Set(cacheKey(req), data)
+			tp := &Transport{Cache: cache, Transport: originStub{}, MarkCachedResponses: true, ParsedResponseCache: pc}
+			for i := 0; i < 2; i++ {
+				resp, err := tp.RoundTrip(req)
+				if err != nil {
+					t.Fatalf("RoundTrip failed on cached entry: %v", err)
+				}
+				io.ReadAll(resp.Body)
+				resp.Body.Close()
+				cache.Set(cacheKey(req), data)
+			}
+		}
+	})
+}
+
+// TestReadCachedResponseRejectsCorruptedEntries verifies corrupted entries are
+// reported as invalid and served as a cache miss
+func TestReadCachedResponseRejectsCorruptedEntries(t *testing.T) {
+	entries := map[string]string{
+		"empty":               "",
+		"garbage":             "\x00\xff garbage",
+		"status out of range": "HTTP/1.1 999 Weird\r\n\r\n",
+		"truncated body":      "HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\nshort",
+		"broken chunking":     "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\nhello\r\n",
+	}
+	for name, entry := range entries {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+			if _, err := readCachedResponse([]byte(entry), req); !errors.Is(err, ErrInvalidCachedResponse) {
+				t.Fatalf("expected ErrInvalidCachedResponse, got %v", err)
+			}
+
+			cache := NewMemoryCache()
+			cache.Set(cacheKey(req), []byte(entry))
+			tp := &Transport{Cache: cache, Transport: originStub{}}
+			resp, err := tp.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "origin" || resp.Header.Get(XFromCache) != "" {
+				t.Fatalf("expected the corrupted entry to be treated as a miss, got %q", body)
+			}
+		})
+	}
+
+	// HEAD entries legitimately declare a Content-Length without a body
+	req, _ := http.NewRequest(http.MethodHead, "http://example.com/", nil)
+	if _, err := readCachedResponse([]byte("HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\n"), req); err != nil {
+		t.Fatalf("unexpected error for HEAD entry: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+// respondGRPC returns a handler answering unary gRPC calls with a 200 and the
+// given grpc-status, in trailers or, for trailersOnly, in the headers
+func respondGRPC(status string, trailersOnly bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if trailersOnly {
+			w.Header().Set("Grpc-Status", status)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("\x00\x00\x00\x00\x05hello"))
+		w.Header().Set("Grpc-Status", status)
+	}
+}
+
+func doGRPCCall(t *testing.T, tp *Transport, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+// TestGRPCStatusOKTrailers verifies only grpc-status 0 responses are cached when
+// the status is sent in HTTP/2 trailers
+func TestGRPCStatusOKTrailers(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       string
+		trailersOnly bool
+		cached       bool
+	}{
+		{"ok in trailers", "0", false, true},
+		{"not found in trailers", "5", false, false},
+		{"ok trailers-only", "0", true, true},
+		{"unavailable trailers-only", "14", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			ts := newHTTP2TestOrigin(t, respondGRPC(tt.status, tt.trailersOnly))
+
+			tp := NewMemoryCacheTransport()
+			tp.Transport = ts.Client().Transport
+			tp.ShouldCacheTrailers = GRPCStatusOK
+
+			resp := doGRPCCall(t, tp, ts.URL)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected HTTP 200, got %d", resp.StatusCode)
+			}
+			if !tt.trailersOnly && resp.Trailer.Get("Grpc-Status") != tt.status {
+				t.Fatalf("expected grpc-status trailer %q, got %q", tt.status, resp.Trailer.Get("Grpc-Status"))
+			}
+
+			resp = doGRPCCall(t, tp, ts.URL)
+			if got := resp.Header.Get(XFromCache) == "1"; got != tt.cached {
+				t.Fatalf("expected cached=%v, got %v", tt.cached, got)
+			}
+			wantRequests := 2
+			if tt.cached {
+				wantRequests = 1
+			}
+			if n := ts.requests(nil); n != wantRequests {
+				t.Fatalf("expected %d origin requests, got %d", wantRequests, n)
+			}
+		})
+	}
+}
+
+// TestShouldCacheTrailersUnset verifies gRPC errors are cached like any other 200
+// when no trailer classifier is configured
+func TestShouldCacheTrailersUnset(t *testing.T) {
+	resetTest()
+	ts := newHTTP2TestOrigin(t, respondGRPC("5", false))
+
+	tp := NewMemoryCacheTransport()
+	tp.Transport = ts.Client().Transport
+
+	doGRPCCall(t, tp, ts.URL)
+	resp := doGRPCCall(t, tp, ts.URL)
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected response to be cached without ShouldCacheTrailers")
+	}
+}
+
+func TestGRPCStatusOK(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		header      string
+		trailer     string
+		want        bool
+	}{
+		{"not grpc", "application/json", "", "", true},
+		{"trailer ok", "application/grpc", "", "0", true},
+		{"trailer error", "application/grpc+proto", "", "3", false},
+		{"header ok", "application/grpc", "0", "", true},
+		{"missing status", "application/grpc", "", "", false},
+		{"grpc-web", "application/grpc-web+proto", "", "2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}, Trailer: http.Header{}}
+			resp.Header.Set("Content-Type", tt.contentType)
+			if tt.header != "" {
+				resp.Header.Set("Grpc-Status", tt.header)
+			}
+			if tt.trailer != "" {
+				resp.Trailer.Set("Grpc-Status", tt.trailer)
+			}
+			if got := GRPCStatusOK(resp); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+// respondHeadUpdate returns a handler whose ETag and Cache-Control can be
+// changed between requests
+func respondHeadUpdate(etag, cacheControl *string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", *etag)
+		w.Header().Set("Cache-Control", *cacheControl)
+		w.Write([]byte("body for " + *etag))
+	}
+}
+
+func doHeadUpdateRequest(t *testing.T, tp *Transport, method, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+func storedGET(t *testing.T, tp *Transport, url string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := CachedResponse(tp.Cache, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		return nil, ""
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+// TestUpdateCacheFromHeadFreshens verifies a HEAD with matching validators
+// updates the stored GET headers and keeps its body
+func TestUpdateCacheFromHeadFreshens(t *testing.T) {
+	resetTest()
+	etag, cacheControl := `"v1"`, "max-age=60"
+	ts := newTestOrigin(t, respondHeadUpdate(&etag, &cacheControl))
+
+	tp := NewMemoryCacheTransport()
+	tp.UpdateCacheFromHead = true
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL)
+	cacheControl = "max-age=3600"
+	doHeadUpdateRequest(t, tp, http.MethodHead, ts.URL)
+
+	stored, body := storedGET(t, tp, ts.URL)
+	if stored == nil {
+		t.Fatal("expected the GET entry to be kept")
+	}
+	if got := stored.Header.Get("Cache-Control"); got != "max-age=3600" {
+		t.Fatalf("expected Cache-Control updated from HEAD, got %q", got)
+	}
+	if stored.Header.Get("ETag") != `"v1"` || body != `body for "v1"` {
+		t.Fatalf("expected validators and body unchanged, got %q %q", stored.Header.Get("ETag"), body)
+	}
+
+	if resp := doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected GET to be served from the updated entry")
+	}
+	if gets := ts.requests(withMethod(http.MethodGet)); gets != 1 {
+		t.Fatalf("expected 1 GET to the origin, got %d", gets)
+	}
+}
+
+// TestUpdateCacheFromHeadChangedValidators verifies a HEAD announcing a new
+// representation invalidates the stored GET instead of relabelling its body
+func TestUpdateCacheFromHeadChangedValidators(t *testing.T) {
+	resetTest()
+	etag, cacheControl := `"v1"`, "max-age=3600"
+	ts := newTestOrigin(t, respondHeadUpdate(&etag, &cacheControl))
+
+	tp := NewMemoryCacheTransport()
+	tp.UpdateCacheFromHead = true
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL)
+	etag = `"v2"`
+	doHeadUpdateRequest(t, tp, http.MethodHead, ts.URL)
+
+	if stored, _ := storedGET(t, tp, ts.URL); stored != nil {
+		t.Fatalf("expected the outdated GET entry to be removed, still stored with ETag %s", stored.Header.Get("ETag"))
+	}
+
+	resp := doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL)
+	if resp.Header.Get(XFromCache) != "" || ts.requests(withMethod(http.MethodGet)) != 2 {
+		t.Fatal("expected GET to fetch the new representation")
+	}
+	if stored, body := storedGET(t, tp, ts.URL); stored.Header.Get("ETag") != `"v2"` || body != `body for "v2"` {
+		t.Fatalf("expected the new representation to be stored, got %q", body)
+	}
+}
+
+// TestHeadDoesNotUpdateCacheByDefault verifies HEAD responses leave the GET entry
+// untouched without UpdateCacheFromHead
+func TestHeadDoesNotUpdateCacheByDefault(t *testing.T) {
+	resetTest()
+	etag, cacheControl := `"v1"`, "max-age=60"
+	ts := newTestOrigin(t, respondHeadUpdate(&etag, &cacheControl))
+
+	tp := NewMemoryCacheTransport()
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL)
+	etag, cacheControl = `"v2"`, "max-age=3600"
+	doHeadUpdateRequest(t, tp, http.MethodHead, ts.URL)
+
+	stored, _ := storedGET(t, tp, ts.URL)
+	if stored == nil || stored.Header.Get("ETag") != `"v1"` || stored.Header.Get("Cache-Control") != "max-age=60" {
+		t.Fatal("expected the GET entry to be unchanged")
+	}
+}
@@ -0,0 +1,101 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// http2Stub answers like an HTTP/2 RoundTripper that leaks pseudo-headers, with
+// a body of unknown length
+type http2Stub struct{}
+
+func (http2Stub) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header: http.Header{
+			":status":       {"200"},
+			"Cache-Control": {"max-age=3600"},
+			"Date":          {time.Now().UTC().Format(http.TimeFormat)},
+		},
+		Body:          io.NopCloser(strings.NewReader("data")),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// assertHTTP11Entry checks the entry stored for url is a clean HTTP/1.1 message
+// and that it is served back as one
+func assertHTTP11Entry(t *testing.T, tp *Transport, client *http.Client, url string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	raw, ok := tp.Cache.Get(tp.requestCacheKey(req))
+	if !ok {
+		t.Fatal("expected a stored entry")
+	}
+	if !bytes.HasPrefix(raw, []byte("HTTP/1.1 200 OK\r\n")) {
+		t.Errorf("expected an HTTP/1.1 status line, got %q", raw[:bytes.IndexByte(raw, '\n')])
+	}
+	if bytes.Contains(raw, []byte("\n:")) {
+		t.Errorf("expected no pseudo-headers in the stored entry:\n%s", raw)
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get(XFromCache) != "1" || string(body) != "data" {
+		t.Fatalf("expected the entry to be served, got %q", body)
+	}
+	if resp.Proto != "HTTP/1.1" || resp.ProtoMajor != 1 || resp.ProtoMinor != 1 {
+		t.Errorf("expected an HTTP/1.1 cached response, got %s", resp.Proto)
+	}
+	for name := range resp.Header {
+		if strings.HasPrefix(name, ":") {
+			t.Errorf("expected no pseudo-headers in the served response, got %s", name)
+		}
+	}
+}
+
+// TestStoredAsHTTP11 verifies a response fetched over HTTP/2 with leaked
+// pseudo-headers is stored and served as HTTP/1.1
+func TestStoredAsHTTP11(t *testing.T) {
+	resetTest()
+	tp := NewMemoryCacheTransport()
+	tp.Transport = http2Stub{}
+	client := tp.Client()
+
+	fetchAndDrain(t, client, "http://example.com/")
+	assertHTTP11Entry(t, tp, client, "http://example.com/")
+}
+
+// TestStoredAsHTTP11OverHTTP2 verifies the same with a real HTTP/2 origin
+func TestStoredAsHTTP11OverHTTP2(t *testing.T) {
+	resetTest()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("expected an HTTP/2 request, got %s", r.Proto)
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("data"))
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.Transport = ts.Client().Transport
+	client := tp.Client()
+
+	fetchAndDrain(t, client, ts.URL)
+	assertHTTP11Entry(t, tp, client, ts.URL)
+}
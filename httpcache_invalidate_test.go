@@ -0,0 +1,129 @@
+package httpcache
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestInvalidate(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith("data", "Cache-Control", "max-age=3600", "Vary", "Accept-Language"))
+
+	tp := NewMemoryCacheTransport()
+	tp.CacheKeyHeaders = []string{"X-Tenant"}
+	client := tp.Client()
+	get := func(tenant string) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/resource", nil)
+		req.Header.Set("X-Tenant", tenant)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainAndClose(resp)
+		return req
+	}
+
+	reqA := get("a")
+	get("b")
+	if err := tp.Invalidate(reqA); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	get("a")
+	get("b")
+
+	if n := ts.requests(withHeader("X-Tenant", "a")); n != 2 {
+		t.Errorf("expected the invalidated entry to be fetched again, got %d requests", n)
+	}
+	if n := ts.requests(withHeader("X-Tenant", "b")); n != 1 {
+		t.Errorf("expected the entry keyed by another header value to be kept, got %d requests", n)
+	}
+}
+
+// TestInvalidateVariants verifies Invalidate removes every variant of an entry,
+// finding them through the MaxVaryVariants manifest when the backend can't be
+// enumerated
+func TestInvalidateVariants(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith("data", "Cache-Control", "max-age=3600", "Vary", "Accept-Language"))
+
+	cache := NewMemoryCache()
+	tp := NewTransport(nonIterableCache{cache})
+	tp.EnableVarySeparation = true
+	tp.MaxVaryVariants = 10
+	client := tp.Client()
+	get := func(lang string) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/resource", nil)
+		req.Header.Set("Accept-Language", lang)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainAndClose(resp)
+		return req
+	}
+
+	get("en")
+	reqFr := get("fr")
+	get("de")
+	if err := tp.Invalidate(reqFr); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	if n := len(cache.items); n != 0 {
+		t.Fatalf("expected every variant and the manifest to be removed, %d entries left", n)
+	}
+	for _, lang := range []string{"en", "fr", "de"} {
+		get(lang)
+		if n := ts.requests(withHeader("Accept-Language", lang)); n != 2 {
+			t.Errorf("expected the %s variant to be fetched again, got %d requests", lang, n)
+		}
+	}
+}
+
+// TestUnsafeMethodInvalidatesKeyedEntries verifies a successful POST removes
+// the entries of its URL whatever the cache key is built from
+func TestUnsafeMethodInvalidatesKeyedEntries(t *testing.T) {
+	configs := map[string]func(*Transport){
+		"EnableVarySeparation": func(tp *Transport) { tp.EnableVarySeparation = true },
+		"VaryByHeaders":        func(tp *Transport) { tp.VaryByHeaders = []string{"Accept-Language"} },
+		"NegotiationHeaders":   func(tp *Transport) { tp.NegotiationHeaders = []string{"Accept-Language"} },
+		"CacheKeyCookies":      func(tp *Transport) { tp.CacheKeyCookies = []string{"session"} },
+	}
+	for name, configure := range configs {
+		t.Run(name, func(t *testing.T) {
+			resetTest()
+			ts := newTestOrigin(t, respondWith("data", "Cache-Control", "max-age=3600", "Vary", "Accept-Language"))
+
+			cache := NewMemoryCache()
+			tp := NewTransport(cache)
+			configure(tp)
+			client := tp.Client()
+			do := func(method string) {
+				req, _ := http.NewRequest(method, ts.URL+"/resource", nil)
+				req.Header.Set("Accept-Language", "fr")
+				req.AddCookie(&http.Cookie{Name: "session", Value: "a"})
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				drainAndClose(resp)
+			}
+
+			do(http.MethodGet)
+			do(http.MethodPost)
+			if n := len(cache.items); n != 0 {
+				t.Errorf("expected the entries of the URL to be removed, %d entries left", n)
+			}
+			do(http.MethodGet)
+			if n := ts.requests(withHeader("Accept-Language", "fr")); n != 3 {
+				t.Errorf("expected the entry to be fetched again, got %d requests", n)
+			}
+		})
+	}
+}
+
+func TestInvalidateUnsafeMethod(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/resource", nil)
+	if err := NewMemoryCacheTransport().Invalidate(req); err == nil {
+		t.Error("expected an error invalidating a POST request")
+	}
+}
@@ -0,0 +1,81 @@
+package httpcache
+
+import (
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestKeyComponents verifies the header contributions reported for a request
+// match the headers that went into its cache key
+func TestKeyComponents(t *testing.T) {
+	resetTest()
+	tp := NewMemoryCacheTransport()
+	tp.CacheKeyHeaders = []string{"x-tenant", "Authorization", "X-Absent"}
+	tp.QueryParamDenylist = []string{"utm_*"}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/items?id=1&utm_source=mail", nil)
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Other", "ignored")
+
+	baseURL, headers, vary := tp.KeyComponents(req)
+	if baseURL != "http://example.com/items?id=1" {
+		t.Errorf("expected the filtered URL, got %q", baseURL)
+	}
+	want := map[string]string{
+		"X-Tenant":      "acme",
+		"Authorization": keyHeaderValue("Authorization", "Bearer secret"),
+	}
+	if !maps.Equal(headers, want) {
+		t.Errorf("expected header contributions %v, got %v", want, headers)
+	}
+	if strings.Contains(headers["Authorization"], "secret") {
+		t.Error("expected the credential to be reported hashed")
+	}
+	if vary != nil {
+		t.Errorf("expected no vary contributions, got %v", vary)
+	}
+
+	key := tp.requestCacheKey(req)
+	for name, value := range headers {
+		if !strings.Contains(key, name+":"+value) {
+			t.Errorf("expected %s:%s in the cache key %q", name, value, key)
+		}
+	}
+}
+
+// TestKeyComponentsVary verifies vary contributions are read from the stored
+// response with EnableVarySeparation
+func TestKeyComponentsVary(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Vary", "Accept-Language, X-Device")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.EnableVarySeparation = true
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	req.Header.Set("Accept-Language", "en")
+
+	if _, _, vary := tp.KeyComponents(req); vary != nil {
+		t.Fatalf("expected no vary contributions before a response is stored, got %v", vary)
+	}
+
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+
+	_, _, vary := tp.KeyComponents(req)
+	want := map[string]string{"Accept-Language": "en", "X-Device": ""}
+	if !maps.Equal(vary, want) {
+		t.Errorf("expected vary contributions %v, got %v", want, vary)
+	}
+}
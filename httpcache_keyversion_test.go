@@ -0,0 +1,92 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"testing"
+)
+
+func TestKeyVersionInvalidatesPriorEntries(t *testing.T) {
+	resetTest()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer ts.Close()
+
+	cache := NewMemoryCache()
+	paths := []string{"/a", "/b", "/c"}
+	fetchAll := func(version string) (hits int) {
+		tp := NewTransport(cache)
+		tp.KeyVersion = version
+		client := tp.Client()
+		for _, path := range paths {
+			resp, err := client.Get(ts.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.Header.Get(XFromCache) == "1" {
+				hits++
+			}
+		}
+		return hits
+	}
+
+	if hits := fetchAll("v1"); hits != 0 {
+		t.Fatalf("expected misses while populating, got %d hits", hits)
+	}
+	if hits := fetchAll("v1"); hits != len(paths) {
+		t.Fatalf("expected all hits for the same version, got %d", hits)
+	}
+	if hits := fetchAll("v2"); hits != 0 {
+		t.Fatalf("expected all misses after bumping the version, got %d hits", hits)
+	}
+	if hits := fetchAll(""); hits != 0 {
+		t.Fatalf("expected unversioned keys not to see versioned entries, got %d hits", hits)
+	}
+	if requests != 3*len(paths) {
+		t.Fatalf("expected %d origin requests, got %d", 3*len(paths), requests)
+	}
+}
+
+func TestKeyVersionWithPartition(t *testing.T) {
+	tp := &Transport{
+		KeyVersion:       "v1",
+		PartitionKeyFunc: func(*http.Request) string { return "tenant" },
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if got, want := tp.requestCacheKey(req), "version:v1|partition:tenant|http://example.com/"; got != want {
+		t.Fatalf("requestCacheKey = %q, want %q", got, want)
+	}
+}
+
+func TestVersionFromBuildInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		info debug.BuildInfo
+		want string
+	}{
+		{"module version", debug.BuildInfo{Main: debug.Module{Version: "v1.2.3"}}, "v1.2.3"},
+		{"vcs revision", debug.BuildInfo{
+			Main:     debug.Module{Version: "(devel)"},
+			Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "abc123"}, {Key: "vcs.modified", Value: "false"}},
+		}, "abc123"},
+		{"modified tree", debug.BuildInfo{
+			Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "abc123"}, {Key: "vcs.modified", Value: "true"}},
+		}, "abc123-dirty"},
+		{"no information", debug.BuildInfo{Main: debug.Module{Version: "(devel)"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versionFromBuildInfo(&tt.info); got != tt.want {
+				t.Fatalf("versionFromBuildInfo = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowCache delays reads by delay once slow is set
+type slowCache struct {
+	Cache
+	delay time.Duration
+	slow  atomic.Bool
+}
+
+func (c *slowCache) Get(key string) ([]byte, bool) {
+	if c.slow.Load() {
+		time.Sleep(c.delay)
+	}
+	return c.Cache.Get(key)
+}
+
+// slowContextCache is a ContextCache whose reads block until their context is done
+type slowContextCache struct {
+	Cache
+	cancelled atomic.Bool
+}
+
+func (c *slowContextCache) GetWithContext(ctx context.Context, key string) ([]byte, bool) {
+	<-ctx.Done()
+	c.cancelled.Store(true)
+	return nil, false
+}
+
+// deadlineRecorder is a RoundTripper recording the time left before the
+// deadline of the requests it forwards
+type deadlineRecorder struct {
+	http.RoundTripper
+	remaining atomic.Int64
+}
+
+func (d *deadlineRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if deadline, ok := req.Context().Deadline(); ok {
+		d.remaining.Store(int64(time.Until(deadline)))
+	}
+	return d.RoundTripper.RoundTrip(req)
+}
+
+// TestCacheLookupBudget verifies a cache read exceeding CacheLookupBudget is
+// treated as a miss, and the origin fetch keeps most of the request deadline
+func TestCacheLookupBudget(t *testing.T) {
+	resetTest()
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	cache := &slowCache{Cache: NewMemoryCache(), delay: 2 * time.Second}
+	upstream := &deadlineRecorder{RoundTripper: http.DefaultTransport}
+	tp := NewTransport(cache)
+	tp.Transport = upstream
+	tp.CacheLookupBudget = 50 * time.Millisecond
+
+	get := func() *http.Response {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		resp, err := tp.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("expected the request to succeed, got %v", err)
+		}
+		drainAndClose(resp)
+		return resp
+	}
+
+	get()
+	if resp := get(); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected a fast cache to serve the stored response")
+	}
+
+	cache.slow.Store(true)
+	start := time.Now()
+	resp := get()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the slow lookup to be abandoned, the request took %v", elapsed)
+	}
+	if resp.Header.Get(XFromCache) != "" || requests.Load() != 2 {
+		t.Errorf("expected a slow lookup to be a miss served by the origin, got %d origin requests", requests.Load())
+	}
+	if remaining := time.Duration(upstream.remaining.Load()); remaining < 800*time.Millisecond {
+		t.Errorf("expected the origin fetch to keep most of the deadline, got %v", remaining)
+	}
+}
+
+// TestCacheLookupBudgetContextCache verifies a ContextCache read is cancelled
+// once CacheLookupBudget is exhausted
+func TestCacheLookupBudgetContextCache(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	cache := &slowContextCache{Cache: NewMemoryCache()}
+	tp := NewTransport(cache)
+	tp.CacheLookupBudget = 20 * time.Millisecond
+
+	fetchAndDrain(t, tp.Client(), ts.URL)
+	deadline := time.Now().Add(time.Second)
+	for !cache.cancelled.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !cache.cancelled.Load() {
+		t.Error("expected the lookup context to be cancelled after the budget")
+	}
+}
@@ -0,0 +1,106 @@
+package httpcache
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMaxAgeZeroMustRevalidate verifies the entry is stored and revalidated with
+// the origin on every request
+func TestMaxAgeZeroMustRevalidate(t *testing.T) {
+	for _, cacheControl := range []string{
+		"max-age=0, must-revalidate",
+		"max-age=0, must-revalidate, stale-while-revalidate=600",
+	} {
+		t.Run(cacheControl, func(t *testing.T) {
+			resetTest()
+			ts := newTestOrigin(t, respondETag(`"v1"`, "data", "Cache-Control", cacheControl))
+
+			tp := NewMemoryCacheTransport()
+			client := tp.Client()
+
+			fetchAndDrain(t, client, ts.URL)
+			if stored, _ := storedGET(t, tp, ts.URL); stored == nil {
+				t.Fatal("expected the response to be stored for revalidation")
+			}
+
+			for i := 2; i <= 3; i++ {
+				resp, body := getBody(t, client, ts.URL)
+				if got := ts.requests(nil); got != i {
+					t.Fatalf("request %d: expected %d origin requests, got %d", i, i, got)
+				}
+				if resp.Header.Get(XRevalidated) != "1" || body != "data" {
+					t.Errorf("request %d: expected the revalidated entry, got %q revalidated %q", i, body, resp.Header.Get(XRevalidated))
+				}
+			}
+		})
+	}
+}
+
+// TestMaxAgeZeroMustRevalidateErrors verifies origin errors are returned instead
+// of the stale entry, whatever allows stale-if-error
+func TestMaxAgeZeroMustRevalidateErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheControl string
+		reqControl   string
+		setup        func(tp *Transport)
+	}{
+		{"no stale-if-error", "max-age=0, must-revalidate", "", nil},
+		{"response stale-if-error", "max-age=0, must-revalidate, stale-if-error=600", "", nil},
+		{"request stale-if-error", "max-age=0, must-revalidate", "stale-if-error=600", nil},
+		{"default window", "max-age=0, must-revalidate", "", func(tp *Transport) { tp.StaleIfError = time.Hour }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			var failing atomic.Bool
+			ts := newTestOrigin(t, respondFailing(&failing, respondETag(`"v1"`, "data", "Cache-Control", tt.cacheControl), http.StatusInternalServerError))
+
+			tp := NewMemoryCacheTransport()
+			if tt.setup != nil {
+				tt.setup(tp)
+			}
+			client := tp.Client()
+			fetchAndDrain(t, client, ts.URL)
+
+			failing.Store(true)
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+			if tt.reqControl != "" {
+				req.Header.Set("Cache-Control", tt.reqControl)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			drainAndClose(resp)
+			if resp.StatusCode != http.StatusInternalServerError {
+				t.Errorf("expected the 500 to surface, got %d", resp.StatusCode)
+			}
+			if resp.Header.Get(XStale) != "" {
+				t.Error("expected no stale response")
+			}
+		})
+	}
+}
+
+// TestMaxAgeZeroMustRevalidateTransportError verifies a failed revalidation
+// request returns its error instead of the stale entry
+func TestMaxAgeZeroMustRevalidateTransportError(t *testing.T) {
+	resetTest()
+	origin := &failingOrigin{cacheControl: "max-age=0, must-revalidate, stale-if-error=600"}
+	tp := NewMemoryCacheTransport()
+	tp.Transport = origin
+	doHeadUpdateRequest(t, tp, http.MethodGet, "http://example.com/")
+
+	upstreamErr := errors.New("connection refused")
+	origin.failing, origin.err = true, upstreamErr
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	resp, err := tp.RoundTrip(req)
+	if !errors.Is(err, upstreamErr) {
+		t.Errorf("expected the upstream error, got response %v and error %v", resp, err)
+	}
+}
@@ -0,0 +1,91 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestMaxServableAgeSinceStore verifies fresh entries stored longer ago than the
+// threshold are treated as a miss
+func TestMaxServableAgeSinceStore(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=31536000")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.MaxServableAgeSinceStore = 24 * time.Hour
+	client := tp.Client()
+	get := func() *http.Response {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	get()
+	clock = &fakeClock{elapsed: time.Hour}
+	if resp := get(); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected entry within the threshold to be served from cache")
+	}
+
+	clock = &fakeClock{elapsed: 48 * time.Hour}
+	if resp := get(); resp.Header.Get(XFromCache) != "" {
+		t.Fatal("expected entry stored past the threshold to be ignored")
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", requests)
+	}
+}
+
+// TestMaxServableAgeSinceStoreOldEntry verifies entries left in the backend by a
+// previous process are ignored based on their X-Cached-Time
+func TestMaxServableAgeSinceStoreOldEntry(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=31536000")
+		w.Write([]byte("new"))
+	}))
+	defer ts.Close()
+
+	now := time.Now().UTC()
+	entries := map[string]string{
+		"old":     now.Add(-30 * 24 * time.Hour).Format(time.RFC3339),
+		"missing": "",
+		"invalid": "yesterday",
+	}
+	for name, cachedTime := range entries {
+		t.Run(name, func(t *testing.T) {
+			tp := NewMemoryCacheTransport()
+			tp.MaxServableAgeSinceStore = 7 * 24 * time.Hour
+
+			entry := "HTTP/1.1 200 OK\r\nCache-Control: max-age=31536000\r\nDate: " + now.Format(http.TimeFormat) + "\r\n"
+			if cachedTime != "" {
+				entry += XCachedTime + ": " + cachedTime + "\r\n"
+			}
+			entry += "Content-Length: 3\r\n\r\nold"
+			tp.Cache.Set(ts.URL, []byte(entry))
+
+			resp, err := tp.Client().Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "new" {
+				t.Fatalf("expected the old entry to be ignored, got %q", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// laggingCache is a MaybeStaleCache flagging every value as possibly stale
+// while degraded
+type laggingCache struct {
+	Cache
+	degraded bool
+}
+
+func (c *laggingCache) GetMaybeStale(key string) ([]byte, bool, bool) {
+	value, ok := c.Get(key)
+	return value, ok, ok && c.degraded
+}
+
+// TestMaybeStaleWindow verifies entries flagged as possibly stale are served
+// marked stale within MaybeStaleWindow, and revalidated otherwise
+func TestMaybeStaleWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		degraded  bool
+		window    time.Duration
+		elapsed   time.Duration
+		fromCache bool
+		stale     bool
+	}{
+		{"degraded within window", true, 5 * time.Minute, 3 * time.Minute, true, true},
+		{"degraded fresh entry", true, 5 * time.Minute, 0, true, true},
+		{"degraded beyond window", true, time.Minute, 3 * time.Minute, false, false},
+		{"degraded without window", true, 0, 3 * time.Minute, false, false},
+		{"not degraded", false, 5 * time.Minute, 3 * time.Minute, false, false},
+		{"not degraded fresh entry", false, 5 * time.Minute, 0, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			var requests atomic.Int64
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.Header().Set("Cache-Control", "max-age=60")
+				_, _ = w.Write([]byte("data"))
+			}))
+			defer ts.Close()
+
+			cache := &laggingCache{Cache: NewMemoryCache()}
+			tp := NewTransport(cache)
+			tp.MaybeStaleWindow = tt.window
+			client := tp.Client()
+
+			fetchAndDrain(t, client, ts.URL)
+			cache.degraded = tt.degraded
+			clock = &fakeClock{elapsed: tt.elapsed}
+
+			resp, _ := getBody(t, client, ts.URL)
+			if got := resp.Header.Get(XFromCache) == "1" && requests.Load() == 1; got != tt.fromCache {
+				t.Errorf("expected served from cache %v, got %v", tt.fromCache, got)
+			}
+			if got := resp.Header.Get(XStale) == "1"; got != tt.stale {
+				t.Errorf("expected X-Stale %v, got %v", tt.stale, got)
+			}
+			if resp.Header.Get(xMaybeStale) != "" {
+				t.Error("expected the possibly-stale tag not to be served")
+			}
+		})
+	}
+}
+
+// TestMaybeStaleTagNotStored verifies the possibly-stale tag isn't persisted when
+// a flagged entry is revalidated and stored again
+func TestMaybeStaleTagNotStored(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	cache := &laggingCache{Cache: NewMemoryCache()}
+	tp := NewTransport(cache)
+	tp.MaybeStaleWindow = time.Minute
+	client := tp.Client()
+
+	fetchAndDrain(t, client, ts.URL)
+	cache.degraded = true
+	clock = &fakeClock{elapsed: 10 * time.Minute}
+	resp, _ := getBody(t, client, ts.URL)
+	if resp.Header.Get(XRevalidated) != "1" {
+		t.Fatal("expected the entry to be revalidated")
+	}
+
+	stored, _ := storedGET(t, tp, ts.URL)
+	if stored.Header.Get(xMaybeStale) != "" {
+		t.Error("expected the possibly-stale tag not to be stored")
+	}
+}
@@ -0,0 +1,73 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestNegotiationHeadersIsolateEntries verifies JSON and XML responses negotiated
+// on Accept are stored apart, equivalent Accept values share an entry, and the
+// served responses carry a synthesized Vary
+func TestNegotiationHeadersIsolateEntries(t *testing.T) {
+	resetTest()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if preferredValue(r.Header.Get("Accept")) == "application/json" {
+			w.Write([]byte(`{"format":"json"}`))
+			return
+		}
+		w.Write([]byte("<format>xml</format>"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.NegotiationHeaders = []string{"accept"}
+	client := tp.Client()
+
+	steps := []struct {
+		accept    string
+		body      string
+		fromCache bool
+	}{
+		{"application/json", `{"format":"json"}`, false},
+		{"application/xml", "<format>xml</format>", false},
+		{"application/json", `{"format":"json"}`, true},
+		{"application/xml;q=0.5, application/json", `{"format":"json"}`, true},
+		{"Application/XML; charset=utf-8", "<format>xml</format>", true},
+	}
+	for i, step := range steps {
+		resp, body := doAcceptRequest(t, client, ts.URL, step.accept)
+		if body != step.body || (resp.Header.Get(XFromCache) == "1") != step.fromCache {
+			t.Fatalf("step %d (%s): got %q from cache %q", i, step.accept, body, resp.Header.Get(XFromCache))
+		}
+		if resp.Header.Get("Vary") != "Accept" {
+			t.Fatalf("step %d: expected a synthesized Vary: Accept, got %q", i, resp.Header.Get("Vary"))
+		}
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", requests)
+	}
+}
+
+func TestPreferredValue(t *testing.T) {
+	tests := []struct {
+		list string
+		want string
+	}{
+		{"application/json", "application/json"},
+		{"text/html, application/json;q=0.8", "text/html"},
+		{"text/html;q=0.2, application/json;q=0.8", "application/json"},
+		{"en-US;q=0.5, fr", "fr"},
+		{"gzip;q=0, br", "br"},
+		{"identity;q=0", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := preferredValue(tt.list); got != tt.want {
+			t.Errorf("preferredValue(%q) = %q, want %q", tt.list, got, tt.want)
+		}
+	}
+}
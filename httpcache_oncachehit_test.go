@@ -0,0 +1,94 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestOnCacheHitAndMiss verifies the callbacks fire once per request of a
+// cacheable method, according to whether it was answered from cache
+func TestOnCacheHitAndMiss(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	var hits, misses []string
+	tp := NewMemoryCacheTransport()
+	tp.OnCacheHit = func(req *http.Request) { hits = append(hits, req.URL.Path) }
+	tp.OnCacheMiss = func(req *http.Request) { misses = append(misses, req.URL.Path) }
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL+"/a")  // miss
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL+"/a")  // fresh hit
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL+"/b")  // miss
+	doHeadUpdateRequest(t, tp, http.MethodPost, ts.URL+"/a") // not cacheable
+
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL+"/b") // revalidated with a 304
+
+	if len(hits) != 2 || hits[0] != "/a" || hits[1] != "/b" {
+		t.Fatalf("expected hits for /a and /b, got %v", hits)
+	}
+	if len(misses) != 2 || misses[0] != "/a" || misses[1] != "/b" {
+		t.Fatalf("expected misses for /a and /b, got %v", misses)
+	}
+}
+
+// TestOnCacheMissOnError verifies a failed request of a cacheable method is
+// reported as a miss
+func TestOnCacheMissOnError(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := ts.URL
+	ts.Close()
+
+	misses := 0
+	tp := NewMemoryCacheTransport()
+	tp.OnCacheMiss = func(*http.Request) { misses++ }
+	tp.OnCacheHit = func(*http.Request) { t.Fatal("unexpected hit") }
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if _, err := tp.RoundTrip(req); err == nil {
+		t.Fatal("expected an error")
+	}
+	if misses != 1 {
+		t.Fatalf("expected 1 miss, got %d", misses)
+	}
+}
+
+// TestOnCacheHitWithoutMarkers verifies hits are reported without the
+// X-From-Cache header: with MarkCachedResponses off, or removed by ServeFilter
+func TestOnCacheHitWithoutMarkers(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	hits := 0
+	tp := &Transport{Cache: NewMemoryCache()}
+	tp.OnCacheHit = func(*http.Request) { hits++ }
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL+"/a") // miss
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL+"/a") // hit
+	if hits != 1 {
+		t.Fatalf("expected 1 hit with MarkCachedResponses off, got %d", hits)
+	}
+
+	tp.MarkCachedResponses = true
+	tp.ServeFilter = func(resp *http.Response) { resp.Header.Del(XFromCache) }
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL+"/a") // hit
+	if hits != 2 {
+		t.Fatalf("expected 2 hits with X-From-Cache removed, got %d", hits)
+	}
+}
@@ -0,0 +1,61 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func onlyIfCachedRequest(t *testing.T, tp *Transport, url string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Cache-Control", "only-if-cached")
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+	return resp
+}
+
+// TestOnlyIfCachedRetryAfter verifies only-if-cached misses get a Retry-After
+// within the configured range
+func TestOnlyIfCachedRetryAfter(t *testing.T) {
+	resetTest()
+	tp := NewMemoryCacheTransport()
+	tp.OnlyIfCachedRetryAfterMin = 5 * time.Second
+	tp.OnlyIfCachedRetryAfterMax = 15 * time.Second
+
+	seen := map[int]bool{}
+	for i := 0; i < 200; i++ {
+		resp := onlyIfCachedRequest(t, tp, "http://example.invalid/missing")
+		if resp.StatusCode != http.StatusGatewayTimeout {
+			t.Fatalf("expected 504, got %d", resp.StatusCode)
+		}
+		seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil || seconds < 5 || seconds > 15 {
+			t.Fatalf("expected Retry-After between 5 and 15, got %q", resp.Header.Get("Retry-After"))
+		}
+		seen[seconds] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("expected jittered values, got %v", seen)
+	}
+}
+
+// TestOnlyIfCachedRetryAfterFixed verifies no Retry-After is added by default,
+// and a range with equal bounds gives a fixed value
+func TestOnlyIfCachedRetryAfterFixed(t *testing.T) {
+	resetTest()
+	tp := NewMemoryCacheTransport()
+	if got := onlyIfCachedRequest(t, tp, "http://example.invalid/missing").Header.Get("Retry-After"); got != "" {
+		t.Fatalf("expected no Retry-After by default, got %q", got)
+	}
+
+	tp.OnlyIfCachedRetryAfterMin = 10 * time.Second
+	tp.OnlyIfCachedRetryAfterMax = 10 * time.Second
+	if got := onlyIfCachedRequest(t, tp, "http://example.invalid/missing").Header.Get("Retry-After"); got != "10" {
+		t.Fatalf("expected Retry-After 10, got %q", got)
+	}
+}
@@ -0,0 +1,192 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"testing"
+)
+
+func storedEntry(t testing.TB, resp *http.Response) []byte {
+	t.Helper()
+	raw, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func newStoredResponse(body string) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}, "Cache-Control": []string{"max-age=3600"}},
+		ContentLength: int64(len(body)),
+		Body:          io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// TestParsedResponseCacheMatchesReadResponse verifies the fast path builds the same
+// response as re-parsing the stored bytes
+func TestParsedResponseCacheMatchesReadResponse(t *testing.T) {
+	raw := storedEntry(t, newStoredResponse(`{"id":1}`))
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	p, err := NewParsedResponseCache(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// First call parses and remembers, second takes the fast path
+	if _, err := p.readResponse("key", raw, req); err != nil {
+		t.Fatal(err)
+	}
+	fast, err := p.readResponse("key", raw, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow, err := cachedResponseWithKey(&staticCache{raw}, req, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fast.StatusCode != slow.StatusCode || fast.Status != slow.Status || fast.Proto != slow.Proto ||
+		fast.ContentLength != slow.ContentLength || fast.Close != slow.Close || fast.Request != req {
+		t.Fatalf("fast path response differs: %+v vs %+v", fast, slow)
+	}
+	if len(fast.Header) != len(slow.Header) {
+		t.Fatalf("headers differ: %v vs %v", fast.Header, slow.Header)
+	}
+	for k := range slow.Header {
+		if fast.Header.Get(k) != slow.Header.Get(k) {
+			t.Fatalf("header %s differs: %q vs %q", k, fast.Header.Get(k), slow.Header.Get(k))
+		}
+	}
+	fastBody, _ := io.ReadAll(fast.Body)
+	slowBody, _ := io.ReadAll(slow.Body)
+	if !bytes.Equal(fastBody, slowBody) {
+		t.Fatalf("bodies differ: %q vs %q", fastBody, slowBody)
+	}
+
+	// Mutating a served response must not affect later hits
+	fast.Header.Set("X-From-Cache", "1")
+	again, _ := p.readResponse("key", raw, req)
+	if again.Header.Get("X-From-Cache") != "" {
+		t.Fatal("served header mutation leaked into the parsed entry")
+	}
+}
+
+// TestParsedResponseCacheDetectsChangedEntry verifies a changed stored entry is re-parsed
+func TestParsedResponseCacheDetectsChangedEntry(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	p, _ := NewParsedResponseCache(10)
+
+	p.readResponse("key", storedEntry(t, newStoredResponse("old")), req)
+	resp, err := p.readResponse("key", storedEntry(t, newStoredResponse("new body")), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "new body" {
+		t.Fatalf("expected re-parsed body, got %q", body)
+	}
+}
+
+// TestParsedResponseCacheChunkedEntry verifies entries without a plain body length
+// are still served correctly
+func TestParsedResponseCacheChunkedEntry(t *testing.T) {
+	stored := newStoredResponse("chunked body")
+	stored.ContentLength = -1
+	raw := storedEntry(t, stored)
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	p, _ := NewParsedResponseCache(10)
+
+	for i := 0; i < 2; i++ {
+		resp, err := p.readResponse("key", raw, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "chunked body" {
+			t.Fatalf("unexpected body %q", body)
+		}
+	}
+}
+
+func TestParsedResponseCacheTransport(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.ParsedResponseCache, _ = NewParsedResponseCache(10)
+	client := tp.Client()
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "content" {
+			t.Fatalf("unexpected body %q", body)
+		}
+		if i > 0 && resp.Header.Get(XFromCache) != "1" {
+			t.Fatal("expected response from cache")
+		}
+	}
+}
+
+func TestNewParsedResponseCacheInvalidSize(t *testing.T) {
+	if _, err := NewParsedResponseCache(0); err == nil {
+		t.Fatal("expected error for non-positive size")
+	}
+}
+
+// staticCache always returns the same entry
+type staticCache struct{ raw []byte }
+
+func (c *staticCache) Get(string) ([]byte, bool) { return c.raw, true }
+func (c *staticCache) Set(string, []byte)        {}
+func (c *staticCache) Delete(string)             {}
+
+func benchmarkCachedResponse(b *testing.B, parsed bool) {
+	body := strings.Repeat(`{"id":1,"name":"item","tags":["a","b","c"]},`, 40)
+	stored := newStoredResponse(body)
+	stored.Header.Set("ETag", `"abc123"`)
+	stored.Header.Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+	stored.Header.Set("Vary", "Accept-Encoding")
+	stored.Header.Set(XResponseTime, "2006-01-02T15:04:05Z")
+	tp := NewTransport(&staticCache{storedEntry(b, stored)})
+	if parsed {
+		tp.ParsedResponseCache, _ = NewParsedResponseCache(100)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/api/items", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := tp.cachedResponseWithKey(req, "key")
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+	}
+}
+
+func BenchmarkCachedResponseReadResponse(b *testing.B) {
+	benchmarkCachedResponse(b, false)
+}
+
+func BenchmarkCachedResponseParsed(b *testing.B) {
+	benchmarkCachedResponse(b, true)
+}
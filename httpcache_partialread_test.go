@@ -0,0 +1,171 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/iotest"
+)
+
+// respondLargeBody returns a handler sending a cacheable body of size bytes,
+// with a Content-Length unless chunked is set
+func respondLargeBody(size int, chunked bool) http.HandlerFunc {
+	body := bytes.Repeat([]byte("x"), size)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if !chunked {
+			w.Header().Set("Content-Length", strconv.Itoa(size))
+		}
+		for i := 0; i < size; i += 4096 {
+			w.Write(body[i:min(i+4096, size)])
+			if chunked {
+				w.(http.Flusher).Flush()
+			}
+		}
+	}
+}
+
+// fetchAndRead sends a GET for url and reads at least n bytes of the body (all
+// of it if n is negative) before closing it
+func fetchAndRead(t *testing.T, tp *Transport, url string, n int) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < 0 {
+		_, err = io.ReadAll(resp.Body)
+	} else {
+		// Read in steps larger than the flushed chunks, so reads return short
+		buf := make([]byte, 32<<10)
+		for read := 0; read < n && err == nil; {
+			var m int
+			m, err = resp.Body.Read(buf[:min(len(buf), n-read)])
+			read += m
+		}
+	}
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+// TestPartialReadNotCached verifies a body closed halfway through is not stored
+func TestPartialReadNotCached(t *testing.T) {
+	resetTest()
+	const size = 1 << 20
+	for _, chunked := range []bool{false, true} {
+		ts := newTestOrigin(t, respondLargeBody(size, chunked))
+		tp := NewMemoryCacheTransport()
+
+		fetchAndRead(t, tp, ts.URL, size/2)
+		if resp, _ := storedGET(t, tp, ts.URL); resp != nil {
+			t.Errorf("expected no entry after a partial read (chunked %v)", chunked)
+		}
+	}
+}
+
+// TestFullReadCached verifies a body read to the end is stored, whether the
+// caller reads until EOF or stops after Content-Length bytes
+func TestFullReadCached(t *testing.T) {
+	resetTest()
+	const size = 1 << 20
+	tests := []struct {
+		name string
+		read int
+	}{
+		{"read to EOF", -1},
+		{"read Content-Length bytes", size},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestOrigin(t, respondLargeBody(size, false))
+			tp := NewMemoryCacheTransport()
+
+			fetchAndRead(t, tp, ts.URL, tt.read)
+			resp, body := storedGET(t, tp, ts.URL)
+			if resp == nil {
+				t.Fatal("expected an entry after a full read")
+			}
+			if len(body) != size {
+				t.Errorf("expected a stored body of %d bytes, got %d", size, len(body))
+			}
+		})
+	}
+}
+
+// TestInterruptedBodyNotCached verifies a body ending in a read error, such as a
+// dropped connection, is not stored
+func TestInterruptedBodyNotCached(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Content-Length", "1000")
+		w.Write(bytes.Repeat([]byte("x"), 500))
+	}))
+	defer ts.Close()
+	tp := NewMemoryCacheTransport()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Fatal("expected the truncated body to fail")
+	}
+	resp.Body.Close()
+
+	if resp, _ := storedGET(t, tp, ts.URL); resp != nil {
+		t.Error("expected no entry for an interrupted body")
+	}
+}
+
+// TestCachingReadCloserShortReads verifies short reads, which network bodies
+// return routinely, don't store a partial body
+func TestCachingReadCloserShortReads(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 100)
+	calls := 0
+	r := &cachingReadCloser{
+		R:     io.NopCloser(iotest.OneByteReader(bytes.NewReader(content))),
+		Size:  int64(len(content)),
+		OnEOF: func(io.Reader) { calls++ },
+	}
+
+	if _, err := io.ReadFull(r, make([]byte, 50)); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if calls != 0 {
+		t.Errorf("expected no store for a body closed halfway, got %d", calls)
+	}
+}
+
+// TestCachingReadCloserCloseAfterSize verifies a body closed without seeing EOF
+// is stored once all of its Size bytes were read, and only once
+func TestCachingReadCloserCloseAfterSize(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 100)
+	var stored []byte
+	calls := 0
+	r := &cachingReadCloser{
+		R:    io.NopCloser(iotest.OneByteReader(bytes.NewReader(content))),
+		Size: int64(len(content)),
+		OnEOF: func(body io.Reader) {
+			calls++
+			stored, _ = io.ReadAll(body)
+		},
+	}
+
+	if _, err := io.ReadFull(r, make([]byte, len(content))); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	r.Close()
+	if calls != 1 || !bytes.Equal(stored, content) {
+		t.Errorf("expected the full body to be stored once, got %d calls with %d bytes", calls, len(stored))
+	}
+}
@@ -0,0 +1,115 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func doOriginRequest(t *testing.T, client *http.Client, method, url, origin string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+// TestPartitionKeyFuncIsolatesEntries verifies the same URL under two partitions
+// yields isolated cache entries
+func TestPartitionKeyFuncIsolatesEntries(t *testing.T) {
+	resetTest()
+	requestCount := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("shared resource"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.PartitionKeyFunc = PartitionByOrigin
+	client := tp.Client()
+
+	doOriginRequest(t, client, http.MethodGet, ts.URL, "https://site-a.example")
+	resp := doOriginRequest(t, client, http.MethodGet, ts.URL, "https://site-b.example")
+	if resp.Header.Get(XFromCache) != "" {
+		t.Fatal("site-b must not be served site-a's entry")
+	}
+	if requestCount != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", requestCount)
+	}
+
+	resp = doOriginRequest(t, client, http.MethodGet, ts.URL, "https://site-a.example")
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("site-a should be served from its own partition")
+	}
+	resp = doOriginRequest(t, client, http.MethodGet, ts.URL, "")
+	if resp.Header.Get(XFromCache) != "" {
+		t.Fatal("unpartitioned request must not be served a partitioned entry")
+	}
+	if requestCount != 3 {
+		t.Fatalf("expected 3 origin requests, got %d", requestCount)
+	}
+}
+
+// TestPartitionKeyFuncInvalidation verifies unsafe methods invalidate within their partition
+func TestPartitionKeyFuncInvalidation(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("resource"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.PartitionKeyFunc = PartitionByOrigin
+	client := tp.Client()
+
+	doOriginRequest(t, client, http.MethodGet, ts.URL, "https://site-a.example")
+	doOriginRequest(t, client, http.MethodGet, ts.URL, "https://site-b.example")
+	doOriginRequest(t, client, http.MethodPost, ts.URL, "https://site-a.example")
+
+	resp := doOriginRequest(t, client, http.MethodGet, ts.URL, "https://site-a.example")
+	if resp.Header.Get(XFromCache) != "" {
+		t.Fatal("site-a entry should have been invalidated by POST")
+	}
+	resp = doOriginRequest(t, client, http.MethodGet, ts.URL, "https://site-b.example")
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("site-b entry should not be affected by site-a's POST")
+	}
+}
+
+func TestPartitionByOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"origin", map[string]string{"Origin": "https://a.example"}, "https://a.example"},
+		{"referer", map[string]string{"Referer": "https://b.example/page?q=1"}, "https://b.example"},
+		{"opaque origin falls back to referer", map[string]string{"Origin": "null", "Referer": "https://c.example/"}, "https://c.example"},
+		{"none", map[string]string{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := PartitionByOrigin(req); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
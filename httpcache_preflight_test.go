@@ -0,0 +1,145 @@
+package httpcache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// respondPreflight answers preflight requests with Access-Control-Max-Age: 600
+// and GET requests with a cacheable body
+func respondPreflight(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
+		w.Header().Set("Access-Control-Allow-Methods", "GET, PUT")
+		w.Header().Set("Access-Control-Max-Age", "600")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Cache-Control", "max-age=600")
+	_, _ = w.Write([]byte("data"))
+}
+
+func doPreflight(t *testing.T, client *http.Client, url, origin string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodOptions, url, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+	return resp
+}
+
+// TestCachePreflight verifies preflight responses are cached and served on repeat
+func TestCachePreflight(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondPreflight)
+
+	tp := NewMemoryCacheTransport()
+	tp.CachePreflight = true
+	client := tp.Client()
+
+	doPreflight(t, client, ts.URL, "https://app.example.com")
+	resp := doPreflight(t, client, ts.URL, "https://app.example.com")
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Error("expected the repeated preflight to be served from cache")
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+	}
+	if got := ts.requests(withMethod(http.MethodOptions)); got != 1 {
+		t.Errorf("expected 1 preflight to reach the origin, got %d", got)
+	}
+
+	// Another origin gets its own entry
+	resp = doPreflight(t, client, ts.URL, "https://other.example.com")
+	if resp.Header.Get(XFromCache) != "" {
+		t.Error("expected a preflight from another origin not to be served from cache")
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://other.example.com" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+	}
+}
+
+// TestCachePreflightSeparateFromGet verifies preflight and GET entries for the
+// same URL don't replace each other
+func TestCachePreflightSeparateFromGet(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondPreflight)
+
+	tp := NewMemoryCacheTransport()
+	tp.CachePreflight = true
+	client := tp.Client()
+
+	doPreflight(t, client, ts.URL, "https://app.example.com")
+	fetchAndDrain(t, client, ts.URL)
+	fetchAndDrain(t, client, ts.URL)
+	resp := doPreflight(t, client, ts.URL, "https://app.example.com")
+
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get(XFromCache) != "1" {
+		t.Errorf("expected the cached preflight, got status %d from cache %q", resp.StatusCode, resp.Header.Get(XFromCache))
+	}
+	if got := ts.requests(withMethod(http.MethodGet)); got != 1 {
+		t.Errorf("expected 1 GET to reach the origin, got %d", got)
+	}
+	if got := ts.requests(withMethod(http.MethodOptions)); got != 1 {
+		t.Errorf("expected 1 preflight to reach the origin, got %d", got)
+	}
+}
+
+// TestCachePreflightMaxAge verifies Access-Control-Max-Age sets the lifetime
+func TestCachePreflightMaxAge(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondPreflight)
+
+	tp := NewMemoryCacheTransport()
+	tp.CachePreflight = true
+	client := tp.Client()
+
+	doPreflight(t, client, ts.URL, "https://app.example.com")
+	clock = &fakeClock{elapsed: 9 * time.Minute}
+	if resp := doPreflight(t, client, ts.URL, "https://app.example.com"); resp.Header.Get(XFromCache) != "1" {
+		t.Error("expected the preflight to be fresh within Access-Control-Max-Age")
+	}
+	clock = &fakeClock{elapsed: 11 * time.Minute}
+	if resp := doPreflight(t, client, ts.URL, "https://app.example.com"); resp.Header.Get(XFromCache) == "1" {
+		t.Error("expected the preflight to be stale after Access-Control-Max-Age")
+	}
+	if got := ts.requests(withMethod(http.MethodOptions)); got != 2 {
+		t.Errorf("expected 2 preflights to reach the origin, got %d", got)
+	}
+}
+
+// TestCachePreflightDisabled verifies OPTIONS requests bypass the cache by
+// default, and requests that aren't preflights bypass it even when enabled
+func TestCachePreflightDisabled(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		origin  string
+	}{
+		{"disabled", false, "https://app.example.com"},
+		{"not a preflight", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			ts := newTestOrigin(t, respondPreflight)
+
+			tp := NewMemoryCacheTransport()
+			tp.CachePreflight = tt.enabled
+			client := tp.Client()
+
+			doPreflight(t, client, ts.URL, tt.origin)
+			doPreflight(t, client, ts.URL, tt.origin)
+			if got := ts.requests(withMethod(http.MethodOptions)); got != 2 {
+				t.Errorf("expected 2 requests to reach the origin, got %d", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestQueryParamDenylist verifies denied parameters don't fragment the cache
+// while still being sent upstream
+func TestQueryParamDenylist(t *testing.T) {
+	resetTest()
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("id=" + r.URL.Query().Get("id")))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.QueryParamDenylist = []string{"utm_*", "fbclid"}
+	client := tp.Client()
+
+	get := func(query string) (*http.Response, string) {
+		resp, err := client.Get(ts.URL + "/?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	get("id=5&utm_source=x")
+	if len(queries) != 1 || queries[0] != "id=5&utm_source=x" {
+		t.Fatalf("expected the full query upstream, got %v", queries)
+	}
+
+	for _, query := range []string{"id=5", "utm_campaign=y&id=5&fbclid=abc"} {
+		resp, body := get(query)
+		if resp.Header.Get(XFromCache) != "1" {
+			t.Fatalf("expected %q to be served from cache", query)
+		}
+		if body != "id=5" {
+			t.Fatalf("unexpected body %q", body)
+		}
+	}
+
+	resp, body := get("id=6&utm_source=x")
+	if resp.Header.Get(XFromCache) != "" || body != "id=6" {
+		t.Fatal("expected a different id to miss the cache")
+	}
+	if len(queries) != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", len(queries))
+	}
+}
+
+// TestQueryParamAllowlist verifies only allowed parameters are part of the key
+func TestQueryParamAllowlist(t *testing.T) {
+	resetTest()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.QueryParamAllowlist = []string{"id", "page"}
+	client := tp.Client()
+
+	for _, query := range []string{"id=5&page=2", "page=2&id=5&session=abc", "id=5&page=2&ref=home"} {
+		resp, err := client.Get(ts.URL + "/?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if requests != 1 {
+		t.Fatalf("expected 1 origin request, got %d", requests)
+	}
+
+	resp, err := client.Get(ts.URL + "/?id=5&page=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if requests != 2 {
+		t.Fatalf("expected an allowed parameter to change the key, got %d origin requests", requests)
+	}
+}
+
+func TestQueryParamAffectsKey(t *testing.T) {
+	tp := &Transport{
+		QueryParamAllowlist: []string{"id", "filter_*"},
+		QueryParamDenylist:  []string{"filter_debug"},
+	}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"id", true},
+		{"filter_color", true},
+		{"filter_debug", false},
+		{"utm_source", false},
+		{"ID", false},
+	}
+	for _, tt := range tests {
+		if got := tp.queryParamAffectsKey(tt.name); got != tt.want {
+			t.Errorf("queryParamAffectsKey(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func doRangeRequest(t *testing.T, client *http.Client, url, rangeHeader string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+// TestServeRangeFromCache verifies byte ranges are sliced from a cached full body
+func TestServeRangeFromCache(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith("0123456789", "Cache-Control", "max-age=3600"))
+
+	tp := NewMemoryCacheTransport()
+	tp.ServeRangeFromCache = true
+	client := tp.Client()
+
+	doRangeRequest(t, client, ts.URL, "")
+
+	tests := []struct {
+		rangeHeader  string
+		body         string
+		contentRange string
+	}{
+		{"bytes=2-5", "2345", "bytes 2-5/10"},
+		{"bytes=7-", "789", "bytes 7-9/10"},
+		{"bytes=-3", "789", "bytes 7-9/10"},
+		{"bytes=8-100", "89", "bytes 8-9/10"},
+	}
+	for _, tt := range tests {
+		resp, body := doRangeRequest(t, client, ts.URL, tt.rangeHeader)
+		if resp.StatusCode != http.StatusPartialContent {
+			t.Fatalf("%s: expected 206, got %d", tt.rangeHeader, resp.StatusCode)
+		}
+		if body != tt.body {
+			t.Errorf("%s: expected body %q, got %q", tt.rangeHeader, tt.body, body)
+		}
+		if got := resp.Header.Get("Content-Range"); got != tt.contentRange {
+			t.Errorf("%s: expected Content-Range %q, got %q", tt.rangeHeader, tt.contentRange, got)
+		}
+		if resp.ContentLength != int64(len(tt.body)) {
+			t.Errorf("%s: expected Content-Length %d, got %d", tt.rangeHeader, len(tt.body), resp.ContentLength)
+		}
+		if resp.Header.Get(XFromCache) != "1" {
+			t.Errorf("%s: expected response to be marked as cached", tt.rangeHeader)
+		}
+	}
+
+	if n := ts.requests(nil); n != 1 {
+		t.Fatalf("expected origin to be contacted once, got %d", n)
+	}
+
+	// The full entry must still be cached
+	resp, body := doRangeRequest(t, client, ts.URL, "")
+	if body != "0123456789" || resp.Header.Get(XFromCache) != "1" {
+		t.Fatalf("expected full body from cache, got %q", body)
+	}
+}
+
+// TestServeRangeFromCacheUnsatisfiable verifies out-of-bounds ranges return 416
+func TestServeRangeFromCacheUnsatisfiable(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith("0123456789", "Cache-Control", "max-age=3600"))
+
+	tp := NewMemoryCacheTransport()
+	tp.ServeRangeFromCache = true
+	client := tp.Client()
+
+	doRangeRequest(t, client, ts.URL, "")
+	resp, _ := doRangeRequest(t, client, ts.URL, "bytes=10-20")
+
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("expected 416, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Range"); got != "bytes */10" {
+		t.Fatalf("expected Content-Range %q, got %q", "bytes */10", got)
+	}
+	if n := ts.requests(nil); n != 1 {
+		t.Fatalf("expected origin to be contacted once, got %d", n)
+	}
+}
+
+// TestServeRangeFromCacheServeTimeHeaders verifies partial responses carry the
+// serve-time headers of a full hit, such as Age
+func TestServeRangeFromCacheServeTimeHeaders(t *testing.T) {
+	for _, disableAge := range []bool{false, true} {
+		resetTest()
+		ts := newTestOrigin(t, respondWith("0123456789", "Cache-Control", "max-age=3600"))
+
+		tp := NewMemoryCacheTransport()
+		tp.ServeRangeFromCache = true
+		tp.DisableAgeHeader = disableAge
+		client := tp.Client()
+
+		doRangeRequest(t, client, ts.URL, "")
+		resp, _ := doRangeRequest(t, client, ts.URL, "bytes=2-5")
+
+		if resp.StatusCode != http.StatusPartialContent {
+			t.Fatalf("expected 206, got %d", resp.StatusCode)
+		}
+		if _, ok := resp.Header["Age"]; ok == disableAge {
+			t.Errorf("DisableAgeHeader=%v: unexpected Age header %q", disableAge, resp.Header.Get("Age"))
+		}
+		if got := resp.Header.Get(XFreshness); got != "fresh" {
+			t.Errorf("expected %s fresh, got %q", XFreshness, got)
+		}
+	}
+}
+
+// TestServeRangeFromCacheFallsThrough verifies unsupported or uncached ranges go upstream
+func TestServeRangeFromCacheFallsThrough(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith("0123456789", "Cache-Control", "max-age=3600"))
+
+	tp := NewMemoryCacheTransport()
+	tp.ServeRangeFromCache = true
+	client := tp.Client()
+
+	// Nothing cached yet
+	doRangeRequest(t, client, ts.URL, "bytes=0-1")
+	if n := ts.requests(nil); n != 1 {
+		t.Fatalf("expected uncached range request to reach origin, got %d requests", n)
+	}
+
+	doRangeRequest(t, client, ts.URL, "")
+	// Multiple ranges are not handled by the cache
+	doRangeRequest(t, client, ts.URL, "bytes=0-1,4-5")
+	if n := ts.requests(nil); n != 3 {
+		t.Fatalf("expected multi-range request to reach origin, got %d requests", n)
+	}
+}
+
+// TestServeRangeFromCacheDisabled verifies range requests bypass the cache by default
+func TestServeRangeFromCacheDisabled(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondWith("0123456789", "Cache-Control", "max-age=3600"))
+
+	client := NewMemoryCacheTransport().Client()
+	doRangeRequest(t, client, ts.URL, "")
+	doRangeRequest(t, client, ts.URL, "bytes=0-1")
+
+	if n := ts.requests(nil); n != 2 {
+		t.Fatalf("expected range request to reach origin, got %d requests", n)
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		header      string
+		size        int64
+		start, end  int64
+		satisfiable bool
+		valid       bool
+	}{
+		{"bytes=0-0", 10, 0, 0, true, true},
+		{"bytes=0-", 10, 0, 9, true, true},
+		{"bytes=-20", 10, 0, 9, true, true},
+		{"bytes=-0", 10, 0, 0, false, true},
+		{"bytes=10-", 10, 0, 0, false, true},
+		{"bytes=5-2", 10, 0, 0, false, false},
+		{"items=0-1", 10, 0, 0, false, false},
+		{"bytes=a-b", 10, 0, 0, false, false},
+		{"bytes=0-1,3-4", 10, 0, 0, false, false},
+	}
+	for _, tt := range tests {
+		start, end, satisfiable, valid := parseByteRange(tt.header, tt.size)
+		if valid != tt.valid || satisfiable != tt.satisfiable {
+			t.Errorf("%s: got satisfiable=%v valid=%v", tt.header, satisfiable, valid)
+			continue
+		}
+		if satisfiable && (start != tt.start || end != tt.end) {
+			t.Errorf("%s: expected %d-%d, got %d-%d", tt.header, tt.start, tt.end, start, end)
+		}
+	}
+}
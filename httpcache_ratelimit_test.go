@@ -0,0 +1,80 @@
+package httpcache
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestTooManyRequestsServesStale verifies a 429 is treated like a server error
+// for stale-if-error, and the origin isn't contacted again until Retry-After elapses
+func TestTooManyRequestsServesStale(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	var limited atomic.Bool
+	ts := newTestOrigin(t, respondFailing(&limited, respondWith("content", "Cache-Control", "max-age=1, stale-if-error=3600"), http.StatusTooManyRequests, "Retry-After", "120"))
+
+	tp := NewMemoryCacheTransport()
+	tp.HonorRetryAfter = true
+	client := tp.Client()
+
+	getResponse(t, client, ts.URL)
+	limited.Store(true)
+	clock = &fakeClock{elapsed: 10 * time.Second}
+
+	for i := 0; i < 3; i++ {
+		resp := getResponse(t, client, ts.URL)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get(XStale) != "1" {
+			t.Fatalf("request %d: expected the stale entry instead of the 429, got %d", i, resp.StatusCode)
+		}
+	}
+	if n := ts.requests(nil); n != 2 {
+		t.Fatalf("expected the origin not to be re-hit within Retry-After, got %d requests", n)
+	}
+
+	clock = &fakeClock{elapsed: 130 * time.Second}
+	if resp := getResponse(t, client, ts.URL); resp.Header.Get(XStale) != "1" {
+		t.Fatal("expected the stale entry while the origin still rate-limits")
+	}
+	if n := ts.requests(nil); n != 3 {
+		t.Fatalf("expected the origin to be contacted after Retry-After, got %d requests", n)
+	}
+}
+
+// TestStaleOnErrorStatusClassifier verifies the classifier decides which statuses
+// allow serving stale
+func TestStaleOnErrorStatusClassifier(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	var limited atomic.Bool
+	ts := newTestOrigin(t, respondFailing(&limited, respondWith("content", "Cache-Control", "max-age=1, stale-if-error=3600"), http.StatusTooManyRequests, "Retry-After", "120"))
+
+	tp := NewMemoryCacheTransport()
+	tp.StaleOnErrorStatus = func(resp *http.Response) bool {
+		return resp.StatusCode >= http.StatusInternalServerError
+	}
+	client := tp.Client()
+
+	getResponse(t, client, ts.URL)
+	limited.Store(true)
+	clock = &fakeClock{elapsed: 10 * time.Second}
+
+	if resp := getResponse(t, client, ts.URL); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the 429 to be propagated when not classified as an error, got %d", resp.StatusCode)
+	}
+}
+
+func TestDefaultStaleOnErrorStatus(t *testing.T) {
+	for status, want := range map[int]bool{
+		http.StatusOK:                  false,
+		http.StatusNotFound:            false,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusServiceUnavailable:  true,
+	} {
+		if got := DefaultStaleOnErrorStatus(&http.Response{StatusCode: status}); got != want {
+			t.Errorf("DefaultStaleOnErrorStatus(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
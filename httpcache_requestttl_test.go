@@ -0,0 +1,114 @@
+package httpcache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestWithRequestTTL verifies a context TTL replaces the origin's max-age for the
+// stored entry
+func TestWithRequestTTL(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	client := NewMemoryCacheTransport().Client()
+	get := func(ctx context.Context, path string) *http.Response {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	ctx := WithRequestTTL(context.Background(), time.Hour)
+	get(ctx, "/ttl")
+	get(context.Background(), "/default")
+	if requests != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", requests)
+	}
+
+	// Past the origin's max-age but within the context TTL
+	clock = &fakeClock{elapsed: 30 * time.Minute}
+	if resp := get(ctx, "/ttl"); resp.Header.Get(XFromCache) != "1" || resp.Header.Get(XStale) != "" {
+		t.Fatal("expected fresh cached response within the context TTL")
+	}
+	if requests != 2 {
+		t.Fatalf("expected no origin request within the context TTL, got %d", requests)
+	}
+	get(context.Background(), "/default")
+	if requests != 3 {
+		t.Fatalf("expected the entry without TTL override to expire after max-age, got %d requests", requests)
+	}
+
+	// Past the context TTL
+	clock = &fakeClock{elapsed: 2 * time.Hour}
+	get(ctx, "/ttl")
+	if requests != 4 {
+		t.Fatalf("expected an origin request after the context TTL, got %d", requests)
+	}
+}
+
+// TestWithRequestTTLShorterThanMaxAge verifies the context TTL can also shorten
+// the lifetime
+func TestWithRequestTTLShorterThanMaxAge(t *testing.T) {
+	respHeaders := http.Header{}
+	respHeaders.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	respHeaders.Set("Cache-Control", "max-age=3600")
+
+	req, _ := http.NewRequestWithContext(WithRequestTTL(context.Background(), 10*time.Second), http.MethodGet, "http://example.com/", nil)
+	storeLifetimeOverride(&http.Response{Header: respHeaders}, req)
+
+	resetTest()
+	defer resetTest()
+	clock = &fakeClock{elapsed: 5 * time.Second}
+	if got := getFreshness(respHeaders, http.Header{}); got != fresh {
+		t.Fatalf("expected fresh, got %s", freshnessString(got))
+	}
+	clock = &fakeClock{elapsed: time.Minute}
+	if got := getFreshness(respHeaders, http.Header{}); got != stale {
+		t.Fatalf("expected stale after the context TTL, got %s", freshnessString(got))
+	}
+}
+
+// TestWithRequestTTLNoStore verifies the context TTL doesn't make no-store
+// responses cacheable
+func TestWithRequestTTLNoStore(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	ctx := WithRequestTTL(context.Background(), time.Hour)
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		resp, err := tp.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.Header.Get(XFromCache) != "" {
+			t.Fatal("no-store response must not be served from cache")
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func getResponse(t *testing.T, client *http.Client, url string) *http.Response {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+// TestHonorRetryAfter verifies the origin isn't contacted again until the
+// Retry-After window passes
+func TestHonorRetryAfter(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	var unavailable atomic.Bool
+	unavailable.Store(true)
+	ts := newTestOrigin(t, respondFailing(&unavailable, respondWith("content", "Cache-Control", "max-age=60"), http.StatusServiceUnavailable, "Retry-After", "30"))
+
+	tp := NewMemoryCacheTransport()
+	tp.HonorRetryAfter = true
+	client := tp.Client()
+
+	if resp := getResponse(t, client, ts.URL); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", resp.StatusCode)
+	}
+	unavailable.Store(false)
+
+	resp := getResponse(t, client, ts.URL)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 within the Retry-After window, got %d", resp.StatusCode)
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || seconds < 1 || seconds > 30 {
+		t.Fatalf("expected remaining Retry-After, got %q", resp.Header.Get("Retry-After"))
+	}
+	if n := ts.requests(nil); n != 1 {
+		t.Fatalf("expected the origin not to be contacted within the window, got %d requests", n)
+	}
+
+	clock = &fakeClock{elapsed: 31 * time.Second}
+	if resp := getResponse(t, client, ts.URL); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 after the window, got %d", resp.StatusCode)
+	}
+	if n := ts.requests(nil); n != 2 {
+		t.Fatalf("expected the origin to be contacted after the window, got %d requests", n)
+	}
+}
+
+// TestHonorRetryAfterServesStale verifies stale-if-error entries are served
+// during the window without contacting the origin
+func TestHonorRetryAfterServesStale(t *testing.T) {
+	resetTest()
+	var unavailable atomic.Bool
+	ts := newTestOrigin(t, respondFailing(&unavailable, respondWith("content", "Cache-Control", "max-age=0, stale-if-error=3600"), http.StatusServiceUnavailable, "Retry-After", "30"))
+
+	tp := NewMemoryCacheTransport()
+	tp.HonorRetryAfter = true
+	client := tp.Client()
+
+	getResponse(t, client, ts.URL)
+	unavailable.Store(true)
+
+	for i := 0; i < 3; i++ {
+		resp := getResponse(t, client, ts.URL)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get(XStale) != "1" {
+			t.Fatalf("expected stale response, got %d", resp.StatusCode)
+		}
+	}
+	if n := ts.requests(nil); n != 2 {
+		t.Fatalf("expected a single failed revalidation, got %d requests", n)
+	}
+}
+
+// TestHonorRetryAfterMustRevalidate verifies entries that can't be served stale
+// get the 503 during the window without contacting the origin
+func TestHonorRetryAfterMustRevalidate(t *testing.T) {
+	resetTest()
+	var unavailable atomic.Bool
+	ts := newTestOrigin(t, respondFailing(&unavailable, respondWith("content", "Cache-Control", "max-age=0, must-revalidate"), http.StatusServiceUnavailable, "Retry-After", "30"))
+
+	tp := NewMemoryCacheTransport()
+	tp.HonorRetryAfter = true
+	client := tp.Client()
+
+	getResponse(t, client, ts.URL)
+	unavailable.Store(true)
+
+	for i := 0; i < 3; i++ {
+		if resp := getResponse(t, client, ts.URL); resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503, got %d", resp.StatusCode)
+		}
+	}
+	if n := ts.requests(nil); n != 2 {
+		t.Fatalf("expected a single failed revalidation, got %d requests", n)
+	}
+}
+
+// TestRetryAfterIgnoredByDefault verifies the origin is contacted on every
+// request without HonorRetryAfter
+func TestRetryAfterIgnoredByDefault(t *testing.T) {
+	resetTest()
+	var unavailable atomic.Bool
+	unavailable.Store(true)
+	ts := newTestOrigin(t, respondFailing(&unavailable, respondWith("content", "Cache-Control", "max-age=60"), http.StatusServiceUnavailable, "Retry-After", "30"))
+
+	client := NewMemoryCacheTransport().Client()
+	for i := 0; i < 3; i++ {
+		getResponse(t, client, ts.URL)
+	}
+	if n := ts.requests(nil); n != 3 {
+		t.Fatalf("expected 3 origin requests, got %d", n)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"30", 30 * time.Second, true},
+		{" 120 ", 2 * time.Minute, true},
+		{"0", 0, false},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	delay, ok := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if !ok || delay < 59*time.Minute || delay > time.Hour {
+		t.Errorf("expected about an hour for an HTTP-date, got %v, %v", delay, ok)
+	}
+}
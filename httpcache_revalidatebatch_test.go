@@ -0,0 +1,201 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// respondRevalidateBatch returns a handler serving ETag-bearing paths. The
+// version of each path can be changed between requests; a matching
+// If-None-Match gets a 304, anything else a 200 with the current version.
+func respondRevalidateBatch(versions map[string]string, mu *sync.Mutex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		version, ok := versions[r.URL.Path]
+		mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		etag := `"` + version + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.Header.Get("If-None-Match") == etag {
+			w.Header().Set("X-Revalidated", "yes")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body " + version))
+	}
+}
+
+func newRevalidateRequests(t *testing.T, base string, paths ...string) []*http.Request {
+	t.Helper()
+	reqs := make([]*http.Request, len(paths))
+	for i, path := range paths {
+		req, err := http.NewRequest(http.MethodGet, base+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs[i] = req
+	}
+	return reqs
+}
+
+// TestRevalidateBatchOutcomes verifies unchanged entries are revalidated with a
+// 304 and changed entries replaced, reporting the outcomes in order
+func TestRevalidateBatchOutcomes(t *testing.T) {
+	resetTest()
+	var mu sync.Mutex
+	versions := map[string]string{"/a": "v1", "/b": "v1", "/c": "v1"}
+	ts := newTestOrigin(t, respondRevalidateBatch(versions, &mu))
+
+	tp := NewMemoryCacheTransport()
+	for _, path := range []string{"/a", "/b", "/c"} {
+		doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL+path)
+	}
+
+	mu.Lock()
+	versions["/b"] = "v2"
+	mu.Unlock()
+
+	reqs := newRevalidateRequests(t, ts.URL, "/a", "/b", "/c", "/uncached")
+	results, err := tp.RevalidateBatch(context.Background(), reqs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		outcome RevalidationOutcome
+		status  int
+	}{
+		{RevalidationNotModified, http.StatusNotModified},
+		{RevalidationUpdated, http.StatusOK},
+		{RevalidationNotModified, http.StatusNotModified},
+		{RevalidationNotCached, 0},
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Request != reqs[i] || r.Outcome != w.outcome || r.StatusCode != w.status || r.Err != nil {
+			t.Errorf("result %d: got %v %d %v, want %v %d", i, r.Outcome, r.StatusCode, r.Err, w.outcome, w.status)
+		}
+	}
+	isConditional := func(r *http.Request) bool { return r.Header.Get("If-None-Match") != "" }
+	if n := ts.requests(isConditional); n != 3 {
+		t.Fatalf("expected 3 conditional requests, got %d", n)
+	}
+
+	stored, body := storedGET(t, tp, ts.URL+"/a")
+	if stored.Header.Get("X-Revalidated") != "yes" || body != "body v1" {
+		t.Fatalf("expected /a headers refreshed and body kept, got %q %q", stored.Header.Get("X-Revalidated"), body)
+	}
+	stored, body = storedGET(t, tp, ts.URL+"/b")
+	if stored.Header.Get("ETag") != `"v2"` || body != "body v2" {
+		t.Fatalf("expected /b replaced, got %q %q", stored.Header.Get("ETag"), body)
+	}
+}
+
+// TestRevalidateBatchFailures verifies failing origins and non-GET requests are
+// reported as failures and leave the stored entry in place
+func TestRevalidateBatchFailures(t *testing.T) {
+	resetTest()
+	var mu sync.Mutex
+	versions := map[string]string{"/a": "v1"}
+	ts := newTestOrigin(t, respondRevalidateBatch(versions, &mu))
+
+	tp := NewMemoryCacheTransport()
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL+"/a")
+
+	mu.Lock()
+	delete(versions, "/a")
+	mu.Unlock()
+
+	post, _ := http.NewRequest(http.MethodPost, ts.URL+"/a", nil)
+	reqs := append(newRevalidateRequests(t, ts.URL, "/a"), post)
+	results, err := tp.RevalidateBatch(context.Background(), reqs, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := results[0]; r.Outcome != RevalidationFailed || r.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected the 500 to fail, got %v %d", r.Outcome, r.StatusCode)
+	}
+	if r := results[1]; r.Outcome != RevalidationFailed || r.Err == nil {
+		t.Fatalf("expected POST to fail with an error, got %v %v", r.Outcome, r.Err)
+	}
+	if _, body := storedGET(t, tp, ts.URL+"/a"); body != "body v1" {
+		t.Fatalf("expected the stored entry kept, got %q", body)
+	}
+}
+
+// TestRevalidateBatchConcurrency verifies no more than concurrency requests are
+// in flight at once
+func TestRevalidateBatchConcurrency(t *testing.T) {
+	resetTest()
+	var inFlight, peak int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	paths := []string{"/1", "/2", "/3", "/4", "/5", "/6"}
+	for _, path := range paths {
+		doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL+path)
+	}
+	atomic.StoreInt32(&peak, 0)
+
+	results, err := tp.RevalidateBatch(context.Background(), newRevalidateRequests(t, ts.URL, paths...), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if r.Outcome != RevalidationNotModified {
+			t.Errorf("result %d: expected not-modified, got %v %v", i, r.Outcome, r.Err)
+		}
+	}
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Fatalf("expected at most 2 requests in flight, got %d", p)
+	}
+}
+
+// TestRevalidateBatchCanceled verifies a canceled context fails the requests
+// that weren't sent
+func TestRevalidateBatchCanceled(t *testing.T) {
+	resetTest()
+	tp := NewMemoryCacheTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	reqs := newRevalidateRequests(t, "http://example.invalid", "/a", "/b")
+	results, err := tp.RevalidateBatch(ctx, reqs, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	for i, r := range results {
+		if r.Request != reqs[i] || r.Outcome == RevalidationNotModified || r.Outcome == RevalidationUpdated {
+			t.Errorf("result %d: unexpected %v", i, r.Outcome)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSensitiveEntriesTagged(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", r.URL.Query().Get("cc"))
+		w.Write([]byte("X-Cache-Sensitive: 1"))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		cacheControl  string
+		authorization string
+		sensitive     bool
+	}{
+		{"public", "max-age=60", "", false},
+		{"private", "private, max-age=60", "", true},
+		{"authorization", "max-age=60", "Bearer token", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewMemoryCache()
+			tp := NewTransport(cache)
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/?cc="+url.QueryEscape(tt.cacheControl), nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			resp, err := tp.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			value, ok := cache.Get(tp.requestCacheKey(req))
+			if !ok {
+				t.Fatal("expected the response to be stored")
+			}
+			if got := IsSensitiveEntry(value); got != tt.sensitive {
+				t.Fatalf("IsSensitiveEntry = %v, want %v", got, tt.sensitive)
+			}
+		})
+	}
+}
+
+func TestIsSensitiveEntry(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"HTTP/1.1 200 OK\r\nX-Cache-Sensitive: 1\r\n\r\nbody", true},
+		{"HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\n\r\nX-Cache-Sensitive: 1\r\n", false},
+		{"HTTP/1.1 200 OK\r\n\r\n", false},
+		{"", false},
+		{"vary manifest data", false},
+	}
+	for _, tt := range tests {
+		if got := IsSensitiveEntry([]byte(tt.value)); got != tt.want {
+			t.Errorf("IsSensitiveEntry(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPlaintextEntriesTagged(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		}
+		w.Write([]byte("body"))
+	}))
+	defer ts.Close()
+
+	cache := NewMemoryCache()
+	tp := NewTransport(cache)
+	tp.ShouldEncrypt = func(req *http.Request, _ *http.Response) bool {
+		return req.URL.Path == "/secret"
+	}
+	for path, want := range map[string]bool{"/public": true, "/secret": false, "/private": false} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		resp, err := tp.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		value, ok := cache.Get(tp.requestCacheKey(req))
+		if !ok {
+			t.Fatalf("%s: expected the response to be stored", path)
+		}
+		if got := IsPlaintextEntry(value); got != want {
+			t.Errorf("%s: IsPlaintextEntry = %v, want %v", path, got, want)
+		}
+	}
+}
+
+// TestSensitiveKeyHeadersHashed verifies credential headers in CacheKeyHeaders
+// and Vary still separate entries per token, while the raw token never appears
+// in a cache key or a log line
+func TestSensitiveKeyHeadersHashed(t *testing.T) {
+	resetTest()
+	var logs bytes.Buffer
+	previous := GetLogger()
+	SetLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer SetLogger(previous)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Authorization")
+		w.Write([]byte("data for " + r.Header.Get("Authorization")[len("Bearer "):]))
+	}))
+	defer ts.Close()
+
+	cache := NewMemoryCache()
+	tp := NewTransport(cache)
+	tp.CacheKeyHeaders = []string{"Authorization"}
+	tp.EnableVarySeparation = true
+
+	get := func(token string) (*http.Response, string) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := tp.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	get("secret-one")
+	get("secret-two")
+	resp, body := get("secret-one")
+	if resp.Header.Get(XFromCache) != "1" || body != "data for secret-one" {
+		t.Fatalf("expected the entry for the first token, got %q (from cache %q)", body, resp.Header.Get(XFromCache))
+	}
+	if _, body := get("secret-two"); body != "data for secret-two" {
+		t.Fatalf("expected the entry for the second token, got %q", body)
+	}
+
+	keys := 0
+	cache.Iterate(context.Background(), func(key string, _ []byte) bool {
+		keys++
+		if strings.Contains(key, "secret") {
+			t.Errorf("cache key contains the raw token: %q", key)
+		}
+		return true
+	})
+	if keys == 0 {
+		t.Fatal("expected stored entries")
+	}
+	if strings.Contains(logs.String(), "secret") {
+		t.Fatalf("logs contain the raw token:\n%s", logs.String())
+	}
+}
+
+func TestKeyHeaderValue(t *testing.T) {
+	if got := keyHeaderValue("Accept-Language", "en"); got != "en" {
+		t.Errorf("expected non-sensitive values unchanged, got %q", got)
+	}
+	if got := keyHeaderValue("Authorization", ""); got != "" {
+		t.Errorf("expected empty values unchanged, got %q", got)
+	}
+	a, b := keyHeaderValue("Cookie", "session=a"), keyHeaderValue("Cookie", "session=b")
+	if a == b || strings.Contains(a, "session") || !strings.HasPrefix(a, "sha256:") {
+		t.Errorf("expected distinct hashed values, got %q and %q", a, b)
+	}
+}
@@ -0,0 +1,102 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestRewriteDateOnServe verifies a cached entry whose Date is more recent than
+// its Age implies, as relayed by an intermediate cache, is served with a Date
+// consistent with the Age
+func TestRewriteDateOnServe(t *testing.T) {
+	date := time.Now().UTC().Format(http.TimeFormat)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=86400")
+		w.Header().Set("Date", date)
+		w.Header().Set("Age", "3600")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		rewrite  bool
+		noAge    bool
+		unmarked bool
+	}{
+		{"disabled", false, false, false},
+		{"enabled", true, false, false},
+		{"enabled without Age header", true, true, false},
+		{"enabled without MarkCachedResponses", true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			tp := NewMemoryCacheTransport()
+			tp.RewriteDateOnServe = tt.rewrite
+			tp.DisableAgeHeader = tt.noAge
+			tp.MarkCachedResponses = !tt.unmarked
+			client := tp.Client()
+
+			fetchAndDrain(t, client, ts.URL)
+			resp, err := client.Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			drainAndClose(resp)
+			if !tt.unmarked && resp.Header.Get(XFromCache) != "1" {
+				t.Fatal("expected response from cache")
+			}
+
+			if !tt.rewrite {
+				if got := resp.Header.Get("Date"); got != date {
+					t.Errorf("expected stored Date %q, got %q", date, got)
+				}
+				return
+			}
+
+			served, err := http.ParseTime(resp.Header.Get("Date"))
+			if err != nil {
+				t.Fatalf("invalid served Date: %v", err)
+			}
+			age := time.Hour
+			if !tt.noAge {
+				seconds, err := strconv.Atoi(resp.Header.Get("Age"))
+				if err != nil {
+					t.Fatalf("invalid served Age: %v", err)
+				}
+				age = time.Duration(seconds) * time.Second
+			}
+			if diff := time.Since(served.Add(age)); diff < -2*time.Second || diff > 2*time.Second {
+				t.Errorf("Date %v plus Age %v is %v away from now", served, age, diff)
+			}
+		})
+	}
+}
+
+// TestRewriteDateOnServeLeavesStoredDate verifies the rewritten Date isn't persisted
+func TestRewriteDateOnServeLeavesStoredDate(t *testing.T) {
+	resetTest()
+	date := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=86400")
+		w.Header().Set("Date", date)
+		w.Header().Set("Age", "600")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.RewriteDateOnServe = true
+	client := tp.Client()
+	fetchAndDrain(t, client, ts.URL)
+	fetchAndDrain(t, client, ts.URL)
+
+	stored, _ := storedGET(t, tp, ts.URL)
+	if got := stored.Header.Get("Date"); got != date {
+		t.Errorf("expected stored Date %q, got %q", date, got)
+	}
+}
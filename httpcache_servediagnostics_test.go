@@ -0,0 +1,51 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestServeDiagnostics verifies ServeDiagnostics reports the backend and key
+// hash of responses served by a single cache, without a tier
+func TestServeDiagnostics(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	for _, enabled := range []bool{false, true} {
+		tp := NewMemoryCacheTransport()
+		tp.ServeDiagnostics = enabled
+		client := tp.Client()
+
+		fetchAndDrain(t, client, ts.URL)
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainAndClose(resp)
+		if resp.Header.Get(XFromCache) != "1" {
+			t.Fatal("expected the second response to be served from cache")
+		}
+
+		backend, tier, keyHash := resp.Header.Get(XCacheBackend), resp.Header.Get(XCacheTier), resp.Header.Get(XCacheKeyHash)
+		if !enabled {
+			if backend != "" || tier != "" || keyHash != "" {
+				t.Errorf("expected no diagnostics when disabled, got %q %q %q", backend, tier, keyHash)
+			}
+			continue
+		}
+		if backend != "memory" {
+			t.Errorf("expected backend %q, got %q", "memory", backend)
+		}
+		if tier != "" {
+			t.Errorf("expected no tier for a single cache, got %q", tier)
+		}
+		if len(keyHash) != keyHashPrefixLen {
+			t.Errorf("expected a %d digit key hash, got %q", keyHashPrefixLen, keyHash)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestServeFilter verifies served cache hits carry the injected header while the
+// stored entry is unchanged
+func TestServeFilter(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	calls := 0
+	tp := NewMemoryCacheTransport()
+	tp.ServeFilter = func(resp *http.Response) {
+		calls++
+		resp.Header.Add("X-Cache-Age-Pretty", "fresh")
+	}
+	client := tp.Client()
+
+	get := func() *http.Response {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get()
+	if resp.Header.Get("X-Cache-Age-Pretty") != "" {
+		t.Fatal("ServeFilter must not run on responses fetched from origin")
+	}
+
+	for i := 0; i < 3; i++ {
+		resp = get()
+		if resp.Header.Get(XFromCache) != "1" {
+			t.Fatal("expected response from cache")
+		}
+		if got := resp.Header.Values("X-Cache-Age-Pretty"); len(got) != 1 || got[0] != "fresh" {
+			t.Fatalf("expected injected header once, got %v", got)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("expected ServeFilter to run 3 times, ran %d", calls)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	stored, err := CachedResponse(tp.Cache, req)
+	if err != nil || stored == nil {
+		t.Fatalf("expected stored entry, got %v", err)
+	}
+	if stored.Header.Get("X-Cache-Age-Pretty") != "" {
+		t.Fatal("ServeFilter changes leaked into the stored entry")
+	}
+}
+
+// TestServeFilterRevalidated verifies ServeFilter runs on entries served after a 304
+func TestServeFilterRevalidated(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.ServeFilter = func(resp *http.Response) {
+		resp.Header.Set("X-Served-By", "cache")
+	}
+	client := tp.Client()
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if i == 1 {
+			if resp.Header.Get(XRevalidated) != "1" {
+				t.Fatal("expected revalidated response")
+			}
+			if resp.Header.Get("X-Served-By") != "cache" {
+				t.Fatal("expected ServeFilter to run on revalidated response")
+			}
+		}
+	}
+}
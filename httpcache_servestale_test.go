@@ -0,0 +1,112 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestServeStaleMode verifies expired entries are served without contacting the
+// origin while the mode is on, and revalidated again once it is switched off
+func TestServeStaleMode(t *testing.T) {
+	resetTest()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	client := tp.Client()
+	get := func(url string) (*http.Response, string) {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	get(ts.URL)
+	clock = &fakeClock{elapsed: time.Hour}
+
+	tp.SetServeStaleMode(true)
+	if !tp.ServeStaleMode() {
+		t.Fatal("expected serve-stale mode to be enabled")
+	}
+	for i := 0; i < 3; i++ {
+		resp, body := get(ts.URL)
+		if body != "content" {
+			t.Fatalf("unexpected body %q", body)
+		}
+		if resp.Header.Get(XStale) != "1" || resp.Header.Get(XFromCache) != "1" {
+			t.Fatal("expected expired entry marked as stale and served from cache")
+		}
+		if resp.Header.Get("Warning") == "" {
+			t.Fatal("expected Warning 110 on stale response")
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("expected no upstream contact in serve-stale mode, got %d requests", requests)
+	}
+
+	// Requests with no cached entry still go upstream
+	get(ts.URL + "/uncached")
+	if requests != 2 {
+		t.Fatalf("expected uncached request to reach origin, got %d requests", requests)
+	}
+
+	tp.SetServeStaleMode(false)
+	resp, _ := get(ts.URL)
+	if resp.Header.Get(XStale) != "" {
+		t.Fatal("expected a fresh response after disabling serve-stale mode")
+	}
+	if requests != 3 {
+		t.Fatalf("expected expired entry to be refetched, got %d requests", requests)
+	}
+}
+
+// TestServeStaleModeNoCacheRequest verifies serve-stale mode also skips the origin
+// for entries that would otherwise require revalidation
+func TestServeStaleModeNoCacheRequest(t *testing.T) {
+	resetTest()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.DisableWarningHeader = true
+	client := tp.Client()
+
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	tp.SetServeStaleMode(true)
+	resp, err = client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if requests != 1 {
+		t.Fatalf("expected no revalidation in serve-stale mode, got %d requests", requests)
+	}
+	if resp.Header.Get("Warning") != "" {
+		t.Fatal("Warning header must respect DisableWarningHeader")
+	}
+}
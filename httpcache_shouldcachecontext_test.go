@@ -0,0 +1,63 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// cacheFlagKey is the context key toggling caching in the tests
+type cacheFlagKey struct{}
+
+// TestShouldCacheWithContext verifies a context value toggles caching of the
+// same response
+func TestShouldCacheWithContext(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.ShouldCache = func(*http.Response) bool { return true }
+	tp.ShouldCacheWithContext = func(ctx context.Context, req *http.Request, resp *http.Response) bool {
+		enabled, _ := ctx.Value(cacheFlagKey{}).(bool)
+		return enabled && resp.StatusCode == http.StatusOK
+	}
+
+	for _, enabled := range []bool{false, true} {
+		tp.Cache.Delete(ts.URL)
+		ctx := context.WithValue(context.Background(), cacheFlagKey{}, enabled)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		resp, err := tp.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainAndClose(resp)
+
+		if stored, _ := storedGET(t, tp, ts.URL); (stored != nil) != enabled {
+			t.Errorf("with the flag %v, expected stored %v", enabled, enabled)
+		}
+	}
+}
+
+// TestShouldCacheWithContextRespectsCacheControl verifies the predicate can't
+// store a no-store response
+func TestShouldCacheWithContextRespectsCacheControl(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.ShouldCacheWithContext = func(context.Context, *http.Request, *http.Response) bool { return true }
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL)
+	if stored, _ := storedGET(t, tp, ts.URL); stored != nil {
+		t.Fatal("expected the no-store response not to be stored")
+	}
+}
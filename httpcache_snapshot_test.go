@@ -0,0 +1,186 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// TestMemoryCacheSnapshotRestore verifies a restored cache serves hits without
+// contacting the origin
+func TestMemoryCacheSnapshotRestore(t *testing.T) {
+	resetTest()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("content for " + r.URL.Path))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	for _, path := range []string{"/a", "/b"} {
+		resp, err := tp.Client().Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	snapshot := filepath.Join(t.TempDir(), "cache.snapshot")
+	if err := tp.Cache.(*MemoryCache).SaveToFile(snapshot); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+
+	restored := NewMemoryCache()
+	if err := restored.LoadFromFile(snapshot); err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+
+	client := NewTransport(restored).Client()
+	for _, path := range []string{"/a", "/b"} {
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.Header.Get(XFromCache) != "1" {
+			t.Fatalf("%s: expected hit from restored cache", path)
+		}
+		if string(body) != "content for "+path {
+			t.Fatalf("%s: unexpected body %q", path, body)
+		}
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", requests)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	src := NewMemoryCache()
+	src.Set("plain", []byte("value"))
+	src.Set("empty", []byte{})
+	src.Set("binary\x00key", []byte{0, 1, 2, 255})
+
+	var buf bytes.Buffer
+	if err := WriteSnapshot(context.Background(), src, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := NewMemoryCache()
+	dst.Set("existing", []byte("kept"))
+	if err := ReadSnapshot(&buf, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"plain", "empty", "binary\x00key"} {
+		want, _ := src.Get(key)
+		got, ok := dst.Get(key)
+		if !ok || !bytes.Equal(got, want) {
+			t.Errorf("%q: expected %v, got %v (present=%v)", key, want, got, ok)
+		}
+	}
+	if _, ok := dst.Get("existing"); !ok {
+		t.Error("existing entry should be kept")
+	}
+}
+
+func TestReadSnapshotInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	src := NewMemoryCache()
+	src.Set("key", []byte("some value"))
+	if err := WriteSnapshot(context.Background(), src, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]byte{
+		"wrong magic": []byte("not a snapshot"),
+		"truncated":   buf.Bytes()[:buf.Len()-3],
+	}
+	for name, data := range tests {
+		if err := ReadSnapshot(bytes.NewReader(data), NewMemoryCache()); !errors.Is(err, ErrInvalidSnapshot) {
+			t.Errorf("%s: expected ErrInvalidSnapshot, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadFromFileMissing(t *testing.T) {
+	err := NewMemoryCache().LoadFromFile(filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Fatal("expected error for missing snapshot file")
+	}
+}
+
+// snapshottingCache is a cache implementing SnapshotCache by copying its
+// entries
+type snapshottingCache struct {
+	*MemoryCache
+	released int
+}
+
+func (c *snapshottingCache) Snapshot() (Snapshot, error) {
+	copied := NewMemoryCache()
+	_ = c.MemoryCache.Iterate(context.Background(), func(key string, value []byte) bool {
+		copied.Set(key, value)
+		return true
+	})
+	return &releasingSnapshot{MemoryCache: copied, cache: c}, nil
+}
+
+type releasingSnapshot struct {
+	*MemoryCache
+	cache *snapshottingCache
+}
+
+func (s *releasingSnapshot) Release() {
+	s.cache.released++
+}
+
+// TestIterateConsistentlyUsesSnapshot verifies whole-cache features iterate a
+// Snapshot when the backend supports it, and release it
+func TestIterateConsistentlyUsesSnapshot(t *testing.T) {
+	resetTest()
+	cache := &snapshottingCache{MemoryCache: NewMemoryCache()}
+	cache.Set("a", []byte("1"))
+	cache.Set("b", []byte("2"))
+
+	var keys []string
+	err := iterateConsistently(context.Background(), struct{ Cache }{cache}, func(key string, _ []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if !errors.Is(err, ErrCacheNotIterable) {
+		t.Fatalf("expected ErrCacheNotIterable without SnapshotCache, got %v", err)
+	}
+
+	err = iterateConsistently(context.Background(), cache, func(key string, _ []byte) bool {
+		keys = append(keys, key)
+		// Writes during the iteration don't reach the snapshot
+		cache.Set("c", []byte("3"))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("expected the 2 entries of the snapshot, got %v", keys)
+	}
+	if cache.released != 1 {
+		t.Errorf("expected the snapshot to be released once, got %d", cache.released)
+	}
+
+	tp := NewTransport(cache)
+	if _, err := tp.Vacuum(context.Background()); err != nil {
+		t.Errorf("expected Vacuum to use the snapshot, got %v", err)
+	}
+	if cache.released != 2 {
+		t.Errorf("expected Vacuum to release its snapshot, got %d releases", cache.released)
+	}
+}
@@ -0,0 +1,126 @@
+package httpcache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestSoftHardTTL verifies entries are fresh before the soft TTL, served stale
+// while revalidating between the soft and hard TTLs, and missed after the hard TTL
+func TestSoftHardTTL(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	ts := newTestOrigin(t, respondNumbered("Cache-Control", "max-age=3600"))
+
+	tp := NewMemoryCacheTransport()
+	tp.SoftTTL = 10 * time.Second
+	tp.HardTTL = time.Minute
+	client := tp.Client()
+
+	getBody(t, client, ts.URL)
+	stored, _ := storedGET(t, tp, ts.URL)
+	if stored.Header.Get(XCacheLifetime) != "10" || stored.Header.Get(XCacheHardTTL) != "60" {
+		t.Fatalf("expected the TTLs stored with the entry, got %q and %q",
+			stored.Header.Get(XCacheLifetime), stored.Header.Get(XCacheHardTTL))
+	}
+
+	// Before the soft TTL: fresh, despite being shorter than max-age
+	clock = &fakeClock{elapsed: 5 * time.Second}
+	resp, body := getBody(t, client, ts.URL)
+	if body != "response 1" || resp.Header.Get(XFromCache) != "1" || ts.requests(nil) != 1 {
+		t.Fatalf("expected a fresh hit before the soft TTL, got %q", body)
+	}
+
+	// Between the soft and hard TTLs: served stale, refreshed in the background
+	clock = &fakeClock{elapsed: 30 * time.Second}
+	resp, body = getBody(t, client, ts.URL)
+	if body != "response 1" || resp.Header.Get(XFromCache) != "1" {
+		t.Fatalf("expected the stale entry to be served, got %q", body)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for ts.requests(nil) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a background revalidation")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// After the hard TTL: a miss, fetched synchronously
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	resp, body = getBody(t, client, ts.URL)
+	if body != "response 3" || resp.Header.Get(XFromCache) != "" {
+		t.Fatalf("expected a miss after the hard TTL, got %q", body)
+	}
+}
+
+// TestHardTTLNotAfterSoftTTL verifies a HardTTL equal to or shorter than
+// SoftTTL leaves no stale window: the entry is fresh until SoftTTL, then a miss
+func TestHardTTLNotAfterSoftTTL(t *testing.T) {
+	for _, hardTTL := range []time.Duration{time.Minute, 30 * time.Second} {
+		resetTest()
+		ts := newTestOrigin(t, respondNumbered("Cache-Control", "max-age=3600"))
+
+		tp := NewMemoryCacheTransport()
+		tp.SoftTTL = time.Minute
+		tp.HardTTL = hardTTL
+		client := tp.Client()
+
+		getBody(t, client, ts.URL)
+		stored, _ := storedGET(t, tp, ts.URL)
+		if got := stored.Header.Get(XCacheHardTTL); got != "60" {
+			t.Errorf("HardTTL %v: expected a hard TTL of 60, got %q", hardTTL, got)
+		}
+
+		clock = &fakeClock{elapsed: 45 * time.Second}
+		resp, body := getBody(t, client, ts.URL)
+		if body != "response 1" || resp.Header.Get(XFromCache) != "1" {
+			t.Errorf("HardTTL %v: expected a fresh hit before SoftTTL, got %q", hardTTL, body)
+		}
+
+		clock = &fakeClock{elapsed: 90 * time.Second}
+		resp, body = getBody(t, client, ts.URL)
+		if body != "response 2" || resp.Header.Get(XFromCache) != "" || ts.requests(nil) != 2 {
+			t.Errorf("HardTTL %v: expected a miss after SoftTTL, got %q", hardTTL, body)
+		}
+	}
+	resetTest()
+}
+
+// TestHardTTLMustRevalidate verifies responses that must be revalidated get no
+// stale window
+func TestHardTTLMustRevalidate(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	ts := newTestOrigin(t, respondNumbered("Cache-Control", "max-age=10, must-revalidate"))
+
+	tp := NewMemoryCacheTransport()
+	tp.HardTTL = time.Minute
+	client := tp.Client()
+
+	getBody(t, client, ts.URL)
+	clock = &fakeClock{elapsed: 30 * time.Second}
+	resp, body := getBody(t, client, ts.URL)
+	if body != "response 2" || resp.Header.Get(XFromCache) != "" || ts.requests(nil) != 2 {
+		t.Fatalf("expected the entry to be refetched, got %q", body)
+	}
+}
+
+// TestSoftTTLRequestTTLPrecedence verifies WithRequestTTL wins over SoftTTL
+func TestSoftTTLRequestTTLPrecedence(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondNumbered("Cache-Control", "max-age=3600"))
+
+	tp := NewMemoryCacheTransport()
+	tp.SoftTTL = 10 * time.Second
+
+	req, _ := http.NewRequestWithContext(WithRequestTTL(t.Context(), time.Hour), http.MethodGet, ts.URL, nil)
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+	if stored, _ := storedGET(t, tp, ts.URL); stored.Header.Get(XCacheLifetime) != "3600" {
+		t.Fatalf("expected the request TTL to be stored, got %q", stored.Header.Get(XCacheLifetime))
+	}
+}
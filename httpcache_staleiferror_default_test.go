@@ -0,0 +1,125 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingOrigin answers cacheable responses until failing is set, then 500s,
+// or err if set
+type failingOrigin struct {
+	failing      bool
+	err          error
+	cacheControl string
+}
+
+func (o *failingOrigin) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Cache-Control": {o.cacheControl},
+			"Date":          {time.Now().UTC().Format(http.TimeFormat)},
+		},
+		Body:    io.NopCloser(strings.NewReader("data from " + req.URL.Host)),
+		Request: req,
+	}
+	if o.failing && o.err != nil {
+		return nil, o.err
+	}
+	if o.failing {
+		resp.StatusCode = http.StatusInternalServerError
+		resp.Header = http.Header{}
+		resp.Body = io.NopCloser(strings.NewReader("error"))
+	}
+	return resp, nil
+}
+
+// TestStaleIfErrorDefaultAndHosts verifies the default window and per-host
+// overrides decide, from the same Transport, which hosts get a stale entry
+func TestStaleIfErrorDefaultAndHosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    map[string]int
+	}{
+		{"stale by 2 minutes", 3 * time.Minute, map[string]int{
+			"flaky.example.com":    http.StatusOK,
+			"internal.example.com": http.StatusInternalServerError,
+			"other.example.com":    http.StatusInternalServerError,
+		}},
+		{"stale by 30 seconds", 90 * time.Second, map[string]int{
+			"flaky.example.com":    http.StatusOK,
+			"internal.example.com": http.StatusInternalServerError,
+			"other.example.com":    http.StatusOK,
+		}},
+		{"stale by 10 minutes", 11 * time.Minute, map[string]int{
+			"flaky.example.com":    http.StatusInternalServerError,
+			"internal.example.com": http.StatusInternalServerError,
+			"other.example.com":    http.StatusInternalServerError,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			origin := &failingOrigin{cacheControl: "max-age=60"}
+			tp := NewMemoryCacheTransport()
+			tp.Transport = origin
+			tp.StaleIfError = time.Minute
+			tp.StaleIfErrorHosts = map[string]time.Duration{
+				"flaky.example.com":    5 * time.Minute,
+				"internal.example.com": 0,
+			}
+
+			for host := range tt.want {
+				doHeadUpdateRequest(t, tp, http.MethodGet, "http://"+host+":8080/")
+			}
+
+			origin.failing = true
+			clock = &fakeClock{elapsed: tt.elapsed}
+			for host, status := range tt.want {
+				resp := doHeadUpdateRequest(t, tp, http.MethodGet, "http://"+host+":8080/")
+				if resp.StatusCode != status {
+					t.Errorf("%s: expected status %d, got %d", host, status, resp.StatusCode)
+				}
+				if status == http.StatusOK && resp.Header.Get(XStale) != "1" {
+					t.Errorf("%s: expected a stale response", host)
+				}
+			}
+		})
+	}
+}
+
+// TestStaleIfErrorDefaultRespectsOrigin verifies directives from the origin take
+// precedence over the configured window
+func TestStaleIfErrorDefaultRespectsOrigin(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		want         int
+	}{
+		{"max-age=60, must-revalidate", http.StatusInternalServerError},
+		{"max-age=60, stale-if-error=30", http.StatusInternalServerError},
+		{"max-age=60", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			resetTest()
+			origin := &failingOrigin{cacheControl: tt.cacheControl}
+			tp := NewMemoryCacheTransport()
+			tp.Transport = origin
+			tp.StaleIfError = time.Hour
+
+			doHeadUpdateRequest(t, tp, http.MethodGet, "http://example.com/")
+			origin.failing = true
+			clock = &fakeClock{elapsed: 2 * time.Minute}
+			if resp := doHeadUpdateRequest(t, tp, http.MethodGet, "http://example.com/"); resp.StatusCode != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestStatusTTLOverrides verifies each overridden status gets its own lifetime,
+// regardless of the origin's headers, while other statuses keep theirs
+func TestStatusTTLOverrides(t *testing.T) {
+	resetTest()
+	defer resetTest()
+
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/ok":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Write([]byte("ok"))
+		case "/moved":
+			w.Header().Set("Cache-Control", "max-age=86400")
+			w.Header().Set("Location", "/ok")
+			w.WriteHeader(http.StatusMovedPermanently)
+		default:
+			w.Header().Set("Cache-Control", "max-age=86400")
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.StatusTTLOverrides = map[int]time.Duration{
+		http.StatusMovedPermanently: time.Hour,
+		http.StatusNotFound:         30 * time.Second,
+	}
+	// RoundTrip directly, so redirects aren't followed
+	fromCache := func(path string) bool {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		resp, err := tp.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainAndClose(resp)
+		return resp.Header.Get(XFromCache) == "1"
+	}
+
+	for _, path := range []string{"/ok", "/moved", "/missing"} {
+		fromCache(path)
+	}
+
+	clock = &fakeClock{elapsed: 45 * time.Second}
+	if !fromCache("/ok") {
+		t.Error("expected the 200 to be fresh within its max-age")
+	}
+	if !fromCache("/moved") {
+		t.Error("expected the 301 to be fresh within its overridden TTL")
+	}
+	if fromCache("/missing") {
+		t.Error("expected the 404 to expire after its overridden TTL despite its max-age")
+	}
+
+	clock = &fakeClock{elapsed: 30 * time.Minute}
+	if fromCache("/ok") {
+		t.Error("expected the 200 to expire after its max-age")
+	}
+	if !fromCache("/moved") {
+		t.Error("expected the 301 to be fresh within its overridden TTL")
+	}
+
+	clock = &fakeClock{elapsed: 61 * time.Minute}
+	if fromCache("/moved") {
+		t.Error("expected the 301 to expire after its overridden TTL despite its max-age")
+	}
+
+	if requests["/ok"] != 2 || requests["/moved"] != 2 || requests["/missing"] != 2 {
+		t.Errorf("unexpected origin requests: %v", requests)
+	}
+}
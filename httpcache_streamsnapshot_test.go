@@ -0,0 +1,122 @@
+package httpcache
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// respondSSE returns a handler emitting snapshot as the first event, then
+// update once release is closed
+func respondSSE(snapshot string, release <-chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, snapshot)
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		io.WriteString(w, "data: update\n\n")
+	}
+}
+
+func TestStreamSnapshotDelimiter(t *testing.T) {
+	resetTest()
+	release := make(chan struct{})
+	ts := newTestOrigin(t, respondSSE("data: snapshot\n\n", release))
+
+	tp := NewMemoryCacheTransport()
+	tp.StreamSnapshot = &StreamSnapshot{Delimiter: []byte("\n\n"), Lifetime: time.Minute}
+	client := tp.Client()
+
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(resp.Body)
+	for _, want := range []string{"data: snapshot\n", "\n"} {
+		if line, _ := reader.ReadString('\n'); line != want {
+			t.Fatalf("expected %q from the live stream, got %q", want, line)
+		}
+	}
+
+	// The snapshot is served while the live request is still streaming
+	cached, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(cached.Body)
+	cached.Body.Close()
+	if cached.Header.Get(XFromCache) != "1" || string(body) != "data: snapshot\n\n" {
+		t.Fatalf("expected the cached snapshot, got %q (X-From-Cache %q)", body, cached.Header.Get(XFromCache))
+	}
+	if cached.ContentLength != int64(len(body)) {
+		t.Fatalf("expected a complete response with Content-Length %d, got %d", len(body), cached.ContentLength)
+	}
+
+	close(release)
+	rest, _ := io.ReadAll(reader)
+	resp.Body.Close()
+	if string(rest) != "data: update\n\n" {
+		t.Fatalf("expected the live stream to continue, got %q", rest)
+	}
+	if n := ts.requests(nil); n != 1 {
+		t.Fatalf("expected 1 origin request, got %d", n)
+	}
+}
+
+func TestStreamSnapshotBoundaryNotFound(t *testing.T) {
+	resetTest()
+	release := make(chan struct{})
+	close(release)
+	ts := newTestOrigin(t, respondSSE("data: a snapshot without its end", release))
+
+	tp := NewMemoryCacheTransport()
+	tp.StreamSnapshot = &StreamSnapshot{Delimiter: []byte("\n\n"), MaxBytes: 10, Lifetime: time.Minute}
+	client := tp.Client()
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.Header.Get(XFromCache) != "" {
+			t.Fatal("expected no snapshot to be cached when the delimiter is not within MaxBytes")
+		}
+	}
+	if n := ts.requests(nil); n != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", n)
+	}
+}
+
+func TestStreamSnapshotIgnoresOtherResponses(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, "first\n\nsecond")
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.StreamSnapshot = &StreamSnapshot{Delimiter: []byte("\n\n")}
+	client := tp.Client()
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "first\n\nsecond" {
+			t.Fatalf("expected the full body for a non-streaming response, got %q", body)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+// respondTraceEcho answers with a cacheable response echoing the trace headers
+// of the request
+func respondTraceEcho() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		for _, name := range TraceHeaders {
+			if v := r.Header.Get(name); v != "" {
+				w.Header().Set(name, v)
+			}
+		}
+		w.Write([]byte("content"))
+	}
+}
+
+func doTracedRequest(t *testing.T, client *http.Client, method, url, traceparent string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Traceparent", traceparent)
+	req.Header.Set("Tracestate", "vendor=opaque")
+	req.Header.Set("Baggage", "userId=alice")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+// TestStripStoredHeadersTrace verifies trace headers are not persisted in cached
+// entries while the origin response still carries them
+func TestStripStoredHeadersTrace(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondTraceEcho())
+
+	tp := NewMemoryCacheTransport()
+	tp.StripStoredHeaders = TraceHeaders
+	client := tp.Client()
+
+	const trace1 = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	const trace2 = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	resp := doTracedRequest(t, client, http.MethodGet, ts.URL, trace1)
+	if resp.Header.Get("Traceparent") != trace1 {
+		t.Fatal("origin response should keep its trace context")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	stored, err := CachedResponse(tp.Cache, req)
+	if err != nil || stored == nil {
+		t.Fatalf("expected stored entry, got %v", err)
+	}
+	for _, name := range TraceHeaders {
+		if v := stored.Header.Get(name); v != "" {
+			t.Errorf("stored entry contains %s: %q", name, v)
+		}
+	}
+	if stored.Header.Get("Cache-Control") == "" {
+		t.Error("other headers should still be stored")
+	}
+
+	resp = doTracedRequest(t, client, http.MethodGet, ts.URL, trace2)
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("trace headers must not affect the cache key")
+	}
+	if v := resp.Header.Get("Traceparent"); v != "" {
+		t.Fatalf("cached response served a stale trace context: %q", v)
+	}
+}
+
+// TestStripStoredHeadersHead verifies headers are stripped from entries stored
+// without a body read
+func TestStripStoredHeadersHead(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondTraceEcho())
+
+	tp := NewMemoryCacheTransport()
+	tp.StripStoredHeaders = TraceHeaders
+
+	const trace = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	resp := doTracedRequest(t, tp.Client(), http.MethodHead, ts.URL, trace)
+	if resp.Header.Get("Traceparent") != trace {
+		t.Fatal("origin response should keep its trace context")
+	}
+
+	req, _ := http.NewRequest(http.MethodHead, ts.URL, nil)
+	stored, err := CachedResponse(tp.Cache, req)
+	if err != nil || stored == nil {
+		t.Fatalf("expected stored entry, got %v", err)
+	}
+	if v := stored.Header.Get("Traceparent"); v != "" {
+		t.Fatalf("stored entry contains Traceparent: %q", v)
+	}
+}
+
+// TestStripStoredHeadersDefault verifies no headers are stripped unless configured
+func TestStripStoredHeadersDefault(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondTraceEcho())
+
+	tp := NewMemoryCacheTransport()
+	const trace = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	doTracedRequest(t, tp.Client(), http.MethodGet, ts.URL, trace)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	stored, err := CachedResponse(tp.Cache, req)
+	if err != nil || stored == nil {
+		t.Fatalf("expected stored entry, got %v", err)
+	}
+	if stored.Header.Get("Traceparent") != trace {
+		t.Fatal("headers should be stored unchanged by default")
+	}
+}
@@ -2,15 +2,19 @@ package httpcache
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
 	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -230,6 +234,127 @@ func resetTest() {
 	clock = &realClock{}
 }
 
+// testOrigin is an origin server for tests needing responses the shared
+// s.server doesn't serve. It records the requests it receives, so tests can
+// count them.
+type testOrigin struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	received []*http.Request
+}
+
+// newTestOrigin starts a testOrigin answering with handler, closed when the test
+// ends. The request is recorded before handler runs.
+func newTestOrigin(t testing.TB, handler http.HandlerFunc) *testOrigin {
+	o := &testOrigin{}
+	o.Server = httptest.NewServer(o.record(handler))
+	t.Cleanup(o.Close)
+	return o
+}
+
+// newHTTP2TestOrigin is like newTestOrigin, serving HTTP/2 over TLS. Requests
+// must go through the Transport of o.Client().
+func newHTTP2TestOrigin(t testing.TB, handler http.HandlerFunc) *testOrigin {
+	o := &testOrigin{}
+	o.Server = httptest.NewUnstartedServer(o.record(handler))
+	o.EnableHTTP2 = true
+	o.StartTLS()
+	t.Cleanup(o.Close)
+	return o
+}
+
+// record returns a handler recording each request before answering it with
+// handler
+func (o *testOrigin) record(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		o.mu.Lock()
+		o.received = append(o.received, r.Clone(context.Background()))
+		o.mu.Unlock()
+		handler(w, r)
+	}
+}
+
+// requests returns the number of requests received, counting only those
+// matching match when it isn't nil
+func (o *testOrigin) requests(match func(*http.Request) bool) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for _, r := range o.received {
+		if match == nil || match(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// withMethod matches the requests of method, for testOrigin.requests
+func withMethod(method string) func(*http.Request) bool {
+	return func(r *http.Request) bool { return r.Method == method }
+}
+
+// withHeader matches the requests carrying value in the named header, for
+// testOrigin.requests
+func withHeader(name, value string) func(*http.Request) bool {
+	return func(r *http.Request) bool { return r.Header.Get(name) == value }
+}
+
+// respondWith returns a handler answering every request with body and the
+// headers given as name, value pairs. Headers with an empty value are left out.
+func respondWith(body string, headers ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeaders(w, headers)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+// setHeaders sets the headers given as name, value pairs on w, leaving out
+// those with an empty value
+func setHeaders(w http.ResponseWriter, headers []string) {
+	for i := 0; i+1 < len(headers); i += 2 {
+		if headers[i+1] != "" {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+	}
+}
+
+// respondETag returns a handler like respondWith adding an ETag header, and
+// answering 304 Not Modified to requests whose If-None-Match matches it
+func respondETag(etag, body string, headers ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			setHeaders(w, headers)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		respondWith(body, headers...)(w, r)
+	}
+}
+
+// respondFailing returns a handler answering like handler until failing is
+// set, and with status and the headers given as name, value pairs afterwards
+func respondFailing(failing *atomic.Bool, handler http.HandlerFunc, status int, headers ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			setHeaders(w, headers)
+			w.WriteHeader(status)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// respondNumbered returns a handler like respondWith whose body is "response N"
+// for its Nth request, to tell fresh responses apart from cached ones
+func respondNumbered(headers ...string) http.HandlerFunc {
+	var n atomic.Int64
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondWith(fmt.Sprintf("response %d", n.Add(1)), headers...)(w, r)
+	}
+}
+
 // TestCacheableMethod ensures that uncacheable method does not get stored
 // in cache and get incorrectly used for a following cacheable method request.
 func TestCacheableMethod(t *testing.T) {
@@ -731,7 +856,7 @@ func TestSkipServerErrorsFromCache(t *testing.T) {
 	}
 
 	// With default settings (SkipServerErrorsFromCache = false), handleCachedResponse should allow it
-	_, useCache := s.transport.handleCachedResponse(cachedResp, req)
+	_, useCache, _ := s.transport.handleCachedResponse(cachedResp, req)
 	if !useCache {
 		t.Fatal("Expected to use cached 500 with default settings")
 	}
@@ -741,7 +866,7 @@ func TestSkipServerErrorsFromCache(t *testing.T) {
 
 	// Now handleCachedResponse should NOT allow using the cached 500
 	cachedResp2, _ := CachedResponse(s.transport.Cache, req)
-	_, useCache2 := s.transport.handleCachedResponse(cachedResp2, req)
+	_, useCache2, _ := s.transport.handleCachedResponse(cachedResp2, req)
 	if useCache2 {
 		t.Fatal("Should NOT use cached 500 when SkipServerErrorsFromCache is true")
 	}
@@ -0,0 +1,114 @@
+package httpcache
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// respondSlow returns a handler answering cacheable responses after delay,
+// tracking the highest number of requests it handled at once. Requests to
+// /fast are answered at once.
+func respondSlow(delay time.Duration, maxInFlight *atomic.Int64) http.HandlerFunc {
+	var inFlight atomic.Int64
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			prev := maxInFlight.Load()
+			if n <= prev || maxInFlight.CompareAndSwap(prev, n) {
+				break
+			}
+		}
+		if r.URL.Path != "/fast" {
+			time.Sleep(delay)
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("data"))
+	}
+}
+
+// TestMaxInFlightUpstream verifies concurrent misses never exceed the limit at
+// the origin, while hits are served without waiting for a slot
+func TestMaxInFlightUpstream(t *testing.T) {
+	resetTest()
+	var maxInFlight atomic.Int64
+	ts := newTestOrigin(t, respondSlow(100*time.Millisecond, &maxInFlight))
+
+	tp := NewMemoryCacheTransport()
+	tp.MaxInFlightUpstream = 2
+	client := tp.Client()
+	fetchAndDrain(t, client, ts.URL+"/fast")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Get(ts.URL + "/slow/" + strconv.Itoa(i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			drainAndClose(resp)
+		}(i)
+	}
+
+	// The origin is saturated: a hit must still be instant
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	resp, err := client.Get(ts.URL + "/fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected a cache hit")
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected the hit not to wait for the origin, took %v", elapsed)
+	}
+
+	wg.Wait()
+	if n := maxInFlight.Load(); n > 2 {
+		t.Errorf("expected at most 2 requests in flight at the origin, got %d", n)
+	}
+}
+
+// TestMaxInFlightUpstreamFailFast verifies requests over the limit fail with
+// ErrUpstreamLimit when UpstreamFailFast is set, and succeed once a slot frees
+func TestMaxInFlightUpstreamFailFast(t *testing.T) {
+	resetTest()
+	var maxInFlight atomic.Int64
+	ts := newTestOrigin(t, respondSlow(100*time.Millisecond, &maxInFlight))
+
+	tp := NewMemoryCacheTransport()
+	tp.MaxInFlightUpstream = 1
+	tp.UpstreamFailFast = true
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/slow", nil)
+		if resp, err := tp.RoundTrip(req); err == nil {
+			drainAndClose(resp)
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/fast", nil)
+	if _, err := tp.RoundTrip(req); !errors.Is(err, ErrUpstreamLimit) {
+		t.Fatalf("expected ErrUpstreamLimit, got %v", err)
+	}
+
+	<-done
+	resp, err := tp.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected the request to succeed once the slot was released, got %v", err)
+	}
+	drainAndClose(resp)
+}
@@ -0,0 +1,156 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// nonIterableCache is a Cache that does not implement Iterable
+type nonIterableCache struct {
+	Cache
+}
+
+func fetchAndDrain(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+}
+
+// TestListURLs verifies that URLs stored with StoreURLMetadata can be listed back
+func TestListURLs(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.StoreURLMetadata = true
+	client := tp.Client()
+
+	fetchAndDrain(t, client, ts.URL+"/b")
+	fetchAndDrain(t, client, ts.URL+"/a?x=1")
+	fetchAndDrain(t, client, ts.URL+"/b")
+
+	urls, err := tp.ListURLs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{ts.URL + "/a?x=1", ts.URL + "/b"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Fatalf("expected %v, got %v", expected, urls)
+	}
+
+	// Invalidated entries are no longer reported
+	tp.Cache.Delete(ts.URL + "/b")
+	urls, err = tp.ListURLs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(urls, []string{ts.URL + "/a?x=1"}) {
+		t.Fatalf("expected only /a after delete, got %v", urls)
+	}
+}
+
+// TestListURLsDisabled verifies that nothing is listed when StoreURLMetadata is off
+func TestListURLsDisabled(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	fetchAndDrain(t, tp.Client(), ts.URL)
+
+	urls, err := tp.ListURLs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 0 {
+		t.Fatalf("expected no URLs, got %v", urls)
+	}
+}
+
+// TestListURLsNotIterable verifies the error returned for non-iterable backends
+func TestListURLsNotIterable(t *testing.T) {
+	tp := NewTransport(nonIterableCache{NewMemoryCache()})
+	tp.StoreURLMetadata = true
+
+	if _, err := tp.ListURLs(context.Background()); !errors.Is(err, ErrCacheNotIterable) {
+		t.Fatalf("expected ErrCacheNotIterable, got %v", err)
+	}
+}
+
+// TestURLMetadataDeletedWithEntry verifies sidecars are removed when their
+// entry is invalidated, instead of piling up in the backend
+func TestURLMetadataDeletedWithEntry(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	cache := NewMemoryCache()
+	tp := NewTransport(cache)
+	tp.StoreURLMetadata = true
+	client := tp.Client()
+
+	fetchAndDrain(t, client, ts.URL+"/a")
+	fetchAndDrain(t, client, ts.URL+"/b")
+	if _, ok := cache.Get(urlMetadataKeyPrefix + ts.URL + "/a"); !ok {
+		t.Fatal("expected a sidecar to be stored")
+	}
+
+	// Programmatic invalidation
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/a", nil)
+	if err := tp.Invalidate(req); err != nil {
+		t.Fatal(err)
+	}
+	// Invalidation by an unsafe method
+	resp, err := client.Post(ts.URL+"/b", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+
+	for _, path := range []string{"/a", "/b"} {
+		if _, ok := cache.Get(urlMetadataKeyPrefix + ts.URL + path); ok {
+			t.Errorf("%s: expected the sidecar to be deleted with its entry", path)
+		}
+	}
+}
+
+// TestURLMetadataStoredWithEntry verifies no sidecar is written for a response
+// whose body wasn't read to the end, and so wasn't stored
+func TestURLMetadataStoredWithEntry(t *testing.T) {
+	resetTest()
+	cache := NewMemoryCache()
+	tp := NewTransport(cache)
+	tp.StoreURLMetadata = true
+
+	resp, err := tp.Client().Get(s.server.URL + "/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if _, ok := cache.Get(s.server.URL + "/json"); ok {
+		t.Fatal("expected the unread response not to be stored")
+	}
+	if _, ok := cache.Get(urlMetadataKeyPrefix + s.server.URL + "/json"); ok {
+		t.Error("expected no sidecar without a stored entry")
+	}
+}
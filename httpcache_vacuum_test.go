@@ -0,0 +1,124 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// respondVacuum answers with responses whose headers are given per path,
+// dated two hours ago unless the path is /fresh
+func respondVacuum(headers map[string]map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		date := time.Now().Add(-2 * time.Hour)
+		if r.URL.Path == "/fresh" {
+			date = time.Now()
+		}
+		w.Header().Set("Date", date.UTC().Format(http.TimeFormat))
+		for name, value := range headers[r.URL.Path] {
+			w.Header().Set(name, value)
+		}
+		_, _ = w.Write([]byte("data"))
+	}
+}
+
+// TestVacuum verifies only entries that can no longer be served or revalidated
+// are removed
+func TestVacuum(t *testing.T) {
+	resetTest()
+	headers := map[string]map[string]string{
+		"/fresh":     {"Cache-Control": "max-age=3600"},
+		"/expired":   {"Cache-Control": "max-age=60"},
+		"/validator": {"Cache-Control": "max-age=60", "ETag": `"v1"`},
+		"/swr":       {"Cache-Control": "max-age=60, stale-while-revalidate=86400"},
+		"/sie":       {"Cache-Control": "max-age=60, stale-if-error=600"},
+		"/sie-any":   {"Cache-Control": "max-age=60, stale-if-error"},
+	}
+	ts := newTestOrigin(t, respondVacuum(headers))
+
+	tp := NewMemoryCacheTransport()
+	tp.StoreURLMetadata = true
+	client := tp.Client()
+	for path := range headers {
+		fetchAndDrain(t, client, ts.URL+path)
+	}
+	tp.Cache.Set("unrelated", []byte("not a response"))
+
+	removed, err := tp.Vacuum(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 removed entries, got %d", removed)
+	}
+
+	for path, want := range map[string]bool{"/fresh": true, "/expired": false, "/validator": true, "/swr": true, "/sie": false, "/sie-any": true} {
+		if _, ok := tp.Cache.Get(ts.URL + path); ok != want {
+			t.Errorf("%s: expected kept %v, got %v", path, want, ok)
+		}
+	}
+	if _, ok := tp.Cache.Get("unrelated"); !ok {
+		t.Error("expected values that aren't responses to be kept")
+	}
+	if _, ok := tp.Cache.Get(urlMetadataKeyPrefix + ts.URL + "/expired"); ok {
+		t.Error("expected the URL metadata of removed entries to be deleted")
+	}
+	if _, ok := tp.Cache.Get(urlMetadataKeyPrefix + ts.URL + "/fresh"); !ok {
+		t.Error("expected the URL metadata of kept entries to be kept")
+	}
+}
+
+// TestVacuumConfiguredWindows verifies HardTTL and StaleIfError decide expiry
+func TestVacuumConfiguredWindows(t *testing.T) {
+	headers := map[string]map[string]string{
+		"/expired":   {"Cache-Control": "max-age=60"},
+		"/validator": {"ETag": `"v1"`},
+	}
+	tests := []struct {
+		name  string
+		setup func(tp *Transport)
+		kept  map[string]bool
+	}{
+		{"stale-if-error default", func(tp *Transport) { tp.StaleIfError = 3 * time.Hour },
+			map[string]bool{"/expired": true, "/validator": true}},
+		{"stale-if-error host", func(tp *Transport) {
+			tp.StaleIfErrorHosts = map[string]time.Duration{"example.com": 3 * time.Hour}
+		}, map[string]bool{"/expired": true, "/validator": true}},
+		{"hard TTL passed", func(tp *Transport) { tp.HardTTL = time.Hour },
+			map[string]bool{"/expired": false, "/validator": false}},
+		{"hard TTL ahead", func(tp *Transport) { tp.HardTTL = 3 * time.Hour },
+			map[string]bool{"/expired": true, "/validator": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			ts := newTestOrigin(t, respondVacuum(headers))
+
+			tp := NewMemoryCacheTransport()
+			tt.setup(tp)
+			client := tp.Client()
+			for path := range headers {
+				fetchAndDrain(t, client, ts.URL+path)
+			}
+
+			if _, err := tp.Vacuum(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			for path, want := range tt.kept {
+				if _, ok := tp.Cache.Get(ts.URL + path); ok != want {
+					t.Errorf("%s: expected kept %v, got %v", path, want, ok)
+				}
+			}
+		})
+	}
+}
+
+// TestVacuumNotIterable verifies Vacuum requires an Iterable backend
+func TestVacuumNotIterable(t *testing.T) {
+	tp := NewTransport(nonIterableCache{NewMemoryCache()})
+	if _, err := tp.Vacuum(context.Background()); !errors.Is(err, ErrCacheNotIterable) {
+		t.Errorf("expected ErrCacheNotIterable, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package httpcache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestValidatorOnlyFreshness verifies an ETag-only response is served from cache
+// within the window and revalidated after it
+func TestValidatorOnlyFreshness(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondETag(`"v1"`, "data"))
+	isFull, isConditional := withHeader("If-None-Match", ""), withHeader("If-None-Match", `"v1"`)
+
+	tp := NewMemoryCacheTransport()
+	tp.ValidatorOnlyFreshness = 30 * time.Second
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL)
+
+	clock = &fakeClock{elapsed: 10 * time.Second}
+	if resp := doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("expected a fresh hit within the window")
+	}
+	if full, conditional := ts.requests(isFull), ts.requests(isConditional); full != 1 || conditional != 0 {
+		t.Fatalf("expected no revalidation within the window, got %d full and %d conditional requests", full, conditional)
+	}
+
+	clock = &fakeClock{elapsed: time.Minute}
+	if resp := doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL); resp.Header.Get(XRevalidated) != "1" {
+		t.Fatal("expected the entry to be revalidated after the window")
+	}
+	if full, conditional := ts.requests(isFull), ts.requests(isConditional); full != 1 || conditional != 1 {
+		t.Fatalf("expected one conditional request, got %d full and %d conditional requests", full, conditional)
+	}
+}
+
+// TestValidatorOnlyFreshnessExplicitFreshness verifies responses with explicit
+// freshness or no-cache, and transports without the option, are unaffected
+func TestValidatorOnlyFreshnessExplicitFreshness(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheControl string
+		window       time.Duration
+	}{
+		{"max-age", "max-age=0", 30 * time.Second},
+		{"no-cache", "no-cache", 30 * time.Second},
+		{"disabled", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTest()
+			ts := newTestOrigin(t, respondETag(`"v1"`, "data", "Cache-Control", tt.cacheControl))
+
+			tp := NewMemoryCacheTransport()
+			tp.ValidatorOnlyFreshness = tt.window
+
+			doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL)
+			clock = &fakeClock{elapsed: 10 * time.Second}
+			doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL)
+			if conditional := ts.requests(withHeader("If-None-Match", `"v1"`)); conditional != 1 {
+				t.Fatalf("expected the entry to be revalidated, got %d conditional requests", conditional)
+			}
+		})
+	}
+}
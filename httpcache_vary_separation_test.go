@@ -277,3 +277,102 @@ func TestNoVarySeparation(t *testing.T) {
 		t.Errorf("Expected 1 server request (no vary separation), got %d", requestCount)
 	}
 }
+
+// TestVarySeparationServedVary verifies that a variant served from cache carries
+// the origin's combined Vary set as a single normalized header
+func TestVarySeparationServedVary(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(cacheControlHeader, cacheControlMaxAge3600)
+		w.Header().Add(varyHeader, "accept, Accept-Language")
+		w.Header().Add(varyHeader, "x-device")
+		w.Header().Add(varyHeader, "Accept")
+		fmt.Fprintf(w, "content-for-%s", r.Header.Get(acceptLanguageHeader))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.EnableVarySeparation = true
+	client := tp.Client()
+
+	for _, lang := range []string{"en", "fr"} {
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest(methodGET, ts.URL+testResourcePath, nil)
+			req.Header.Set(acceptLanguageHeader, lang)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "content-for-"+lang {
+				t.Fatalf("expected the %s variant, got %q", lang, body)
+			}
+			if i == 0 {
+				continue
+			}
+			if resp.Header.Get(XFromCache) != "1" {
+				t.Fatalf("expected the %s variant to be served from cache", lang)
+			}
+			if got := resp.Header.Values(varyHeader); len(got) != 1 || got[0] != "Accept, Accept-Language, X-Device" {
+				t.Errorf("expected Vary %q, got %q", "Accept, Accept-Language, X-Device", got)
+			}
+		}
+	}
+}
+
+// TestMaxVaryVariants verifies only the MaxVaryVariants most recently stored
+// variants of a URL remain retrievable
+func TestMaxVaryVariants(t *testing.T) {
+	resetTest()
+	const maxVariants = 2
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set(cacheControlHeader, cacheControlMaxAge3600)
+		w.Header().Set(varyHeader, acceptLanguageHeader)
+		fmt.Fprintf(w, "content-for-%s", r.Header.Get(acceptLanguageHeader))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.EnableVarySeparation = true
+	tp.MaxVaryVariants = maxVariants
+	client := tp.Client()
+
+	get := func(lang string) *http.Response {
+		req, _ := http.NewRequest(methodGET, ts.URL+testResourcePath, nil)
+		req.Header.Set(acceptLanguageHeader, lang)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "content-for-"+lang {
+			t.Fatalf("expected the %s variant, got %q", lang, body)
+		}
+		return resp
+	}
+
+	langs := []string{"en", "fr", "de", "it"}
+	for _, lang := range langs {
+		get(lang)
+	}
+
+	for _, lang := range langs[len(langs)-maxVariants:] {
+		if get(lang).Header.Get(XFromCache) != "1" {
+			t.Errorf("expected the recent %s variant to be served from cache", lang)
+		}
+	}
+	if requests != len(langs) {
+		t.Fatalf("expected %d origin requests, got %d", len(langs), requests)
+	}
+
+	for _, lang := range langs[:len(langs)-maxVariants] {
+		if get(lang).Header.Get(XFromCache) == "1" {
+			t.Errorf("expected the oldest %s variant to be evicted", lang)
+		}
+	}
+}
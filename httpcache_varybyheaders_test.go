@@ -0,0 +1,125 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func doAcceptRequest(t *testing.T, client *http.Client, url, accept string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+// respondNegotiating answers with a body varying by Accept but omits Vary
+func respondNegotiating(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "max-age=3600")
+	if r.Header.Get("Accept") == "application/json" {
+		w.Write([]byte(`{"format":"json"}`))
+		return
+	}
+	w.Write([]byte("<format>xml</format>"))
+}
+
+// TestVaryByHeadersSeparatesEntries verifies entries are keyed by VaryByHeaders and
+// served responses carry the synthesized Vary
+func TestVaryByHeadersSeparatesEntries(t *testing.T) {
+	resetTest()
+	ts := newTestOrigin(t, respondNegotiating)
+
+	tp := NewMemoryCacheTransport()
+	tp.VaryByHeaders = []string{"accept"}
+	client := tp.Client()
+
+	resp, body := doAcceptRequest(t, client, ts.URL, "application/json")
+	if body != `{"format":"json"}` {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if got := resp.Header.Get("Vary"); got != "Accept" {
+		t.Fatalf("expected synthesized Vary on origin response, got %q", got)
+	}
+
+	_, body = doAcceptRequest(t, client, ts.URL, "application/xml")
+	if body != "<format>xml</format>" {
+		t.Fatalf("xml client served wrong representation: %q", body)
+	}
+
+	resp, body = doAcceptRequest(t, client, ts.URL, "application/json")
+	if resp.Header.Get(XFromCache) != "1" || body != `{"format":"json"}` {
+		t.Fatalf("expected cached json representation, got %q", body)
+	}
+	if got := resp.Header.Values("Vary"); len(got) != 1 || got[0] != "Accept" {
+		t.Fatalf("expected a single synthesized Vary on cached response, got %v", got)
+	}
+	if n := ts.requests(nil); n != 2 {
+		t.Fatalf("expected 2 origin requests, got %d", n)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	req.Header.Set("Accept", "application/json")
+	stored, err := cachedResponseWithKey(tp.Cache, req, tp.requestCacheKey(req))
+	if err != nil || stored == nil {
+		t.Fatalf("expected stored entry, got %v", err)
+	}
+	if got := stored.Header.Get("Vary"); got != "" {
+		t.Fatalf("synthesized Vary must not be stored, got %q", got)
+	}
+}
+
+// TestVaryByHeadersKeepsOriginVary verifies names already in the origin's Vary
+// aren't duplicated
+func TestVaryByHeadersKeepsOriginVary(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Vary", "accept, Accept-Language")
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.VaryByHeaders = []string{"Accept", "Accept-Encoding"}
+
+	resp, _ := doAcceptRequest(t, tp.Client(), ts.URL, "text/plain")
+	got := headerAllCommaSepValues(resp.Header, "vary")
+	want := []string{"accept", "Accept-Language", "Accept-Encoding"}
+	if len(got) != len(want) {
+		t.Fatalf("expected Vary %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected Vary %v, got %v", want, got)
+		}
+	}
+}
+
+func TestKeyHeadersMergesVaryByHeaders(t *testing.T) {
+	tp := &Transport{
+		CacheKeyHeaders: []string{"Authorization", "accept"},
+		VaryByHeaders:   []string{"Accept", "Accept-Language"},
+	}
+	got := tp.keyHeaders()
+	want := []string{"Authorization", "accept", "Accept-Language"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// respondTogglingVary returns a handler varying on Accept while vary is set,
+// echoing the Accept header
+func respondTogglingVary(vary *atomic.Bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if vary.Load() {
+			w.Header().Set("Vary", "Accept")
+		}
+		w.Write([]byte("accept " + r.Header.Get("Accept")))
+	}
+}
+
+// variantKeys returns the variant keys stored in cache
+func variantKeys(t *testing.T, cache *MemoryCache) []string {
+	t.Helper()
+	var keys []string
+	if err := cache.Iterate(context.Background(), func(key string, _ []byte) bool {
+		if strings.Contains(key, "|vary:") {
+			keys = append(keys, key)
+		}
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// waitForVariantKeys waits for the background purge of variants to leave n
+// variant keys in cache, returning them
+func waitForVariantKeys(t *testing.T, cache *MemoryCache, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		keys := variantKeys(t, cache)
+		if len(keys) == n || time.Now().After(deadline) {
+			return keys
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestVariantsPurgedWhenVaryDropped verifies that once the origin stops sending
+// Vary, all variants are removed and the URL collapses to a single entry, which
+// a later return of the Vary header doesn't bring back
+func TestVariantsPurgedWhenVaryDropped(t *testing.T) {
+	resetTest()
+	var vary atomic.Bool
+	vary.Store(true)
+	ts := newTestOrigin(t, respondTogglingVary(&vary))
+
+	cache := NewMemoryCache()
+	tp := NewTransport(cache)
+	tp.EnableVarySeparation = true
+	client := tp.Client()
+
+	doAcceptRequest(t, client, ts.URL, "text/html")
+	doAcceptRequest(t, client, ts.URL, "application/json")
+	if keys := variantKeys(t, cache); len(keys) != 2 {
+		t.Fatalf("expected 2 variants, got %v", keys)
+	}
+
+	vary.Store(false)
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	doAcceptRequest(t, client, ts.URL, "text/html")
+	if keys := waitForVariantKeys(t, cache, 0); len(keys) != 0 {
+		t.Fatalf("expected the variants to be purged, got %v", keys)
+	}
+	clock = &fakeClock{}
+	if resp, body := doAcceptRequest(t, client, ts.URL, "application/json"); resp.Header.Get(XFromCache) != "1" || body != "accept text/html" {
+		t.Fatalf("expected the single entry to serve every Accept value, got %q", body)
+	}
+
+	// The origin varies again: the old json variant must not be served
+	vary.Store(true)
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	doAcceptRequest(t, client, ts.URL, "text/html")
+	clock = &fakeClock{}
+	before := ts.requests(nil)
+	resp, body := doAcceptRequest(t, client, ts.URL, "application/json")
+	if resp.Header.Get(XFromCache) == "1" || ts.requests(nil) != before+1 || body != "accept application/json" {
+		t.Fatalf("expected the json variant to be fetched again, got %q", body)
+	}
+}
+
+// TestVariantsPurgedWhenVaryDroppedNotIterable verifies that without Iterable,
+// the variant of the request that saw the change is still removed
+func TestVariantsPurgedWhenVaryDroppedNotIterable(t *testing.T) {
+	resetTest()
+	var vary atomic.Bool
+	vary.Store(true)
+	ts := newTestOrigin(t, respondTogglingVary(&vary))
+
+	cache := NewMemoryCache()
+	tp := NewTransport(nonIterableCache{cache})
+	tp.EnableVarySeparation = true
+	client := tp.Client()
+
+	doAcceptRequest(t, client, ts.URL, "text/html")
+	doAcceptRequest(t, client, ts.URL, "application/json")
+
+	vary.Store(false)
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	doAcceptRequest(t, client, ts.URL, "application/json")
+	keys := variantKeys(t, cache)
+	if len(keys) != 1 || !strings.Contains(keys[0], "text/html") {
+		t.Fatalf("expected only the text/html variant to remain, got %v", keys)
+	}
+}
+
+// TestVaryPurgeSkippedForNonVaryingEntries verifies storing a response that
+// replaces a non-varying entry doesn't read the cache to look for variants
+func TestVaryPurgeSkippedForNonVaryingEntries(t *testing.T) {
+	resetTest()
+	var vary atomic.Bool
+	ts := newTestOrigin(t, respondTogglingVary(&vary))
+
+	cache := &countingGetCache{Cache: NewMemoryCache()}
+	tp := NewTransport(cache)
+	tp.EnableVarySeparation = true
+	client := tp.Client()
+
+	doAcceptRequest(t, client, ts.URL, "text/html")
+	if cache.gets != 1 {
+		t.Fatalf("expected only the lookup to read the cache, got %d reads", cache.gets)
+	}
+}
+
+// countingGetCache counts the reads of the wrapped Cache
+type countingGetCache struct {
+	Cache
+	gets int
+}
+
+func (c *countingGetCache) Get(key string) ([]byte, bool) {
+	c.gets++
+	return c.Cache.Get(key)
+}
@@ -0,0 +1,123 @@
+package httpcache
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestWindowStats verifies windowed figures only reflect activity within the
+// window, and that activity older than the retention ages out
+func TestWindowStats(t *testing.T) {
+	s := NewWindowStats(time.Second, time.Minute)
+	now := time.Unix(1_700_000_000, 0)
+	s.now = func() time.Time { return now }
+
+	// Older activity: 1 hit and 3 misses
+	s.recordRequest(true)
+	s.addBytesSaved(100)
+	for range 3 {
+		s.recordRequest(false)
+	}
+
+	// Recent activity, 30 seconds later: 3 hits and 1 miss
+	now = now.Add(30 * time.Second)
+	for range 3 {
+		s.recordRequest(true)
+		s.addBytesSaved(10)
+	}
+	s.recordRequest(false)
+
+	if got := s.HitRate(10 * time.Second); got != 0.75 {
+		t.Errorf("expected a 0.75 hit rate over 10s, got %v", got)
+	}
+	if got := s.BytesSaved(10 * time.Second); got != 30 {
+		t.Errorf("expected 30 bytes saved over 10s, got %d", got)
+	}
+	if got := s.RequestRate(10 * time.Second); got != 0.4 {
+		t.Errorf("expected 0.4 requests/s over 10s, got %v", got)
+	}
+
+	if got := s.HitRate(time.Minute); got != 0.5 {
+		t.Errorf("expected a 0.5 hit rate over a minute, got %v", got)
+	}
+	if got := s.BytesSaved(time.Minute); got != 130 {
+		t.Errorf("expected 130 bytes saved over a minute, got %d", got)
+	}
+
+	// Windows longer than the retention are capped
+	if got := s.RequestRate(time.Hour); math.Abs(got-8.0/60) > 1e-9 {
+		t.Errorf("expected the rate over an hour to be capped at a minute, got %v", got)
+	}
+
+	// Past the retention everything has aged out, even if buckets were reused
+	now = now.Add(2 * time.Minute)
+	s.recordRequest(false)
+	if got := s.HitRate(time.Minute); got != 0 {
+		t.Errorf("expected old hits to age out, got hit rate %v", got)
+	}
+	if got := s.BytesSaved(time.Minute); got != 0 {
+		t.Errorf("expected old bytes to age out, got %d", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if got := s.RequestRate(time.Minute); got != 0 {
+		t.Errorf("expected no requests in an idle window, got %v", got)
+	}
+	if got := s.HitRate(time.Minute); got != 0 {
+		t.Errorf("expected a 0 hit rate without requests, got %v", got)
+	}
+}
+
+// TestTransportWindowStats verifies the Transport reports hits, misses and the
+// body bytes served from cache to WindowStats
+func TestTransportWindowStats(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("0123456789"))
+	}))
+	defer ts.Close()
+
+	tp := NewMemoryCacheTransport()
+	tp.WindowStats = NewWindowStats(time.Second, time.Minute)
+
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL) // miss
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL) // hit
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL) // hit
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL) // hit
+
+	if got := tp.WindowStats.HitRate(time.Minute); got != 0.75 {
+		t.Errorf("expected a 0.75 hit rate, got %v", got)
+	}
+	if got := tp.WindowStats.BytesSaved(time.Minute); got != 30 {
+		t.Errorf("expected 30 bytes saved, got %d", got)
+	}
+	if got := tp.WindowStats.RequestRate(time.Minute); math.Abs(got-4.0/60) > 1e-9 {
+		t.Errorf("expected 4 requests per minute, got %v", got)
+	}
+}
+
+// TestTransportWindowStatsWithoutMarkers verifies hits are counted with
+// MarkCachedResponses off
+func TestTransportWindowStatsWithoutMarkers(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("0123456789"))
+	}))
+	defer ts.Close()
+
+	tp := &Transport{Cache: NewMemoryCache(), WindowStats: NewWindowStats(time.Second, time.Minute)}
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL) // miss
+	doHeadUpdateRequest(t, tp, http.MethodGet, ts.URL) // hit
+
+	if got := tp.WindowStats.HitRate(time.Minute); got != 0.5 {
+		t.Errorf("expected a 0.5 hit rate, got %v", got)
+	}
+	if got := tp.WindowStats.BytesSaved(time.Minute); got != 10 {
+		t.Errorf("expected 10 bytes saved, got %d", got)
+	}
+}
@@ -0,0 +1,86 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingTransport counts the requests it forwards to its Transport
+type countingTransport struct {
+	http.RoundTripper
+	requests atomic.Int64
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return c.RoundTripper.RoundTrip(req)
+}
+
+// TestWrapClient verifies the wrapped client caches responses and still fetches
+// through its original transport, keeping its other settings
+func TestWrapClient(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	original := &countingTransport{RoundTripper: http.DefaultTransport}
+	client := &http.Client{Transport: original, Timeout: 5 * time.Second}
+
+	wrapped := WrapClient(client, NewMemoryCache())
+	if wrapped != client {
+		t.Fatal("expected the client to be modified in place")
+	}
+	if client.Timeout != 5*time.Second {
+		t.Errorf("expected the Timeout to be kept, got %v", client.Timeout)
+	}
+	tp, ok := client.Transport.(*Transport)
+	if !ok {
+		t.Fatalf("expected a *Transport, got %T", client.Transport)
+	}
+	if tp.Transport != original {
+		t.Error("expected the original transport as the underlying transport")
+	}
+
+	fetchAndDrain(t, client, ts.URL)
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Error("expected the second response from cache")
+	}
+	if got := original.requests.Load(); got != 1 {
+		t.Errorf("expected 1 request through the original transport, got %d", got)
+	}
+}
+
+// TestWrapClientNil verifies a nil client or transport falls back to the defaults
+func TestWrapClientNil(t *testing.T) {
+	resetTest()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	client := WrapClient(nil, NewMemoryCache())
+	if tp, ok := client.Transport.(*Transport); !ok || tp.Transport != nil {
+		t.Fatalf("expected a *Transport over the default transport, got %T", client.Transport)
+	}
+	fetchAndDrain(t, client, ts.URL)
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Error("expected the second response from cache")
+	}
+}
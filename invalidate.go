@@ -0,0 +1,44 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Invalidate removes the entries stored for req, e.g. after a write to the
+// upstream resource makes them stale. The key is computed as RoundTrip would,
+// including CacheKeyHeaders, the partition and KeyVersion, so a wrapper
+// hashing keys such as securecache finds the same entry. Both the GET and
+// HEAD entries for the URL of req are removed.
+//
+// With EnableVarySeparation, the variants of the entry are removed too: the
+// variant matching req, those tracked for MaxVaryVariants, and, when the
+// backend is Iterable and stores keys as given, all the others.
+//
+// It returns an error if req is not a GET or HEAD request.
+func (t *Transport) Invalidate(req *http.Request) error {
+	if req.Method != methodGET && req.Method != methodHEAD {
+		return fmt.Errorf("cannot invalidate %s requests", req.Method)
+	}
+	t.invalidateEntries(req, "invalidate")
+	return nil
+}
+
+// invalidateEntries removes the GET and HEAD entries for the URL of req, with
+// their Vary variants under EnableVarySeparation, computing the keys from the
+// headers of req as RoundTrip does. source is logged with each removed key.
+func (t *Transport) invalidateEntries(req *http.Request, source string) {
+	for _, method := range []string{methodGET, methodHEAD} {
+		methodReq := req
+		if req.Method != method {
+			methodReq = cloneRequest(req)
+			methodReq.Method = method
+		}
+		key := t.requestCacheKey(methodReq)
+		if t.EnableVarySeparation {
+			t.purgeStoredVariants(methodReq, key)
+		}
+		t.deleteEntry(key)
+		GetLogger().Debug("invalidated cache entry", "key", key, "source", source, "url", req.URL.String())
+	}
+}
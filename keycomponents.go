@@ -0,0 +1,80 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+)
+
+// KeyComponents returns the components the cache key for req is built from,
+// before they are joined, to troubleshoot why two requests get different
+// entries:
+//
+//   - baseURL is the request URL as keyed, after QueryParamAllowlist and
+//     QueryParamDenylist, prefixed by the method for methods other than GET
+//   - headerContributions maps the request headers listed in CacheKeyHeaders,
+//     VaryByHeaders and NegotiationHeaders (and the preflight headers of
+//     cacheable CORS preflight requests) to the value they add to the key.
+//     Headers absent from req don't contribute and are left out; for
+//     NegotiationHeaders the value is the preferred one
+//   - varyContributions maps the headers listed in the Vary header of the
+//     stored response to the value they add to the variant key, when
+//     EnableVarySeparation is set and a response is stored for req; it is nil
+//     otherwise
+//
+// Credential headers contribute their SHA-256 hash, as in the key itself.
+// CacheKeyCookies, PartitionKeyFunc and KeyVersion aren't reported.
+func (t *Transport) KeyComponents(req *http.Request) (baseURL string, headerContributions map[string]string, varyContributions map[string]string) {
+	keyReq := t.keyRequest(req)
+	baseURL = cacheKey(keyReq)
+
+	headers := t.keyHeaders()
+	if t.isCacheablePreflight(req) {
+		headers = append(append([]string(nil), headers...), preflightKeyHeaders...)
+	}
+	headerContributions = make(map[string]string)
+	for _, header := range headers {
+		canonicalHeader := http.CanonicalHeaderKey(header)
+		if value := keyReq.Header.Get(canonicalHeader); value != "" {
+			headerContributions[canonicalHeader] = keyHeaderValue(canonicalHeader, value)
+		}
+	}
+	for _, name := range t.NegotiationHeaders {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if value := canonicalNegotiationValue(name, keyReq.Header.Values(name)); value != "" {
+			headerContributions[name] = keyHeaderValue(name, value)
+		}
+	}
+
+	if t.EnableVarySeparation {
+		varyContributions = t.varyKeyComponents(req, keyReq)
+	}
+	return baseURL, headerContributions, varyContributions
+}
+
+// varyKeyComponents returns the Vary header values of keyReq that select the
+// variant of the response stored for req, or nil if none is stored or it
+// doesn't vary
+func (t *Transport) varyKeyComponents(req, keyReq *http.Request) map[string]string {
+	raw, ok := t.Cache.Get(t.requestCacheKey(req))
+	if !ok {
+		return nil
+	}
+	base, err := readCachedResponse(raw, req)
+	if err != nil {
+		return nil
+	}
+	_ = base.Body.Close()
+
+	var contributions map[string]string
+	for _, header := range headerAllCommaSepValues(base.Header, "vary") {
+		canonicalHeader := http.CanonicalHeaderKey(strings.TrimSpace(header))
+		if canonicalHeader == "" || canonicalHeader == "*" {
+			continue
+		}
+		if contributions == nil {
+			contributions = make(map[string]string)
+		}
+		contributions[canonicalHeader] = varyKeyValue(keyReq, canonicalHeader)
+	}
+	return contributions
+}
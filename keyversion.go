@@ -0,0 +1,51 @@
+package httpcache
+
+import (
+	"runtime/debug"
+)
+
+// keyVersionPrefix marks cache keys namespaced by KeyVersion
+const keyVersionPrefix = "version:"
+
+// versionedKey prepends the KeyVersion namespace to key, if set
+func (t *Transport) versionedKey(key string) string {
+	if t.KeyVersion == "" {
+		return key
+	}
+	return keyVersionPrefix + t.KeyVersion + "|" + key
+}
+
+// VersionFromBuildInfo returns a version string for KeyVersion derived from the
+// build information embedded in the running binary: the main module version
+// when built from a tagged module, otherwise the VCS revision (suffixed with
+// "-dirty" for modified working trees). It returns an empty string, which
+// disables key versioning, when neither is available (e.g. under go test).
+func VersionFromBuildInfo() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return versionFromBuildInfo(info)
+}
+
+// versionFromBuildInfo extracts the version from info, see VersionFromBuildInfo
+func versionFromBuildInfo(info *debug.BuildInfo) string {
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		return revision + "-dirty"
+	}
+	return revision
+}
@@ -3,15 +3,27 @@
 package leveldbcache
 
 import (
+	"context"
+
 	"github.com/sandrolain/httpcache"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
 )
 
+// clearBatchSize is the number of keys deleted per write batch by Clear
+const clearBatchSize = 1000
+
 // Cache is an implementation of httpcache.Cache with leveldb storage
 type Cache struct {
 	db *leveldb.DB
 }
 
+// Name returns "leveldb", the backend name reported with
+// httpcache.Transport.ServeDiagnostics
+func (c *Cache) Name() string {
+	return "leveldb"
+}
+
 // Get returns the response corresponding to key if present
 func (c *Cache) Get(key string) (resp []byte, ok bool) {
 	var err error
@@ -36,6 +48,80 @@ func (c *Cache) Delete(key string) {
 	}
 }
 
+// ClearWithContext removes all entries from the cache, deleting them in batches. It
+// implements httpcache.Clearer.
+func (c *Cache) ClearWithContext(ctx context.Context) error {
+	iter := c.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+		if batch.Len() < clearBatchSize {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.db.Write(batch, nil); err != nil {
+			return err
+		}
+		batch.Reset()
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return c.db.Write(batch, nil)
+}
+
+// Iterate implements httpcache.Iterable. It calls fn for each entry until fn
+// returns false or ctx is done; fn may safely call back into the cache.
+func (c *Cache) Iterate(ctx context.Context, fn func(key string, value []byte) bool) error {
+	return iterate(ctx, c.db.NewIterator(nil, nil), fn)
+}
+
+// Snapshot implements httpcache.SnapshotCache, returning a view of the cache
+// as it is now, unaffected by later writes
+func (c *Cache) Snapshot() (httpcache.Snapshot, error) {
+	snap, err := c.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot{snap: snap}, nil
+}
+
+// snapshot is a point-in-time view of a leveldb Cache
+type snapshot struct {
+	snap *leveldb.Snapshot
+}
+
+// Iterate calls fn for each entry of the snapshot until fn returns false or
+// ctx is done
+func (s *snapshot) Iterate(ctx context.Context, fn func(key string, value []byte) bool) error {
+	return iterate(ctx, s.snap.NewIterator(nil, nil), fn)
+}
+
+// Release releases the snapshot
+func (s *snapshot) Release() {
+	s.snap.Release()
+}
+
+// iterate calls fn for each entry of iter, releasing it when done. Values are
+// copied, since leveldb reuses the iterator buffers.
+func iterate(ctx context.Context, iter iterator.Iterator, fn func(key string, value []byte) bool) error {
+	defer iter.Release()
+	for iter.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		value := append([]byte(nil), iter.Value()...)
+		if !fn(string(iter.Key()), value) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
 // New returns a new Cache that will store leveldb in path
 func New(path string) (*Cache, error) {
 	cache := &Cache{}
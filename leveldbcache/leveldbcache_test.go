@@ -1,10 +1,15 @@
 package leveldbcache
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/sandrolain/httpcache"
 	"github.com/sandrolain/httpcache/test"
 )
 
@@ -24,3 +29,115 @@ func TestDiskCache(t *testing.T) {
 
 	test.Cache(t, cache)
 }
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+	cache, err := New(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatalf("New leveldb: %v", err)
+	}
+	return cache
+}
+
+func TestClear(t *testing.T) {
+	test.Clear(t, newTestCache(t))
+
+	// More entries than a single delete batch
+	cache := newTestCache(t)
+	for i := 0; i < 2*clearBatchSize+1; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+	if err := cache.ClearWithContext(context.Background()); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	remaining := 0
+	if err := cache.Iterate(context.Background(), func(string, []byte) bool {
+		remaining++
+		return true
+	}); err != nil {
+		t.Fatalf("Iterate failed: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("expected no entries after Clear, got %d", remaining)
+	}
+}
+
+func TestIterate(t *testing.T) {
+	cache := newTestCache(t)
+	want := map[string]string{"a": "1", "b": "2", "c": "3"}
+	for k, v := range want {
+		cache.Set(k, []byte(v))
+	}
+
+	got := map[string]string{}
+	err := cache.Iterate(context.Background(), func(key string, value []byte) bool {
+		got[key] = string(value)
+		// Deleting while iterating is safe
+		cache.Delete(key)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, got[k])
+		}
+	}
+}
+
+// TestSnapshotConsistent verifies iterating a snapshot sees the entries as they
+// were when it was taken, despite concurrent writes and deletes
+func TestSnapshotConsistent(t *testing.T) {
+	cache := newTestCache(t)
+	const entries = 500
+	for i := 0; i < entries; i++ {
+		cache.Set(fmt.Sprintf("key-%04d", i), []byte("v1"))
+	}
+
+	snap, err := cache.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Release()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < entries; i++ {
+			cache.Set(fmt.Sprintf("key-%04d", i), []byte("v2"))
+			cache.Set(fmt.Sprintf("new-%04d", i), []byte("v2"))
+			if i%2 == 0 {
+				cache.Delete(fmt.Sprintf("key-%04d", i))
+			}
+		}
+	}()
+
+	seen := map[string]bool{}
+	err = snap.Iterate(context.Background(), func(key string, value []byte) bool {
+		if seen[key] {
+			t.Errorf("%s seen twice", key)
+		}
+		seen[key] = true
+		if !strings.HasPrefix(key, "key-") || string(value) != "v1" {
+			t.Errorf("unexpected entry %s=%s", key, value)
+		}
+		return true
+	})
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != entries {
+		t.Errorf("expected %d entries, got %d", entries, len(seen))
+	}
+}
+
+func TestSnapshotInterfaces(t *testing.T) {
+	var _ httpcache.Iterable = &Cache{}
+	var _ httpcache.SnapshotCache = &Cache{}
+}
@@ -0,0 +1,34 @@
+package httpcache
+
+import (
+	"context"
+)
+
+// cachedRead is the result of a cache read
+type cachedRead struct {
+	responseBytes []byte
+	ok            bool
+	maybeStale    bool
+}
+
+// getCachedWithinBudget reads key from the cache like readCached, reporting a
+// miss if the read takes longer than CacheLookupBudget or ctx is done first
+func (t *Transport) getCachedWithinBudget(ctx context.Context, key string) (responseBytes []byte, ok bool, maybeStale bool) {
+	lookupCtx, cancel := context.WithTimeout(ctx, t.CacheLookupBudget)
+	defer cancel()
+
+	done := make(chan cachedRead, 1)
+	go func() {
+		responseBytes, ok, maybeStale := t.readCached(lookupCtx, key)
+		done <- cachedRead{responseBytes, ok, maybeStale}
+	}()
+
+	select {
+	case read := <-done:
+		return read.responseBytes, read.ok, read.maybeStale
+	case <-lookupCtx.Done():
+		GetLogger().Warn("cache lookup exceeded its budget, treating as a miss",
+			"key", key, "budget", t.CacheLookupBudget)
+		return nil, false, false
+	}
+}
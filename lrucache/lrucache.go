@@ -0,0 +1,155 @@
+// Package lrucache provides an in-memory implementation of httpcache.Cache
+// bounded by entry count and, optionally, by total size, using the LRU list of
+// github.com/hashicorp/golang-lru/v2 as the underlying storage.
+//
+// This backend is a lighter alternative to freecache for small caches: entries
+// live on the Go heap and the least recently used entries are evicted once
+// maxEntries, or maxBytes, is reached. It is also the bounded alternative to
+// httpcache.NewMemoryCache, which grows without limit.
+//
+// Example usage:
+//
+//	cache, err := lrucache.New(1000)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	transport := httpcache.NewTransport(cache)
+//	client := transport.Client()
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+// Cache is an implementation of httpcache.Cache that stores at most a fixed
+// number of entries, and optionally a fixed number of bytes, evicting the least
+// recently used entries when full.
+type Cache struct {
+	mu        sync.Mutex
+	cache     *simplelru.LRU[string, []byte]
+	maxBytes  int64
+	size      int64
+	evictions int64
+}
+
+// New creates a new Cache holding at most maxEntries entries.
+// It returns an error if maxEntries is not positive.
+func New(maxEntries int) (*Cache, error) {
+	c := &Cache{}
+	lru, err := simplelru.NewLRU(maxEntries, c.onRemove)
+	if err != nil {
+		return nil, err
+	}
+	c.cache = lru
+	return c, nil
+}
+
+// NewWithMaxBytes creates a new Cache holding at most maxEntries entries and
+// maxBytes bytes of keys and values, so that memory stays bounded whatever the
+// size of the responses. Entries bigger than maxBytes are not stored.
+// It returns an error if maxEntries or maxBytes is not positive.
+func NewWithMaxBytes(maxEntries int, maxBytes int64) (*Cache, error) {
+	if maxBytes <= 0 {
+		return nil, errors.New("lrucache: maxBytes must be positive")
+	}
+	c, err := New(maxEntries)
+	if err != nil {
+		return nil, err
+	}
+	c.maxBytes = maxBytes
+	return c, nil
+}
+
+// onRemove keeps the total size in step with every entry leaving the list
+func (c *Cache) onRemove(key string, value []byte) {
+	c.size -= entrySize(key, value)
+}
+
+// entrySize returns the bytes counted against maxBytes for an entry
+func entrySize(key string, value []byte) int64 {
+	return int64(len(key) + len(value))
+}
+
+// Name returns "lru", the backend name reported with
+// httpcache.Transport.ServeDiagnostics
+func (c *Cache) Name() string {
+	return "lru"
+}
+
+// Get returns the cached response bytes and true if present, false if not found
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Get(key)
+}
+
+// Set stores the response bytes in the cache with the given key.
+// If the cache is full, the least recently used entries are evicted. Setting a
+// value too big to ever fit within maxBytes is a no-op, rather than evicting the
+// whole cache.
+func (c *Cache) Set(key string, value []byte) {
+	size := entrySize(key, value)
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Removing the previous value first keeps the size accounting exact
+	c.cache.Remove(key)
+	if c.cache.Add(key, value) {
+		c.evictions++
+	}
+	c.size += size
+	for c.maxBytes > 0 && c.size > c.maxBytes {
+		c.cache.RemoveOldest()
+		c.evictions++
+	}
+}
+
+// Delete removes the entry with the given key from the cache
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	c.cache.Remove(key)
+	c.mu.Unlock()
+}
+
+// Purge removes all entries from the cache
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	c.cache.Purge()
+	c.mu.Unlock()
+}
+
+// ClearWithContext removes all entries from the cache, like Purge. It implements
+// httpcache.Clearer.
+func (c *Cache) ClearWithContext(_ context.Context) error {
+	c.Purge()
+	return nil
+}
+
+// Len returns the number of entries currently in the cache
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Len()
+}
+
+// Size returns the bytes of keys and values currently in the cache
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// EvictionCount returns the number of entries evicted so far to make room for
+// new ones, by entry count or by size. Deleted and purged entries don't count.
+func (c *Cache) EvictionCount() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evictions
+}
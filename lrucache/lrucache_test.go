@@ -0,0 +1,203 @@
+package lrucache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/sandrolain/httpcache"
+	"github.com/sandrolain/httpcache/test"
+)
+
+func TestLRUCacheImplementsCache(t *testing.T) {
+	var _ httpcache.Cache = &Cache{}
+}
+
+func TestLRUCache(t *testing.T) {
+	cache, err := New(10)
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	test.Cache(t, cache)
+}
+
+func TestLRUCacheClear(t *testing.T) {
+	cache, err := New(10)
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	test.Clear(t, cache)
+}
+
+func TestNewInvalidSize(t *testing.T) {
+	if _, err := New(0); err == nil {
+		t.Error("New(0) should return an error")
+	}
+	if _, err := New(-1); err == nil {
+		t.Error("New(-1) should return an error")
+	}
+}
+
+func TestEvictionAtCapacity(t *testing.T) {
+	cache, err := New(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		cache.Set("key"+strconv.Itoa(i), []byte("value"))
+	}
+	// Touch key0 so key1 becomes the least recently used entry
+	if _, ok := cache.Get("key0"); !ok {
+		t.Fatal("key0 should be present")
+	}
+
+	cache.Set("key3", []byte("value"))
+
+	if cache.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", cache.Len())
+	}
+	if _, ok := cache.Get("key1"); ok {
+		t.Error("key1 should have been evicted")
+	}
+	for _, key := range []string{"key0", "key2", "key3"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("%s should still be present", key)
+		}
+	}
+}
+
+func TestSetOverwrite(t *testing.T) {
+	cache, _ := New(2)
+	cache.Set("key", []byte("old"))
+	cache.Set("key", []byte("new"))
+
+	value, ok := cache.Get("key")
+	if !ok || string(value) != "new" {
+		t.Errorf("Get returned %q, want %q", value, "new")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", cache.Len())
+	}
+}
+
+func TestPurge(t *testing.T) {
+	cache, _ := New(10)
+	cache.Set("key1", []byte("value1"))
+	cache.Set("key2", []byte("value2"))
+
+	cache.Purge()
+
+	if cache.Len() != 0 {
+		t.Errorf("Len() = %d after Purge, want 0", cache.Len())
+	}
+	if _, ok := cache.Get("key1"); ok {
+		t.Error("key1 should not exist after Purge")
+	}
+}
+
+func TestNewWithMaxBytesInvalid(t *testing.T) {
+	if _, err := NewWithMaxBytes(10, 0); err == nil {
+		t.Error("NewWithMaxBytes(10, 0) should return an error")
+	}
+	if _, err := NewWithMaxBytes(0, 100); err == nil {
+		t.Error("NewWithMaxBytes(0, 100) should return an error")
+	}
+}
+
+func TestMaxBytesCache(t *testing.T) {
+	cache, err := NewWithMaxBytes(10, 1024)
+	if err != nil {
+		t.Fatalf("NewWithMaxBytes() returned error: %v", err)
+	}
+	test.Cache(t, cache)
+}
+
+func TestEvictionBySize(t *testing.T) {
+	// Each entry is 4 bytes of key and 6 of value
+	cache, err := NewWithMaxBytes(100, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		cache.Set("key"+strconv.Itoa(i), []byte("value"+strconv.Itoa(i)))
+	}
+	if cache.Size() != 30 || cache.EvictionCount() != 0 {
+		t.Fatalf("Size() = %d, EvictionCount() = %d, want 30 and 0", cache.Size(), cache.EvictionCount())
+	}
+	// Touch key0 so key1 becomes the least recently used entry
+	if _, ok := cache.Get("key0"); !ok {
+		t.Fatal("key0 should be present")
+	}
+
+	// A 19-byte entry needs the room of two
+	cache.Set("key3", []byte("value3-and-more"))
+
+	for _, key := range []string{"key1", "key2"} {
+		if _, ok := cache.Get(key); ok {
+			t.Errorf("%s should have been evicted", key)
+		}
+	}
+	for _, key := range []string{"key0", "key3"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("%s should still be present", key)
+		}
+	}
+	if cache.Size() != 29 {
+		t.Errorf("Size() = %d, want 29", cache.Size())
+	}
+	if cache.EvictionCount() != 2 {
+		t.Errorf("EvictionCount() = %d, want 2", cache.EvictionCount())
+	}
+}
+
+func TestEvictionCountByEntries(t *testing.T) {
+	cache, _ := New(2)
+	for i := 0; i < 5; i++ {
+		cache.Set("key"+strconv.Itoa(i), []byte("value"))
+	}
+	cache.Delete("key4")
+	cache.Purge()
+
+	if cache.EvictionCount() != 3 {
+		t.Errorf("EvictionCount() = %d, want 3", cache.EvictionCount())
+	}
+}
+
+func TestOversizedSet(t *testing.T) {
+	cache, _ := NewWithMaxBytes(10, 20)
+	cache.Set("a", []byte("small"))
+	cache.Set("key", []byte("small"))
+
+	cache.Set("key", []byte("far too large for the cache"))
+
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("an oversized value should not evict other entries")
+	}
+	if value, ok := cache.Get("key"); !ok || string(value) != "small" {
+		t.Errorf("an oversized value should leave the previous value of its key, got %q", value)
+	}
+	if cache.Size() != 14 || cache.EvictionCount() != 0 {
+		t.Errorf("Size() = %d, EvictionCount() = %d, want 14 and 0", cache.Size(), cache.EvictionCount())
+	}
+}
+
+func TestSizeAfterOverwriteAndDelete(t *testing.T) {
+	cache, _ := NewWithMaxBytes(10, 100)
+	cache.Set("key", []byte("old"))
+	cache.Set("key", []byte("newer"))
+	if cache.Size() != 8 {
+		t.Errorf("Size() = %d after overwrite, want 8", cache.Size())
+	}
+
+	cache.Delete("key")
+	if cache.Size() != 0 {
+		t.Errorf("Size() = %d after Delete, want 0", cache.Size())
+	}
+
+	cache.Set("key", []byte("value"))
+	cache.Purge()
+	if cache.Size() != 0 {
+		t.Errorf("Size() = %d after Purge, want 0", cache.Size())
+	}
+}
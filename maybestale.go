@@ -0,0 +1,72 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+)
+
+// MaybeStaleCache is an optional interface implemented by caches that can tell
+// when a value may be outdated, e.g. one read from a lagging Redis replica while
+// the primary is unreachable. GetMaybeStale behaves like Get, and also reports
+// maybeStale == true when the backend couldn't confirm the value is current.
+// The Transport uses it when MaybeStaleWindow is set.
+type MaybeStaleCache interface {
+	GetMaybeStale(key string) (responseBytes []byte, ok bool, maybeStale bool)
+}
+
+// xMaybeStale tags a cached response the backend flagged as possibly stale,
+// between the lookup and the freshness decision. It is never stored or served.
+const xMaybeStale = "X-Cache-Maybe-Stale"
+
+// getCached reads key from the cache for a request with ctx, reporting whether
+// a MaybeStaleCache flagged the value as possibly stale when MaybeStaleWindow is
+// set. A ContextCache is passed ctx. The read is bounded by CacheLookupBudget,
+// if set.
+func (t *Transport) getCached(ctx context.Context, key string) (responseBytes []byte, ok bool, maybeStale bool) {
+	if t.CacheLookupBudget > 0 {
+		return t.getCachedWithinBudget(ctx, key)
+	}
+	return t.readCached(ctx, key)
+}
+
+// readCached reads key from the cache as described by getCached, without a budget
+func (t *Transport) readCached(ctx context.Context, key string) (responseBytes []byte, ok bool, maybeStale bool) {
+	if t.MaybeStaleWindow > 0 {
+		if c, isMaybeStale := t.Cache.(MaybeStaleCache); isMaybeStale {
+			return c.GetMaybeStale(key)
+		}
+	}
+	if c, isContext := t.Cache.(ContextCache); isContext {
+		responseBytes, ok = c.GetWithContext(ctx, key)
+		return responseBytes, ok, false
+	}
+	responseBytes, ok = t.Cache.Get(key)
+	return responseBytes, ok, false
+}
+
+// takeMaybeStaleTag removes the possibly-stale tag from cachedResp, reporting
+// whether it was set
+func takeMaybeStaleTag(cachedResp *http.Response) bool {
+	if cachedResp.Header.Get(xMaybeStale) == "" {
+		return false
+	}
+	cachedResp.Header.Del(xMaybeStale)
+	return true
+}
+
+// canServeMaybeStale reports whether cachedResp, flagged as possibly stale by
+// the backend, may be served without contacting the origin: it must be fresh, or
+// stale by less than MaybeStaleWindow unless it is marked must-revalidate
+func (t *Transport) canServeMaybeStale(cachedResp *http.Response) bool {
+	date, err := Date(cachedResp.Header)
+	if err != nil {
+		return false
+	}
+	respCacheControl := parseCacheControl(cachedResp.Header)
+	window := t.MaybeStaleWindow
+	if _, mustRevalidate := respCacheControl[cacheControlMustRevalidate]; mustRevalidate {
+		window = 0
+	}
+	lifetime := calculateLifetime(respCacheControl, cachedResp.Header, date)
+	return currentAge(cachedResp.Header, date) < lifetime+window
+}
@@ -25,6 +25,12 @@ func cacheKey(key string) string {
 	return "httpcache:" + key
 }
 
+// Name returns "memcache", the backend name reported with
+// httpcache.Transport.ServeDiagnostics.
+func (c *Cache) Name() string {
+	return "memcache"
+}
+
 // Get returns the response corresponding to key if present.
 func (c *Cache) Get(key string) (resp []byte, ok bool) {
 	item, err := c.Client.Get(cacheKey(key))
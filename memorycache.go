@@ -1,6 +1,9 @@
 package httpcache
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 // MemoryCache is an implemtation of Cache that stores responses in an in-memory map.
 type MemoryCache struct {
@@ -8,6 +11,12 @@ type MemoryCache struct {
 	items map[string][]byte
 }
 
+// Name returns "memory", the backend name reported with
+// Transport.ServeDiagnostics
+func (c *MemoryCache) Name() string {
+	return "memory"
+}
+
 // Get returns the []byte representation of the response and true if present, false if not
 func (c *MemoryCache) Get(key string) (resp []byte, ok bool) {
 	c.mu.RLock()
@@ -23,6 +32,18 @@ func (c *MemoryCache) Set(key string, resp []byte) {
 	c.mu.Unlock()
 }
 
+// Add saves response resp to the cache with key unless key is already present,
+// reporting whether it was saved
+func (c *MemoryCache) Add(key string, resp []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[key]; ok {
+		return false
+	}
+	c.items[key] = resp
+	return true
+}
+
 // Delete removes key from the cache
 func (c *MemoryCache) Delete(key string) {
 	c.mu.Lock()
@@ -30,6 +51,47 @@ func (c *MemoryCache) Delete(key string) {
 	c.mu.Unlock()
 }
 
+// ClearWithContext removes all entries from the cache. It implements Clearer.
+func (c *MemoryCache) ClearWithContext(_ context.Context) error {
+	c.mu.Lock()
+	c.items = make(map[string][]byte)
+	c.mu.Unlock()
+	return nil
+}
+
+// Iterate calls fn for each entry in the cache until fn returns false or ctx is done.
+// It iterates over a snapshot taken under the read lock, so fn may safely call
+// back into the cache.
+func (c *MemoryCache) Iterate(ctx context.Context, fn func(key string, value []byte) bool) error {
+	c.mu.RLock()
+	snapshot := make(map[string][]byte, len(c.items))
+	for k, v := range c.items {
+		snapshot[k] = v
+	}
+	c.mu.RUnlock()
+
+	for k, v := range snapshot {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fn(k, v) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// SaveToFile writes a snapshot of the cache to path, so it can be restored with
+// LoadFromFile after a restart
+func (c *MemoryCache) SaveToFile(path string) error {
+	return SaveSnapshotFile(context.Background(), c, path)
+}
+
+// LoadFromFile restores a snapshot written by SaveToFile into the cache
+func (c *MemoryCache) LoadFromFile(path string) error {
+	return LoadSnapshotFile(path, c)
+}
+
 // NewMemoryCache returns a new Cache that will store items in an in-memory map
 func NewMemoryCache() *MemoryCache {
 	c := &MemoryCache{items: map[string][]byte{}}
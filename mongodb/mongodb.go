@@ -66,6 +66,12 @@ func (c cache) cacheKey(key string) string {
 	return c.keyPrefix + key
 }
 
+// Name returns "mongodb", the backend name reported with
+// httpcache.Transport.ServeDiagnostics.
+func (c cache) Name() string {
+	return "mongodb"
+}
+
 // Get returns the response corresponding to key if present.
 func (c cache) Get(key string) (resp []byte, ok bool) {
 	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
@@ -98,6 +104,23 @@ func (c cache) Set(key string, resp []byte) {
 	}
 }
 
+// Add saves a response to the cache as key unless key already exists, using an
+// upsert that only sets the fields on insert. It reports whether the response
+// was saved.
+func (c cache) Add(key string, resp []byte) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	update := bson.M{"$setOnInsert": bson.M{"data": resp, "createdAt": time.Now()}}
+	opts := options.Update().SetUpsert(true)
+	result, err := c.collection.UpdateOne(ctx, bson.M{bsonIDField: c.cacheKey(key)}, update, opts)
+	if err != nil {
+		httpcache.GetLogger().Warn("failed to write to MongoDB cache", "key", key, "error", err)
+		return false
+	}
+	return result.UpsertedCount == 1
+}
+
 // Delete removes the response with key from the cache.
 func (c cache) Delete(key string) {
 	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
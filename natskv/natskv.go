@@ -47,6 +47,12 @@ func cacheKey(key string) string {
 	return "httpcache." + key
 }
 
+// Name returns "natskv", the backend name reported with
+// httpcache.Transport.ServeDiagnostics.
+func (c cache) Name() string {
+	return "natskv"
+}
+
 // Get returns the response corresponding to key if present.
 func (c cache) Get(key string) (resp []byte, ok bool) {
 	entry, err := c.kv.Get(context.Background(), cacheKey(key))
@@ -0,0 +1,62 @@
+package httpcache
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// negotiationKey appends the canonical values of NegotiationHeaders in req to key
+func (t *Transport) negotiationKey(req *http.Request, key string) string {
+	var parts []string
+	for _, name := range t.NegotiationHeaders {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if value := canonicalNegotiationValue(name, req.Header.Values(name)); value != "" {
+			parts = append(parts, name+":"+keyHeaderValue(name, value))
+		}
+	}
+	if len(parts) == 0 {
+		return key
+	}
+	sort.Strings(parts)
+	return key + "|negotiate:" + strings.Join(parts, "|")
+}
+
+// canonicalNegotiationValue reduces the values of a negotiation header to the
+// value that decides the response: for the Accept family, the most preferred
+// media type, coding or language; for other headers, the normalized value.
+func canonicalNegotiationValue(name string, values []string) string {
+	value := strings.Join(values, ",")
+	if !strings.HasPrefix(name, "Accept") {
+		return normalizeHeaderValue(value)
+	}
+	return preferredValue(value)
+}
+
+// preferredValue returns the element of a comma-separated preference list with
+// the highest q-value, lowercased and without parameters. The first element wins
+// ties; elements with q=0 are never preferred.
+func preferredValue(list string) string {
+	best, bestQ := "", 0.0
+	for _, element := range strings.Split(list, ",") {
+		fields := strings.Split(element, ";")
+		token := strings.ToLower(strings.TrimSpace(fields[0]))
+		if token == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(name), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = token, q
+		}
+	}
+	return best
+}
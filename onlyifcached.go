@@ -0,0 +1,31 @@
+package httpcache
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// addOnlyIfCachedRetryAfter adds a jittered Retry-After to the 504 returned for an
+// only-if-cached request that missed, when OnlyIfCachedRetryAfterMax is set
+func (t *Transport) addOnlyIfCachedRetryAfter(req *http.Request, resp *http.Response) {
+	if t.OnlyIfCachedRetryAfterMax <= 0 || resp.StatusCode != http.StatusGatewayTimeout || resp.Header.Get(XFromCache) != "" {
+		return
+	}
+	if _, ok := parseCacheControl(req.Header)[cacheControlOnlyIfCached]; !ok {
+		return
+	}
+	resp.Header.Set(headerRetryAfter, strconv.FormatInt(t.onlyIfCachedRetryAfter(), 10))
+}
+
+// onlyIfCachedRetryAfter returns a random delay in seconds between
+// OnlyIfCachedRetryAfterMin and OnlyIfCachedRetryAfterMax, inclusive
+func (t *Transport) onlyIfCachedRetryAfter() int64 {
+	lower := int64(max(t.OnlyIfCachedRetryAfterMin, 0) / time.Second)
+	upper := int64(t.OnlyIfCachedRetryAfterMax / time.Second)
+	if upper <= lower {
+		return upper
+	}
+	return lower + rand.Int64N(upper-lower+1) //nolint:gosec // jitter doesn't need a secure source
+}
@@ -0,0 +1,101 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// ParsedResponseCache keeps recently parsed cache entries in process memory, so
+// a cache hit on unchanged stored bytes builds the http.Response from the parsed
+// status line and headers instead of re-parsing them.
+// Set it on Transport.ParsedResponseCache. It is safe for concurrent use.
+type ParsedResponseCache struct {
+	entries *lru.Cache[string, *parsedResponse]
+}
+
+// parsedResponse is the result of parsing a stored entry, valid for as long as
+// the stored bytes are unchanged
+type parsedResponse struct {
+	raw        []byte
+	status     string
+	statusCode int
+	proto      string
+	protoMajor int
+	protoMinor int
+	header     http.Header
+	close      bool
+	bodyOffset int
+}
+
+// NewParsedResponseCache returns a ParsedResponseCache holding at most maxEntries
+// parsed responses. It returns an error if maxEntries is not positive.
+func NewParsedResponseCache(maxEntries int) (*ParsedResponseCache, error) {
+	entries, err := lru.New[string, *parsedResponse](maxEntries)
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedResponseCache{entries: entries}, nil
+}
+
+// readResponse parses raw into a response for req, reusing the parsed form of
+// key when raw is unchanged since it was last parsed
+func (p *ParsedResponseCache) readResponse(key string, raw []byte, req *http.Request) (*http.Response, error) {
+	if req.Method != methodGET {
+		return readCachedResponse(raw, req)
+	}
+
+	if parsed, ok := p.entries.Get(key); ok && sameBytes(parsed.raw, raw) {
+		body := raw[parsed.bodyOffset:]
+		return &http.Response{
+			Status:        parsed.status,
+			StatusCode:    parsed.statusCode,
+			Proto:         parsed.proto,
+			ProtoMajor:    parsed.protoMajor,
+			ProtoMinor:    parsed.protoMinor,
+			Header:        parsed.header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Close:         parsed.close,
+			Request:       req,
+		}, nil
+	}
+
+	resp, bodyOffset, err := parseCachedResponse(raw, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only entries with a plain Content-Length body can be served by slicing raw;
+	// anything else (e.g. chunked encoding) always takes the regular path
+	if len(resp.TransferEncoding) == 0 && resp.Trailer == nil && resp.ContentLength == int64(len(raw)-bodyOffset) {
+		p.entries.Add(key, &parsedResponse{
+			raw:        raw,
+			status:     resp.Status,
+			statusCode: resp.StatusCode,
+			proto:      resp.Proto,
+			protoMajor: resp.ProtoMajor,
+			protoMinor: resp.ProtoMinor,
+			header:     resp.Header.Clone(),
+			close:      resp.Close,
+			bodyOffset: bodyOffset,
+		})
+	} else {
+		p.entries.Remove(key)
+	}
+	return resp, nil
+}
+
+// sameBytes reports whether a and b hold the same content, short-circuiting when
+// they share the same backing array (e.g. MemoryCache returns the stored slice)
+func sameBytes(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	if len(a) > 0 && &a[0] == &b[0] {
+		return true
+	}
+	return bytes.Equal(a, b)
+}
@@ -0,0 +1,80 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// partitionKeyPrefix marks partitioned cache keys
+const partitionKeyPrefix = "partition:"
+
+// requestCacheKey returns the cache key for req, including CacheKeyHeaders,
+// VaryByHeaders, NegotiationHeaders, CacheKeyCookies, the partition returned by
+// PartitionKeyFunc and KeyVersion, with the query filtered by QueryParamAllowlist
+// and QueryParamDenylist. Cacheable CORS preflight requests are also keyed by their
+// preflight headers.
+func (t *Transport) requestCacheKey(req *http.Request) string {
+	keyReq := t.keyRequest(req)
+	headers := t.keyHeaders()
+	if t.isCacheablePreflight(req) {
+		headers = append(append([]string(nil), headers...), preflightKeyHeaders...)
+	}
+	return t.partitionedKey(req, t.cookieKey(keyReq, t.negotiationKey(keyReq, cacheKeyWithHeaders(keyReq, headers))))
+}
+
+// keyHeaders returns CacheKeyHeaders followed by the VaryByHeaders not already
+// listed, with Authorization added or removed as AuthorizationPolicy requires
+func (t *Transport) keyHeaders() []string {
+	if t.AuthorizationPolicy != AuthorizationRFC {
+		return t.authorizationKeyHeaders(t.configuredKeyHeaders())
+	}
+	return t.configuredKeyHeaders()
+}
+
+// configuredKeyHeaders returns CacheKeyHeaders followed by the VaryByHeaders not
+// already listed
+func (t *Transport) configuredKeyHeaders() []string {
+	if len(t.VaryByHeaders) == 0 {
+		return t.CacheKeyHeaders
+	}
+	headers := append([]string(nil), t.CacheKeyHeaders...)
+	for _, name := range t.VaryByHeaders {
+		listed := false
+		for _, h := range headers {
+			if http.CanonicalHeaderKey(h) == http.CanonicalHeaderKey(name) {
+				listed = true
+				break
+			}
+		}
+		if !listed {
+			headers = append(headers, name)
+		}
+	}
+	return headers
+}
+
+// partitionedKey prepends the partition of req to key when PartitionKeyFunc is
+// set, and the KeyVersion namespace when set.
+func (t *Transport) partitionedKey(req *http.Request, key string) string {
+	if t.PartitionKeyFunc != nil {
+		if partition := t.PartitionKeyFunc(req); partition != "" {
+			key = partitionKeyPrefix + partition + "|" + key
+		}
+	}
+	return t.versionedKey(key)
+}
+
+// PartitionByOrigin is a PartitionKeyFunc that partitions the cache by the site
+// that initiated the request: the Origin header if present, otherwise the origin
+// of the Referer header. Requests with neither share the unpartitioned cache.
+func PartitionByOrigin(req *http.Request) string {
+	if origin := req.Header.Get("Origin"); origin != "" && origin != "null" {
+		return origin
+	}
+	if referer := req.Header.Get("Referer"); referer != "" {
+		if u, err := url.Parse(referer); err == nil && u.Host != "" {
+			return getOrigin(u)
+		}
+	}
+	return ""
+}
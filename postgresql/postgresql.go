@@ -58,6 +58,12 @@ func (c *Cache) cacheKey(key string) string {
 	return c.keyPrefix + key
 }
 
+// Name returns "postgresql", the backend name reported with
+// httpcache.Transport.ServeDiagnostics.
+func (c *Cache) Name() string {
+	return "postgresql"
+}
+
 // Get returns the response corresponding to key if present.
 func (c *Cache) Get(key string) (resp []byte, ok bool) {
 	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
@@ -0,0 +1,46 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// preflightKeyHeaders are the request headers a CORS preflight response depends
+// on, added to the cache key of preflight requests
+var preflightKeyHeaders = []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}
+
+// isPreflight reports whether req is a CORS preflight request: an OPTIONS request
+// with Origin and Access-Control-Request-Method headers
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions &&
+		req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// isCacheablePreflight reports whether req is a CORS preflight request whose
+// response may be cached, per CachePreflight
+func (t *Transport) isCacheablePreflight(req *http.Request) bool {
+	return t.CachePreflight && isPreflight(req)
+}
+
+// storePreflightLifetime records the Access-Control-Max-Age of a preflight
+// response as its lifetime, unless another override is set
+func (t *Transport) storePreflightLifetime(resp *http.Response, req *http.Request) {
+	if !t.isCacheablePreflight(req) || resp.Header.Get(XCacheLifetime) != "" {
+		return
+	}
+	value := strings.TrimSpace(resp.Header.Get("Access-Control-Max-Age"))
+	if value == "" {
+		return
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return
+	}
+	// A negative value (e.g. -1) disables preflight caching in browsers
+	if seconds < 0 {
+		seconds = 0
+	}
+	resp.Header.Set(XCacheLifetime, strconv.FormatInt(seconds, 10))
+}
@@ -0,0 +1,57 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// keyRequest returns the request used to derive cache keys for req. When
+// QueryParamAllowlist or QueryParamDenylist is set, it is a shallow copy whose URL
+// query only holds the parameters that affect the key; otherwise it is req itself.
+// The request sent upstream is never modified.
+func (t *Transport) keyRequest(req *http.Request) *http.Request {
+	if (len(t.QueryParamAllowlist) == 0 && len(t.QueryParamDenylist) == 0) || req.URL == nil || req.URL.RawQuery == "" {
+		return req
+	}
+
+	query, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		// Keep malformed queries as they are rather than guessing which parts matter
+		return req
+	}
+	for name := range query {
+		if !t.queryParamAffectsKey(name) {
+			delete(query, name)
+		}
+	}
+
+	u := *req.URL
+	u.RawQuery = query.Encode()
+	keyReq := *req
+	keyReq.URL = &u
+	return &keyReq
+}
+
+// queryParamAffectsKey reports whether the query parameter name is part of the cache key
+func (t *Transport) queryParamAffectsKey(name string) bool {
+	if len(t.QueryParamAllowlist) > 0 && !matchesQueryParam(t.QueryParamAllowlist, name) {
+		return false
+	}
+	return !matchesQueryParam(t.QueryParamDenylist, name)
+}
+
+// matchesQueryParam reports whether name matches one of the patterns. A pattern
+// ending in "*" matches any parameter with that prefix, e.g. "utm_*".
+func matchesQueryParam(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerRange        = "Range"
+	headerIfRange      = "If-Range"
+	headerContentRange = "Content-Range"
+	headerContentLen   = "Content-Length"
+	rangeUnitPrefix    = "bytes="
+)
+
+// parseByteRange parses a single-range "bytes=" Range header value against a body of
+// the given size (RFC 9110 Section 14.1.2). It returns the inclusive [start, end]
+// offsets and whether the range is satisfiable. valid is false for headers this
+// cache does not handle (other units, multiple ranges, malformed specs), which
+// should be forwarded to the origin instead.
+func parseByteRange(header string, size int64) (start, end int64, satisfiable, valid bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), rangeUnitPrefix)
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false, false
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false, false
+	}
+	first = strings.TrimSpace(first)
+	last = strings.TrimSpace(last)
+
+	if first == "" {
+		// Suffix range: the last N bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false, false
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false, true
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, false
+	}
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, false
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return 0, 0, false, true
+	}
+	return start, end, true, true
+}
+
+// serveRangeFromCache answers a Range request from a complete, fresh 200 entry
+// without contacting the origin. It returns false when the request must be
+// forwarded instead (no entry, stale entry, unsupported Range or If-Range).
+func (t *Transport) serveRangeFromCache(req *http.Request) (*http.Response, bool) {
+	if req.Method != methodGET || req.Header.Get(headerIfRange) != "" {
+		return nil, false
+	}
+
+	// Look up the full entry with a Range-free copy of the request
+	fullReq := cloneRequest(req)
+	fullReq.Header.Del(headerRange)
+	var source *serveSource
+	if t.ServeDiagnostics {
+		fullReq, source = withServeSource(fullReq)
+	}
+	cachedResp, key, err := t.lookupCachedResponse(fullReq, t.requestCacheKey(fullReq))
+	if err != nil || cachedResp == nil || cachedResp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+	// An entry the backend couldn't confirm as current goes through RoundTrip
+	if takeMaybeStaleTag(cachedResp) {
+		return nil, false
+	}
+	if !varyMatches(cachedResp, fullReq) || getFreshness(cachedResp.Header, fullReq.Header) != fresh {
+		return nil, false
+	}
+
+	// The partial response carries the same serve-time headers as a full hit
+	t.addServedHeaders(cachedResp, fresh)
+
+	body, err := io.ReadAll(cachedResp.Body)
+	_ = cachedResp.Body.Close()
+	if err != nil {
+		return nil, false
+	}
+
+	size := int64(len(body))
+	start, end, satisfiable, valid := parseByteRange(req.Header.Get(headerRange), size)
+	if !valid {
+		return nil, false
+	}
+
+	resp := &http.Response{
+		Proto:      cachedResp.Proto,
+		ProtoMajor: cachedResp.ProtoMajor,
+		ProtoMinor: cachedResp.ProtoMinor,
+		Header:     cachedResp.Header.Clone(),
+		Request:    req,
+	}
+	if t.MarkCachedResponses {
+		resp.Header.Set(XFromCache, "1")
+	}
+	t.addServeDiagnostics(resp, source, key)
+
+	if !satisfiable {
+		// RFC 9110 Section 15.5.17: 416 with the current length of the representation
+		resp.StatusCode = http.StatusRequestedRangeNotSatisfiable
+		resp.Status = strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode)
+		resp.Header.Set(headerContentRange, fmt.Sprintf("bytes */%d", size))
+		resp.Header.Set(headerContentLen, "0")
+		resp.ContentLength = 0
+		resp.Body = http.NoBody
+		return resp, true
+	}
+
+	part := body[start : end+1]
+	resp.StatusCode = http.StatusPartialContent
+	resp.Status = strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode)
+	resp.Header.Set(headerContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	resp.Header.Set(headerContentLen, strconv.Itoa(len(part)))
+	resp.ContentLength = int64(len(part))
+	resp.Body = io.NopCloser(bytes.NewReader(part))
+	return resp, true
+}
@@ -2,6 +2,8 @@
 package redis
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -48,6 +50,9 @@ type Config struct {
 	WriteTimeout time.Duration
 }
 
+// clearBatchSize is the number of keys Clear asks SCAN for at a time
+const clearBatchSize = 1000
+
 // cache is an implementation of httpcache.Cache that caches responses in a
 // redis server.
 type cache struct {
@@ -60,8 +65,21 @@ func cacheKey(key string) string {
 	return "rediscache:" + key
 }
 
+// Name returns "redis", the backend name reported with
+// httpcache.Transport.ServeDiagnostics.
+func (c cache) Name() string {
+	return "redis"
+}
+
 // Get returns the response corresponding to key if present.
 func (c cache) Get(key string) (resp []byte, ok bool) {
+	resp, ok, _ = c.GetWithError(key)
+	return resp, ok
+}
+
+// GetWithError returns the response corresponding to key if present, and the
+// error if the redis command failed.
+func (c cache) GetWithError(key string) (resp []byte, ok bool, err error) {
 	conn := c.pool.Get()
 	defer func() {
 		if err := conn.Close(); err != nil {
@@ -71,13 +89,24 @@ func (c cache) Get(key string) (resp []byte, ok bool) {
 
 	item, err := redis.Bytes(conn.Do("GET", cacheKey(key)))
 	if err != nil {
-		return nil, false
+		if errors.Is(err, redis.ErrNil) {
+			return nil, false, nil
+		}
+		return nil, false, err
 	}
-	return item, true
+	return item, true, nil
 }
 
 // Set saves a response to the cache as key.
 func (c cache) Set(key string, resp []byte) {
+	if err := c.SetWithError(key, resp); err != nil {
+		httpcache.GetLogger().Warn("failed to write to redis cache", "key", key, "error", err)
+	}
+}
+
+// SetWithError saves a response to the cache as key, returning the error if the
+// redis command failed.
+func (c cache) SetWithError(key string, resp []byte) error {
 	conn := c.pool.Get()
 	defer func() {
 		if err := conn.Close(); err != nil {
@@ -85,13 +114,41 @@ func (c cache) Set(key string, resp []byte) {
 		}
 	}()
 
-	if _, err := conn.Do("SET", cacheKey(key), resp); err != nil {
-		httpcache.GetLogger().Warn("failed to write to redis cache", "key", key, "error", err)
+	_, err := conn.Do("SET", cacheKey(key), resp)
+	return err
+}
+
+// Add saves a response to the cache as key unless key already exists, using
+// SET NX so concurrent writers agree on a single winner. It reports whether the
+// response was saved.
+func (c cache) Add(key string, resp []byte) bool {
+	conn := c.pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			httpcache.GetLogger().Error("failed to close redis connection", "error", err)
+		}
+	}()
+
+	_, err := redis.String(conn.Do("SET", cacheKey(key), resp, "NX"))
+	if err != nil {
+		if !errors.Is(err, redis.ErrNil) {
+			httpcache.GetLogger().Warn("failed to write to redis cache", "key", key, "error", err)
+		}
+		return false
 	}
+	return true
 }
 
 // Delete removes the response with key from the cache.
 func (c cache) Delete(key string) {
+	if err := c.DeleteWithError(key); err != nil {
+		httpcache.GetLogger().Warn("failed to delete from redis cache", "key", key, "error", err)
+	}
+}
+
+// DeleteWithError removes the response with key from the cache, returning the
+// error if the redis command failed.
+func (c cache) DeleteWithError(key string) error {
 	conn := c.pool.Get()
 	defer func() {
 		if err := conn.Close(); err != nil {
@@ -99,8 +156,43 @@ func (c cache) Delete(key string) {
 		}
 	}()
 
-	if _, err := conn.Do("DEL", cacheKey(key)); err != nil {
-		httpcache.GetLogger().Warn("failed to delete from redis cache", "key", key, "error", err)
+	_, err := conn.Do("DEL", cacheKey(key))
+	return err
+}
+
+// ClearWithContext removes all the entries stored by the cache, i.e. the keys under its
+// prefix, leaving the other keys of the database alone. Keys are found with
+// SCAN and deleted in batches, so Redis isn't blocked on large databases.
+// It implements httpcache.Clearer.
+func (c cache) ClearWithContext(ctx context.Context) error {
+	conn := c.pool.Get()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			httpcache.GetLogger().Error("failed to close redis connection", "error", err)
+		}
+	}()
+
+	cursor := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", cacheKey("*"), "COUNT", clearBatchSize))
+		if err != nil {
+			return err
+		}
+		var keys []string
+		if _, err := redis.Scan(values, &cursor, &keys); err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if _, err := conn.Do("DEL", redis.Args{}.AddFlat(keys)...); err != nil {
+				return err
+			}
+		}
+		if cursor == 0 {
+			return nil
+		}
 	}
 }
 
@@ -183,3 +183,34 @@ func TestRedisCacheIntegrationPersistence(t *testing.T) {
 		}
 	}
 }
+
+// TestRedisCacheIntegrationClear tests that Clear removes the cache entries only.
+func TestRedisCacheIntegrationClear(t *testing.T) {
+	if testing.Short() {
+		t.Skip(skipIntegrationMsg)
+	}
+
+	c, cleanup := setupRedisCache(t)
+	defer cleanup()
+
+	conn := c.pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("SET", "unrelated", "value"); err != nil {
+		t.Fatalf("failed to set unrelated key: %v", err)
+	}
+
+	keys := []string{"key1", "key2", "key3"}
+	for _, key := range keys {
+		c.Set(key, []byte("value"))
+	}
+
+	if err := c.ClearWithContext(context.Background()); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	for _, key := range keys {
+		verifyKeyExists(t, c, key, false)
+	}
+	if exists, err := redis.Bool(conn.Do("EXISTS", "unrelated")); err != nil || !exists {
+		t.Errorf("expected keys outside the cache prefix to survive Clear, exists=%v err=%v", exists, err)
+	}
+}
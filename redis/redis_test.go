@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"testing"
 
 	"github.com/gomodule/redigo/redis"
@@ -17,3 +18,24 @@ func TestRedisCache(t *testing.T) {
 
 	test.Cache(t, NewWithClient(conn))
 }
+
+func TestRedisCacheReportsErrors(t *testing.T) {
+	c := cache{pool: &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", "127.0.0.1:1")
+		},
+	}}
+
+	if _, ok, err := c.GetWithError("key"); ok || err == nil {
+		t.Fatalf("expected Get error from unreachable server, got ok=%v err=%v", ok, err)
+	}
+	if err := c.SetWithError("key", []byte("value")); err == nil {
+		t.Fatal("expected Set error from unreachable server")
+	}
+	if err := c.DeleteWithError("key"); err == nil {
+		t.Fatal("expected Delete error from unreachable server")
+	}
+	if err := c.ClearWithContext(context.Background()); err == nil {
+		t.Fatal("expected Clear error from unreachable server")
+	}
+}
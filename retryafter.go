@@ -0,0 +1,118 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const headerRetryAfter = "Retry-After"
+
+// retryAfterTracker remembers, per cache key, the Retry-After window announced by
+// the origin. The zero value is ready to use.
+type retryAfterTracker struct {
+	mu      sync.Mutex
+	windows map[string]retryAfterWindow
+}
+
+// retryAfterWindow is a Retry-After delay and the response that announced it
+type retryAfterWindow struct {
+	recorded   time.Time
+	delay      time.Duration
+	statusCode int
+}
+
+// active reports whether the window is still open, and the time left
+func (w retryAfterWindow) active() (time.Duration, bool) {
+	remaining := w.delay - clock.since(w.recorded)
+	return remaining, remaining > 0
+}
+
+// recordRetryAfter starts a Retry-After window for key when HonorRetryAfter is
+// set and resp is a 503 or 429 with a valid Retry-After header
+func (t *Transport) recordRetryAfter(key string, resp *http.Response) {
+	if !t.HonorRetryAfter || resp == nil {
+		return
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable && resp.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+	delay, ok := parseRetryAfter(resp.Header.Get(headerRetryAfter))
+	if !ok {
+		return
+	}
+
+	tracker := &t.retryAfter
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if tracker.windows == nil {
+		tracker.windows = make(map[string]retryAfterWindow)
+	}
+	// Drop closed windows so keys that are never requested again don't pile up
+	for k, w := range tracker.windows {
+		if _, open := w.active(); !open {
+			delete(tracker.windows, k)
+		}
+	}
+	tracker.windows[key] = retryAfterWindow{recorded: time.Now(), delay: delay, statusCode: resp.StatusCode}
+	GetLogger().Debug("origin asked to retry later", "key", key, "retry_after", delay)
+}
+
+// retryAfterResponse returns a response replaying the origin's 503 or 429 when
+// key is within a Retry-After window, so the origin isn't contacted, or nil
+func (t *Transport) retryAfterResponse(req *http.Request, key string) *http.Response {
+	if !t.HonorRetryAfter {
+		return nil
+	}
+
+	tracker := &t.retryAfter
+	tracker.mu.Lock()
+	w, ok := tracker.windows[key]
+	remaining, open := w.active()
+	if ok && !open {
+		delete(tracker.windows, key)
+	}
+	tracker.mu.Unlock()
+	if !ok || !open {
+		return nil
+	}
+
+	seconds := int64((remaining + time.Second - 1) / time.Second)
+	return &http.Response{
+		Status:        strconv.Itoa(w.statusCode) + " " + http.StatusText(w.statusCode),
+		StatusCode:    w.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{headerRetryAfter: {strconv.FormatInt(seconds, 10)}},
+		Body:          http.NoBody,
+		ContentLength: 0,
+		Request:       req,
+	}
+}
+
+// parseRetryAfter parses a Retry-After value, either delay-seconds or an
+// HTTP-date (RFC 9110 Section 10.2.3), into the delay from now
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds <= 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := parseHTTPDate(value)
+	if err != nil {
+		return 0, false
+	}
+	delay := time.Until(date)
+	if delay <= 0 {
+		return 0, false
+	}
+	return delay, true
+}
@@ -0,0 +1,148 @@
+package httpcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// RevalidationOutcome is the result of revalidating a single cached entry
+type RevalidationOutcome int
+
+const (
+	// RevalidationNotModified means the origin answered 304 and the stored
+	// entry's headers were refreshed
+	RevalidationNotModified RevalidationOutcome = iota
+	// RevalidationUpdated means the origin sent a new response, which replaced
+	// the stored entry
+	RevalidationUpdated
+	// RevalidationNotCached means there was no stored entry to revalidate, so
+	// no request was sent
+	RevalidationNotCached
+	// RevalidationFailed means the request failed or the origin answered with
+	// a status that doesn't update the entry; Err or StatusCode tells why
+	RevalidationFailed
+)
+
+// String returns the name of the outcome
+func (o RevalidationOutcome) String() string {
+	switch o {
+	case RevalidationNotModified:
+		return "not-modified"
+	case RevalidationUpdated:
+		return "updated"
+	case RevalidationNotCached:
+		return "not-cached"
+	case RevalidationFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// RevalidationResult reports the outcome of revalidating one request
+type RevalidationResult struct {
+	// Request is the request as passed to RevalidateBatch
+	Request *http.Request
+	// Outcome is what happened to the stored entry
+	Outcome RevalidationOutcome
+	// StatusCode is the origin status code, or 0 if no response was received
+	StatusCode int
+	// Err is set when the request could not be sent or completed
+	Err error
+}
+
+// RevalidateBatch revalidates the stored entries for reqs with the origin,
+// running at most concurrency requests at a time. Unlike a normal request, the
+// origin is always contacted, using the stored validators (If-None-Match,
+// If-Modified-Since) so unchanged entries cost a 304: their headers are then
+// refreshed, while changed entries are replaced by the new response. Entries
+// without validators are refetched in full.
+//
+// Results are returned in the order of reqs. Only GET requests can be
+// revalidated. The returned error is non-nil only if ctx is done before all
+// requests were sent; results for the remaining requests report it as Err.
+func (t *Transport) RevalidateBatch(ctx context.Context, reqs []*http.Request, concurrency int) ([]RevalidationResult, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]RevalidationResult, len(reqs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, req := range reqs {
+		results[i].Request = req
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(reqs); j++ {
+				results[j] = RevalidationResult{Request: reqs[j], Outcome: RevalidationFailed, Err: ctx.Err()}
+			}
+			wg.Wait()
+			return results, ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(i int, req *http.Request) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = t.revalidate(req.WithContext(ctx))
+			results[i].Request = req
+		}(i, req)
+	}
+
+	wg.Wait()
+	return results, nil
+}
+
+// revalidate sends a conditional request for the stored entry of req and
+// updates the cache with the origin's answer
+func (t *Transport) revalidate(req *http.Request) RevalidationResult {
+	if req.Method != methodGET {
+		return RevalidationResult{Outcome: RevalidationFailed, Err: fmt.Errorf("cannot revalidate %s requests", req.Method)}
+	}
+
+	cachedResp, cacheKey, err := t.lookupCachedResponse(req, t.requestCacheKey(req))
+	if err != nil || cachedResp == nil || !varyMatches(cachedResp, req) {
+		return RevalidationResult{Outcome: RevalidationNotCached}
+	}
+
+	storedVary := headerAllCommaSepValues(cachedResp.Header, "vary")
+	transport := t.upstreamTransport(true)
+
+	resp, err := performRequest(transport, addValidatorsToRequest(req, cachedResp), false)
+	if err == nil && resp.StatusCode == http.StatusNotModified && !notModifiedMatches(cachedResp, resp) {
+		// The 304 refers to another representation: fetch the full response
+		_ = drainDiscardedBody(resp.Body)
+		resp, err = performRequest(transport, req, false)
+	}
+	if err != nil {
+		return RevalidationResult{Outcome: RevalidationFailed, Err: err}
+	}
+	t.recordRetryAfter(cacheKey, resp)
+	result := RevalidationResult{StatusCode: resp.StatusCode}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		_ = drainDiscardedBody(resp.Body)
+		resp = handleNotModifiedResponse(cachedResp, resp, false, false)
+		result.Outcome = RevalidationNotModified
+	case resp.StatusCode == http.StatusOK:
+		result.Outcome = RevalidationUpdated
+	default:
+		_ = drainDiscardedBody(resp.Body)
+		result.Outcome = RevalidationFailed
+		return result
+	}
+
+	// Reading the body to the end completes the cache write
+	t.storeResponseInCache(resp, req, cacheKey, true, storedVary)
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		result.Outcome, result.Err = RevalidationFailed, err
+	}
+	_ = resp.Body.Close()
+	return result
+}
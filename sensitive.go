@@ -0,0 +1,80 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// sensitiveKeyHeaders are the request headers whose values are hashed before
+// they enter a cache key, so credentials never appear in keys, which backends
+// and the Transport log
+var sensitiveKeyHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// keyHeaderValue returns the form of a request header value used in cache keys:
+// the value itself, or its SHA-256 hash for sensitiveKeyHeaders. The canonical
+// header name is expected. Distinct values still give distinct keys.
+func keyHeaderValue(canonicalHeader, value string) string {
+	if value == "" || !sensitiveKeyHeaders[canonicalHeader] {
+		return value
+	}
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// sensitiveTag is the stored form of the XCacheSensitive header line
+var sensitiveTag = []byte("\r\n" + XCacheSensitive + ": 1\r\n")
+
+// storeSensitiveTag tags resp with XCacheSensitive when it holds user-specific
+// data, so the tag is persisted with the entry
+func storeSensitiveTag(resp *http.Response, req *http.Request) {
+	if _, private := parseCacheControl(resp.Header)[cacheControlPrivate]; private || req.Header.Get("Authorization") != "" {
+		resp.Header.Set(XCacheSensitive, "1")
+	}
+}
+
+// IsSensitiveEntry reports whether value is a stored response tagged with
+// XCacheSensitive by the Transport. Only the header section is inspected, so it
+// is cheap enough to call on every Set.
+func IsSensitiveEntry(value []byte) bool {
+	return hasStoredTag(value, sensitiveTag)
+}
+
+// plaintextTag is the stored form of the XCachePlaintext header line
+var plaintextTag = []byte("\r\n" + XCachePlaintext + ": 1\r\n")
+
+// storePlaintextTag tags resp with XCachePlaintext when ShouldEncrypt exempts
+// it from encryption, clearing any tag carried over from a revalidated entry.
+// Sensitive entries are never exempted.
+func (t *Transport) storePlaintextTag(resp *http.Response, req *http.Request) {
+	resp.Header.Del(XCachePlaintext)
+	if t.ShouldEncrypt == nil || resp.Header.Get(XCacheSensitive) != "" {
+		return
+	}
+	if !t.ShouldEncrypt(req, resp) {
+		resp.Header.Set(XCachePlaintext, "1")
+	}
+}
+
+// IsPlaintextEntry reports whether value is a stored response tagged with
+// XCachePlaintext by the Transport, i.e. one that Transport.ShouldEncrypt
+// exempted from encryption. Like IsSensitiveEntry, only the header section is
+// inspected.
+func IsPlaintextEntry(value []byte) bool {
+	return hasStoredTag(value, plaintextTag)
+}
+
+// hasStoredTag reports whether the header section of the stored response value
+// contains tag
+func hasStoredTag(value, tag []byte) bool {
+	header := value
+	if end := bytes.Index(value, []byte("\r\n\r\n")); end >= 0 {
+		header = value[:end+2]
+	}
+	return bytes.Contains(header, tag)
+}
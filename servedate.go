@@ -0,0 +1,32 @@
+package httpcache
+
+import (
+	"net/http"
+	"time"
+)
+
+// rewriteServedDate sets the Date of resp, a response served from cache, to the
+// current time minus its age, if RewriteDateOnServe is enabled, so Date and Age
+// agree. Callers only pass responses served from cache.
+func (t *Transport) rewriteServedDate(resp *http.Response) {
+	if !t.RewriteDateOnServe {
+		return
+	}
+
+	var age time.Duration
+	if t.DisableAgeHeader {
+		// The Age header, if any, is the one stored from the origin
+		var err error
+		if age, err = calculateAge(resp.Header); err != nil {
+			return
+		}
+		age = age.Truncate(time.Second)
+	} else {
+		var valid bool
+		if age, valid = parseAgeHeader(resp.Header); !valid {
+			return
+		}
+	}
+
+	resp.Header.Set(headerDate, time.Now().Add(-age).UTC().Format(http.TimeFormat))
+}
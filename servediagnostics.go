@@ -0,0 +1,88 @@
+package httpcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// keyHashPrefixLen is the number of hex digits of the cache key hash reported
+// in the X-Cache-Key-Hash header
+const keyHashPrefixLen = 16
+
+// Named is an optional interface implemented by caches that report their
+// identity, e.g. "redis", in the X-Cache-Backend header added with
+// Transport.ServeDiagnostics.
+type Named interface {
+	Name() string
+}
+
+// serveSourceKey is the context key of the serveSource recorded by a lookup
+type serveSourceKey struct{}
+
+// serveSource records the tier of a tiered cache that served a lookup
+type serveSource struct {
+	mu       sync.Mutex
+	tier     int
+	backend  string
+	reported bool
+}
+
+// withServeSource returns a copy of req whose context records the tier that
+// serves the lookup made with it, and the record
+func withServeSource(req *http.Request) (*http.Request, *serveSource) {
+	source := &serveSource{}
+	return req.WithContext(context.WithValue(req.Context(), serveSourceKey{}, source)), source
+}
+
+// ReportServingTier is called by tiered caches, such as multicache.MultiCache,
+// when a read made with ctx is served by the tier at index tier, backed by
+// backend. With Transport.ServeDiagnostics, the tier and the name of backend,
+// if it implements Named, are added to the served response. It does nothing if
+// ctx doesn't come from a Transport lookup with ServeDiagnostics.
+func ReportServingTier(ctx context.Context, tier int, backend Cache) {
+	source, ok := ctx.Value(serveSourceKey{}).(*serveSource)
+	if !ok {
+		return
+	}
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	source.tier, source.backend, source.reported = tier, cacheName(backend), true
+}
+
+// cacheName returns the name of c if it implements Named, "" otherwise
+func cacheName(c Cache) string {
+	if named, ok := c.(Named); ok {
+		return named.Name()
+	}
+	return ""
+}
+
+// addServeDiagnostics adds the X-Cache-Backend, X-Cache-Tier and X-Cache-Key-Hash
+// headers to a response served from cache under key, if ServeDiagnostics is set.
+// source holds the tier reported by a tiered cache during the lookup, if any;
+// otherwise the backend is the Cache itself.
+func (t *Transport) addServeDiagnostics(resp *http.Response, source *serveSource, key string) {
+	if !t.ServeDiagnostics {
+		return
+	}
+
+	backend := cacheName(t.Cache)
+	if source != nil {
+		source.mu.Lock()
+		if source.reported {
+			backend = source.backend
+			resp.Header.Set(XCacheTier, strconv.Itoa(source.tier))
+		}
+		source.mu.Unlock()
+	}
+	if backend != "" {
+		resp.Header.Set(XCacheBackend, backend)
+	}
+
+	hash := sha256.Sum256([]byte(key))
+	resp.Header.Set(XCacheKeyHash, hex.EncodeToString(hash[:])[:keyHashPrefixLen])
+}
@@ -0,0 +1,172 @@
+package httpcache
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// snapshotMagic identifies the snapshot format and its version
+const snapshotMagic = "httpcache-snapshot-v1\n"
+
+// maxSnapshotFieldLen bounds a single key or value read from a snapshot, so a
+// corrupted length prefix can't trigger a huge allocation
+const maxSnapshotFieldLen = 1 << 30
+
+// ErrInvalidSnapshot is returned when restoring data that is not a valid snapshot.
+var ErrInvalidSnapshot = errors.New("invalid cache snapshot")
+
+// iterateConsistently calls fn for each entry of cache, iterating a Snapshot
+// when cache implements SnapshotCache and falling back to Iterable. It returns
+// ErrCacheNotIterable if cache implements neither.
+func iterateConsistently(ctx context.Context, cache Cache, fn func(key string, value []byte) bool) error {
+	if sc, ok := cache.(SnapshotCache); ok {
+		snapshot, err := sc.Snapshot()
+		if err != nil {
+			return err
+		}
+		defer snapshot.Release()
+		return snapshot.Iterate(ctx, fn)
+	}
+	iterable, ok := cache.(Iterable)
+	if !ok {
+		return ErrCacheNotIterable
+	}
+	return iterable.Iterate(ctx, fn)
+}
+
+// WriteSnapshot writes every entry of cache to w, so it can later be restored
+// with ReadSnapshot. Keys and values are written verbatim: snapshotting the
+// backend beneath a securecache wrapper preserves its hashed keys and encrypted
+// values. Caches implementing SnapshotCache are written from a consistent
+// Snapshot.
+func WriteSnapshot(ctx context.Context, cache Iterable, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(snapshotMagic); err != nil {
+		return err
+	}
+
+	var writeErr error
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	writeField := func(b []byte) {
+		n := binary.PutUvarint(lenBuf, uint64(len(b)))
+		if _, writeErr = bw.Write(lenBuf[:n]); writeErr == nil {
+			_, writeErr = bw.Write(b)
+		}
+	}
+
+	iterate := cache.Iterate
+	if sc, ok := cache.(SnapshotCache); ok {
+		snapshot, err := sc.Snapshot()
+		if err != nil {
+			return err
+		}
+		defer snapshot.Release()
+		iterate = snapshot.Iterate
+	}
+	err := iterate(ctx, func(key string, value []byte) bool {
+		writeField([]byte(key))
+		if writeErr == nil {
+			writeField(value)
+		}
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	return bw.Flush()
+}
+
+// ReadSnapshot restores the entries written by WriteSnapshot into cache.
+// Existing entries with the same keys are overwritten; other entries are kept.
+func ReadSnapshot(r io.Reader, cache Cache) error {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != snapshotMagic {
+		return ErrInvalidSnapshot
+	}
+
+	for {
+		key, err := readSnapshotField(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		value, err := readSnapshotField(br)
+		if err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("%w: truncated entry", ErrInvalidSnapshot)
+			}
+			return err
+		}
+		cache.Set(string(key), value)
+	}
+}
+
+// readSnapshotField reads a single length-prefixed field. It returns io.EOF only
+// when no bytes of the field could be read.
+func readSnapshotField(br *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if n > maxSnapshotFieldLen {
+		return nil, fmt.Errorf("%w: field length %d too large", ErrInvalidSnapshot, n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(br, b); err != nil {
+		return nil, fmt.Errorf("%w: truncated entry", ErrInvalidSnapshot)
+	}
+	return b, nil
+}
+
+// SaveSnapshotFile writes a snapshot of cache to the file at path. The file is
+// written to a temporary file in the same directory and renamed into place, so
+// an interrupted save never leaves a partial snapshot behind.
+func SaveSnapshotFile(ctx context.Context, cache Iterable, path string) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = WriteSnapshot(ctx, cache, tmp); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshotFile restores a snapshot written by SaveSnapshotFile into cache
+func LoadSnapshotFile(path string, cache Cache) error {
+	f, err := os.Open(path) // #nosec G304 -- path is provided by the application
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return ReadSnapshot(f, cache)
+}
@@ -0,0 +1,51 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// hasStaleIfError reports whether the Cache-Control of header carries a
+// stale-if-error directive
+func hasStaleIfError(header http.Header) bool {
+	_, ok := parseCacheControl(header)["stale-if-error"]
+	return ok
+}
+
+// staleIfErrorWindow returns the configured stale-if-error window for req: the
+// StaleIfErrorHosts entry of its host if any, StaleIfError otherwise
+func (t *Transport) staleIfErrorWindow(req *http.Request) time.Duration {
+	if len(t.StaleIfErrorHosts) > 0 {
+		host := strings.ToLower(req.URL.Hostname())
+		if window, ok := t.StaleIfErrorHosts[host]; ok {
+			return window
+		}
+	}
+	return t.StaleIfError
+}
+
+// canStaleOnErrorByDefault reports whether cachedResp, which carries no
+// stale-if-error directive, may be served on error under the configured window:
+// it must be stale by less than the window, and the origin must not have asked
+// for revalidation once stale (no-cache, must-revalidate, proxy-revalidate)
+func (t *Transport) canStaleOnErrorByDefault(cachedResp *http.Response, req *http.Request) bool {
+	window := t.staleIfErrorWindow(req)
+	if window <= 0 {
+		return false
+	}
+
+	respCacheControl := parseCacheControl(cachedResp.Header)
+	for _, directive := range []string{cacheControlNoCache, cacheControlMustRevalidate, "proxy-revalidate"} {
+		if _, ok := respCacheControl[directive]; ok {
+			return false
+		}
+	}
+
+	date, err := Date(cachedResp.Header)
+	if err != nil {
+		return false
+	}
+	lifetime := calculateLifetime(respCacheControl, cachedResp.Header, date)
+	return currentAge(cachedResp.Header, date) < lifetime+window
+}
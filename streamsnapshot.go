@@ -0,0 +1,149 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StreamSnapshot configures caching of the leading snapshot of a streaming
+// response, such as a Server-Sent Events endpoint that emits the current state
+// before streaming updates. Set it on Transport.StreamSnapshot.
+//
+// The live response keeps streaming to the caller unchanged; once the boundary
+// is reached, the bytes read so far are stored as a complete response with a
+// Content-Length, and later requests are served that snapshot instead of the
+// stream. The snapshot is only stored if the caller reads up to the boundary.
+type StreamSnapshot struct {
+	// Match selects the responses whose snapshot is cached.
+	// If nil, responses with Content-Type text/event-stream are matched.
+	Match func(*http.Response) bool
+
+	// Delimiter ends the snapshot after its first occurrence, which is included
+	// in the snapshot, e.g. []byte("\n\n") for the first SSE event.
+	Delimiter []byte
+
+	// MaxBytes bounds the snapshot. With a Delimiter, nothing is cached if the
+	// delimiter doesn't occur within MaxBytes; without one, the snapshot is the
+	// first MaxBytes bytes. Zero means no bound, and requires a Delimiter.
+	MaxBytes int
+
+	// Lifetime, if positive, replaces the freshness lifetime of the stored
+	// snapshot, since streaming endpoints rarely send one (see WithRequestTTL).
+	Lifetime time.Duration
+}
+
+// matches reports whether the snapshot of resp should be cached
+func (s *StreamSnapshot) matches(resp *http.Response) bool {
+	if s.Match != nil {
+		return s.Match(resp)
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// boundary returns the length of the snapshot within buf, or -1 if the boundary
+// hasn't been reached yet
+func (s *StreamSnapshot) boundary(buf []byte) int {
+	if len(s.Delimiter) > 0 {
+		if i := bytes.Index(buf, s.Delimiter); i >= 0 && (s.MaxBytes <= 0 || i+len(s.Delimiter) <= s.MaxBytes) {
+			return i + len(s.Delimiter)
+		}
+		return -1
+	}
+	if s.MaxBytes > 0 && len(buf) >= s.MaxBytes {
+		return s.MaxBytes
+	}
+	return -1
+}
+
+// exceeded reports whether buf has grown past MaxBytes without reaching the
+// boundary, so no snapshot will be cached
+func (s *StreamSnapshot) exceeded(buf []byte) bool {
+	return s.MaxBytes > 0 && len(buf) >= s.MaxBytes
+}
+
+// usesStreamSnapshot reports whether resp is handled by StreamSnapshot
+func (t *Transport) usesStreamSnapshot(resp *http.Response) bool {
+	return t.StreamSnapshot != nil && (len(t.StreamSnapshot.Delimiter) > 0 || t.StreamSnapshot.MaxBytes > 0) &&
+		t.StreamSnapshot.matches(resp)
+}
+
+// setupSnapshotBody wraps the response body to store its snapshot under
+// cacheKeys once the boundary is read. Like setupCachingBody, the headers are
+// snapshotted now.
+func (t *Transport) setupSnapshotBody(resp *http.Response, req *http.Request, cacheKeys []string) {
+	header := t.storedHeader(resp.Header)
+	resp.Body = &snapshotReadCloser{
+		R:        resp.Body,
+		snapshot: t.StreamSnapshot,
+		store: func(body []byte) {
+			stored := *resp
+			stored.Header = header
+			stored.Header.Del("Content-Length")
+			stored.TransferEncoding = nil
+			stored.ContentLength = int64(len(body))
+			stored.Body = io.NopCloser(bytes.NewReader(body))
+			stored.Header.Set(XCachedTime, stored.Header.Get(XResponseTime))
+			if lifetime := t.StreamSnapshot.Lifetime; lifetime > 0 {
+				stored.Header.Set(XCacheLifetime, strconv.FormatInt(int64(lifetime/time.Second), 10))
+			}
+			respBytes, err := dumpStoredResponse(&stored)
+			if err != nil {
+				GetLogger().Warn("failed to store stream snapshot", "error", err)
+				return
+			}
+			for _, key := range cacheKeys {
+				t.cacheSet(key, req, stored.Header, respBytes)
+			}
+			GetLogger().Debug("stored stream snapshot", "key", cacheKeys[0], "size", len(body))
+		},
+	}
+}
+
+// snapshotReadCloser passes the stream through while buffering it until the
+// snapshot boundary, then stores the snapshot once
+type snapshotReadCloser struct {
+	R        io.ReadCloser
+	snapshot *StreamSnapshot
+	store    func([]byte)
+
+	buf  bytes.Buffer
+	done bool
+}
+
+// Read reads from the stream, storing the snapshot when the boundary is reached.
+// A stream ending within MaxBytes before the boundary is stored whole, as it is
+// complete.
+func (r *snapshotReadCloser) Read(p []byte) (n int, err error) {
+	n, err = r.R.Read(p)
+	if r.done {
+		return n, err
+	}
+	r.buf.Write(p[:n])
+
+	switch end := r.snapshot.boundary(r.buf.Bytes()); {
+	case end >= 0:
+		r.finish(r.buf.Bytes()[:end])
+	case r.snapshot.exceeded(r.buf.Bytes()) || (err != nil && err != io.EOF):
+		r.done = true
+		r.buf = bytes.Buffer{}
+	case err == io.EOF:
+		r.finish(r.buf.Bytes())
+	}
+	return n, err
+}
+
+// finish stores body as the snapshot and stops buffering
+func (r *snapshotReadCloser) finish(body []byte) {
+	r.done = true
+	r.store(append([]byte(nil), body...))
+	r.buf = bytes.Buffer{}
+}
+
+func (r *snapshotReadCloser) Close() error {
+	return r.R.Close()
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"testing"
 
 	"github.com/sandrolain/httpcache"
@@ -33,3 +34,30 @@ func Cache(t *testing.T, cache httpcache.Cache) {
 		t.Fatal("deleted key still present")
 	}
 }
+
+// Clear excercises the httpcache.Clearer implementation of a httpcache.Cache.
+func Clear(t *testing.T, cache httpcache.Cache) {
+	clearer, ok := cache.(httpcache.Clearer)
+	if !ok {
+		t.Fatal("cache does not implement httpcache.Clearer")
+	}
+
+	keys := []string{"clearKey1", "clearKey2", "clearKey3"}
+	for _, key := range keys {
+		cache.Set(key, []byte("some bytes"))
+	}
+
+	if err := clearer.ClearWithContext(context.Background()); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	for _, key := range keys {
+		if _, ok := cache.Get(key); ok {
+			t.Fatalf("key %q still present after Clear", key)
+		}
+	}
+
+	cache.Set(keys[0], []byte("some bytes"))
+	if _, ok := cache.Get(keys[0]); !ok {
+		t.Fatal("could not retrieve an element added after Clear")
+	}
+}
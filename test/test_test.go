@@ -10,3 +10,7 @@ import (
 func TestMemoryCache(t *testing.T) {
 	test.Cache(t, httpcache.NewMemoryCache())
 }
+
+func TestMemoryCacheClear(t *testing.T) {
+	test.Clear(t, httpcache.NewMemoryCache())
+}
@@ -0,0 +1,71 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// isTextContentType reports whether contentType names a textual media type
+// BodyTransformer may rewrite: text/*, JSON, XML and JavaScript
+func isTextContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	_, subtype, _ := strings.Cut(mediaType, "/")
+	switch {
+	case subtype == "json", strings.HasSuffix(subtype, "+json"),
+		subtype == "xml", strings.HasSuffix(subtype, "+xml"),
+		subtype == "javascript", subtype == "x-javascript":
+		return true
+	}
+	return false
+}
+
+// transformsBody reports whether BodyTransformer applies to resp: encoded
+// bodies are skipped, since the transformer would see compressed bytes
+func (t *Transport) transformsBody(resp *http.Response) bool {
+	if t.BodyTransformer == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return false
+	}
+	if ce := resp.Header.Get("Content-Encoding"); ce != "" && !strings.EqualFold(ce, "identity") {
+		return false
+	}
+	return isTextContentType(resp.Header.Get("Content-Type"))
+}
+
+// transformStoredBody applies BodyTransformer to the body of resp, the copy
+// about to be stored, and recomputes its Content-Length. On error the body is
+// left as it was.
+func (t *Transport) transformStoredBody(resp *http.Response) {
+	if !t.transformsBody(resp) {
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return
+	}
+	contentType := resp.Header.Get("Content-Type")
+	transformed, err := t.BodyTransformer(contentType, body)
+	if err != nil {
+		GetLogger().Warn("body transformer failed, storing the original body",
+			"content_type", contentType, "error", err)
+		transformed = body
+	}
+	// The digest describes the original body
+	if !bytes.Equal(transformed, body) {
+		resp.Header.Del(headerContentDigest)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(transformed))
+	resp.ContentLength = int64(len(transformed))
+	resp.TransferEncoding = nil
+	resp.Header.Set("Content-Length", strconv.Itoa(len(transformed)))
+}
@@ -0,0 +1,121 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// requestTTLKey is the context key for the lifetime set by WithRequestTTL
+type requestTTLKey struct{}
+
+// WithRequestTTL returns a copy of ctx that overrides the freshness lifetime of
+// the response to a request made with it. When the response is stored, it stays
+// fresh for ttl from its Date instead of the lifetime computed from max-age or
+// Expires. The override is stored with the entry, so later requests for the same
+// URL see the same lifetime until the entry is replaced by a new response.
+//
+// The override only changes the lifetime: responses that can't be stored (e.g.
+// no-store) are still not cached, and no-cache responses are still revalidated.
+// Negative values are ignored.
+//
+// Example:
+//
+//	ctx := httpcache.WithRequestTTL(context.Background(), time.Hour)
+//	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+//	resp, err := client.Do(req)
+func WithRequestTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, requestTTLKey{}, ttl)
+}
+
+// requestTTL returns the lifetime set on the request context by WithRequestTTL
+func requestTTL(req *http.Request) (time.Duration, bool) {
+	ttl, ok := req.Context().Value(requestTTLKey{}).(time.Duration)
+	if !ok || ttl < 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// storeLifetimeOverride records the lifetime requested via WithRequestTTL in the
+// response headers, so it is persisted with the entry
+func storeLifetimeOverride(resp *http.Response, req *http.Request) {
+	if ttl, ok := requestTTL(req); ok {
+		resp.Header.Set(XCacheLifetime, strconv.FormatInt(int64(ttl/time.Second), 10))
+	}
+}
+
+// storeStatusTTL records the StatusTTLOverrides lifetime for the status of resp
+// as its lifetime, unless another override is set
+func (t *Transport) storeStatusTTL(resp *http.Response) {
+	ttl, ok := t.StatusTTLOverrides[resp.StatusCode]
+	if !ok || ttl < 0 || resp.Header.Get(XCacheLifetime) != "" {
+		return
+	}
+	resp.Header.Set(XCacheLifetime, strconv.FormatInt(int64(ttl/time.Second), 10))
+}
+
+// storeSoftHardTTL records SoftTTL as the lifetime of resp, unless another
+// override is set, and HardTTL, raised to SoftTTL if shorter, as its hard TTL
+func (t *Transport) storeSoftHardTTL(resp *http.Response, respCacheControl cacheControl) {
+	if t.SoftTTL > 0 && resp.Header.Get(XCacheLifetime) == "" {
+		resp.Header.Set(XCacheLifetime, strconv.FormatInt(int64(t.SoftTTL/time.Second), 10))
+	}
+	if t.HardTTL <= 0 {
+		return
+	}
+	for _, directive := range []string{cacheControlNoCache, cacheControlMustRevalidate, "proxy-revalidate"} {
+		if _, ok := respCacheControl[directive]; ok {
+			return
+		}
+	}
+	hardTTL := max(t.HardTTL, t.SoftTTL)
+	resp.Header.Set(XCacheHardTTL, strconv.FormatInt(int64(hardTTL/time.Second), 10))
+}
+
+// storedHardTTL returns the hard TTL stored by storeSoftHardTTL, if any
+func storedHardTTL(respHeaders http.Header) (time.Duration, bool) {
+	value := respHeaders.Get(XCacheHardTTL)
+	if value == "" {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// storeValidatorOnlyFreshness records ValidatorOnlyFreshness as the lifetime of
+// resp when it has a validator but no explicit freshness and no other override
+func (t *Transport) storeValidatorOnlyFreshness(resp *http.Response, respCacheControl cacheControl) {
+	if t.ValidatorOnlyFreshness <= 0 || resp.Header.Get(XCacheLifetime) != "" {
+		return
+	}
+	if resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return
+	}
+	for _, directive := range []string{cacheControlMaxAge, cacheControlSMaxAge, cacheControlNoCache} {
+		if _, ok := respCacheControl[directive]; ok {
+			return
+		}
+	}
+	if resp.Header.Get("Expires") != "" {
+		return
+	}
+	resp.Header.Set(XCacheLifetime, strconv.FormatInt(int64(t.ValidatorOnlyFreshness/time.Second), 10))
+}
+
+// lifetimeOverride returns the lifetime stored by storeLifetimeOverride, if any
+func lifetimeOverride(respHeaders http.Header) (time.Duration, bool) {
+	value := respHeaders.Get(XCacheLifetime)
+	if value == "" {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
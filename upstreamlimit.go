@@ -0,0 +1,81 @@
+package httpcache
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// ErrUpstreamLimit is returned when MaxInFlightUpstream is reached and
+// UpstreamFailFast is set.
+var ErrUpstreamLimit = errors.New("upstream request limit reached")
+
+// upstreamLimiter bounds the requests in flight to the origin, see
+// Transport.MaxInFlightUpstream
+type upstreamLimiter struct {
+	once sync.Once
+	sem  *semaphore.Weighted
+}
+
+// limitTransport holds a slot of sem for each request until its response body
+// is read to the end or closed
+type limitTransport struct {
+	next     http.RoundTripper
+	sem      *semaphore.Weighted
+	failFast bool
+}
+
+func (l limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if l.failFast {
+		if !l.sem.TryAcquire(1) {
+			GetLogger().Debug("upstream request rejected: in-flight limit reached", "url", req.URL.String())
+			return nil, ErrUpstreamLimit
+		}
+	} else if err := l.sem.Acquire(req.Context(), 1); err != nil {
+		return nil, err
+	}
+
+	resp, err := l.next.RoundTrip(req)
+	if err != nil || resp == nil || resp.Body == nil {
+		l.sem.Release(1)
+		return resp, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { l.sem.Release(1) }}
+	return resp, nil
+}
+
+// releasingBody calls release once, when the body is read to the end, fails
+// or is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// limitUpstream wraps transport with the MaxInFlightUpstream limit, if configured
+func (t *Transport) limitUpstream(transport http.RoundTripper) http.RoundTripper {
+	if t.MaxInFlightUpstream <= 0 {
+		return transport
+	}
+	t.upstreamLimit.once.Do(func() {
+		t.upstreamLimit.sem = semaphore.NewWeighted(int64(t.MaxInFlightUpstream))
+	})
+	return limitTransport{next: transport, sem: t.upstreamLimit.sem, failFast: t.UpstreamFailFast}
+}
@@ -0,0 +1,86 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+)
+
+const (
+	// urlMetadataKeyPrefix is prepended to the cache key to build the sidecar key
+	urlMetadataKeyPrefix = "httpcache-url-metadata:"
+	// urlMetadataMarker identifies sidecar values when iterating a backend whose
+	// keys may be hashed (and therefore no longer carry the prefix)
+	urlMetadataMarker = "httpcache-url-metadata\n"
+)
+
+// ErrCacheNotIterable is returned when an operation needs to enumerate the cache
+// but the configured backend does not implement Iterable.
+var ErrCacheNotIterable = errors.New("cache does not implement Iterable")
+
+// storeURLMetadata writes the request URL as a sidecar entry for cacheKey.
+// The sidecar value also carries the cache key so ListURLs can skip URLs whose
+// response has since been evicted or invalidated.
+func (t *Transport) storeURLMetadata(cacheKey string, req *http.Request) {
+	value := urlMetadataMarker + cacheKey + "\n" + req.URL.String()
+	t.Cache.Set(urlMetadataKeyPrefix+cacheKey, []byte(value))
+}
+
+// deleteEntry removes the entry stored under key and, with StoreURLMetadata,
+// its URL sidecar, so sidecars don't outlive their entry in the backend
+func (t *Transport) deleteEntry(key string) {
+	t.Cache.Delete(key)
+	if t.StoreURLMetadata {
+		t.Cache.Delete(urlMetadataKeyPrefix + key)
+	}
+}
+
+// parseURLMetadata extracts the cache key and URL from a sidecar value.
+func parseURLMetadata(value []byte) (cacheKey, rawURL string, ok bool) {
+	rest, found := bytes.CutPrefix(value, []byte(urlMetadataMarker))
+	if !found {
+		return "", "", false
+	}
+	key, u, found := bytes.Cut(rest, []byte("\n"))
+	if !found {
+		return "", "", false
+	}
+	return string(key), string(u), true
+}
+
+// ListURLs returns the sorted, de-duplicated list of URLs that currently have a
+// cached response. It only reports entries stored while StoreURLMetadata was enabled,
+// and requires the cache backend to implement Iterable or SnapshotCache
+// (ErrCacheNotIterable otherwise).
+//
+// This is a debugging aid for private caches: see the warning on StoreURLMetadata.
+func (t *Transport) ListURLs(ctx context.Context) ([]string, error) {
+	var keys, urls []string
+	err := iterateConsistently(ctx, t.Cache, func(_ string, value []byte) bool {
+		if key, u, ok := parseURLMetadata(value); ok {
+			keys = append(keys, key)
+			urls = append(urls, u)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Only report URLs whose response is still cached
+	seen := make(map[string]struct{})
+	result := make([]string, 0, len(urls))
+	for i, u := range urls {
+		if _, dup := seen[u]; dup {
+			continue
+		}
+		if _, ok := t.Cache.Get(keys[i]); ok {
+			seen[u] = struct{}{}
+			result = append(result, u)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Vacuum deletes the stored responses that have expired for good, so persistent
+// backends don't accumulate entries that will never be served again. It is
+// meant to be triggered on demand, e.g. from a cron job, and requires the
+// backend to implement Iterable or SnapshotCache (ErrCacheNotIterable
+// otherwise). A SnapshotCache is scanned from a consistent Snapshot.
+//
+// An entry has expired for good once its HardTTL has passed or, without one,
+// once it is past its freshness lifetime plus the stale-while-revalidate and
+// stale-if-error windows allowed by its directives, StaleIfError,
+// StaleIfErrorHosts and MaybeStaleWindow, and has no ETag or Last-Modified it
+// could be revalidated with. Entries ServeStaleMode could still serve are
+// removed too. Values that aren't stored responses are kept, except the
+// StoreURLMetadata sidecars of removed entries.
+//
+// Keys are deleted as passed by Iterate, so wrappers that transform keys, such
+// as securecache, can't be vacuumed through the Transport. Vacuum returns the
+// number of removed entries; it stops early with ctx.Err() when ctx is done.
+func (t *Transport) Vacuum(ctx context.Context) (removed int, err error) {
+	var expired []string
+	err = iterateConsistently(ctx, t.Cache, func(key string, value []byte) bool {
+		if resp, parseErr := readCachedResponse(value, nil); parseErr == nil {
+			_ = resp.Body.Close()
+			if t.expiredForGood(resp.Header) {
+				expired = append(expired, key)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range expired {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		t.deleteEntry(key)
+		removed++
+	}
+	if removed > 0 {
+		GetLogger().Debug("vacuumed expired cache entries", "removed", removed)
+	}
+	return removed, nil
+}
+
+// expiredForGood reports whether a stored response with header can no longer be
+// served, even stale, nor revalidated
+func (t *Transport) expiredForGood(header http.Header) bool {
+	date, err := Date(header)
+	if err != nil {
+		// Without a Date the entry is always stale: only validators make it useful
+		return !hasValidators(header)
+	}
+	age := currentAge(header, date)
+
+	if hardTTL, ok := storedHardTTL(header); ok {
+		return age >= hardTTL
+	}
+	if hasValidators(header) {
+		return false
+	}
+
+	respCacheControl := parseCacheControl(header)
+	lifetime := calculateLifetime(respCacheControl, header, date)
+	grace, unbounded := t.maxStaleGrace(respCacheControl)
+	return !unbounded && age >= lifetime+grace
+}
+
+// hasValidators reports whether header carries an ETag or Last-Modified
+func hasValidators(header http.Header) bool {
+	return header.Get(headerETag) != "" || header.Get(headerLastModified) != ""
+}
+
+// maxStaleGrace returns the longest window past its lifetime during which a
+// response with respCacheControl may still be served: its stale-while-revalidate
+// and stale-if-error directives, or the configured stale-if-error windows. A
+// must-revalidate response is never served stale. unbounded is true for a
+// stale-if-error directive without a value, which allows serving the response
+// on error whatever its age.
+func (t *Transport) maxStaleGrace(respCacheControl cacheControl) (grace time.Duration, unbounded bool) {
+	if _, mustRevalidate := respCacheControl[cacheControlMustRevalidate]; mustRevalidate {
+		return 0, false
+	}
+	if _, acceptAny, _ := parseStaleIfError(respCacheControl); acceptAny {
+		return 0, true
+	}
+	for _, directive := range []string{cacheControlStaleWhileRevalidate, "stale-if-error"} {
+		if value, ok := respCacheControl[directive]; ok {
+			if d, err := time.ParseDuration(value + "s"); err == nil && d > grace {
+				grace = d
+			}
+		}
+	}
+	if _, ok := respCacheControl["stale-if-error"]; !ok {
+		if t.StaleIfError > grace {
+			grace = t.StaleIfError
+		}
+		for _, window := range t.StaleIfErrorHosts {
+			if window > grace {
+				grace = window
+			}
+		}
+	}
+	if t.MaybeStaleWindow > grace {
+		grace = t.MaybeStaleWindow
+	}
+	return grace, false
+}
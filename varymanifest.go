@@ -0,0 +1,117 @@
+package httpcache
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// varyManifestKeyPrefix is prepended to the base cache key to build the key
+	// of the manifest listing its variants
+	varyManifestKeyPrefix = "httpcache-vary-manifest:"
+	// varyManifestMarker identifies manifest values, like urlMetadataMarker
+	varyManifestMarker = "httpcache-vary-manifest\n"
+)
+
+// variantRecord is a variant listed in a vary manifest, with when it was stored
+type variantRecord struct {
+	key    string
+	stored time.Time
+}
+
+// trackVariant records in the manifest of baseKey that the variant stored under
+// varyKey is being stored, and evicts the least recently stored variants beyond
+// MaxVaryVariants. It does nothing if MaxVaryVariants is not set, or with
+// DryRunStore.
+//
+// Manifest updates are serialized within a Transport only: Transports sharing a
+// backend may lose each other's updates, and then keep a few extra variants.
+func (t *Transport) trackVariant(baseKey, varyKey string) {
+	if t.MaxVaryVariants <= 0 || t.DryRunStore {
+		return
+	}
+
+	t.variantsMu.Lock()
+	defer t.variantsMu.Unlock()
+
+	manifestKey := varyManifestKeyPrefix + baseKey
+	var variants []variantRecord
+	if raw, ok := t.Cache.Get(manifestKey); ok {
+		variants = parseVaryManifest(raw)
+	}
+
+	// A variant stored again moves to the end of the manifest
+	kept := variants[:0]
+	for _, variant := range variants {
+		if variant.key != varyKey {
+			kept = append(kept, variant)
+		}
+	}
+	variants = append(kept, variantRecord{key: varyKey, stored: time.Now()})
+	sort.SliceStable(variants, func(i, j int) bool { return variants[i].stored.Before(variants[j].stored) })
+
+	if excess := len(variants) - t.MaxVaryVariants; excess > 0 {
+		for _, variant := range variants[:excess] {
+			t.deleteEntry(variant.key)
+		}
+		GetLogger().Debug("evicted oldest variants", "key", baseKey, "variants", excess)
+		variants = variants[excess:]
+	}
+
+	t.Cache.Set(manifestKey, formatVaryManifest(variants))
+}
+
+// deleteTrackedVariants removes the variants listed in the manifest of baseKey,
+// and the manifest itself
+func (t *Transport) deleteTrackedVariants(baseKey string) {
+	manifestKey := varyManifestKeyPrefix + baseKey
+	raw, ok := t.Cache.Get(manifestKey)
+	if !ok {
+		return
+	}
+	for _, variant := range parseVaryManifest(raw) {
+		t.deleteEntry(variant.key)
+	}
+	t.Cache.Delete(manifestKey)
+}
+
+// parseVaryManifest decodes a manifest value, skipping malformed lines; a value
+// that isn't a manifest yields no variants
+func parseVaryManifest(raw []byte) []variantRecord {
+	rest, found := bytes.CutPrefix(raw, []byte(varyManifestMarker))
+	if !found {
+		return nil
+	}
+	var variants []variantRecord
+	for _, line := range strings.Split(string(rest), "\n") {
+		stored, key, found := strings.Cut(line, " ")
+		if !found {
+			continue
+		}
+		nanos, err := strconv.ParseInt(stored, 10, 64)
+		if err != nil {
+			continue
+		}
+		variants = append(variants, variantRecord{key: key, stored: time.Unix(0, nanos)})
+	}
+	return variants
+}
+
+// formatVaryManifest encodes variants as a manifest value, one
+// "<stored unix nanoseconds> <key>" line per variant
+func formatVaryManifest(variants []variantRecord) []byte {
+	var b strings.Builder
+	b.WriteString(varyManifestMarker)
+	for i, variant := range variants {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(strconv.FormatInt(variant.stored.UnixNano(), 10))
+		b.WriteByte(' ')
+		b.WriteString(variant.key)
+	}
+	return []byte(b.String())
+}
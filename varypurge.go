@@ -0,0 +1,76 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// purgeVariants removes the variant entries left behind when the origin stops
+// varying a response stored under baseKey with EnableVarySeparation, given the
+// varyHeaders of the entry it replaces. Without this they would linger, and be
+// served again if the origin later re-added the same Vary header.
+//
+// The variant for the values of req and the variants tracked for
+// MaxVaryVariants are removed at once. The others can only be found by
+// enumerating the cache: when the backend is Iterable and stores keys as given
+// (a wrapper hashing keys hides them), they are removed by a scan running in
+// the background, so the request doesn't wait for it.
+func (t *Transport) purgeVariants(req *http.Request, baseKey string, varyHeaders []string) {
+	t.deleteEntry(t.variantCacheKey(req, varyHeaders))
+	t.deleteTrackedVariants(baseKey)
+
+	if _, ok := t.Cache.(Iterable); ok {
+		go t.deleteUntrackedVariants(t.requestCacheKey(req), baseKey)
+	}
+}
+
+// purgeStoredVariants removes the variants of the entry stored under baseKey
+// for req: those tracked for MaxVaryVariants, even if the entry itself is gone,
+// and, if it varies, the variant for the values of req and those found by
+// waiting for the scan of an Iterable backend
+func (t *Transport) purgeStoredVariants(req *http.Request, baseKey string) {
+	t.deleteTrackedVariants(baseKey)
+
+	raw, ok := t.Cache.Get(baseKey)
+	if !ok {
+		return
+	}
+	base, err := readCachedResponse(raw, req)
+	if err != nil {
+		return
+	}
+	_ = base.Body.Close()
+	varyHeaders := headerAllCommaSepValues(base.Header, "vary")
+	if len(varyHeaders) == 0 {
+		return
+	}
+
+	t.deleteEntry(t.variantCacheKey(req, varyHeaders))
+	t.deleteUntrackedVariants(t.requestCacheKey(req), baseKey)
+}
+
+// deleteUntrackedVariants removes the variants of requestKey, the key of a
+// request without its Vary values, found by enumerating an Iterable cache. It
+// does nothing for other backends.
+func (t *Transport) deleteUntrackedVariants(requestKey, baseKey string) {
+	iterable, ok := t.Cache.(Iterable)
+	if !ok {
+		return
+	}
+	prefix := requestKey + varyKeyMarker
+	var variants []string
+	err := iterable.Iterate(context.Background(), func(key string, _ []byte) bool {
+		if strings.HasPrefix(key, prefix) {
+			variants = append(variants, key)
+		}
+		return true
+	})
+	if err != nil {
+		GetLogger().Warn("failed to enumerate cached variants", "key", baseKey, "error", err)
+	}
+	for _, key := range variants {
+		t.deleteEntry(key)
+	}
+	GetLogger().Debug("purged variants of a response no longer varying", "key", baseKey, "variants", len(variants))
+}
@@ -0,0 +1,141 @@
+package httpcache
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultWindowStatsResolution is the bucket width used by NewWindowStats
+	// when no resolution is given
+	defaultWindowStatsResolution = 10 * time.Second
+	// defaultWindowStatsRetention is how far back NewWindowStats keeps counters
+	// when no retention is given
+	defaultWindowStatsRetention = time.Hour
+)
+
+// WindowStats computes cache efficiency over recent time windows: hit rate,
+// origin bytes saved and request rate, e.g. "over the last five minutes".
+//
+// Counters are kept in fixed-width time buckets covering the retention period,
+// so memory is constant and old activity ages out without any pruning. Windows
+// are rounded up to whole buckets and capped at the retention period.
+//
+// WindowStats is safe for concurrent use and can be shared between Transports.
+type WindowStats struct {
+	resolution time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	buckets []windowBucket
+}
+
+// windowBucket holds the counters of one resolution-wide time slot
+type windowBucket struct {
+	slot       int64
+	hits       int64
+	misses     int64
+	bytesSaved int64
+}
+
+// NewWindowStats returns a WindowStats with buckets resolution wide, keeping
+// counters for the last retention. If resolution is not positive, 10 seconds is
+// used; if retention is not positive, one hour is used.
+func NewWindowStats(resolution, retention time.Duration) *WindowStats {
+	if resolution <= 0 {
+		resolution = defaultWindowStatsResolution
+	}
+	if retention <= 0 {
+		retention = defaultWindowStatsRetention
+	}
+	count := int((retention + resolution - 1) / resolution)
+	return &WindowStats{
+		resolution: resolution,
+		now:        time.Now,
+		buckets:    make([]windowBucket, count),
+	}
+}
+
+// HitRate returns the fraction of requests served from cache within the last
+// window, between 0 and 1. It returns 0 when there were no requests.
+func (s *WindowStats) HitRate(window time.Duration) float64 {
+	total := s.sum(window)
+	if requests := total.hits + total.misses; requests > 0 {
+		return float64(total.hits) / float64(requests)
+	}
+	return 0
+}
+
+// BytesSaved returns the response body bytes served from cache within the last
+// window, i.e. the origin egress the cache saved.
+func (s *WindowStats) BytesSaved(window time.Duration) int64 {
+	return s.sum(window).bytesSaved
+}
+
+// RequestRate returns the cacheable requests per second within the last window,
+// hits and misses alike.
+func (s *WindowStats) RequestRate(window time.Duration) float64 {
+	span := s.span(window)
+	if span <= 0 {
+		return 0
+	}
+	total := s.sum(window)
+	return float64(total.hits+total.misses) / span.Seconds()
+}
+
+// recordRequest counts a request, as a hit if it was served from cache
+func (s *WindowStats) recordRequest(hit bool) {
+	s.mu.Lock()
+	b := s.bucketLocked(s.now())
+	if hit {
+		b.hits++
+	} else {
+		b.misses++
+	}
+	s.mu.Unlock()
+}
+
+// addBytesSaved counts n response body bytes served from cache
+func (s *WindowStats) addBytesSaved(n int64) {
+	s.mu.Lock()
+	s.bucketLocked(s.now()).bytesSaved += n
+	s.mu.Unlock()
+}
+
+// bucketLocked returns the bucket for now, resetting it if it still holds an
+// older slot. Must be called with s.mu held.
+func (s *WindowStats) bucketLocked(now time.Time) *windowBucket {
+	slot := now.UnixNano() / int64(s.resolution)
+	b := &s.buckets[slot%int64(len(s.buckets))]
+	if b.slot != slot {
+		*b = windowBucket{slot: slot}
+	}
+	return b
+}
+
+// span returns window rounded up to whole buckets and capped at the retention
+func (s *WindowStats) span(window time.Duration) time.Duration {
+	if window <= 0 {
+		return 0
+	}
+	count := min(int64((window+s.resolution-1)/s.resolution), int64(len(s.buckets)))
+	return time.Duration(count) * s.resolution
+}
+
+// sum adds up the buckets within the last window, including the current one
+func (s *WindowStats) sum(window time.Duration) windowBucket {
+	count := int64(s.span(window) / s.resolution)
+	var total windowBucket
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.now().UnixNano() / int64(s.resolution)
+	for i := range s.buckets {
+		b := &s.buckets[i]
+		if b.slot > current-count && b.slot <= current {
+			total.hits += b.hits
+			total.misses += b.misses
+			total.bytesSaved += b.bytesSaved
+		}
+	}
+	return total
+}
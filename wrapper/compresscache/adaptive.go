@@ -0,0 +1,165 @@
+package compresscache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/sandrolain/httpcache"
+)
+
+// DefaultDenseThreshold is the entry size in bytes from which the default
+// AdaptiveCompressConfig thresholds switch from Snappy to Brotli
+const DefaultDenseThreshold = 16 * 1024
+
+// SizeThreshold selects the compression algorithm for entries of at least
+// MinSize bytes
+type SizeThreshold struct {
+	MinSize   int
+	Algorithm Algorithm
+}
+
+// AdaptiveCache wraps a cache with compression, choosing the algorithm for
+// each entry by its size. The algorithm is recorded in the entry marker, so
+// entries are read back whatever algorithm they were stored with.
+type AdaptiveCache struct {
+	*baseCompressCache
+	thresholds  []SizeThreshold
+	compressors map[Algorithm]compressFunc
+}
+
+// AdaptiveCompressConfig holds the configuration for adaptive compression
+type AdaptiveCompressConfig struct {
+	// Cache is the underlying cache backend (required)
+	Cache httpcache.Cache
+
+	// Thresholds map entry sizes to algorithms: an entry is compressed with the
+	// algorithm of the threshold with the largest MinSize not above its size,
+	// and entries smaller than every MinSize are stored uncompressed. Order
+	// doesn't matter. Default: Snappy, fast, below DefaultDenseThreshold, and
+	// Brotli, dense, from there on.
+	Thresholds []SizeThreshold
+
+	// GzipLevel is the compression level for Gzip entries (-2 to 9)
+	// Default: gzip.DefaultCompression (-1)
+	GzipLevel int
+
+	// BrotliLevel is the compression level for Brotli entries (0 to 11)
+	// Default: 6
+	BrotliLevel int
+
+	// RefuseSensitive, if true, doesn't persist entries the Transport tagged as
+	// sensitive (see httpcache.IsSensitiveEntry); any existing entry for the key
+	// is removed instead
+	RefuseSensitive bool
+}
+
+// NewAdaptive creates a new AdaptiveCache choosing the compression algorithm
+// of each entry by its size
+func NewAdaptive(config AdaptiveCompressConfig) (*AdaptiveCache, error) {
+	if config.Cache == nil {
+		return nil, fmt.Errorf("cache cannot be nil")
+	}
+
+	// Set defaults
+	thresholds := append([]SizeThreshold(nil), config.Thresholds...)
+	if len(thresholds) == 0 {
+		thresholds = []SizeThreshold{
+			{MinSize: 0, Algorithm: Snappy},
+			{MinSize: DefaultDenseThreshold, Algorithm: Brotli},
+		}
+	}
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i].MinSize < thresholds[j].MinSize })
+
+	// Validate thresholds
+	for i, threshold := range thresholds {
+		if threshold.MinSize < 0 {
+			return nil, fmt.Errorf("threshold size cannot be negative: %d", threshold.MinSize)
+		}
+		if i > 0 && threshold.MinSize == thresholds[i-1].MinSize {
+			return nil, fmt.Errorf("duplicate threshold size: %d", threshold.MinSize)
+		}
+	}
+
+	gzipCache, err := NewGzip(GzipConfig{Cache: config.Cache, Level: config.GzipLevel})
+	if err != nil {
+		return nil, err
+	}
+	brotliCache, err := NewBrotli(BrotliConfig{Cache: config.Cache, Level: config.BrotliLevel})
+	if err != nil {
+		return nil, err
+	}
+	snappyCache := &SnappyCache{}
+	compressors := map[Algorithm]compressFunc{
+		Gzip:   gzipCache.compress,
+		Brotli: brotliCache.compress,
+		Snappy: snappyCache.compress,
+	}
+	for _, threshold := range thresholds {
+		if _, ok := compressors[threshold.Algorithm]; !ok {
+			return nil, fmt.Errorf("unsupported compression algorithm: %v", threshold.Algorithm)
+		}
+	}
+
+	base, err := newBaseCompressCache(config.Cache, thresholds[0].Algorithm, config.RefuseSensitive, thresholds[0].MinSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdaptiveCache{
+		baseCompressCache: base,
+		thresholds:        thresholds,
+		compressors:       compressors,
+	}, nil
+}
+
+// algorithmFor returns the algorithm for an entry of size bytes
+func (c *AdaptiveCache) algorithmFor(size int) Algorithm {
+	algorithm := c.thresholds[0].Algorithm
+	for _, threshold := range c.thresholds {
+		if size < threshold.MinSize {
+			break
+		}
+		algorithm = threshold.Algorithm
+	}
+	return algorithm
+}
+
+// decompress decompresses data stored with the algorithm of the first threshold
+func (c *AdaptiveCache) decompress(data []byte) ([]byte, error) {
+	return c.decompressAny(data, c.algorithm)
+}
+
+// Set compresses value with the algorithm for its size and stores it in the cache
+func (c *AdaptiveCache) Set(key string, value []byte) {
+	algorithm := c.algorithmFor(len(value))
+	c.setWithAlgorithm(key, value, algorithm, c.compressors[algorithm])
+}
+
+// Get retrieves and decompresses a value from the cache
+func (c *AdaptiveCache) Get(key string) ([]byte, bool) {
+	return c.get(key, c.decompress)
+}
+
+// GetStream retrieves a value from the cache as a reader that decompresses it
+// as it is read, avoiding a full decompressed copy of large entries.
+func (c *AdaptiveCache) GetStream(ctx context.Context, key string) (io.ReadCloser, bool, error) {
+	return c.getStream(ctx, key)
+}
+
+// Delete removes a value from the cache
+func (c *AdaptiveCache) Delete(key string) {
+	c.delete(key)
+}
+
+// ClearWithContext implements httpcache.Clearer when the underlying cache does, clearing
+// it. Returns httpcache.ErrClearNotSupported otherwise.
+func (c *AdaptiveCache) ClearWithContext(ctx context.Context) error {
+	return c.clear(ctx)
+}
+
+// Stats returns compression statistics
+func (c *AdaptiveCache) Stats() Stats {
+	return c.stats()
+}
@@ -2,6 +2,7 @@ package compresscache
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 
@@ -23,6 +24,16 @@ type BrotliConfig struct {
 	// Level is the compression level (0 to 11)
 	// Default: 6
 	Level int
+
+	// RefuseSensitive, if true, doesn't persist entries the Transport tagged as
+	// sensitive (see httpcache.IsSensitiveEntry); any existing entry for the key
+	// is removed instead
+	RefuseSensitive bool
+
+	// MinSizeToCompress is the size in bytes below which entries are stored
+	// uncompressed, since compressing tiny entries wastes CPU and can even
+	// enlarge them. Default: 0 (compress every entry)
+	MinSizeToCompress int
 }
 
 // NewBrotli creates a new BrotliCache with Brotli compression
@@ -41,8 +52,13 @@ func NewBrotli(config BrotliConfig) (*BrotliCache, error) {
 		return nil, fmt.Errorf("invalid brotli compression level: %d", config.Level)
 	}
 
+	base, err := newBaseCompressCache(config.Cache, Brotli, config.RefuseSensitive, config.MinSizeToCompress)
+	if err != nil {
+		return nil, err
+	}
+
 	return &BrotliCache{
-		baseCompressCache: newBaseCompressCache(config.Cache, Brotli),
+		baseCompressCache: base,
 		level:             config.Level,
 	}, nil
 }
@@ -84,11 +100,23 @@ func (c *BrotliCache) Get(key string) ([]byte, bool) {
 	return c.get(key, c.decompress)
 }
 
+// GetStream retrieves a value from the cache as a reader that decompresses it
+// as it is read, avoiding a full decompressed copy of large entries.
+func (c *BrotliCache) GetStream(ctx context.Context, key string) (io.ReadCloser, bool, error) {
+	return c.getStream(ctx, key)
+}
+
 // Delete removes a value from the cache
 func (c *BrotliCache) Delete(key string) {
 	c.delete(key)
 }
 
+// ClearWithContext implements httpcache.Clearer when the underlying cache does, clearing
+// it. Returns httpcache.ErrClearNotSupported otherwise.
+func (c *BrotliCache) ClearWithContext(ctx context.Context) error {
+	return c.clear(ctx)
+}
+
 // Stats returns compression statistics
 func (c *BrotliCache) Stats() Stats {
 	return c.stats()
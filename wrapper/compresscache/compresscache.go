@@ -1,12 +1,19 @@
 // Package compresscache provides a cache wrapper that automatically compresses
 // cached data to reduce storage requirements and network bandwidth usage.
-// Supports multiple compression algorithms: gzip, brotli, and snappy.
+// Supports multiple compression algorithms: gzip, brotli, and snappy, or a
+// choice among them per entry by size.
 package compresscache
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
+	"io"
 	"sync/atomic"
 
+	"github.com/andybalholm/brotli"
+	"github.com/golang/snappy"
 	"github.com/sandrolain/httpcache"
 )
 
@@ -50,6 +57,14 @@ type Stats struct {
 // Deprecated: Use GzipCache, BrotliCache, or SnappyCache directly
 type CompressCache = GzipCache
 
+// StreamGetter is implemented by the compression caches to read an entry as a
+// stream, decompressing it as it is read instead of into a single buffer
+type StreamGetter interface {
+	// GetStream returns a reader for the decompressed value of key and true if
+	// present. Corrupted compressed data may only be reported while reading.
+	GetStream(ctx context.Context, key string) (io.ReadCloser, bool, error)
+}
+
 // compressFunc is a function type for compression operations
 type compressFunc func([]byte) ([]byte, error)
 
@@ -58,8 +73,10 @@ type decompressFunc func([]byte) ([]byte, error)
 
 // baseCompressCache provides common functionality for all compression implementations
 type baseCompressCache struct {
-	cache     httpcache.Cache
-	algorithm Algorithm
+	cache           httpcache.Cache
+	algorithm       Algorithm
+	refuseSensitive bool
+	minSize         int
 
 	// Statistics
 	compressedBytes   atomic.Int64
@@ -69,11 +86,16 @@ type baseCompressCache struct {
 }
 
 // newBaseCompressCache creates a new base compression cache
-func newBaseCompressCache(cache httpcache.Cache, algorithm Algorithm) *baseCompressCache {
-	return &baseCompressCache{
-		cache:     cache,
-		algorithm: algorithm,
+func newBaseCompressCache(cache httpcache.Cache, algorithm Algorithm, refuseSensitive bool, minSize int) (*baseCompressCache, error) {
+	if minSize < 0 {
+		return nil, fmt.Errorf("minimum size to compress cannot be negative: %d", minSize)
 	}
+	return &baseCompressCache{
+		cache:           cache,
+		algorithm:       algorithm,
+		refuseSensitive: refuseSensitive,
+		minSize:         minSize,
+	}, nil
 }
 
 // get retrieves and decompresses a value from the cache
@@ -111,6 +133,59 @@ func (c *baseCompressCache) get(key string, decompressFn decompressFunc) ([]byte
 	return decompressed, true
 }
 
+// getStream retrieves a value from the cache and returns a reader decompressing
+// it as it is read
+func (c *baseCompressCache) getStream(ctx context.Context, key string) (io.ReadCloser, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	data, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if len(data) < 1 {
+		return io.NopCloser(bytes.NewReader(data)), true, nil
+	}
+
+	r, err := newDecompressReader(data[1:], data[0])
+	if err != nil {
+		httpcache.GetLogger().Warn("decompression failed",
+			"key", key,
+			"marker", data[0],
+			"error", err)
+		return nil, false, err
+	}
+	return r, true, nil
+}
+
+// newDecompressReader returns a reader decompressing data stored with the given
+// marker. Snappy entries use the block format, which can't be decoded
+// incrementally, so they are decoded up front.
+func newDecompressReader(data []byte, marker byte) (io.ReadCloser, error) {
+	if marker == 0 {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	switch algorithm := Algorithm(marker - 1); algorithm {
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("gzip reader creation failed: %w", err)
+		}
+		return r, nil
+	case Brotli:
+		return io.NopCloser(brotli.NewReader(bytes.NewReader(data))), nil
+	case Snappy:
+		decompressed, err := snappy.Decode(nil, data)
+		if err != nil {
+			return nil, fmt.Errorf("snappy decode failed: %w", err)
+		}
+		return io.NopCloser(bytes.NewReader(decompressed)), nil
+	default:
+		return nil, fmt.Errorf("unsupported decompression algorithm: %v", algorithm)
+	}
+}
+
 // decompressWithAlgorithm decompresses data, delegating to the appropriate decompressor
 func (c *baseCompressCache) decompressWithAlgorithm(data []byte, algorithm Algorithm, decompressFn decompressFunc) ([]byte, error) {
 	// If the stored algorithm matches ours, use our decompressor
@@ -146,38 +221,46 @@ func (c *baseCompressCache) decompressAny(data []byte, algorithm Algorithm) ([]b
 
 // set compresses and stores a value in the cache
 func (c *baseCompressCache) set(key string, value []byte, compressFn compressFunc) {
+	c.setWithAlgorithm(key, value, c.algorithm, compressFn)
+}
+
+// setWithAlgorithm compresses value with compressFn, an implementation of
+// algorithm, and stores it behind the marker of algorithm
+func (c *baseCompressCache) setWithAlgorithm(key string, value []byte, algorithm Algorithm, compressFn compressFunc) {
+	if c.refuseSensitive && httpcache.IsSensitiveEntry(value) {
+		httpcache.GetLogger().Debug("refusing to store sensitive entry", "key", key)
+		c.cache.Delete(key)
+		return
+	}
+
+	// Entries below the threshold aren't worth compressing
+	if len(value) < c.minSize {
+		c.setUncompressed(key, value)
+		return
+	}
+
 	// Compress the data
 	compressed, err := compressFn(value)
 	if err != nil {
 		httpcache.GetLogger().Warn("compression failed, storing uncompressed",
 			"key", key,
-			"algorithm", c.algorithm.String(),
+			"algorithm", algorithm.String(),
 			"error", err)
 		// Fallback to uncompressed
-		data := make([]byte, len(value)+1)
-		data[0] = 0
-		copy(data[1:], value)
-		c.cache.Set(key, data)
-		c.uncompressedCount.Add(1)
-		c.uncompressedBytes.Add(int64(len(value)))
+		c.setUncompressed(key, value)
 		return
 	}
 
 	// Prefix with marker (algorithm + 1, so 0 means uncompressed)
 	data := make([]byte, len(compressed)+1)
-	marker := c.algorithm + 1
+	marker := algorithm + 1
 	if marker > 0 && marker <= 255 {
 		data[0] = byte(marker)
 	} else {
 		httpcache.GetLogger().Warn("invalid compression marker, storing uncompressed",
 			"key", key,
-			"algorithm", c.algorithm.String())
-		fallbackData := make([]byte, len(value)+1)
-		fallbackData[0] = 0
-		copy(fallbackData[1:], value)
-		c.cache.Set(key, fallbackData)
-		c.uncompressedCount.Add(1)
-		c.uncompressedBytes.Add(int64(len(value)))
+			"algorithm", algorithm.String())
+		c.setUncompressed(key, value)
 		return
 	}
 	copy(data[1:], compressed)
@@ -188,11 +271,30 @@ func (c *baseCompressCache) set(key string, value []byte, compressFn compressFun
 	c.uncompressedBytes.Add(int64(len(value)))
 }
 
+// setUncompressed stores value as is, behind the uncompressed marker
+func (c *baseCompressCache) setUncompressed(key string, value []byte) {
+	data := make([]byte, len(value)+1)
+	data[0] = 0
+	copy(data[1:], value)
+	c.cache.Set(key, data)
+	c.uncompressedCount.Add(1)
+	c.uncompressedBytes.Add(int64(len(value)))
+}
+
 // delete removes a value from the cache
 func (c *baseCompressCache) delete(key string) {
 	c.cache.Delete(key)
 }
 
+// clear clears the underlying cache, if it implements httpcache.Clearer
+func (c *baseCompressCache) clear(ctx context.Context) error {
+	clearer, ok := c.cache.(httpcache.Clearer)
+	if !ok {
+		return httpcache.ErrClearNotSupported
+	}
+	return clearer.ClearWithContext(ctx)
+}
+
 // stats returns compression statistics
 func (c *baseCompressCache) stats() Stats {
 	compressed := c.compressedBytes.Load()
@@ -213,3 +315,11 @@ func (c *baseCompressCache) stats() Stats {
 		SavingsPercent:    savings,
 	}
 }
+
+// Verify interface implementations at compile time
+var (
+	_ StreamGetter = (*GzipCache)(nil)
+	_ StreamGetter = (*BrotliCache)(nil)
+	_ StreamGetter = (*SnappyCache)(nil)
+	_ StreamGetter = (*AdaptiveCache)(nil)
+)
@@ -3,11 +3,18 @@ package compresscache
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/sandrolain/httpcache"
+	"github.com/sandrolain/httpcache/test"
 )
 
 // mockCache is a simple in-memory cache for testing
@@ -533,6 +540,22 @@ func TestIntegration(t *testing.T) {
 	}
 }
 
+func TestClear(t *testing.T) {
+	cache, err := NewSnappy(SnappyConfig{Cache: httpcache.NewMemoryCache()})
+	if err != nil {
+		t.Fatalf("NewSnappy() failed: %v", err)
+	}
+	test.Clear(t, cache)
+
+	cache, err = NewSnappy(SnappyConfig{Cache: newMockCache()})
+	if err != nil {
+		t.Fatalf("NewSnappy() failed: %v", err)
+	}
+	if err := cache.ClearWithContext(context.Background()); !errors.Is(err, httpcache.ErrClearNotSupported) {
+		t.Errorf("expected ErrClearNotSupported, got %v", err)
+	}
+}
+
 func TestCorruptedData(t *testing.T) {
 	mock := newMockCache()
 	cache, err := NewGzip(GzipConfig{
@@ -748,3 +771,326 @@ func TestSnappyCorruptedData(t *testing.T) {
 		t.Error("Get() should return false for corrupted snappy data")
 	}
 }
+
+func TestGetStream(t *testing.T) {
+	large := []byte(strings.Repeat("streamed cache entry ", 4096))
+	caches := map[string]interface {
+		httpcache.Cache
+		StreamGetter
+	}{}
+	gz, _ := NewGzip(GzipConfig{Cache: newMockCache()})
+	br, _ := NewBrotli(BrotliConfig{Cache: newMockCache()})
+	sn, _ := NewSnappy(SnappyConfig{Cache: newMockCache()})
+	caches["gzip"], caches["brotli"], caches["snappy"] = gz, br, sn
+
+	for name, cache := range caches {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			cache.Set("key", large)
+
+			r, ok, err := cache.GetStream(ctx, "key")
+			if err != nil || !ok {
+				t.Fatalf("GetStream failed: ok=%v err=%v", ok, err)
+			}
+			got, err := io.ReadAll(r)
+			r.Close()
+			if err != nil || !bytes.Equal(got, large) {
+				t.Fatalf("streamed value differs from stored value (err=%v)", err)
+			}
+
+			if _, ok, err := cache.GetStream(ctx, "missing"); ok || err != nil {
+				t.Fatalf("expected miss, got ok=%v err=%v", ok, err)
+			}
+
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+			if _, _, err := cache.GetStream(cancelled, "key"); err == nil {
+				t.Fatal("expected error for cancelled context")
+			}
+		})
+	}
+}
+
+func TestGetStreamCrossAlgorithm(t *testing.T) {
+	backend := newMockCache()
+	br, _ := NewBrotli(BrotliConfig{Cache: backend})
+	gz, _ := NewGzip(GzipConfig{Cache: backend})
+
+	br.Set("key", []byte("written with brotli"))
+	r, ok, err := gz.GetStream(context.Background(), "key")
+	if err != nil || !ok {
+		t.Fatalf("GetStream failed: ok=%v err=%v", ok, err)
+	}
+	defer r.Close()
+	if got, _ := io.ReadAll(r); string(got) != "written with brotli" {
+		t.Fatalf("unexpected value %q", got)
+	}
+
+	backend.Set("raw", []byte{0, 'r', 'a', 'w'})
+	r, _, _ = gz.GetStream(context.Background(), "raw")
+	defer r.Close()
+	if got, _ := io.ReadAll(r); string(got) != "raw" {
+		t.Fatalf("unexpected uncompressed value %q", got)
+	}
+}
+
+// TestGetStreamMemory verifies streaming a large entry doesn't allocate a full
+// decompressed copy, unlike Get
+func TestGetStreamMemory(t *testing.T) {
+	const size = 16 << 20
+	cache, _ := NewGzip(GzipConfig{Cache: newMockCache(), Level: gzip.BestSpeed})
+	cache.Set("large", bytes.Repeat([]byte("0123456789abcdef"), size/16))
+
+	allocated := func(fn func()) uint64 {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		fn()
+		runtime.ReadMemStats(&after)
+		return after.TotalAlloc - before.TotalAlloc
+	}
+
+	getAlloc := allocated(func() {
+		value, _ := cache.Get("large")
+		io.Copy(io.Discard, bytes.NewReader(value))
+	})
+	streamAlloc := allocated(func() {
+		r, _, _ := cache.GetStream(context.Background(), "large")
+		io.Copy(io.Discard, r)
+		r.Close()
+	})
+
+	t.Logf("Get allocated %d bytes, GetStream allocated %d bytes", getAlloc, streamAlloc)
+	if getAlloc < size {
+		t.Fatalf("expected Get to allocate at least the decompressed size, got %d", getAlloc)
+	}
+	if streamAlloc > size/8 {
+		t.Fatalf("expected GetStream to allocate much less than the decompressed size, got %d", streamAlloc)
+	}
+}
+
+func TestRefuseSensitive(t *testing.T) {
+	sensitive := []byte("HTTP/1.1 200 OK\r\nX-Cache-Sensitive: 1\r\n\r\nsecret")
+	regular := []byte("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\npublic")
+
+	for _, tt := range []struct {
+		name string
+		new  func(httpcache.Cache) (httpcache.Cache, error)
+	}{
+		{"gzip", func(c httpcache.Cache) (httpcache.Cache, error) {
+			return NewGzip(GzipConfig{Cache: c, RefuseSensitive: true})
+		}},
+		{"brotli", func(c httpcache.Cache) (httpcache.Cache, error) {
+			return NewBrotli(BrotliConfig{Cache: c, RefuseSensitive: true})
+		}},
+		{"snappy", func(c httpcache.Cache) (httpcache.Cache, error) {
+			return NewSnappy(SnappyConfig{Cache: c, RefuseSensitive: true})
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newMockCache()
+			cache, err := tt.new(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cache.Set("regular", regular)
+			if _, ok := backend.data["regular"]; !ok {
+				t.Fatal("expected untagged entry to be stored")
+			}
+
+			cache.Set("sensitive", regular)
+			cache.Set("sensitive", sensitive)
+			if _, ok := backend.data["sensitive"]; ok {
+				t.Fatal("expected tagged entry not to be written, and the previous one removed")
+			}
+		})
+	}
+}
+
+func TestSensitiveStoredByDefault(t *testing.T) {
+	backend := newMockCache()
+	cache, err := NewGzip(GzipConfig{Cache: backend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Set("sensitive", []byte("HTTP/1.1 200 OK\r\nX-Cache-Sensitive: 1\r\n\r\nsecret"))
+	if _, ok := backend.data["sensitive"]; !ok {
+		t.Fatal("expected tagged entry to be stored without RefuseSensitive")
+	}
+}
+
+func TestRefuseSensitiveThroughTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	backend := newMockCache()
+	cache, err := NewGzip(GzipConfig{Cache: backend, RefuseSensitive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := httpcache.NewTransport(cache).Client()
+
+	for _, path := range []string{"/public", "/private"} {
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	if _, ok := backend.data[ts.URL+"/public"]; !ok {
+		t.Error("expected the public response to be stored")
+	}
+	if _, ok := backend.data[ts.URL+"/private"]; ok {
+		t.Error("expected the private response not to be written through the wrapper")
+	}
+}
+
+func TestMinSizeToCompress(t *testing.T) {
+	small := []byte("abc")
+	large := bytes.Repeat([]byte("compressible data "), 100)
+
+	for _, tt := range []struct {
+		name  string
+		new   func(httpcache.Cache) (httpcache.Cache, error)
+		stats func(httpcache.Cache) Stats
+	}{
+		{"gzip", func(c httpcache.Cache) (httpcache.Cache, error) {
+			return NewGzip(GzipConfig{Cache: c, MinSizeToCompress: 64})
+		}, func(c httpcache.Cache) Stats { return c.(*GzipCache).Stats() }},
+		{"brotli", func(c httpcache.Cache) (httpcache.Cache, error) {
+			return NewBrotli(BrotliConfig{Cache: c, MinSizeToCompress: 64})
+		}, func(c httpcache.Cache) Stats { return c.(*BrotliCache).Stats() }},
+		{"snappy", func(c httpcache.Cache) (httpcache.Cache, error) {
+			return NewSnappy(SnappyConfig{Cache: c, MinSizeToCompress: 64})
+		}, func(c httpcache.Cache) Stats { return c.(*SnappyCache).Stats() }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newMockCache()
+			cache, err := tt.new(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cache.Set("small", small)
+			cache.Set("large", large)
+
+			if raw := backend.data["small"]; !bytes.Equal(raw, append([]byte{0}, small...)) {
+				t.Errorf("expected the small value stored raw behind the uncompressed marker, got %v", raw)
+			}
+			if raw := backend.data["large"]; raw[0] == 0 || len(raw) >= len(large) {
+				t.Errorf("expected the large value to be compressed, got marker %d and %d bytes", raw[0], len(raw))
+			}
+
+			for key, want := range map[string][]byte{"small": small, "large": large} {
+				if got, ok := cache.Get(key); !ok || !bytes.Equal(got, want) {
+					t.Errorf("expected %s to round-trip", key)
+				}
+			}
+
+			stats := tt.stats(cache)
+			if stats.CompressedCount != 1 || stats.UncompressedCount != 1 {
+				t.Errorf("expected 1 compressed and 1 uncompressed entry, got %d and %d",
+					stats.CompressedCount, stats.UncompressedCount)
+			}
+		})
+	}
+
+	if _, err := NewGzip(GzipConfig{Cache: newMockCache(), MinSizeToCompress: -1}); err == nil {
+		t.Error("expected a negative MinSizeToCompress to be rejected")
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	backend := newMockCache()
+	cache, err := NewAdaptive(AdaptiveCompressConfig{Cache: backend})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	small := bytes.Repeat([]byte("small entry "), 10)
+	large := bytes.Repeat([]byte("large compressible entry "), DefaultDenseThreshold/10)
+	cache.Set("small", small)
+	cache.Set("large", large)
+
+	if marker := backend.data["small"][0]; Algorithm(marker-1) != Snappy {
+		t.Errorf("expected the small entry to use snappy, got marker %d", marker)
+	}
+	if marker := backend.data["large"][0]; Algorithm(marker-1) != Brotli {
+		t.Errorf("expected the large entry to use brotli, got marker %d", marker)
+	}
+
+	for key, want := range map[string][]byte{"small": small, "large": large} {
+		if got, ok := cache.Get(key); !ok || !bytes.Equal(got, want) {
+			t.Errorf("expected %s to round-trip", key)
+		}
+		r, ok, err := cache.GetStream(context.Background(), key)
+		if err != nil || !ok {
+			t.Fatalf("GetStream(%s) failed: %v", key, err)
+		}
+		got, _ := io.ReadAll(r)
+		r.Close()
+		if !bytes.Equal(got, want) {
+			t.Errorf("expected %s to round-trip as a stream", key)
+		}
+	}
+	if stats := cache.Stats(); stats.CompressedCount != 2 {
+		t.Errorf("expected 2 compressed entries, got %d", stats.CompressedCount)
+	}
+}
+
+func TestAdaptiveThresholds(t *testing.T) {
+	backend := newMockCache()
+	cache, err := NewAdaptive(AdaptiveCompressConfig{
+		Cache: backend,
+		Thresholds: []SizeThreshold{
+			{MinSize: 1024, Algorithm: Gzip},
+			{MinSize: 64, Algorithm: Snappy},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		size   int
+		marker byte
+	}{
+		{10, 0},
+		{64, byte(Snappy) + 1},
+		{1023, byte(Snappy) + 1},
+		{4096, byte(Gzip) + 1},
+	} {
+		value := bytes.Repeat([]byte("x"), tt.size)
+		key := strconv.Itoa(tt.size)
+		cache.Set(key, value)
+		if marker := backend.data[key][0]; marker != tt.marker {
+			t.Errorf("size %d: expected marker %d, got %d", tt.size, tt.marker, marker)
+		}
+		if got, ok := cache.Get(key); !ok || !bytes.Equal(got, value) {
+			t.Errorf("size %d: expected the value to round-trip", tt.size)
+		}
+	}
+
+	for _, thresholds := range [][]SizeThreshold{
+		{{MinSize: -1, Algorithm: Snappy}},
+		{{MinSize: 0, Algorithm: Snappy}, {MinSize: 0, Algorithm: Gzip}},
+		{{MinSize: 0, Algorithm: Algorithm(42)}},
+	} {
+		if _, err := NewAdaptive(AdaptiveCompressConfig{Cache: backend, Thresholds: thresholds}); err == nil {
+			t.Errorf("expected thresholds %v to be rejected", thresholds)
+		}
+	}
+	if _, err := NewAdaptive(AdaptiveCompressConfig{}); err == nil {
+		t.Error("expected a nil cache to be rejected")
+	}
+}
@@ -3,6 +3,7 @@ package compresscache
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 
@@ -23,6 +24,16 @@ type GzipConfig struct {
 	// Level is the compression level (-2 to 9)
 	// Default: gzip.DefaultCompression (-1)
 	Level int
+
+	// RefuseSensitive, if true, doesn't persist entries the Transport tagged as
+	// sensitive (see httpcache.IsSensitiveEntry); any existing entry for the key
+	// is removed instead
+	RefuseSensitive bool
+
+	// MinSizeToCompress is the size in bytes below which entries are stored
+	// uncompressed, since compressing tiny entries wastes CPU and can even
+	// enlarge them. Default: 0 (compress every entry)
+	MinSizeToCompress int
 }
 
 // NewGzip creates a new GzipCache with Gzip compression
@@ -41,8 +52,13 @@ func NewGzip(config GzipConfig) (*GzipCache, error) {
 		return nil, fmt.Errorf("invalid gzip compression level: %d", config.Level)
 	}
 
+	base, err := newBaseCompressCache(config.Cache, Gzip, config.RefuseSensitive, config.MinSizeToCompress)
+	if err != nil {
+		return nil, err
+	}
+
 	return &GzipCache{
-		baseCompressCache: newBaseCompressCache(config.Cache, Gzip),
+		baseCompressCache: base,
 		level:             config.Level,
 	}, nil
 }
@@ -95,11 +111,23 @@ func (c *GzipCache) Get(key string) ([]byte, bool) {
 	return c.get(key, c.decompress)
 }
 
+// GetStream retrieves a value from the cache as a reader that decompresses it
+// as it is read, avoiding a full decompressed copy of large entries.
+func (c *GzipCache) GetStream(ctx context.Context, key string) (io.ReadCloser, bool, error) {
+	return c.getStream(ctx, key)
+}
+
 // Delete removes a value from the cache
 func (c *GzipCache) Delete(key string) {
 	c.delete(key)
 }
 
+// ClearWithContext implements httpcache.Clearer when the underlying cache does, clearing
+// it. Returns httpcache.ErrClearNotSupported otherwise.
+func (c *GzipCache) ClearWithContext(ctx context.Context) error {
+	return c.clear(ctx)
+}
+
 // Stats returns compression statistics
 func (c *GzipCache) Stats() Stats {
 	return c.stats()
@@ -1,7 +1,9 @@
 package compresscache
 
 import (
+	"context"
 	"fmt"
+	"io"
 
 	"github.com/golang/snappy"
 	"github.com/sandrolain/httpcache"
@@ -16,6 +18,16 @@ type SnappyCache struct {
 type SnappyConfig struct {
 	// Cache is the underlying cache backend (required)
 	Cache httpcache.Cache
+
+	// RefuseSensitive, if true, doesn't persist entries the Transport tagged as
+	// sensitive (see httpcache.IsSensitiveEntry); any existing entry for the key
+	// is removed instead
+	RefuseSensitive bool
+
+	// MinSizeToCompress is the size in bytes below which entries are stored
+	// uncompressed, since compressing tiny entries wastes CPU and can even
+	// enlarge them. Default: 0 (compress every entry)
+	MinSizeToCompress int
 }
 
 // NewSnappy creates a new SnappyCache with Snappy compression
@@ -24,8 +36,13 @@ func NewSnappy(config SnappyConfig) (*SnappyCache, error) {
 		return nil, fmt.Errorf("cache cannot be nil")
 	}
 
+	base, err := newBaseCompressCache(config.Cache, Snappy, config.RefuseSensitive, config.MinSizeToCompress)
+	if err != nil {
+		return nil, err
+	}
+
 	return &SnappyCache{
-		baseCompressCache: newBaseCompressCache(config.Cache, Snappy),
+		baseCompressCache: base,
 	}, nil
 }
 
@@ -54,11 +71,24 @@ func (c *SnappyCache) Get(key string) ([]byte, bool) {
 	return c.get(key, c.decompress)
 }
 
+// GetStream retrieves a value from the cache as a reader that decompresses it
+// as it is read, avoiding a full decompressed copy of large entries.
+// Snappy entries are decoded up front, since their format can't be streamed.
+func (c *SnappyCache) GetStream(ctx context.Context, key string) (io.ReadCloser, bool, error) {
+	return c.getStream(ctx, key)
+}
+
 // Delete removes a value from the cache
 func (c *SnappyCache) Delete(key string) {
 	c.delete(key)
 }
 
+// ClearWithContext implements httpcache.Clearer when the underlying cache does, clearing
+// it. Returns httpcache.ErrClearNotSupported otherwise.
+func (c *SnappyCache) ClearWithContext(ctx context.Context) error {
+	return c.clear(ctx)
+}
+
 // Stats returns compression statistics
 func (c *SnappyCache) Stats() Stats {
 	return c.stats()
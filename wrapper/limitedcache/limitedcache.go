@@ -0,0 +1,128 @@
+// Package limitedcache provides a wrapper for httpcache.Cache implementations
+// that bounds the number of concurrent operations reaching the underlying
+// backend, protecting fragile backends (e.g. Redis or MongoDB with a limited
+// connection pool) from request bursts.
+package limitedcache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/sandrolain/httpcache"
+	"golang.org/x/sync/semaphore"
+)
+
+// LimitedCache wraps a cache and allows at most a fixed number of Get, Set and
+// Delete operations to run on it at the same time. Operations over the limit
+// either wait for a free slot or, with FailFast, are skipped.
+type LimitedCache struct {
+	cache    httpcache.Cache
+	sem      *semaphore.Weighted
+	failFast bool
+	timeout  time.Duration
+	rejected atomic.Int64
+}
+
+// Config holds the configuration for creating a LimitedCache.
+type Config struct {
+	// Cache is the underlying cache implementation to wrap.
+	Cache httpcache.Cache
+
+	// MaxConcurrent is the maximum number of operations running on the
+	// underlying cache at the same time. Must be positive.
+	MaxConcurrent int64
+
+	// FailFast skips Get and Set operations when the limit is reached instead
+	// of waiting: a skipped Get is reported as a miss and a skipped Set is not
+	// stored. Delete always waits, since skipping it could leave outdated
+	// entries to be served.
+	FailFast bool
+
+	// Timeout bounds how long an operation waits for a free slot before it is
+	// skipped as with FailFast. Zero means wait indefinitely.
+	Timeout time.Duration
+}
+
+// New creates a new LimitedCache that wraps the provided cache.
+func New(config Config) (*LimitedCache, error) {
+	if config.Cache == nil {
+		return nil, fmt.Errorf("cache cannot be nil")
+	}
+	if config.MaxConcurrent <= 0 {
+		return nil, fmt.Errorf("max concurrent operations must be positive, got %d", config.MaxConcurrent)
+	}
+	if config.Timeout < 0 {
+		return nil, fmt.Errorf("timeout cannot be negative")
+	}
+
+	return &LimitedCache{
+		cache:    config.Cache,
+		sem:      semaphore.NewWeighted(config.MaxConcurrent),
+		failFast: config.FailFast,
+		timeout:  config.Timeout,
+	}, nil
+}
+
+// Get returns the cached value for the given key. It reports a miss if no slot
+// becomes available in time.
+func (c *LimitedCache) Get(key string) ([]byte, bool) {
+	if !c.acquire(c.failFast) {
+		httpcache.GetLogger().Debug("cache get skipped: concurrency limit reached", "key", key)
+		return nil, false
+	}
+	defer c.sem.Release(1)
+	return c.cache.Get(key)
+}
+
+// Set stores the value in the underlying cache. The value is dropped if no slot
+// becomes available in time.
+func (c *LimitedCache) Set(key string, value []byte) {
+	if !c.acquire(c.failFast) {
+		httpcache.GetLogger().Debug("cache set skipped: concurrency limit reached", "key", key)
+		return
+	}
+	defer c.sem.Release(1)
+	c.cache.Set(key, value)
+}
+
+// Delete removes the value from the underlying cache. It ignores FailFast but
+// still honors Timeout.
+func (c *LimitedCache) Delete(key string) {
+	if !c.acquire(false) {
+		httpcache.GetLogger().Warn("cache delete skipped: concurrency limit reached", "key", key)
+		return
+	}
+	defer c.sem.Release(1)
+	c.cache.Delete(key)
+}
+
+// Rejected returns the number of operations skipped because the concurrency
+// limit was reached.
+func (c *LimitedCache) Rejected() int64 {
+	return c.rejected.Load()
+}
+
+// acquire takes a slot, waiting for one unless failFast is set, and reports
+// whether it succeeded
+func (c *LimitedCache) acquire(failFast bool) bool {
+	var ok bool
+	switch {
+	case failFast:
+		ok = c.sem.TryAcquire(1)
+	case c.timeout > 0:
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		ok = c.sem.Acquire(ctx, 1) == nil
+		cancel()
+	default:
+		ok = c.sem.Acquire(context.Background(), 1) == nil
+	}
+	if !ok {
+		c.rejected.Add(1)
+	}
+	return ok
+}
+
+// Verify interface implementation at compile time
+var _ httpcache.Cache = (*LimitedCache)(nil)
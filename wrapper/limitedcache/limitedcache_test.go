@@ -0,0 +1,164 @@
+package limitedcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	httpcache "github.com/sandrolain/httpcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowCache tracks the highest number of operations running at the same time
+type slowCache struct {
+	httpcache.Cache
+	delay    time.Duration
+	inFlight atomic.Int64
+	peak     atomic.Int64
+}
+
+func (c *slowCache) track() func() {
+	n := c.inFlight.Add(1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(c.delay)
+	return func() { c.inFlight.Add(-1) }
+}
+
+func (c *slowCache) Get(key string) ([]byte, bool) {
+	defer c.track()()
+	return c.Cache.Get(key)
+}
+
+func (c *slowCache) Set(key string, value []byte) {
+	defer c.track()()
+	c.Cache.Set(key, value)
+}
+
+func (c *slowCache) Delete(key string) {
+	defer c.track()()
+	c.Cache.Delete(key)
+}
+
+func newSlowCache(delay time.Duration) *slowCache {
+	return &slowCache{Cache: httpcache.NewMemoryCache(), delay: delay}
+}
+
+func TestConcurrencyBounded(t *testing.T) {
+	backend := newSlowCache(5 * time.Millisecond)
+	cache, err := New(Config{Cache: backend, MaxConcurrent: 3})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			switch i % 3 {
+			case 0:
+				cache.Get("key")
+			case 1:
+				cache.Set("key", []byte("value"))
+			default:
+				cache.Delete("other")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, backend.peak.Load(), int64(3))
+	assert.Equal(t, int64(3), backend.peak.Load(), "the limit should be reached under load")
+	assert.Equal(t, int64(0), cache.Rejected(), "queued operations should all run")
+}
+
+func TestFailFast(t *testing.T) {
+	backend := newSlowCache(50 * time.Millisecond)
+	backend.Cache.Set("key", []byte("value"))
+	cache, err := New(Config{Cache: backend, MaxConcurrent: 1, FailFast: true})
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		close(started)
+		cache.Get("key")
+		close(done)
+	}()
+	<-started
+	time.Sleep(10 * time.Millisecond)
+
+	_, ok := cache.Get("key")
+	assert.False(t, ok, "a Get over the limit should be reported as a miss")
+	cache.Set("new", []byte("value"))
+	<-done
+
+	_, ok = backend.Cache.Get("new")
+	assert.False(t, ok, "a Set over the limit should be dropped")
+	assert.Equal(t, int64(2), cache.Rejected())
+	assert.Equal(t, int64(1), backend.peak.Load())
+
+	value, ok := cache.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+}
+
+func TestFailFastDeleteWaits(t *testing.T) {
+	backend := newSlowCache(20 * time.Millisecond)
+	backend.Cache.Set("key", []byte("value"))
+	cache, err := New(Config{Cache: backend, MaxConcurrent: 1, FailFast: true})
+	require.NoError(t, err)
+
+	go cache.Get("other")
+	time.Sleep(5 * time.Millisecond)
+	cache.Delete("key")
+
+	_, ok := backend.Cache.Get("key")
+	assert.False(t, ok, "Delete should wait for a slot instead of being skipped")
+	assert.Equal(t, int64(0), cache.Rejected())
+}
+
+func TestTimeout(t *testing.T) {
+	backend := newSlowCache(100 * time.Millisecond)
+	cache, err := New(Config{Cache: backend, MaxConcurrent: 1, Timeout: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	go cache.Set("slow", []byte("value"))
+	time.Sleep(5 * time.Millisecond)
+
+	start := time.Now()
+	_, ok := cache.Get("slow")
+	assert.False(t, ok)
+	assert.Less(t, time.Since(start), 80*time.Millisecond, "Get should give up after the timeout")
+	assert.Equal(t, int64(1), cache.Rejected())
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{MaxConcurrent: 1})
+	assert.Error(t, err)
+
+	_, err = New(Config{Cache: httpcache.NewMemoryCache()})
+	assert.Error(t, err)
+
+	_, err = New(Config{Cache: httpcache.NewMemoryCache(), MaxConcurrent: 1, Timeout: -time.Second})
+	assert.Error(t, err)
+}
+
+func TestPassThrough(t *testing.T) {
+	cache, err := New(Config{Cache: httpcache.NewMemoryCache(), MaxConcurrent: 2})
+	require.NoError(t, err)
+
+	cache.Set("key", []byte("value"))
+	value, ok := cache.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+
+	cache.Delete("key")
+	_, ok = cache.Get("key")
+	assert.False(t, ok)
+}
@@ -5,9 +5,22 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
 	"time"
 )
 
+// Error types reported by ClassifyError
+const (
+	ErrorTypeTimeout    = "timeout"
+	ErrorTypeConnection = "connection"
+	ErrorTypeOther      = "other"
+)
+
 // Collector defines the interface for metrics collection.
 // Implementations of this interface can collect metrics for various
 // monitoring systems without requiring changes to the httpcache core.
@@ -52,6 +65,46 @@ type Collector interface {
 	RecordStaleResponse(errorType string)
 }
 
+// ErrorCollector is an optional interface implemented by collectors that record
+// cache backend errors. It is separate from Collector so existing Collector
+// implementations keep compiling.
+type ErrorCollector interface {
+	// RecordCacheError records a failed cache operation
+	// Parameters:
+	//   - operation: "get", "set", or "delete"
+	//   - backend: cache backend name
+	//   - errorType: error class, as returned by ClassifyError
+	RecordCacheError(operation, backend, errorType string)
+}
+
+// BytesSavedCollector is an optional interface implemented by collectors that
+// record the response body bytes served from cache instead of the origin.
+type BytesSavedCollector interface {
+	// RecordBytesSaved adds sizeBytes to the bytes served from cache
+	RecordBytesSaved(sizeBytes int64)
+}
+
+// ClassifyError maps a cache backend error to one of ErrorTypeTimeout,
+// ErrorTypeConnection, or ErrorTypeOther, keeping metric label cardinality low.
+func ClassifyError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrorTypeTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTypeTimeout
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EPIPE), errors.Is(err, net.ErrClosed),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorTypeConnection
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ErrorTypeConnection
+	}
+	return ErrorTypeOther
+}
+
 // NoOpCollector implements Collector with no-op operations.
 // This is used as the default collector when metrics are not enabled,
 // ensuring zero overhead for users who don't need metrics.
@@ -77,8 +130,18 @@ func (n *NoOpCollector) RecordHTTPResponseSize(cacheStatus string, sizeBytes int
 // RecordStaleResponse does nothing (no-op implementation)
 func (n *NoOpCollector) RecordStaleResponse(errorType string) {}
 
+// RecordCacheError does nothing (no-op implementation)
+func (n *NoOpCollector) RecordCacheError(operation, backend, errorType string) {}
+
+// RecordBytesSaved does nothing (no-op implementation)
+func (n *NoOpCollector) RecordBytesSaved(sizeBytes int64) {}
+
 // DefaultCollector is the default no-op collector used when metrics are not enabled
 var DefaultCollector Collector = &NoOpCollector{}
 
 // Verify that NoOpCollector implements Collector interface
-var _ Collector = (*NoOpCollector)(nil)
+var (
+	_ Collector           = (*NoOpCollector)(nil)
+	_ ErrorCollector      = (*NoOpCollector)(nil)
+	_ BytesSavedCollector = (*NoOpCollector)(nil)
+)
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"context deadline", context.DeadlineExceeded, ErrorTypeTimeout},
+		{"i/o timeout", &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, ErrorTypeTimeout},
+		{"wrapped timeout", fmt.Errorf("get failed: %w", os.ErrDeadlineExceeded), ErrorTypeTimeout},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, ErrorTypeConnection},
+		{"connection reset", syscall.ECONNRESET, ErrorTypeConnection},
+		{"closed connection", io.EOF, ErrorTypeConnection},
+		{"other", errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"), ErrorTypeOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("%s: ClassifyError() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
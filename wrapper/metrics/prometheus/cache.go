@@ -7,9 +7,12 @@ import (
 	"github.com/sandrolain/httpcache/wrapper/metrics"
 )
 
+const resultError = "error"
+
 // InstrumentedCache wraps an httpcache.Cache with Prometheus metrics
 type InstrumentedCache struct {
 	underlying httpcache.Cache
+	fallible   httpcache.FallibleCache // non-nil if underlying reports errors
 	collector  metrics.Collector
 	backend    string // backend name: "memory", "redis", "leveldb", etc.
 }
@@ -17,6 +20,10 @@ type InstrumentedCache struct {
 // NewInstrumentedCache creates a new instrumented cache that records metrics
 // for all cache operations.
 //
+// If the cache implements httpcache.FallibleCache (e.g. the redis backend),
+// failed operations are recorded with the "error" result and, when the
+// collector implements metrics.ErrorCollector, counted by error type.
+//
 // Parameters:
 //   - cache: the underlying cache implementation to wrap
 //   - backend: the name of the cache backend (e.g., "memory", "redis", "leveldb")
@@ -35,8 +42,11 @@ func NewInstrumentedCache(cache httpcache.Cache, backend string, collector metri
 		collector = metrics.DefaultCollector
 	}
 
+	fallible, _ := cache.(httpcache.FallibleCache)
+
 	return &InstrumentedCache{
 		underlying: cache,
+		fallible:   fallible,
 		collector:  collector,
 		backend:    backend,
 	}
@@ -45,13 +55,24 @@ func NewInstrumentedCache(cache httpcache.Cache, backend string, collector metri
 // Get retrieves a value from the cache with metrics recording
 func (c *InstrumentedCache) Get(key string) ([]byte, bool) {
 	start := time.Now()
-	value, ok := c.underlying.Get(key)
+	var value []byte
+	var ok bool
+	var err error
+	if c.fallible != nil {
+		value, ok, err = c.fallible.GetWithError(key)
+	} else {
+		value, ok = c.underlying.Get(key)
+	}
 	duration := time.Since(start)
 
 	result := resultMiss
 	if ok {
 		result = resultHit
 	}
+	if err != nil {
+		result = resultError
+		c.recordError("get", err)
+	}
 
 	c.collector.RecordCacheOperation("get", c.backend, result, duration)
 
@@ -61,19 +82,50 @@ func (c *InstrumentedCache) Get(key string) ([]byte, bool) {
 // Set stores a value in the cache with metrics recording
 func (c *InstrumentedCache) Set(key string, value []byte) {
 	start := time.Now()
-	c.underlying.Set(key, value)
+	var err error
+	if c.fallible != nil {
+		err = c.fallible.SetWithError(key, value)
+	} else {
+		c.underlying.Set(key, value)
+	}
 	duration := time.Since(start)
 
-	c.collector.RecordCacheOperation("set", c.backend, "success", duration)
+	result := "success"
+	if err != nil {
+		result = resultError
+		c.recordError("set", err)
+		httpcache.GetLogger().Warn("failed to write to cache", "backend", c.backend, "key", key, "error", err)
+	}
+
+	c.collector.RecordCacheOperation("set", c.backend, result, duration)
 }
 
 // Delete removes a value from the cache with metrics recording
 func (c *InstrumentedCache) Delete(key string) {
 	start := time.Now()
-	c.underlying.Delete(key)
+	var err error
+	if c.fallible != nil {
+		err = c.fallible.DeleteWithError(key)
+	} else {
+		c.underlying.Delete(key)
+	}
 	duration := time.Since(start)
 
-	c.collector.RecordCacheOperation("delete", c.backend, "success", duration)
+	result := "success"
+	if err != nil {
+		result = resultError
+		c.recordError("delete", err)
+		httpcache.GetLogger().Warn("failed to delete from cache", "backend", c.backend, "key", key, "error", err)
+	}
+
+	c.collector.RecordCacheOperation("delete", c.backend, result, duration)
+}
+
+// recordError reports a failed operation to collectors that record errors
+func (c *InstrumentedCache) recordError(operation string, err error) {
+	if ec, ok := c.collector.(metrics.ErrorCollector); ok {
+		ec.RecordCacheError(operation, c.backend, metrics.ClassifyError(err))
+	}
 }
 
 // Verify interface implementation at compile time
@@ -4,6 +4,7 @@ package prometheus
 
 import (
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -28,6 +29,13 @@ type Collector struct {
 	httpDuration     *prometheus.HistogramVec
 	httpResponseSize *prometheus.CounterVec
 	staleResponses   *prometheus.CounterVec
+	cacheErrors      *prometheus.CounterVec
+	bytesSaved       prometheus.Counter
+
+	// Duration sampling: observe 1 in durationSampleRate durations
+	durationSampleRate uint64
+	cacheOpSamples     atomic.Uint64
+	httpSamples        atomic.Uint64
 }
 
 // CollectorConfig provides configuration options for the Prometheus collector
@@ -43,6 +51,11 @@ type CollectorConfig struct {
 
 	// ConstLabels are labels added to all metrics
 	ConstLabels prometheus.Labels
+
+	// DurationSampleRate records duration histogram observations for only 1 in N
+	// cache operations and HTTP requests, reducing overhead at very high request
+	// rates. Counters are always incremented. 0 or 1 records every observation.
+	DurationSampleRate uint64
 }
 
 // NewCollector creates a new Prometheus collector with default registry and configuration
@@ -70,6 +83,7 @@ func NewCollectorWithConfig(config CollectorConfig) *Collector {
 	factory := promauto.With(config.Registry)
 
 	return &Collector{
+		durationSampleRate: config.DurationSampleRate,
 		cacheRequests: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace:   config.Namespace,
@@ -152,13 +166,34 @@ func NewCollectorWithConfig(config CollectorConfig) *Collector {
 			},
 			[]string{"error_type"},
 		),
+		cacheErrors: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "cache_errors_total",
+				Help:        "Total number of failed cache backend operations",
+				ConstLabels: config.ConstLabels,
+			},
+			[]string{"operation", cacheBackendLabel, "error_type"},
+		),
+		bytesSaved: factory.NewCounter(
+			prometheus.CounterOpts{
+				Namespace:   config.Namespace,
+				Subsystem:   config.Subsystem,
+				Name:        "bytes_saved_total",
+				Help:        "Total response body bytes served from cache instead of the origin",
+				ConstLabels: config.ConstLabels,
+			},
+		),
 	}
 }
 
 // RecordCacheOperation records a cache operation
 func (c *Collector) RecordCacheOperation(operation, backend, result string, duration time.Duration) {
 	c.cacheRequests.WithLabelValues(operation, backend, result).Inc()
-	c.cacheOpDuration.WithLabelValues(operation, backend).Observe(duration.Seconds())
+	if c.sampled(&c.cacheOpSamples) {
+		c.cacheOpDuration.WithLabelValues(operation, backend).Observe(duration.Seconds())
+	}
 }
 
 // RecordCacheSize records current cache size
@@ -174,7 +209,9 @@ func (c *Collector) RecordCacheEntries(backend string, count int64) {
 // RecordHTTPRequest records an HTTP request
 func (c *Collector) RecordHTTPRequest(method, cacheStatus string, statusCode int, duration time.Duration) {
 	c.httpRequests.WithLabelValues(method, cacheStatus, strconv.Itoa(statusCode)).Inc()
-	c.httpDuration.WithLabelValues(method, cacheStatus).Observe(duration.Seconds())
+	if c.sampled(&c.httpSamples) {
+		c.httpDuration.WithLabelValues(method, cacheStatus).Observe(duration.Seconds())
+	}
 }
 
 // RecordHTTPResponseSize records HTTP response size
@@ -187,5 +224,27 @@ func (c *Collector) RecordStaleResponse(errorType string) {
 	c.staleResponses.WithLabelValues(errorType).Inc()
 }
 
+// RecordCacheError records a failed cache backend operation
+func (c *Collector) RecordCacheError(operation, backend, errorType string) {
+	c.cacheErrors.WithLabelValues(operation, backend, errorType).Inc()
+}
+
+// RecordBytesSaved records response body bytes served from cache
+func (c *Collector) RecordBytesSaved(sizeBytes int64) {
+	c.bytesSaved.Add(float64(sizeBytes))
+}
+
+// sampled reports whether the current duration observation should be recorded
+func (c *Collector) sampled(counter *atomic.Uint64) bool {
+	if c.durationSampleRate <= 1 {
+		return true
+	}
+	return counter.Add(1)%c.durationSampleRate == 0
+}
+
 // Verify interface implementation at compile time
-var _ metrics.Collector = (*Collector)(nil)
+var (
+	_ metrics.Collector           = (*Collector)(nil)
+	_ metrics.ErrorCollector      = (*Collector)(nil)
+	_ metrics.BytesSavedCollector = (*Collector)(nil)
+)
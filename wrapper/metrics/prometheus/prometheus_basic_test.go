@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"syscall"
 	"testing"
 	"time"
 
@@ -393,6 +395,49 @@ func TestPrometheusIntegrationStaleResponses(t *testing.T) {
 	}
 }
 
+// TestPrometheusIntegrationCacheErrors tests backend error metrics
+func TestPrometheusIntegrationCacheErrors(t *testing.T) {
+	if testing.Short() {
+		t.Skip(skipIntegrationMsg)
+	}
+
+	registry := prometheus.NewRegistry()
+	collector := NewCollectorWithRegistry(registry)
+
+	server, metricsURL := setupMetricsServer(registry)
+	defer server.Close()
+
+	backend := &failingCache{Cache: httpcache.NewMemoryCache(), err: os.ErrDeadlineExceeded}
+	cache := NewInstrumentedCache(backend, "redis", collector)
+	cache.Get("key")
+	cache.Set("key", []byte("value"))
+
+	backend.err = syscall.ECONNREFUSED
+	cache.Get("key")
+
+	metrics := scrapeMetrics(t, metricsURL)
+	if !containsMetric(metrics, "httpcache_cache_errors_total") {
+		t.Fatal("cache error metrics not found")
+	}
+
+	for _, tt := range []struct {
+		operation string
+		errorType string
+	}{
+		{"get", "timeout"},
+		{"set", "timeout"},
+		{"get", "connection"},
+	} {
+		value := getMetricValue(t, registry, "httpcache_cache_errors_total", map[string]string{
+			"operation":  tt.operation,
+			"error_type": tt.errorType,
+		})
+		if value != 1 {
+			t.Errorf("expected 1 %s error for %s, got %v", tt.errorType, tt.operation, value)
+		}
+	}
+}
+
 // TestPrometheusIntegrationHistogramBuckets tests that histogram buckets are properly configured
 func TestPrometheusIntegrationHistogramBuckets(t *testing.T) {
 	if testing.Short() {
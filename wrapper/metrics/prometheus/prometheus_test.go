@@ -1,6 +1,7 @@
 package prometheus
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -124,6 +125,80 @@ func TestInstrumentedCache(t *testing.T) {
 	}
 }
 
+// failingCache is a FallibleCache whose operations fail with err
+type failingCache struct {
+	httpcache.Cache
+	err error
+}
+
+func (c *failingCache) GetWithError(key string) ([]byte, bool, error) {
+	if c.err != nil {
+		return nil, false, c.err
+	}
+	value, ok := c.Cache.Get(key)
+	return value, ok, nil
+}
+
+func (c *failingCache) SetWithError(key string, value []byte) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.Cache.Set(key, value)
+	return nil
+}
+
+func (c *failingCache) DeleteWithError(key string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.Cache.Delete(key)
+	return nil
+}
+
+func TestInstrumentedCacheErrors(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	collector := NewCollectorWithRegistry(registry)
+
+	backend := &failingCache{Cache: httpcache.NewMemoryCache()}
+	cache := NewInstrumentedCache(backend, "redis", collector)
+
+	cache.Set("key1", []byte("value1"))
+	if _, ok := cache.Get("key1"); !ok {
+		t.Fatal("expected cache hit")
+	}
+
+	backend.err = errors.New("READONLY You can't write against a read only replica")
+	cache.Set("key1", []byte("value2"))
+	if _, ok := cache.Get("key1"); ok {
+		t.Fatal("expected failed Get to report a miss")
+	}
+	cache.Delete("key1")
+
+	expected := `
+		# HELP httpcache_cache_errors_total Total number of failed cache backend operations
+		# TYPE httpcache_cache_errors_total counter
+		httpcache_cache_errors_total{cache_backend="redis",error_type="other",operation="delete"} 1
+		httpcache_cache_errors_total{cache_backend="redis",error_type="other",operation="get"} 1
+		httpcache_cache_errors_total{cache_backend="redis",error_type="other",operation="set"} 1
+	`
+	if err := testutil.CollectAndCompare(collector.cacheErrors, strings.NewReader(expected)); err != nil {
+		t.Errorf("unexpected metrics: %v", err)
+	}
+
+	expected = `
+		# HELP httpcache_cache_requests_total Total number of cache operations
+		# TYPE httpcache_cache_requests_total counter
+		httpcache_cache_requests_total{cache_backend="redis",operation="delete",result="error"} 1
+		httpcache_cache_requests_total{cache_backend="redis",operation="get",result="error"} 1
+		httpcache_cache_requests_total{cache_backend="redis",operation="get",result="hit"} 1
+		httpcache_cache_requests_total{cache_backend="redis",operation="set",result="error"} 1
+		httpcache_cache_requests_total{cache_backend="redis",operation="set",result="success"} 1
+	`
+	if err := testutil.CollectAndCompare(collector.cacheRequests, strings.NewReader(expected)); err != nil {
+		t.Errorf("unexpected metrics: %v", err)
+	}
+}
+
 func TestInstrumentedCacheWithNilCollector(t *testing.T) {
 	baseCache := httpcache.NewMemoryCache()
 
@@ -243,3 +318,103 @@ func TestRecordStaleResponse(t *testing.T) {
 		t.Errorf("unexpected metrics: %v", err)
 	}
 }
+
+func TestRecordCacheError(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	collector := NewCollectorWithRegistry(registry)
+
+	collector.RecordCacheError("get", "redis", "timeout")
+	collector.RecordCacheError("get", "redis", "timeout")
+	collector.RecordCacheError("set", "redis", "connection")
+
+	expected := `
+		# HELP httpcache_cache_errors_total Total number of failed cache backend operations
+		# TYPE httpcache_cache_errors_total counter
+		httpcache_cache_errors_total{cache_backend="redis",error_type="connection",operation="set"} 1
+		httpcache_cache_errors_total{cache_backend="redis",error_type="timeout",operation="get"} 2
+	`
+
+	if err := testutil.CollectAndCompare(collector.cacheErrors, strings.NewReader(expected)); err != nil {
+		t.Errorf("unexpected metrics: %v", err)
+	}
+}
+
+// histogramSampleCount returns the total observation count of a gathered histogram
+func histogramSampleCount(t *testing.T, registry *prometheus.Registry, name string) uint64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	var total uint64
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			total += m.GetHistogram().GetSampleCount()
+		}
+	}
+	return total
+}
+
+func TestDurationSampleRate(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	collector := NewCollectorWithConfig(CollectorConfig{
+		Registry:           registry,
+		DurationSampleRate: 4,
+	})
+
+	for i := 0; i < 8; i++ {
+		collector.RecordHTTPRequest("GET", "hit", 200, time.Millisecond)
+		collector.RecordCacheOperation("get", "memory", "hit", time.Millisecond)
+	}
+
+	if got := testutil.ToFloat64(collector.httpRequests.WithLabelValues("GET", "hit", "200")); got != 8 {
+		t.Errorf("expected 8 HTTP requests counted, got %v", got)
+	}
+	if got := testutil.ToFloat64(collector.cacheRequests.WithLabelValues("get", "memory", "hit")); got != 8 {
+		t.Errorf("expected 8 cache operations counted, got %v", got)
+	}
+	if got := histogramSampleCount(t, registry, "httpcache_http_request_duration_seconds"); got != 2 {
+		t.Errorf("expected 2 sampled HTTP durations, got %d", got)
+	}
+	if got := histogramSampleCount(t, registry, "httpcache_cache_operation_duration_seconds"); got != 2 {
+		t.Errorf("expected 2 sampled cache durations, got %d", got)
+	}
+}
+
+func TestDurationSampleRateDisabled(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	collector := NewCollectorWithRegistry(registry)
+
+	for i := 0; i < 5; i++ {
+		collector.RecordHTTPRequest("GET", "miss", 200, time.Millisecond)
+	}
+
+	if got := histogramSampleCount(t, registry, "httpcache_http_request_duration_seconds"); got != 5 {
+		t.Errorf("expected every duration observed, got %d", got)
+	}
+}
+
+func benchmarkRecordHTTPRequest(b *testing.B, sampleRate uint64) {
+	collector := NewCollectorWithConfig(CollectorConfig{
+		Registry:           prometheus.NewRegistry(),
+		DurationSampleRate: sampleRate,
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			collector.RecordHTTPRequest("GET", "hit", 200, time.Millisecond)
+		}
+	})
+}
+
+func BenchmarkRecordHTTPRequestFull(b *testing.B) {
+	benchmarkRecordHTTPRequest(b, 0)
+}
+
+func BenchmarkRecordHTTPRequestSampled(b *testing.B) {
+	benchmarkRecordHTTPRequest(b, 100)
+}
@@ -1,6 +1,7 @@
 package prometheus
 
 import (
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -58,6 +59,7 @@ func (t *InstrumentedTransport) RoundTrip(req *http.Request) (*http.Response, er
 	cacheStatus := resultMiss
 	if resp.Header.Get(httpcache.XFromCache) == "1" {
 		cacheStatus = resultHit
+		t.countBytesSaved(resp)
 	} else if resp.StatusCode == http.StatusNotModified {
 		cacheStatus = "revalidated"
 	}
@@ -80,6 +82,30 @@ func (t *InstrumentedTransport) RoundTrip(req *http.Request) (*http.Response, er
 	return resp, nil
 }
 
+// countBytesSaved wraps the body of a response served from cache to record the
+// bytes read with the collector, if it implements metrics.BytesSavedCollector
+func (t *InstrumentedTransport) countBytesSaved(resp *http.Response) {
+	bc, ok := t.collector.(metrics.BytesSavedCollector)
+	if !ok || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	resp.Body = &bytesSavedBody{ReadCloser: resp.Body, collector: bc}
+}
+
+// bytesSavedBody records the bytes read from the wrapped body as saved
+type bytesSavedBody struct {
+	io.ReadCloser
+	collector metrics.BytesSavedCollector
+}
+
+func (b *bytesSavedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.collector.RecordBytesSaved(int64(n))
+	}
+	return n, err
+}
+
 // Client returns an HTTP client with instrumented transport
 func (t *InstrumentedTransport) Client() *http.Client {
 	return &http.Client{Transport: t}
@@ -249,3 +249,41 @@ func TestInstrumentedTransportDifferentStatusCodes(t *testing.T) {
 		t.Errorf("expected multiple status codes, got %d", len(statusCodesFound))
 	}
 }
+
+func TestInstrumentedTransportBytesSaved(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	collector := NewCollectorWithRegistry(registry)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=300")
+		w.Write([]byte("test response"))
+	}))
+	defer testServer.Close()
+
+	transport := httpcache.NewMemoryCacheTransport()
+	client := NewInstrumentedTransport(transport, collector).Client()
+
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(testServer.URL)
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	// The first request is a miss, the three others are hits of 13 bytes each
+	if got := testutil.ToFloat64(collector.bytesSaved); got != 39 {
+		t.Errorf("expected 39 bytes saved, got %v", got)
+	}
+	if got := transport.BytesSavedFromOrigin(); got != 39 {
+		t.Errorf("expected the transport to report 39 bytes saved, got %d", got)
+	}
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP httpcache_bytes_saved_total Total response body bytes served from cache instead of the origin
+# TYPE httpcache_bytes_saved_total counter
+httpcache_bytes_saved_total 39
+`), "httpcache_bytes_saved_total"); err != nil {
+		t.Error(err)
+	}
+}
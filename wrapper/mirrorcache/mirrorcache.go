@@ -0,0 +1,94 @@
+// Package mirrorcache provides a wrapper for httpcache.Cache implementations
+// that mirrors every write to a secondary cache, so a new backend warms up from
+// live traffic while migrating to it (e.g. from diskcache to Redis).
+package mirrorcache
+
+import (
+	"fmt"
+
+	"github.com/sandrolain/httpcache"
+)
+
+// MirrorCache writes to both a primary and a secondary cache and reads from the
+// primary. The primary remains authoritative: failures of the secondary are
+// logged and never affect the result of an operation.
+type MirrorCache struct {
+	primary     httpcache.Cache
+	secondary   httpcache.Cache
+	readThrough bool
+}
+
+// Config holds the configuration for creating a MirrorCache.
+type Config struct {
+	// Primary is the cache reads are served from, e.g. the backend being
+	// migrated away from.
+	Primary httpcache.Cache
+
+	// Secondary receives a copy of every Set and Delete, e.g. the backend being
+	// migrated to. Errors are only reported if it implements
+	// httpcache.FallibleCache.
+	Secondary httpcache.Cache
+
+	// ReadThrough, if true, looks an entry up in the secondary when the primary
+	// misses. The entry is not copied back to the primary.
+	ReadThrough bool
+}
+
+// New creates a new MirrorCache that wraps the provided caches.
+func New(config Config) (*MirrorCache, error) {
+	if config.Primary == nil {
+		return nil, fmt.Errorf("primary cache cannot be nil")
+	}
+	if config.Secondary == nil {
+		return nil, fmt.Errorf("secondary cache cannot be nil")
+	}
+	return &MirrorCache{
+		primary:     config.Primary,
+		secondary:   config.Secondary,
+		readThrough: config.ReadThrough,
+	}, nil
+}
+
+// Get returns the cached value for the given key from the primary cache, or
+// from the secondary on a primary miss if ReadThrough is enabled.
+func (c *MirrorCache) Get(key string) ([]byte, bool) {
+	if value, ok := c.primary.Get(key); ok || !c.readThrough {
+		return value, ok
+	}
+
+	if fallible, ok := c.secondary.(httpcache.FallibleCache); ok {
+		value, ok, err := fallible.GetWithError(key)
+		if err != nil {
+			httpcache.GetLogger().Warn("mirror cache secondary get failed", "key", key, "error", err)
+		}
+		return value, ok
+	}
+	return c.secondary.Get(key)
+}
+
+// Set stores the value in the primary cache, then in the secondary.
+func (c *MirrorCache) Set(key string, value []byte) {
+	c.primary.Set(key, value)
+
+	if fallible, ok := c.secondary.(httpcache.FallibleCache); ok {
+		if err := fallible.SetWithError(key, value); err != nil {
+			httpcache.GetLogger().Warn("mirror cache secondary set failed", "key", key, "error", err)
+		}
+		return
+	}
+	c.secondary.Set(key, value)
+}
+
+// Delete removes the entry for key from the primary cache, then from the
+// secondary.
+func (c *MirrorCache) Delete(key string) {
+	c.primary.Delete(key)
+
+	if fallible, ok := c.secondary.(httpcache.FallibleCache); ok {
+		if err := fallible.DeleteWithError(key); err != nil {
+			httpcache.GetLogger().Warn("mirror cache secondary delete failed", "key", key, "error", err)
+		}
+		return
+	}
+	c.secondary.Delete(key)
+}
@@ -0,0 +1,119 @@
+package mirrorcache
+
+import (
+	"errors"
+	"testing"
+
+	httpcache "github.com/sandrolain/httpcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingCache is a FallibleCache whose operations all fail
+type failingCache struct {
+	calls int
+}
+
+var errUnavailable = errors.New("backend unavailable")
+
+func (c *failingCache) Get(key string) ([]byte, bool) { c.calls++; return nil, false }
+func (c *failingCache) Set(key string, value []byte)  { c.calls++ }
+func (c *failingCache) Delete(key string)             { c.calls++ }
+
+func (c *failingCache) GetWithError(key string) ([]byte, bool, error) {
+	c.calls++
+	return nil, false, errUnavailable
+}
+
+func (c *failingCache) SetWithError(key string, value []byte) error {
+	c.calls++
+	return errUnavailable
+}
+
+func (c *failingCache) DeleteWithError(key string) error {
+	c.calls++
+	return errUnavailable
+}
+
+func TestNewRequiresCaches(t *testing.T) {
+	_, err := New(Config{Secondary: httpcache.NewMemoryCache()})
+	assert.Error(t, err)
+	_, err = New(Config{Primary: httpcache.NewMemoryCache()})
+	assert.Error(t, err)
+}
+
+func TestWritesReachBothCaches(t *testing.T) {
+	primary, secondary := httpcache.NewMemoryCache(), httpcache.NewMemoryCache()
+	cache, err := New(Config{Primary: primary, Secondary: secondary})
+	require.NoError(t, err)
+
+	cache.Set("key", []byte("value"))
+	for name, c := range map[string]httpcache.Cache{"primary": primary, "secondary": secondary} {
+		value, ok := c.Get("key")
+		assert.True(t, ok, name)
+		assert.Equal(t, "value", string(value), name)
+	}
+
+	cache.Delete("key")
+	for name, c := range map[string]httpcache.Cache{"primary": primary, "secondary": secondary} {
+		_, ok := c.Get("key")
+		assert.False(t, ok, name)
+	}
+}
+
+func TestReadsFromPrimary(t *testing.T) {
+	primary, secondary := httpcache.NewMemoryCache(), httpcache.NewMemoryCache()
+	cache, err := New(Config{Primary: primary, Secondary: secondary})
+	require.NoError(t, err)
+
+	primary.Set("both", []byte("primary"))
+	secondary.Set("both", []byte("secondary"))
+	secondary.Set("secondary-only", []byte("secondary"))
+
+	value, ok := cache.Get("both")
+	assert.True(t, ok)
+	assert.Equal(t, "primary", string(value))
+
+	_, ok = cache.Get("secondary-only")
+	assert.False(t, ok, "expected a primary miss without ReadThrough")
+}
+
+func TestReadThrough(t *testing.T) {
+	primary, secondary := httpcache.NewMemoryCache(), httpcache.NewMemoryCache()
+	cache, err := New(Config{Primary: primary, Secondary: secondary, ReadThrough: true})
+	require.NoError(t, err)
+
+	primary.Set("both", []byte("primary"))
+	secondary.Set("both", []byte("secondary"))
+	secondary.Set("secondary-only", []byte("secondary"))
+
+	value, ok := cache.Get("both")
+	assert.True(t, ok)
+	assert.Equal(t, "primary", string(value))
+
+	value, ok = cache.Get("secondary-only")
+	assert.True(t, ok)
+	assert.Equal(t, "secondary", string(value))
+
+	_, ok = primary.Get("secondary-only")
+	assert.False(t, ok, "expected the entry not to be copied to the primary")
+}
+
+func TestSecondaryErrorsAreNotFatal(t *testing.T) {
+	primary, secondary := httpcache.NewMemoryCache(), &failingCache{}
+	cache, err := New(Config{Primary: primary, Secondary: secondary, ReadThrough: true})
+	require.NoError(t, err)
+
+	cache.Set("key", []byte("value"))
+	value, ok := cache.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "value", string(value))
+
+	_, ok = cache.Get("missing")
+	assert.False(t, ok)
+
+	cache.Delete("key")
+	_, ok = primary.Get("key")
+	assert.False(t, ok)
+	assert.Equal(t, 3, secondary.calls, "expected the secondary to be called through FallibleCache")
+}
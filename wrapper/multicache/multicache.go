@@ -5,9 +5,45 @@
 package multicache
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru/v2"
 	httpcache "github.com/sandrolain/httpcache"
 )
 
+// skipTiersKey is the context key of the tiers set with WithSkipTiers
+type skipTiersKey struct{}
+
+// WithSkipTiers returns a copy of ctx telling MultiCache not to query the tiers
+// at the given indexes when reading for a request with it, e.g. an expensive
+// tier for requests that can do without it. Writes still go to every tier.
+func WithSkipTiers(ctx context.Context, tiers ...int) context.Context {
+	return context.WithValue(ctx, skipTiersKey{}, append([]int(nil), tiers...))
+}
+
+// promoteTrackedKeys bounds the number of keys whose slow-tier hits are counted
+// for PromoteAfterHits; the least recently hit keys are forgotten first
+const promoteTrackedKeys = 10000
+
+// BackendStats is implemented by tiers that expose their own counters, which
+// are then included in the per-tier stats returned by MultiCache.TierStats.
+type BackendStats interface {
+	BackendStats() map[string]int64
+}
+
+// TierStats holds the statistics of a single tier
+type TierStats struct {
+	// Index is the position of the tier, 0 being the fastest
+	Index int
+	// Hits is the number of Get calls served by this tier
+	Hits int64
+	// Backend holds the tier's own counters if it implements BackendStats, nil otherwise
+	Backend map[string]int64
+}
+
 // MultiCache implements a multi-tiered caching strategy where cache tiers are
 // ordered from fastest/smallest (first) to slowest/largest (last). On reads,
 // it searches each tier in order and promotes found values to faster tiers.
@@ -19,7 +55,34 @@ import (
 //   - Tier 2: Redis (medium speed, larger, persistent)
 //   - Tier 3: PostgreSQL (slower, largest, highly persistent)
 type MultiCache struct {
-	tiers []httpcache.Cache
+	tiers  []httpcache.Cache
+	hits   []atomic.Int64
+	misses atomic.Int64
+
+	skipTier         func(ctx context.Context, tier int) bool
+	promoteAfterHits int
+	slowHitsMu       sync.Mutex
+	slowHits         *lru.Cache[string, int] // nil when every hit promotes
+}
+
+// Config holds the configuration for creating a MultiCache with NewWithConfig.
+type Config struct {
+	// Tiers are the cache tiers, ordered from fastest/smallest to
+	// slowest/largest. At least one is required; all must be non-nil and unique.
+	Tiers []httpcache.Cache
+
+	// PromoteAfterHits is the number of times an entry must be found in a
+	// slower tier before it is promoted to the faster ones, so one-hit wonders
+	// don't pollute the fast tiers. Values below 2 promote on the first hit.
+	// Hits are counted for the 10,000 most recently hit keys.
+	PromoteAfterHits int
+
+	// SkipTier, if set, is called on each read for every tier but the first and
+	// reports whether that tier should not be queried, e.g. an expensive tier
+	// while the system is under load. ctx is the request context when reads go
+	// through GetWithContext (as the Transport does), context.Background()
+	// otherwise. Tiers listed with WithSkipTiers are skipped regardless.
+	SkipTier func(ctx context.Context, tier int) bool
 }
 
 // New creates a MultiCache with the specified cache tiers.
@@ -31,49 +94,101 @@ type MultiCache struct {
 //   - Any tier is nil
 //   - Duplicate tiers are detected
 func New(tiers ...httpcache.Cache) *MultiCache {
-	if len(tiers) == 0 {
+	mc, err := NewWithConfig(Config{Tiers: tiers})
+	if err != nil {
 		return nil
 	}
+	return mc
+}
+
+// NewWithConfig creates a MultiCache from config. It returns an error if no
+// tiers are provided, or any tier is nil or duplicated.
+func NewWithConfig(config Config) (*MultiCache, error) {
+	tiers := config.Tiers
+	if len(tiers) == 0 {
+		return nil, errors.New("at least one cache tier is required")
+	}
 
 	// Validate all tiers are non-nil and unique
 	seen := make(map[httpcache.Cache]bool)
 	for _, tier := range tiers {
 		if tier == nil {
-			return nil
+			return nil, errors.New("cache tier cannot be nil")
 		}
 		if seen[tier] {
-			return nil
+			return nil, errors.New("duplicate cache tier")
 		}
 		seen[tier] = true
 	}
 
-	return &MultiCache{
-		tiers: tiers,
+	mc := &MultiCache{
+		tiers:            tiers,
+		hits:             make([]atomic.Int64, len(tiers)),
+		skipTier:         config.SkipTier,
+		promoteAfterHits: config.PromoteAfterHits,
 	}
+	if config.PromoteAfterHits > 1 {
+		slowHits, err := lru.New[string, int](promoteTrackedKeys)
+		if err != nil {
+			return nil, err
+		}
+		mc.slowHits = slowHits
+	}
+	return mc, nil
 }
 
 // Get returns the cached value for the given key. It searches each tier in order,
 // starting with the fastest. When a value is found in a slower tier, it is
-// automatically promoted (written) to all faster tiers for subsequent quick access.
+// automatically promoted (written) to all faster tiers for subsequent quick access,
+// once it has been found there PromoteAfterHits times.
 //
 // Returns the cached value and true if found in any tier, or nil and false if not found.
 func (c *MultiCache) Get(key string) ([]byte, bool) {
+	return c.GetWithContext(context.Background(), key)
+}
+
+// GetWithContext is like Get, but skips the tiers listed in ctx with
+// WithSkipTiers or rejected by SkipTier. It implements httpcache.ContextCache,
+// so the Transport reads with the request context, and reports the tier of a
+// hit with httpcache.ReportServingTier for Transport.ServeDiagnostics.
+func (c *MultiCache) GetWithContext(ctx context.Context, key string) ([]byte, bool) {
+	skipped, _ := ctx.Value(skipTiersKey{}).([]int)
+
 	// Try each tier in order
 	for i, tier := range c.tiers {
+		if c.shouldSkip(ctx, i, skipped) {
+			continue
+		}
 		value, ok := tier.Get(key)
 		if ok {
+			c.hits[i].Add(1)
+			httpcache.ReportServingTier(ctx, i, tier)
 			// Found in this tier - promote to all faster tiers
-			c.promoteToFasterTiers(key, value, i)
+			if i > 0 && c.admitPromotion(key) {
+				c.promoteToFasterTiers(key, value, i)
+			}
 			return value, true
 		}
 	}
 
+	c.misses.Add(1)
 	return nil, false
 }
 
+// shouldSkip reports whether tier is listed in skipped or rejected by SkipTier
+func (c *MultiCache) shouldSkip(ctx context.Context, tier int, skipped []int) bool {
+	for _, i := range skipped {
+		if i == tier {
+			return true
+		}
+	}
+	return tier > 0 && c.skipTier != nil && c.skipTier(ctx, tier)
+}
+
 // Set stores the value in all cache tiers. This ensures consistency across
 // all levels and allows each tier to apply its own eviction policies independently.
 func (c *MultiCache) Set(key string, value []byte) {
+	c.forgetSlowHits(key)
 	for _, tier := range c.tiers {
 		tier.Set(key, value)
 	}
@@ -81,11 +196,41 @@ func (c *MultiCache) Set(key string, value []byte) {
 
 // Delete removes the value from all cache tiers to maintain consistency.
 func (c *MultiCache) Delete(key string) {
+	c.forgetSlowHits(key)
 	for _, tier := range c.tiers {
 		tier.Delete(key)
 	}
 }
 
+// ClearWithContext implements httpcache.Clearer, clearing every tier. It returns
+// httpcache.ErrClearNotSupported without clearing any tier if one of them
+// doesn't implement httpcache.Clearer, and the errors of the tiers that failed
+// otherwise.
+func (c *MultiCache) ClearWithContext(ctx context.Context) error {
+	clearers := make([]httpcache.Clearer, len(c.tiers))
+	for i, tier := range c.tiers {
+		clearer, ok := tier.(httpcache.Clearer)
+		if !ok {
+			return httpcache.ErrClearNotSupported
+		}
+		clearers[i] = clearer
+	}
+
+	c.slowHitsMu.Lock()
+	if c.slowHits != nil {
+		c.slowHits.Purge()
+	}
+	c.slowHitsMu.Unlock()
+
+	var errs []error
+	for _, clearer := range clearers {
+		if err := clearer.ClearWithContext(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // promoteToFasterTiers writes the value to all tiers faster than the one
 // where it was found. This optimizes future reads by moving hot data to
 // faster tiers.
@@ -94,3 +239,56 @@ func (c *MultiCache) promoteToFasterTiers(key string, value []byte, foundAtTier
 		c.tiers[i].Set(key, value)
 	}
 }
+
+// admitPromotion counts a slower-tier hit for key and reports whether it reached
+// PromoteAfterHits, resetting the count if so
+func (c *MultiCache) admitPromotion(key string) bool {
+	if c.slowHits == nil {
+		return true
+	}
+	c.slowHitsMu.Lock()
+	defer c.slowHitsMu.Unlock()
+	hits, _ := c.slowHits.Get(key)
+	if hits+1 >= c.promoteAfterHits {
+		c.slowHits.Remove(key)
+		return true
+	}
+	c.slowHits.Add(key, hits+1)
+	return false
+}
+
+// forgetSlowHits drops the slower-tier hits counted for key
+func (c *MultiCache) forgetSlowHits(key string) {
+	if c.slowHits != nil {
+		c.slowHits.Remove(key)
+	}
+}
+
+// Tiers returns the cache tiers, ordered from fastest to slowest. The returned
+// slice is a copy; the tiers themselves are shared with the MultiCache.
+func (c *MultiCache) Tiers() []httpcache.Cache {
+	return append([]httpcache.Cache(nil), c.tiers...)
+}
+
+// TierCount returns the number of cache tiers.
+func (c *MultiCache) TierCount() int {
+	return len(c.tiers)
+}
+
+// TierStats returns the statistics of each tier, ordered from fastest to
+// slowest, showing how Get hits are distributed across tiers.
+func (c *MultiCache) TierStats() []TierStats {
+	stats := make([]TierStats, len(c.tiers))
+	for i, tier := range c.tiers {
+		stats[i] = TierStats{Index: i, Hits: c.hits[i].Load()}
+		if backend, ok := tier.(BackendStats); ok {
+			stats[i].Backend = backend.BackendStats()
+		}
+	}
+	return stats
+}
+
+// Misses returns the number of Get calls not found in any tier.
+func (c *MultiCache) Misses() int64 {
+	return c.misses.Load()
+}
@@ -1,7 +1,12 @@
 package multicache
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"testing"
 
 	httpcache "github.com/sandrolain/httpcache"
@@ -383,3 +388,339 @@ func TestConcurrentAccess(t *testing.T) {
 	<-done
 	<-done
 }
+
+// TestOnlyIfCached_EntryInSlowerTier verifies an only-if-cached request is served
+// from a slower tier instead of returning 504 on a fast-tier miss
+func TestOnlyIfCached_EntryInSlowerTier(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte("from tier 2"))
+	}))
+	defer ts.Close()
+
+	tier1 := newMockCache()
+	tier2 := newMockCache()
+
+	// Populate tier 2 only
+	resp, err := httpcache.NewTransport(tier2).Client().Get(ts.URL)
+	require.NoError(t, err)
+	_, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.Equal(t, 1, requests)
+	require.Empty(t, tier1.data)
+
+	client := httpcache.NewTransport(New(tier1, tier2)).Client()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Cache-Control", "only-if-cached")
+
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "from tier 2", string(body))
+	assert.Equal(t, "1", resp.Header.Get(httpcache.XFromCache))
+	assert.Equal(t, 1, requests, "only-if-cached must not reach the origin")
+	assert.NotEmpty(t, tier1.data, "entry should be promoted to tier 1")
+}
+
+// TestOnlyIfCached_MissInAllTiers verifies only-if-cached returns 504 when no tier
+// has the entry, without contacting the origin
+func TestOnlyIfCached_MissInAllTiers(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+
+	client := httpcache.NewTransport(New(newMockCache(), newMockCache())).Client()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Cache-Control", "only-if-cached")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	assert.Equal(t, 0, requests)
+}
+
+// statsCache is a mockCache that exposes its own counters
+type statsCache struct {
+	*mockCache
+}
+
+func (s statsCache) BackendStats() map[string]int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return map[string]int64{"entries": int64(len(s.data))}
+}
+
+func TestTiers(t *testing.T) {
+	tier1, tier2, tier3 := newMockCache(), newMockCache(), newMockCache()
+	mc := New(tier1, tier2, tier3)
+	require.NotNil(t, mc)
+
+	assert.Equal(t, 3, mc.TierCount())
+	tiers := mc.Tiers()
+	require.Len(t, tiers, 3)
+	assert.Same(t, tier1, tiers[0])
+	assert.Same(t, tier2, tiers[1])
+	assert.Same(t, tier3, tiers[2])
+
+	// Modifying the returned slice doesn't affect the MultiCache
+	tiers[0] = nil
+	assert.Same(t, tier1, mc.Tiers()[0])
+}
+
+func TestTierStats(t *testing.T) {
+	tier1, tier2, tier3 := newMockCache(), statsCache{newMockCache()}, newMockCache()
+	mc := New(tier1, tier2, tier3)
+	require.NotNil(t, mc)
+
+	tier3.Set("cold", []byte("value"))
+	tier2.Set("warm", []byte("value"))
+
+	mc.Get("cold") // tier 3 hit, promoted to tiers 1 and 2
+	mc.Get("cold") // tier 1 hit
+	mc.Get("warm") // tier 2 hit
+	mc.Get("missing")
+
+	stats := mc.TierStats()
+	require.Len(t, stats, 3)
+	for i, s := range stats {
+		assert.Equal(t, i, s.Index)
+	}
+	assert.Equal(t, int64(1), stats[0].Hits)
+	assert.Equal(t, int64(1), stats[1].Hits)
+	assert.Equal(t, int64(1), stats[2].Hits)
+	assert.Equal(t, int64(1), mc.Misses())
+
+	assert.Nil(t, stats[0].Backend)
+	assert.Equal(t, map[string]int64{"entries": 2}, stats[1].Backend)
+	assert.Nil(t, stats[2].Backend)
+}
+
+func TestNewWithConfig(t *testing.T) {
+	_, err := NewWithConfig(Config{})
+	assert.Error(t, err)
+
+	tier := newMockCache()
+	_, err = NewWithConfig(Config{Tiers: []httpcache.Cache{tier, tier}})
+	assert.Error(t, err)
+
+	_, err = NewWithConfig(Config{Tiers: []httpcache.Cache{newMockCache(), nil}})
+	assert.Error(t, err)
+}
+
+func TestPromoteAfterHits(t *testing.T) {
+	tier1, tier2 := newMockCache(), newMockCache()
+	mc, err := NewWithConfig(Config{Tiers: []httpcache.Cache{tier1, tier2}, PromoteAfterHits: 3})
+	require.NoError(t, err)
+
+	tier2.Set("key", []byte("value"))
+	for hit := 1; hit <= 2; hit++ {
+		value, ok := mc.Get("key")
+		require.True(t, ok)
+		assert.Equal(t, []byte("value"), value)
+		_, promoted := tier1.Get("key")
+		assert.False(t, promoted, "expected no promotion after %d slow-tier hits", hit)
+	}
+
+	_, ok := mc.Get("key")
+	require.True(t, ok)
+	value, promoted := tier1.Get("key")
+	assert.True(t, promoted, "expected promotion on reaching the threshold")
+	assert.Equal(t, []byte("value"), value)
+
+	// Deleting resets the count
+	mc.Delete("key")
+	tier2.Set("key", []byte("value"))
+	mc.Get("key")
+	_, promoted = tier1.Get("key")
+	assert.False(t, promoted, "expected the count to restart after Delete")
+}
+
+func TestPromoteAfterHitsCountsPerKey(t *testing.T) {
+	tier1, tier2 := newMockCache(), newMockCache()
+	mc, err := NewWithConfig(Config{Tiers: []httpcache.Cache{tier1, tier2}, PromoteAfterHits: 2})
+	require.NoError(t, err)
+
+	tier2.Set("a", []byte("a"))
+	tier2.Set("b", []byte("b"))
+	mc.Get("a")
+	mc.Get("b")
+	mc.Get("a")
+
+	_, promotedA := tier1.Get("a")
+	_, promotedB := tier1.Get("b")
+	assert.True(t, promotedA)
+	assert.False(t, promotedB)
+}
+
+// countingCache counts the Get calls of a tier
+type countingCache struct {
+	*mockCache
+	gets atomic.Int64
+}
+
+func (c *countingCache) Get(key string) ([]byte, bool) {
+	c.gets.Add(1)
+	return c.mockCache.Get(key)
+}
+
+func TestWithSkipTiers(t *testing.T) {
+	cheap, expensive := newMockCache(), &countingCache{mockCache: newMockCache()}
+	mc := New(cheap, expensive)
+
+	mc.Set("key", []byte("value"))
+	_, written := expensive.mockCache.Get("key")
+	assert.True(t, written, "expected Set to write the skipped tier")
+
+	cheap.Delete("key")
+	ctx := WithSkipTiers(context.Background(), 1)
+	_, ok := mc.GetWithContext(ctx, "key")
+	assert.False(t, ok, "expected a miss with the expensive tier skipped")
+	assert.Equal(t, int64(0), expensive.gets.Load())
+
+	value, ok := mc.GetWithContext(context.Background(), "key")
+	require.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+	assert.Equal(t, int64(1), expensive.gets.Load())
+}
+
+func TestSkipTierPolicy(t *testing.T) {
+	cheap, expensive := newMockCache(), &countingCache{mockCache: newMockCache()}
+	var underLoad atomic.Bool
+	mc, err := NewWithConfig(Config{
+		Tiers: []httpcache.Cache{cheap, expensive},
+		SkipTier: func(ctx context.Context, tier int) bool {
+			return tier == 1 && underLoad.Load()
+		},
+	})
+	require.NoError(t, err)
+
+	expensive.Set("key", []byte("value"))
+	underLoad.Store(true)
+	_, ok := mc.Get("key")
+	assert.False(t, ok, "expected the expensive tier to be skipped under load")
+
+	underLoad.Store(false)
+	_, ok = mc.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), expensive.gets.Load())
+}
+
+func TestSkipTiersThroughTransport(t *testing.T) {
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	cheap, expensive := newMockCache(), &countingCache{mockCache: newMockCache()}
+	client := httpcache.NewTransport(New(cheap, expensive)).Client()
+
+	get := func(ctx context.Context) *http.Response {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	get(context.Background())
+	cheap.Delete(ts.URL)
+	expensive.gets.Store(0)
+
+	resp := get(WithSkipTiers(context.Background(), 1))
+	assert.Empty(t, resp.Header.Get(httpcache.XFromCache), "expected a miss with the expensive tier skipped")
+	assert.Equal(t, int64(0), expensive.gets.Load())
+	assert.Equal(t, int64(2), requests.Load())
+}
+
+// namedCache is a mockCache reporting a backend name
+type namedCache struct {
+	*mockCache
+	name string
+}
+
+func (n namedCache) Name() string {
+	return n.name
+}
+
+func TestServeDiagnostics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	memory := namedCache{mockCache: newMockCache(), name: "memory"}
+	redis := namedCache{mockCache: newMockCache(), name: "redis"}
+	tp := httpcache.NewTransport(New(memory, redis))
+	tp.ServeDiagnostics = true
+	client := tp.Client()
+
+	get := func() *http.Response {
+		resp, err := client.Get(ts.URL)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	resp := get()
+	assert.Empty(t, resp.Header.Get(httpcache.XCacheBackend), "a miss must not report a backend")
+	assert.Empty(t, resp.Header.Get(httpcache.XCacheTier))
+
+	memory.Delete(ts.URL)
+	resp = get()
+	assert.Equal(t, "1", resp.Header.Get(httpcache.XFromCache))
+	assert.Equal(t, "redis", resp.Header.Get(httpcache.XCacheBackend))
+	assert.Equal(t, "1", resp.Header.Get(httpcache.XCacheTier))
+	keyHash := resp.Header.Get(httpcache.XCacheKeyHash)
+	assert.Len(t, keyHash, 16)
+
+	// The hit promoted the entry to the first tier
+	resp = get()
+	assert.Equal(t, "memory", resp.Header.Get(httpcache.XCacheBackend))
+	assert.Equal(t, "0", resp.Header.Get(httpcache.XCacheTier))
+	assert.Equal(t, keyHash, resp.Header.Get(httpcache.XCacheKeyHash))
+
+	stored, ok := redis.Get(ts.URL)
+	require.True(t, ok)
+	assert.NotContains(t, string(stored), httpcache.XCacheBackend, "diagnostics must not be stored")
+}
+
+func TestClear(t *testing.T) {
+	tier1, tier2 := httpcache.NewMemoryCache(), httpcache.NewMemoryCache()
+	mc, err := NewWithConfig(Config{Tiers: []httpcache.Cache{tier1, tier2}, PromoteAfterHits: 2})
+	require.NoError(t, err)
+	tier2.Set("key", []byte("value"))
+	_, _ = mc.Get("key")
+
+	require.NoError(t, mc.ClearWithContext(context.Background()))
+	for _, tier := range mc.Tiers() {
+		_, ok := tier.Get("key")
+		assert.False(t, ok, "expected every tier to be cleared")
+	}
+
+	// A tier that can't be cleared leaves every tier untouched
+	unclearable := newMockCache()
+	tier1.Set("key", []byte("value"))
+	mc = New(tier1, unclearable)
+	assert.ErrorIs(t, mc.ClearWithContext(context.Background()), httpcache.ErrClearNotSupported)
+	_, ok := tier1.Get("key")
+	assert.True(t, ok)
+}
@@ -0,0 +1,304 @@
+// Package negotiatedcompress provides a transport wrapper that stores a single
+// decoded entry per URL in the underlying httpcache.Transport and serves gzip,
+// brotli, or identity bodies according to each client's Accept-Encoding.
+// Compressed forms are produced lazily on first use and kept in a separate
+// variants cache, so repeated requests for the same encoding don't re-compress.
+package negotiatedcompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/sandrolain/httpcache"
+)
+
+const (
+	// EncodingGzip is the gzip content-coding
+	EncodingGzip = "gzip"
+	// EncodingBrotli is the brotli content-coding
+	EncodingBrotli = "br"
+	// EncodingIdentity means no content-coding
+	EncodingIdentity = "identity"
+
+	headerAcceptEncoding  = "Accept-Encoding"
+	headerContentEncoding = "Content-Encoding"
+	headerContentLength   = "Content-Length"
+	headerVary            = "Vary"
+
+	variantKeyPrefix = "negotiatedcompress:"
+)
+
+// Config holds the configuration for the negotiated compression transport
+type Config struct {
+	// Transport is the underlying caching transport (required)
+	Transport *httpcache.Transport
+
+	// Variants stores the compressed bodies (required). Entries are keyed by
+	// encoding, URL and validator, so a backend with eviction (e.g. an LRU)
+	// is recommended to drop variants of superseded responses.
+	Variants httpcache.Cache
+
+	// GzipLevel is the gzip compression level
+	// Default: gzip.DefaultCompression
+	GzipLevel int
+
+	// BrotliLevel is the brotli compression level (0 to 11)
+	// Default: 6
+	BrotliLevel int
+}
+
+// Transport wraps an httpcache.Transport and serves the cached decoded body in
+// the encoding negotiated by each client
+type Transport struct {
+	underlying  *httpcache.Transport
+	variants    httpcache.Cache
+	gzipLevel   int
+	brotliLevel int
+}
+
+// New creates a new negotiated compression transport.
+//
+// The Accept-Encoding header is removed from requests passed to the underlying
+// transport, so the origin response is stored once in decoded form regardless
+// of the encodings clients ask for.
+//
+// Example:
+//
+//	cached := httpcache.NewMemoryCacheTransport()
+//	transport, err := negotiatedcompress.New(negotiatedcompress.Config{
+//	    Transport: cached,
+//	    Variants:  httpcache.NewMemoryCache(),
+//	})
+//	client := transport.Client()
+func New(config Config) (*Transport, error) {
+	if config.Transport == nil {
+		return nil, fmt.Errorf("transport cannot be nil")
+	}
+	if config.Variants == nil {
+		return nil, fmt.Errorf("variants cache cannot be nil")
+	}
+
+	if config.GzipLevel == 0 {
+		config.GzipLevel = gzip.DefaultCompression
+	}
+	if config.GzipLevel < gzip.HuffmanOnly || config.GzipLevel > gzip.BestCompression {
+		return nil, fmt.Errorf("invalid gzip compression level: %d", config.GzipLevel)
+	}
+	if config.BrotliLevel == 0 {
+		config.BrotliLevel = 6
+	}
+	if config.BrotliLevel < 0 || config.BrotliLevel > 11 {
+		return nil, fmt.Errorf("invalid brotli compression level: %d", config.BrotliLevel)
+	}
+
+	return &Transport{
+		underlying:  config.Transport,
+		variants:    config.Variants,
+		gzipLevel:   config.GzipLevel,
+		brotliLevel: config.BrotliLevel,
+	}, nil
+}
+
+// RoundTrip fetches the decoded response through the underlying transport and
+// encodes its body as negotiated with the client
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	encoding := Negotiate(req.Header.Get(headerAcceptEncoding))
+
+	upstreamReq := req
+	if req.Header.Get(headerAcceptEncoding) != "" {
+		upstreamReq = req.Clone(req.Context())
+		upstreamReq.Header.Del(headerAcceptEncoding)
+	}
+
+	resp, err := t.underlying.RoundTrip(upstreamReq)
+	if err != nil {
+		return resp, err
+	}
+	resp.Request = req
+
+	if !encodable(req, resp) {
+		return resp, nil
+	}
+	if !variesOn(resp.Header, headerAcceptEncoding) {
+		resp.Header.Add(headerVary, headerAcceptEncoding)
+	}
+	if encoding == EncodingIdentity {
+		return resp, nil
+	}
+
+	// Read the whole body so the underlying transport completes the cache write
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := t.encodedBody(req, resp, encoding, body)
+	if err != nil {
+		// Fall back to the decoded body rather than failing the request
+		httpcache.GetLogger().Warn("failed to encode cached response body", "encoding", encoding, "error", err)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+
+	resp.Header.Set(headerContentEncoding, encoding)
+	resp.Header.Set(headerContentLength, strconv.Itoa(len(encoded)))
+	resp.ContentLength = int64(len(encoded))
+	resp.Body = io.NopCloser(bytes.NewReader(encoded))
+	return resp, nil
+}
+
+// Client returns an HTTP client using the negotiated compression transport
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// encodedBody returns the body in the given encoding, reusing a stored variant
+// when one exists for the same representation
+func (t *Transport) encodedBody(req *http.Request, resp *http.Response, encoding string, body []byte) ([]byte, error) {
+	key := variantKey(req, resp, encoding)
+	if key != "" {
+		if encoded, ok := t.variants.Get(key); ok {
+			return encoded, nil
+		}
+	}
+
+	encoded, err := t.encode(encoding, body)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		t.variants.Set(key, encoded)
+	}
+	return encoded, nil
+}
+
+// encode compresses data with the given content-coding
+func (t *Transport) encode(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch encoding {
+	case EncodingGzip:
+		gw, err := gzip.NewWriterLevel(&buf, t.gzipLevel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip writer: %w", err)
+		}
+		w = gw
+	case EncodingBrotli:
+		w = brotli.NewWriterLevel(&buf, t.brotliLevel)
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return nil, fmt.Errorf("%s write failed: %w", encoding, err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("%s close failed: %w", encoding, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodable reports whether the response body can be re-encoded for the client
+func encodable(req *http.Request, resp *http.Response) bool {
+	if req.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return false
+	}
+	// Leave responses the origin already encoded, or partial content, untouched
+	if ce := resp.Header.Get(headerContentEncoding); ce != "" && ce != EncodingIdentity {
+		return false
+	}
+	return resp.Header.Get("Content-Range") == ""
+}
+
+// variantKey identifies a compressed body by encoding, URL, the request headers
+// the response varies on, and the representation validator. An empty key means
+// the representation can't be identified and the variant must not be stored.
+func variantKey(req *http.Request, resp *http.Response, encoding string) string {
+	validator := resp.Header.Get("ETag")
+	if validator == "" {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	if validator == "" {
+		validator = resp.Header.Get("Date")
+	}
+	if validator == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(variantKeyPrefix)
+	b.WriteString(encoding)
+	b.WriteByte(':')
+	b.WriteString(req.URL.String())
+	for _, name := range varyFields(resp.Header) {
+		if name == headerAcceptEncoding {
+			continue
+		}
+		b.WriteString("|" + name + "=" + req.Header.Get(name))
+	}
+	b.WriteString("|" + validator)
+	return b.String()
+}
+
+// varyFields returns the canonical header names listed in the Vary header
+func varyFields(header http.Header) []string {
+	var names []string
+	for _, field := range header.Values(headerVary) {
+		for _, name := range strings.Split(field, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// variesOn reports whether the Vary header already lists the given header name
+func variesOn(header http.Header, name string) bool {
+	for _, field := range varyFields(header) {
+		if field == name || field == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate selects the preferred supported content-coding from an
+// Accept-Encoding header value (RFC 9110 Section 12.5.3). Brotli is preferred
+// over gzip at equal quality; identity is returned when neither is acceptable.
+func Negotiate(acceptEncoding string) string {
+	best := EncodingIdentity
+	bestQ := 0.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 || (coding != EncodingBrotli && coding != EncodingGzip) {
+			continue
+		}
+		if q > bestQ || (q == bestQ && coding == EncodingBrotli) {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// Verify interface implementation at compile time
+var _ http.RoundTripper = (*Transport)(nil)
@@ -0,0 +1,170 @@
+package negotiatedcompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	httpcache "github.com/sandrolain/httpcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var payload = strings.Repeat("negotiated compression payload ", 64)
+
+// countingCache counts Set calls on the variants cache
+type countingCache struct {
+	httpcache.Cache
+	sets atomic.Int64
+}
+
+func (c *countingCache) Set(key string, value []byte) {
+	c.sets.Add(1)
+	c.Cache.Set(key, value)
+}
+
+func newTestTransport(t *testing.T) (*Transport, *countingCache, *atomic.Int64, *httptest.Server) {
+	t.Helper()
+	var originRequests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originRequests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(payload))
+	}))
+	t.Cleanup(ts.Close)
+
+	variants := &countingCache{Cache: httpcache.NewMemoryCache()}
+	transport, err := New(Config{
+		Transport: httpcache.NewMemoryCacheTransport(),
+		Variants:  variants,
+	})
+	require.NoError(t, err)
+	return transport, variants, &originRequests, ts
+}
+
+func fetch(t *testing.T, client *http.Client, url, acceptEncoding string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var r io.Reader = resp.Body
+	switch resp.Header.Get("Content-Encoding") {
+	case EncodingGzip:
+		gr, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+		r = gr
+	case EncodingBrotli:
+		r = brotli.NewReader(resp.Body)
+	}
+	body, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestNegotiatedEncodingsShareOneEntry(t *testing.T) {
+	transport, variants, originRequests, ts := newTestTransport(t)
+	client := transport.Client()
+
+	for _, tt := range []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", ""},
+		{"gzip", EncodingGzip},
+		{"br, gzip", EncodingBrotli},
+		{"identity", ""},
+		{"gzip;q=1, br;q=0.5", EncodingGzip},
+	} {
+		resp, body := fetch(t, client, ts.URL, tt.acceptEncoding)
+		assert.Equal(t, payload, body, "Accept-Encoding %q", tt.acceptEncoding)
+		assert.Equal(t, tt.want, resp.Header.Get("Content-Encoding"), "Accept-Encoding %q", tt.acceptEncoding)
+		assert.Contains(t, resp.Header.Values("Vary"), "Accept-Encoding")
+	}
+
+	assert.Equal(t, int64(1), originRequests.Load(), "all encodings should be served from one cached entry")
+	assert.Equal(t, int64(2), variants.sets.Load(), "each encoding should be compressed once")
+}
+
+func TestCompressedVariantReused(t *testing.T) {
+	transport, variants, _, ts := newTestTransport(t)
+	client := transport.Client()
+
+	for i := 0; i < 3; i++ {
+		resp, body := fetch(t, client, ts.URL, "br")
+		assert.Equal(t, payload, body)
+		assert.Equal(t, EncodingBrotli, resp.Header.Get("Content-Encoding"))
+		assert.Less(t, resp.ContentLength, int64(len(payload)))
+	}
+	assert.Equal(t, int64(1), variants.sets.Load())
+}
+
+func TestOriginEncodedResponsePassesThrough(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(payload))
+	gw.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(buf.Bytes())
+	}))
+	defer ts.Close()
+
+	variants := &countingCache{Cache: httpcache.NewMemoryCache()}
+	transport, err := New(Config{
+		Transport: &httpcache.Transport{Cache: httpcache.NewMemoryCache(), Transport: &http.Transport{DisableCompression: true}},
+		Variants:  variants,
+	})
+	require.NoError(t, err)
+
+	resp, body := fetch(t, transport.Client(), ts.URL, "br")
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, payload, body)
+	assert.Equal(t, int64(0), variants.sets.Load())
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Variants: httpcache.NewMemoryCache()})
+	assert.Error(t, err)
+
+	_, err = New(Config{Transport: httpcache.NewMemoryCacheTransport()})
+	assert.Error(t, err)
+
+	_, err = New(Config{Transport: httpcache.NewMemoryCacheTransport(), Variants: httpcache.NewMemoryCache(), BrotliLevel: 12})
+	assert.Error(t, err)
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", EncodingIdentity},
+		{"identity", EncodingIdentity},
+		{"gzip", EncodingGzip},
+		{"GZIP", EncodingGzip},
+		{"gzip, br", EncodingBrotli},
+		{"br;q=0.8, gzip", EncodingGzip},
+		{"br;q=0, gzip;q=0", EncodingIdentity},
+		{"deflate, gzip;q=0.5", EncodingGzip},
+		{"br;q=abc, gzip", EncodingGzip},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Negotiate(tt.header), "Accept-Encoding %q", tt.header)
+	}
+}
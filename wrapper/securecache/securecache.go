@@ -3,6 +3,8 @@
 package securecache
 
 import (
+	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -10,6 +12,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"sync/atomic"
 
 	"github.com/sandrolain/httpcache"
 	"golang.org/x/crypto/scrypt"
@@ -28,13 +31,22 @@ const (
 	nonceSize = 12
 )
 
+// plaintextMarker prefixes entries stored without encryption by an encrypting
+// SecureCache, because the Transport tagged them with httpcache.XCachePlaintext.
+// The marker is followed by a nonce and a GCM tag authenticating the readable
+// payload after them, see seal. Encrypted entries start with a random nonce instead.
+var plaintextMarker = []byte("\x00httpcache-plaintext\x00")
+
 // SecureCache wraps an existing cache implementation to add security features:
 // - SHA-256 hashing of all cache keys (always enabled)
 // - Optional AES-256-GCM encryption of cached data (when passphrase is provided)
 type SecureCache struct {
-	cache      httpcache.Cache
-	gcm        cipher.AEAD
-	passphrase string
+	cache           httpcache.Cache
+	gcm             cipher.AEAD
+	passphrase      string
+	refuseSensitive bool
+	verifyKey       bool
+	keyMismatches   atomic.Int64
 }
 
 // Config holds the configuration for creating a SecureCache.
@@ -45,7 +57,22 @@ type Config struct {
 	// Passphrase is the secret used to encrypt/decrypt cached data.
 	// If empty, only key hashing is performed (no encryption).
 	// Must be kept secret and consistent across application restarts.
+	// Entries the Transport exempted from encryption (see
+	// httpcache.Transport.ShouldEncrypt) are stored in plaintext with a marker.
 	Passphrase string
+
+	// RefuseSensitive, if true, doesn't persist entries the Transport tagged as
+	// sensitive (see httpcache.IsSensitiveEntry); any existing entry for the key
+	// is removed instead.
+	RefuseSensitive bool
+
+	// VerifyKey, if true, embeds the SHA-256 hash of the cache key in the
+	// encrypted payload and checks it on every read: an entry decrypted under
+	// another key, e.g. because deployments using different key namespaces
+	// share a passphrase and a backend, is treated as a miss, logged and counted
+	// by KeyMismatches. Requires a Passphrase. Entries stored without VerifyKey
+	// are misses once it is enabled, and vice versa.
+	VerifyKey bool
 }
 
 // New creates a new SecureCache that wraps the provided cache.
@@ -55,10 +82,15 @@ func New(config Config) (*SecureCache, error) {
 	if config.Cache == nil {
 		return nil, fmt.Errorf("cache cannot be nil")
 	}
+	if config.VerifyKey && config.Passphrase == "" {
+		return nil, fmt.Errorf("key verification requires a passphrase")
+	}
 
 	sc := &SecureCache{
-		cache:      config.Cache,
-		passphrase: config.Passphrase,
+		cache:           config.Cache,
+		passphrase:      config.Passphrase,
+		refuseSensitive: config.RefuseSensitive,
+		verifyKey:       config.VerifyKey,
 	}
 
 	// If passphrase is provided, initialize encryption
@@ -146,6 +178,32 @@ func (sc *SecureCache) decrypt(data []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
+// seal returns payload prefixed with a random nonce and the GCM tag of an empty
+// plaintext with payload as additional data, so the payload stays readable but
+// can't be forged or altered without the passphrase.
+func (sc *SecureCache) seal(payload []byte) ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// #nosec G407 -- nonce is randomly generated above using crypto/rand, not hardcoded
+	sealed := sc.gcm.Seal(nonce, nonce, nil, payload)
+	return append(sealed, payload...), nil
+}
+
+// verifySeal checks data produced by seal and returns its payload
+func (sc *SecureCache) verifySeal(data []byte) ([]byte, error) {
+	headerSize := nonceSize + sc.gcm.Overhead()
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("sealed data too short")
+	}
+	payload := data[headerSize:]
+	if _, err := sc.gcm.Open(nil, data[:nonceSize], data[nonceSize:headerSize], payload); err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+	return payload, nil
+}
+
 // Get retrieves a cached response.
 // The key is hashed with SHA-256 before lookup.
 // The data is decrypted if encryption is enabled.
@@ -156,18 +214,32 @@ func (sc *SecureCache) Get(key string) ([]byte, bool) {
 		return nil, false
 	}
 
-	// Decrypt if encryption is enabled
-	if sc.gcm != nil {
-		plaintext, err := sc.decrypt(data)
+	return sc.open(hashedKey, data)
+}
+
+// open decrypts data stored under hashedKey if encryption is enabled, and
+// strips the plaintext marker from entries stored without encryption
+func (sc *SecureCache) open(hashedKey string, data []byte) ([]byte, bool) {
+	if sc.gcm == nil {
+		return data, true
+	}
+
+	if bytes.HasPrefix(data, plaintextMarker) {
+		payload, err := sc.verifySeal(data[len(plaintextMarker):])
 		if err != nil {
-			// Log error but don't expose it to caller
-			httpcache.GetLogger().Warn("failed to decrypt cached data", "key", hashedKey, "error", err)
+			httpcache.GetLogger().Warn("failed to authenticate plaintext cached data", "key", hashedKey, "error", err)
 			return nil, false
 		}
-		return plaintext, true
+		return sc.unbindKey(hashedKey, payload)
 	}
 
-	return data, true
+	plaintext, err := sc.decrypt(data)
+	if err != nil {
+		// Log error but don't expose it to caller
+		httpcache.GetLogger().Warn("failed to decrypt cached data", "key", hashedKey, "error", err)
+		return nil, false
+	}
+	return sc.unbindKey(hashedKey, plaintext)
 }
 
 // Set stores a response in the cache.
@@ -176,10 +248,23 @@ func (sc *SecureCache) Get(key string) ([]byte, bool) {
 func (sc *SecureCache) Set(key string, data []byte) {
 	hashedKey := sc.hashKey(key)
 
-	// Encrypt if encryption is enabled
+	if sc.refuseSensitive && httpcache.IsSensitiveEntry(data) {
+		httpcache.GetLogger().Debug("refusing to store sensitive entry", "key", hashedKey)
+		sc.cache.Delete(hashedKey)
+		return
+	}
+
+	// Encrypt if encryption is enabled, unless the Transport exempted the entry
 	var toStore []byte
-	if sc.gcm != nil {
-		encrypted, err := sc.encrypt(data)
+	if sc.gcm != nil && httpcache.IsPlaintextEntry(data) && !httpcache.IsSensitiveEntry(data) {
+		sealed, err := sc.seal(sc.bindKey(hashedKey, data))
+		if err != nil {
+			httpcache.GetLogger().Warn("failed to authenticate data", "key", hashedKey, "error", err)
+			return
+		}
+		toStore = append(append([]byte{}, plaintextMarker...), sealed...)
+	} else if sc.gcm != nil {
+		encrypted, err := sc.encrypt(sc.bindKey(hashedKey, data))
 		if err != nil {
 			httpcache.GetLogger().Warn("failed to encrypt data", "key", hashedKey, "error", err)
 			return
@@ -199,6 +284,66 @@ func (sc *SecureCache) Delete(key string) {
 	sc.cache.Delete(hashedKey)
 }
 
+// ClearWithContext implements httpcache.Clearer when the underlying cache does, clearing
+// it. Returns httpcache.ErrClearNotSupported if the underlying cache cannot be
+// cleared.
+func (sc *SecureCache) ClearWithContext(ctx context.Context) error {
+	clearer, ok := sc.cache.(httpcache.Clearer)
+	if !ok {
+		return httpcache.ErrClearNotSupported
+	}
+	return clearer.ClearWithContext(ctx)
+}
+
+// Iterate implements httpcache.Iterable when the underlying cache does.
+// Keys are passed as stored (hashed), values are decrypted; entries that fail
+// to decrypt are skipped. Returns httpcache.ErrCacheNotIterable if the
+// underlying cache cannot be iterated.
+func (sc *SecureCache) Iterate(ctx context.Context, fn func(key string, value []byte) bool) error {
+	iterable, ok := sc.cache.(httpcache.Iterable)
+	if !ok {
+		return httpcache.ErrCacheNotIterable
+	}
+
+	return iterable.Iterate(ctx, func(hashedKey string, data []byte) bool {
+		plaintext, ok := sc.open(hashedKey, data)
+		if !ok {
+			return true
+		}
+		return fn(hashedKey, plaintext)
+	})
+}
+
+// bindKey prepends the raw hash of the key to data when VerifyKey is enabled
+func (sc *SecureCache) bindKey(hashedKey string, data []byte) []byte {
+	if !sc.verifyKey {
+		return data
+	}
+	keyHash, _ := hex.DecodeString(hashedKey)
+	return append(keyHash, data...)
+}
+
+// unbindKey checks and strips the key hash prepended by bindKey, reporting a
+// mismatch as a miss
+func (sc *SecureCache) unbindKey(hashedKey string, plaintext []byte) ([]byte, bool) {
+	if !sc.verifyKey {
+		return plaintext, true
+	}
+	keyHash, _ := hex.DecodeString(hashedKey)
+	if !bytes.HasPrefix(plaintext, keyHash) {
+		sc.keyMismatches.Add(1)
+		httpcache.GetLogger().Warn("cached data was stored under another key", "key", hashedKey)
+		return nil, false
+	}
+	return plaintext[len(keyHash):], true
+}
+
+// KeyMismatches returns the number of reads that found data stored under another
+// key, when VerifyKey is enabled.
+func (sc *SecureCache) KeyMismatches() int64 {
+	return sc.keyMismatches.Load()
+}
+
 // IsEncrypted returns true if the cache is configured with encryption.
 func (sc *SecureCache) IsEncrypted() bool {
 	return sc.gcm != nil
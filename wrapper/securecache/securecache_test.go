@@ -2,9 +2,16 @@ package securecache
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
 	"testing"
 
 	"github.com/sandrolain/httpcache"
+	"github.com/sandrolain/httpcache/test"
 )
 
 // mockCache is a simple in-memory cache for testing.
@@ -379,3 +386,313 @@ func TestIntegrationWithMemoryCache(t *testing.T) {
 		t.Error("Get() should return false after Delete()")
 	}
 }
+
+// TestIterate tests that Iterate yields hashed keys and decrypted values.
+func TestIterate(t *testing.T) {
+	sc, err := New(Config{
+		Cache:      httpcache.NewMemoryCache(),
+		Passphrase: "test-passphrase-123",
+	})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	sc.Set("key1", []byte("value1"))
+	sc.Set("key2", []byte("value2"))
+
+	found := map[string]string{}
+	err = sc.Iterate(context.Background(), func(key string, value []byte) bool {
+		found[key] = string(value)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Iterate() failed: %v", err)
+	}
+
+	if found[sc.hashKey("key1")] != "value1" || found[sc.hashKey("key2")] != "value2" {
+		t.Errorf("unexpected iteration result: %v", found)
+	}
+}
+
+// TestIterateNotIterable tests that Iterate fails when the underlying cache cannot be iterated.
+func TestIterateNotIterable(t *testing.T) {
+	sc, err := New(Config{Cache: newMockCache()})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	err = sc.Iterate(context.Background(), func(string, []byte) bool { return true })
+	if !errors.Is(err, httpcache.ErrCacheNotIterable) {
+		t.Errorf("expected ErrCacheNotIterable, got %v", err)
+	}
+}
+
+// TestClear tests that Clear clears the underlying cache, and fails when it cannot be cleared.
+func TestClear(t *testing.T) {
+	sc, err := New(Config{Cache: httpcache.NewMemoryCache()})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	test.Clear(t, sc)
+
+	sc, err = New(Config{Cache: newMockCache()})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := sc.ClearWithContext(context.Background()); !errors.Is(err, httpcache.ErrClearNotSupported) {
+		t.Errorf("expected ErrClearNotSupported, got %v", err)
+	}
+}
+
+func TestRefuseSensitive(t *testing.T) {
+	backend := newMockCache()
+	sc, err := New(Config{Cache: backend, Passphrase: "test-passphrase", RefuseSensitive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc.Set("regular", []byte("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\npublic"))
+	if _, ok := sc.Get("regular"); !ok {
+		t.Fatal("expected untagged entry to be stored")
+	}
+
+	sc.Set("sensitive", []byte("HTTP/1.1 200 OK\r\n\r\nold"))
+	sc.Set("sensitive", []byte("HTTP/1.1 200 OK\r\nX-Cache-Sensitive: 1\r\n\r\nsecret"))
+	if _, ok := backend.data[sc.hashKey("sensitive")]; ok {
+		t.Fatal("expected tagged entry not to be written, and the previous one removed")
+	}
+}
+
+// TestVarySeparationEncrypted tests that, with and without EnableVarySeparation,
+// the base entry and the variant entries are all hashed and encrypted, each
+// variant is served back, and a tampered base entry is a clean miss.
+func TestVarySeparationEncrypted(t *testing.T) {
+	for _, separation := range []bool{false, true} {
+		t.Run(map[bool]string{false: "base only", true: "vary separation"}[separation], func(t *testing.T) {
+			fetches := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetches++
+				w.Header().Set("Cache-Control", "max-age=3600")
+				w.Header().Set("Vary", "Accept-Language")
+				w.Write([]byte("greeting in " + r.Header.Get("Accept-Language")))
+			}))
+			defer ts.Close()
+
+			cache := newMockCache()
+			sc, err := New(Config{Cache: cache, Passphrase: "vary-test-passphrase"})
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			tp := httpcache.NewTransport(sc)
+			tp.EnableVarySeparation = separation
+
+			get := func(lang string) (string, bool) {
+				req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+				req.Header.Set("Accept-Language", lang)
+				resp, err := tp.RoundTrip(req)
+				if err != nil {
+					t.Fatalf("RoundTrip() failed: %v", err)
+				}
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				return string(body), resp.Header.Get(httpcache.XFromCache) == "1"
+			}
+
+			get("en")
+			get("fr")
+			for _, lang := range []string{"en", "fr"} {
+				body, cached := get(lang)
+				if body != "greeting in "+lang {
+					t.Errorf("expected the %s variant, got %q", lang, body)
+				}
+				if separation && !cached {
+					t.Errorf("expected the %s variant to be served from cache", lang)
+				}
+			}
+
+			hashed := regexp.MustCompile(`^[0-9a-f]{64}$`)
+			for key, value := range cache.data {
+				if !hashed.MatchString(key) {
+					t.Errorf("expected a hashed key, got %q", key)
+				}
+				if bytes.Contains(value, []byte("greeting")) || bytes.Contains(value, []byte("Vary")) {
+					t.Errorf("expected an encrypted entry under %s", key)
+				}
+			}
+			if want := map[bool]int{false: 1, true: 3}[separation]; len(cache.data) != want {
+				t.Errorf("expected %d stored entries, got %d", want, len(cache.data))
+			}
+
+			// Tamper with the base entry, which leads to the variants
+			baseKey := sc.hashKey(ts.URL)
+			stored, ok := cache.Get(baseKey)
+			if !ok {
+				t.Fatal("expected an entry under the base key")
+			}
+			stored[len(stored)-1] ^= 0xFF
+			cache.Set(baseKey, stored)
+
+			before := fetches
+			body, cached := get("en")
+			if body != "greeting in en" || cached || fetches != before+1 {
+				t.Fatalf("expected a tampered base entry to be refetched, got %q (cached %v, %d fetches)", body, cached, fetches-before)
+			}
+			if body, cached := get("en"); body != "greeting in en" || !cached {
+				t.Fatalf("expected the refetched entry to be served from cache, got %q (cached %v)", body, cached)
+			}
+		})
+	}
+}
+
+// TestVerifyKey tests that, with VerifyKey, an entry decrypted under another key
+// is a counted miss, and skipped by Iterate, while entries under their own key
+// round-trip.
+func TestVerifyKey(t *testing.T) {
+	backend := httpcache.NewMemoryCache()
+	sc, err := New(Config{Cache: backend, Passphrase: "verify-key-passphrase", VerifyKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc.Set("https://example.com/a", []byte("response a"))
+	sc.Set("https://example.com/b", []byte("response b"))
+	if got, ok := sc.Get("https://example.com/a"); !ok || string(got) != "response a" {
+		t.Fatalf("expected the stored entry, got %q (found %v)", got, ok)
+	}
+
+	// Simulate a key-reuse bug: the entry of a is stored under the key of b
+	stored, _ := backend.Get(sc.hashKey("https://example.com/a"))
+	backend.Set(sc.hashKey("https://example.com/b"), stored)
+	if got, ok := sc.Get("https://example.com/b"); ok {
+		t.Fatalf("expected a miss for data stored under another key, got %q", got)
+	}
+	if n := sc.KeyMismatches(); n != 1 {
+		t.Errorf("expected 1 key mismatch, got %d", n)
+	}
+
+	found := map[string]string{}
+	if err := sc.Iterate(context.Background(), func(key string, value []byte) bool {
+		found[key] = string(value)
+		return true
+	}); err != nil {
+		t.Fatalf("Iterate() failed: %v", err)
+	}
+	if len(found) != 1 || found[sc.hashKey("https://example.com/a")] != "response a" {
+		t.Errorf("expected Iterate to skip the mismatched entry, got %v", found)
+	}
+
+	if _, err := New(Config{Cache: backend, VerifyKey: true}); err == nil {
+		t.Error("expected VerifyKey without a passphrase to be refused")
+	}
+}
+
+// TestShouldEncrypt tests that entries the Transport exempts from encryption
+// are stored in plaintext next to encrypted ones in the same backend, and both
+// are served back, and iterated, correctly.
+func TestShouldEncrypt(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "private, max-age=3600")
+		}
+		w.Write([]byte("content of " + r.URL.Path))
+	}))
+	defer ts.Close()
+
+	backend := httpcache.NewMemoryCache()
+	sc, err := New(Config{Cache: backend, Passphrase: "should-encrypt-passphrase", VerifyKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := httpcache.NewTransport(sc)
+	tp.ShouldEncrypt = func(req *http.Request, _ *http.Response) bool {
+		return req.URL.Path != "/image.png" && req.URL.Path != "/private"
+	}
+
+	get := func(path string) (string, bool) {
+		resp, err := tp.Client().Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.Header.Get(httpcache.XFromCache) == "1"
+	}
+
+	tests := []struct {
+		path      string
+		plaintext bool
+	}{
+		{"/image.png", true},
+		{"/data.json", false},
+		{"/private", false}, // sensitive entries are always encrypted
+	}
+	for _, tt := range tests {
+		get(tt.path)
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			stored, ok := backend.Get(sc.hashKey(ts.URL + tt.path))
+			if !ok {
+				t.Fatal("expected the response to be stored")
+			}
+			if got := bytes.HasPrefix(stored, plaintextMarker); got != tt.plaintext {
+				t.Errorf("expected plaintext %v, got %v", tt.plaintext, got)
+			}
+			if got := bytes.Contains(stored, []byte("content of")); got != tt.plaintext {
+				t.Errorf("expected a readable body %v, got %v", tt.plaintext, got)
+			}
+			body, cached := get(tt.path)
+			if body != "content of "+tt.path || !cached {
+				t.Errorf("expected the cached body, got %q (cached %v)", body, cached)
+			}
+		})
+	}
+
+	count := 0
+	if err := sc.Iterate(context.Background(), func(string, []byte) bool {
+		count++
+		return true
+	}); err != nil {
+		t.Fatalf("Iterate() failed: %v", err)
+	}
+	if count != len(tests) {
+		t.Errorf("expected Iterate to yield %d entries, got %d", len(tests), count)
+	}
+
+	// Without encryption, tagged entries are stored as is
+	plain, _ := New(Config{Cache: newMockCache()})
+	plain.Set("key", []byte("HTTP/1.1 200 OK\r\nX-Cache-Plaintext: 1\r\n\r\nbody"))
+	if got, ok := plain.Get("key"); !ok || bytes.HasPrefix(got, plaintextMarker) {
+		t.Errorf("expected the entry unchanged, got %q (found %v)", got, ok)
+	}
+}
+
+// TestForgedPlaintextEntry tests that plaintext entries are authenticated: a
+// marker entry planted in the backend, or an altered one, is a miss
+func TestForgedPlaintextEntry(t *testing.T) {
+	backend := httpcache.NewMemoryCache()
+	sc, err := New(Config{Cache: backend, Passphrase: "forged-entry-passphrase"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := []byte("HTTP/1.1 200 OK\r\nX-Cache-Plaintext: 1\r\n\r\nbody")
+
+	sc.Set("genuine", entry)
+	if got, ok := sc.Get("genuine"); !ok || !bytes.Equal(got, entry) {
+		t.Fatalf("expected the genuine entry, got %q (found %v)", got, ok)
+	}
+
+	forged := append(append([]byte{}, plaintextMarker...), []byte("HTTP/1.1 200 OK\r\n\r\nforged")...)
+	backend.Set(sc.hashKey("forged"), forged)
+	if got, ok := sc.Get("forged"); ok {
+		t.Errorf("expected a forged entry to be a miss, got %q", got)
+	}
+
+	stored, _ := backend.Get(sc.hashKey("genuine"))
+	altered := bytes.Replace(stored, []byte("body"), []byte("evil"), 1)
+	backend.Set(sc.hashKey("genuine"), altered)
+	if got, ok := sc.Get("genuine"); ok {
+		t.Errorf("expected an altered entry to be a miss, got %q", got)
+	}
+}
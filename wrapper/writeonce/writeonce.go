@@ -0,0 +1,67 @@
+// Package writeonce provides a wrapper for httpcache.Cache implementations that
+// never overwrites an existing entry, for caches of immutable resources (e.g.
+// content-addressed URLs) where rewriting an entry is wasted work and
+// concurrent rewrites could leave a corrupt value behind.
+package writeonce
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sandrolain/httpcache"
+)
+
+// WriteOnceCache wraps a cache so that Set only stores an entry whose key is
+// absent. When the underlying cache implements httpcache.AddCache (MemoryCache,
+// redis, mongodb), the check and the write are a single atomic operation of the
+// backend; otherwise they are serialized within the process only.
+type WriteOnceCache struct {
+	cache httpcache.Cache
+	mu    sync.Mutex
+}
+
+// Config holds the configuration for creating a WriteOnceCache.
+type Config struct {
+	// Cache is the underlying cache implementation to wrap.
+	Cache httpcache.Cache
+}
+
+// New creates a new WriteOnceCache that wraps the provided cache.
+func New(config Config) (*WriteOnceCache, error) {
+	if config.Cache == nil {
+		return nil, fmt.Errorf("cache cannot be nil")
+	}
+	return &WriteOnceCache{cache: config.Cache}, nil
+}
+
+// Get returns the cached value for the given key.
+func (c *WriteOnceCache) Get(key string) ([]byte, bool) {
+	return c.cache.Get(key)
+}
+
+// Set stores the value unless an entry already exists for key, in which case
+// the existing entry is kept.
+func (c *WriteOnceCache) Set(key string, value []byte) {
+	c.Add(key, value)
+}
+
+// Add stores the value unless an entry already exists for key, reporting
+// whether it was stored. It implements httpcache.AddCache.
+func (c *WriteOnceCache) Add(key string, value []byte) bool {
+	if adder, ok := c.cache.(httpcache.AddCache); ok {
+		return adder.Add(key, value)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.cache.Get(key); exists {
+		return false
+	}
+	c.cache.Set(key, value)
+	return true
+}
+
+// Delete removes the entry for key, so the next Set stores a new one.
+func (c *WriteOnceCache) Delete(key string) {
+	c.cache.Delete(key)
+}
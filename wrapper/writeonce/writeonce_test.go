@@ -0,0 +1,97 @@
+package writeonce
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	httpcache "github.com/sandrolain/httpcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapCache is a cache without Add, exercising the in-process fallback
+type mapCache struct {
+	mu    sync.Mutex
+	items map[string][]byte
+	sets  atomic.Int64
+}
+
+func (c *mapCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.items[key]
+	return value, ok
+}
+
+func (c *mapCache) Set(key string, value []byte) {
+	c.sets.Add(1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = value
+}
+
+func (c *mapCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
+
+func TestNewRequiresCache(t *testing.T) {
+	_, err := New(Config{})
+	assert.Error(t, err)
+}
+
+func TestFirstSetWins(t *testing.T) {
+	backends := map[string]httpcache.Cache{
+		"atomic add": httpcache.NewMemoryCache(),
+		"fallback":   &mapCache{items: map[string][]byte{}},
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			cache, err := New(Config{Cache: backend})
+			require.NoError(t, err)
+
+			cache.Set("key", []byte("first"))
+			cache.Set("key", []byte("second"))
+			value, ok := cache.Get("key")
+			require.True(t, ok)
+			assert.Equal(t, "first", string(value))
+
+			assert.False(t, cache.Add("key", []byte("third")))
+			assert.True(t, cache.Add("other", []byte("value")))
+
+			cache.Delete("key")
+			cache.Set("key", []byte("after delete"))
+			value, _ = cache.Get("key")
+			assert.Equal(t, "after delete", string(value))
+		})
+	}
+}
+
+func TestConcurrentSetsWriteOnce(t *testing.T) {
+	backend := &mapCache{items: map[string][]byte{}}
+	cache, err := New(Config{Cache: backend})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var stored atomic.Int64
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cache.Add("key", []byte("value")) {
+				stored.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), stored.Load())
+	assert.Equal(t, int64(1), backend.sets.Load())
+}
+
+func TestImplementsAddCache(t *testing.T) {
+	var _ httpcache.AddCache = httpcache.NewMemoryCache()
+	var _ httpcache.AddCache = (*WriteOnceCache)(nil)
+}